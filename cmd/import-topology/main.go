@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/database"
+	"backend-hotlines3/internal/topology"
+
+	"gorm.io/gorm"
+)
+
+func main() {
+	// Parse command line flags
+	file := flag.String("file", "", "Topology document (.json or .csv) or station/feeder geometry (.geojson) to import")
+	dryRun := flag.Bool("dry-run", false, "Print the changes without writing them")
+	flag.Parse()
+	if *file == "" {
+		log.Fatal("Usage: import-topology -file topology.csv [-dry-run]")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	var doc *topology.Document
+	var geometry *topology.Geometry
+	switch strings.ToLower(filepath.Ext(*file)) {
+	case ".json":
+		doc, err = topology.ParseJSON(f)
+	case ".csv":
+		doc, err = topology.ParseCSV(f)
+	case ".geojson":
+		geometry, err = topology.ParseGeoJSON(f)
+	default:
+		log.Fatalf("Unsupported file type %q, use .json, .csv or .geojson", filepath.Ext(*file))
+	}
+	exitOnProblems(err)
+
+	ctx := context.Background()
+
+	// โหลด configuration
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// เชื่อมต่อ database
+	db, err := database.Connect(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect database: %v", err)
+	}
+
+	// The import and its audit entries commit together
+	var result *topology.Result
+	actor := audit.Actor{Name: "import-topology"}
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if geometry != nil {
+			result, err = topology.ApplyGeometry(ctx, tx, geometry, *dryRun)
+		} else {
+			result, err = topology.Apply(ctx, tx, doc, *dryRun)
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range result.Audit {
+			if err := audit.Record(ctx, tx, actor, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	exitOnProblems(err)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		log.Fatalf("Failed to print result: %v", err)
+	}
+	if *dryRun {
+		log.Println("Dry run, nothing was written")
+	} else {
+		log.Printf("✓ Imported %d changes", len(result.Changes))
+	}
+}
+
+// exitOnProblems prints every problem of a *topology.ProblemsError and exits on any error.
+func exitOnProblems(err error) {
+	if err == nil {
+		return
+	}
+	var problems *topology.ProblemsError
+	if errors.As(err, &problems) {
+		for _, p := range problems.Problems {
+			log.Printf("%s: %s", p.Location, p.Message)
+		}
+	}
+	log.Fatalf("Import failed: %v", err)
+}
@@ -9,6 +9,7 @@ import (
 	"backend-hotlines3/internal/config"
 	"backend-hotlines3/internal/database"
 	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
 
 	"gorm.io/gorm"
 )
@@ -46,6 +47,9 @@ func main() {
 	if err := database.AutoMigrate(ctx, db); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	if err := permission.Seed(ctx, db); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
 
 	log.Println("Migration completed successfully!")
 }
@@ -0,0 +1,225 @@
+// Package apikey issues and verifies long-lived API keys for machine clients.
+// A key looks like "hlk_<prefix>_<secret>". The prefix is stored in clear so a key can be
+// found and shown in listings; the full key is stored only as a SHA-256 hash. Keys carry
+// high entropy, so a fast hash is enough and keeps per-request verification cheap.
+//
+// Scopes limit what a key may call, per top-level /v1 route group:
+//
+//	"*"          everything the key's role allows
+//	"read"       GET/HEAD on every group
+//	"tasks"      everything on /v1/tasks
+//	"tasks:read" GET/HEAD on /v1/tasks
+//
+// Keys never reach the auth, api-keys, roles and permissions groups, whatever their scopes.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// KeyPrefix starts every API key so it can be told apart from a JWT.
+const KeyPrefix = "hlk_"
+
+// touchInterval limits how often lastUsedAt is written for a busy key.
+const touchInterval = time.Minute
+
+var (
+	ErrInvalid = errors.New("invalid api key")
+	ErrExpired = errors.New("api key expired")
+	ErrRevoked = errors.New("api key revoked")
+)
+
+// deniedGroups can never be reached with an API key.
+var deniedGroups = map[string]bool{"auth": true, "api-keys": true, "roles": true, "permissions": true}
+
+var scopePattern = regexp.MustCompile(`^(\*|read|[a-z][a-z-]*(:read)?)$`)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// IsAPIKey reports whether a bearer credential looks like an API key.
+func IsAPIKey(raw string) bool {
+	return strings.HasPrefix(raw, KeyPrefix)
+}
+
+// ParseScopes splits and validates a comma-separated scope list.
+func ParseScopes(s string) ([]string, error) {
+	var scopes []string
+	for _, scope := range strings.Split(s, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !scopePattern.MatchString(scope) {
+			return nil, fmt.Errorf("invalid scope %q", scope)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// Allows reports whether scopes permit method on the route fullPath (as from c.FullPath).
+func Allows(scopes string, method, fullPath string) bool {
+	group := routeGroup(fullPath)
+	if group == "" || deniedGroups[group] {
+		return false
+	}
+	readOnly := method == http.MethodGet || method == http.MethodHead
+
+	for _, scope := range strings.Split(scopes, ",") {
+		switch scope = strings.TrimSpace(scope); scope {
+		case "*", group:
+			return true
+		case "read", group + ":read":
+			if readOnly {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routeGroup returns "tasks" for "/v1/tasks/:id".
+func routeGroup(fullPath string) string {
+	rest := strings.TrimPrefix(fullPath, "/v1/")
+	if rest == fullPath {
+		return ""
+	}
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// generate returns a new raw key and its prefix.
+func generate() (string, string, error) {
+	b := make([]byte, 25)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	s := strings.ToLower(encoding.EncodeToString(b)) // 40 chars
+	prefix := s[:8]
+	return KeyPrefix + prefix + "_" + s[8:], prefix, nil
+}
+
+// parse extracts the prefix from a raw key.
+func parse(raw string) (string, bool) {
+	rest := strings.TrimPrefix(raw, KeyPrefix)
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || rest == raw || len(prefix) != 8 || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+type Store struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// WithTx returns a store that works in tx, so a key change commits or rolls back
+// together with its audit entry.
+func (s *Store) WithTx(tx *gorm.DB) *Store {
+	return &Store{db: tx, now: s.now}
+}
+
+// Create stores key with a freshly generated secret and returns the raw key.
+// The raw key is not recoverable afterwards.
+func (s *Store) Create(ctx context.Context, key *models.APIKey) (string, error) {
+	raw, prefix, err := generate()
+	if err != nil {
+		return "", err
+	}
+	key.Prefix = prefix
+	key.KeyHash = hashKey(raw)
+	key.CreatedAt = s.now()
+	if err := s.db.WithContext(ctx).Create(key).Error; err != nil {
+		return "", fmt.Errorf("failed to store api key: %w", err)
+	}
+	return raw, nil
+}
+
+// Authenticate returns the key for raw if it is valid, and records its use.
+func (s *Store) Authenticate(ctx context.Context, raw, ip string) (*models.APIKey, error) {
+	prefix, ok := parse(raw)
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	var key models.APIKey
+	err := s.db.WithContext(ctx).Where(models.APIKeyCol.Prefix+" = ?", prefix).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load api key: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashKey(raw)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalid
+	}
+
+	now := s.now()
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+		if err := s.db.WithContext(ctx).Model(&key).Updates(map[string]interface{}{
+			"lastUsedAt": now,
+			"lastUsedIp": ip,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("failed to record api key use: %w", err)
+		}
+	}
+	return &key, nil
+}
+
+// List returns all keys, newest first.
+func (s *Store) List(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.WithContext(ctx).Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke disables key id. It reports false if no active key has that id.
+func (s *Store) Revoke(ctx context.Context, id uint) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ?", id).
+		Where(models.APIKeyCol.RevokedAt+" IS NULL").
+		Update("revokedAt", s.now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,178 @@
+// Package audit records who changed what. Every mutating handler writes one AuditLog row
+// per affected entity with JSON snapshots before and after the change and the list of
+// changed fields. Rows are never updated or deleted.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Actions recorded in AuditLog.action.
+const (
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionDelete         = "delete"
+	ActionRestore        = "restore"
+	ActionPurge          = "purge"
+	ActionMerge          = "merge"
+	ActionRevert         = "revert"
+	ActionRevoke         = "revoke"
+	ActionRevokeSessions = "revoke_sessions"
+	ActionPasswordChange = "password_change"
+	ActionUnlock         = "unlock"
+	ActionEnable2FA      = "2fa_enable"
+	ActionDisable2FA     = "2fa_disable"
+	ActionRecoveryCodes  = "recovery_codes_regenerate"
+	ActionSubmit         = "submit"
+	ActionApprove        = "approve"
+	ActionReject         = "reject"
+)
+
+// schemaCache holds parsed model schemas for relationJSONNames.
+var schemaCache sync.Map
+
+// Actor identifies who made a change.
+type Actor struct {
+	UserID    *uint
+	APIKeyID  *uint
+	Name      string
+	IPAddress string
+	RequestID string
+}
+
+// Entry describes one change. Before is nil for creates, After is nil for deletes.
+// Before and After are usually model structs; associations are left out of the snapshot.
+type Entry struct {
+	Action     string
+	EntityType string
+	EntityID   interface{}
+	Before     interface{}
+	After      interface{}
+}
+
+// Change is the old and new value of one field.
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Record appends e to the audit log.
+func Record(ctx context.Context, db *gorm.DB, actor Actor, e Entry) error {
+	before, err := snapshot(db, e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := snapshot(db, e.After)
+	if err != nil {
+		return err
+	}
+
+	row := models.AuditLog{
+		OccurredAt:    time.Now(),
+		ActorUserID:   actor.UserID,
+		ActorAPIKeyID: actor.APIKeyID,
+		ActorName:     actor.Name,
+		Action:        e.Action,
+		EntityType:    e.EntityType,
+		EntityID:      fmt.Sprint(e.EntityID),
+		IPAddress:     actor.IPAddress,
+		RequestID:     actor.RequestID,
+	}
+	if row.Before, err = encode(before); err != nil {
+		return err
+	}
+	if row.After, err = encode(after); err != nil {
+		return err
+	}
+	if before != nil && after != nil {
+		if row.Changes, err = encode(Diff(before, after)); err != nil {
+			return err
+		}
+	}
+
+	if err := db.WithContext(ctx).Create(&row).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Diff returns the fields whose values differ between before and after.
+func Diff(before, after map[string]interface{}) map[string]Change {
+	changes := make(map[string]Change)
+	for k, from := range before {
+		to, ok := after[k]
+		if !ok || !reflect.DeepEqual(from, to) {
+			changes[k] = Change{From: from, To: to}
+		}
+	}
+	for k, to := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = Change{From: nil, To: to}
+		}
+	}
+	return changes
+}
+
+// snapshot converts v to its JSON object form without association fields.
+func snapshot(db *gorm.DB, v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+
+	for _, name := range relationJSONNames(db, v) {
+		delete(m, name)
+	}
+	return m, nil
+}
+
+// relationJSONNames returns the JSON keys of v's GORM associations, if v is a model.
+func relationJSONNames(db *gorm.DB, v interface{}) []string {
+	s, err := schema.Parse(v, &schemaCache, db.NamingStrategy)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, rel := range s.Relationships.Relations {
+		name := rel.Field.Name
+		if tag := rel.Field.Tag.Get("json"); tag != "" {
+			name = strings.Split(tag, ",")[0]
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func encode(v interface{}) (*string, error) {
+	if reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit log: %w", err)
+	}
+	s := string(data)
+	return &s, nil
+}
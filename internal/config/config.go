@@ -3,6 +3,9 @@ package config
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,6 +16,9 @@ type Config struct {
 	Cloudflare CloudflareConfig `mapstructure:"cloudflare"`
 	JWT        JWTConfig        `mapstructure:"jwt"`
 	CORS       CORSConfig       `mapstructure:"cors"`
+	Security   SecurityConfig   `mapstructure:"security"`
+	Trash      TrashConfig      `mapstructure:"trash"`
+	Export     ExportConfig     `mapstructure:"export"`
 }
 
 type ServerConfig struct {
@@ -47,6 +53,80 @@ type JWTConfig struct {
 	Secret             string `mapstructure:"secret"`
 	AccessTokenExpiry  string `mapstructure:"access_token_expiry"`
 	RefreshTokenExpiry string `mapstructure:"refresh_token_expiry"`
+	// Keys switches signing to RS256/EdDSA. The newest key whose active_from has passed
+	// signs; older keys (and the secret above, if set) keep verifying until their tokens expire.
+	Keys []JWTKeyConfig `mapstructure:"keys"`
+}
+
+// JWTKeyConfig is one asymmetric signing key. The algorithm follows from the key type.
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"kid"`
+	PrivateKeyFile string `mapstructure:"private_key_file"` // PEM file (PKCS#1 RSA or PKCS#8 RSA/Ed25519)
+	PrivateKey     string `mapstructure:"private_key"`      // inline PEM, used when private_key_file is empty
+	ActiveFrom     string `mapstructure:"active_from"`      // RFC3339; required
+}
+
+type SecurityConfig struct {
+	Login     LoginConfig     `mapstructure:"login"`
+	TwoFactor TwoFactorConfig `mapstructure:"two_factor"`
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication.
+type TwoFactorConfig struct {
+	Issuer          string   `mapstructure:"issuer"`           // shown in authenticator apps
+	RequiredRoles   []string `mapstructure:"required_roles"`   // roles that must enrol before they can log in
+	ChallengeExpiry string   `mapstructure:"challenge_expiry"` // lifetime of the login challenge token, e.g. "5m"
+}
+
+// LoginConfig controls login throttling. Durations use Go syntax (e.g. "15m").
+// Empty values fall back to lockout.DefaultConfig.
+type LoginConfig struct {
+	Store           string `mapstructure:"store"` // memory (single instance) or database (shared by replicas)
+	MaxUserFailures int    `mapstructure:"max_user_failures"`
+	MaxIPFailures   int    `mapstructure:"max_ip_failures"`
+	BaseDelay       string `mapstructure:"base_delay"`
+	MaxDelay        string `mapstructure:"max_delay"`
+	LockoutDuration string `mapstructure:"lockout_duration"`
+	Window          string `mapstructure:"window"`
+}
+
+// TrashConfig controls the trash bin of soft-deleted records.
+type TrashConfig struct {
+	Retention string `mapstructure:"retention"` // how long deleted items stay restorable before purge removes them, e.g. "720h" or "30d"
+}
+
+// DefaultTrashRetention applies when trash.retention is unset.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// RetentionDuration parses Retention, a Go duration such as "720h" or a whole number of
+// days such as "30d". It returns DefaultTrashRetention when Retention is empty.
+func (t TrashConfig) RetentionDuration() (time.Duration, error) {
+	if t.Retention == "" {
+		return DefaultTrashRetention, nil
+	}
+
+	var d time.Duration
+	if days, ok := strings.CutSuffix(t.Retention, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("trash.retention %q: want a duration such as 720h or a number of days such as 30d", t.Retention)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(t.Retention); err != nil {
+			return 0, fmt.Errorf("trash.retention %q: want a duration such as 720h or a number of days such as 30d", t.Retention)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("trash.retention %q must be positive", t.Retention)
+	}
+	return d, nil
+}
+
+// ExportConfig controls task exports.
+type ExportConfig struct {
+	PDFFont string `mapstructure:"pdf_font"` // TrueType font with Thai glyphs used for PDF reports
 }
 
 type CORSConfig struct {
@@ -75,5 +155,10 @@ func LoadConfig(ctx context.Context) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// A mistyped retention would otherwise purge on a schedule nobody configured
+	if _, err := config.Trash.RetentionDuration(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
@@ -24,6 +24,20 @@ func (s CamelCaseNamingStrategy) ColumnName(table, column string) string {
 	return column
 }
 
+// GormConfig is the gorm configuration shared by every connection: camelCase column
+// names from the struct tags and singular table names.
+func GormConfig(logLevel logger.LogLevel) *gorm.Config {
+	return &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+		NamingStrategy: CamelCaseNamingStrategy{
+			schema.NamingStrategy{
+				SingularTable: true,
+				NoLowerCase:   true,
+			},
+		},
+	}
+}
+
 // Connect establishes a connection to the PostgreSQL database using the provided configuration.
 // It configures connection pooling, logging level, and custom naming strategy for camelCase columns.
 // Returns a GORM database instance or an error if connection fails.
@@ -45,15 +59,7 @@ func Connect(ctx context.Context, cfg *config.Config) (*gorm.DB, error) {
 		logLevel = logger.Info
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
-		NamingStrategy: CamelCaseNamingStrategy{
-			schema.NamingStrategy{
-				SingularTable: true,
-				NoLowerCase:   true,
-			},
-		},
-	})
+	db, err := gorm.Open(postgres.Open(dsn), GormConfig(logLevel))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -72,11 +78,9 @@ func Connect(ctx context.Context, cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
-// AutoMigrate runs automatic migration for all models in the database.
-// It creates or updates tables to match the model definitions without data loss.
-// Returns an error if migration fails for any model.
-func AutoMigrate(ctx context.Context, db *gorm.DB) error {
-	if err := db.WithContext(ctx).AutoMigrate(
+// Models lists every model migrated by AutoMigrate, in dependency order.
+func Models() []interface{} {
+	return []interface{}{
 		&models.OperationCenter{},
 		&models.PEA{},
 		&models.Station{},
@@ -86,8 +90,67 @@ func AutoMigrate(ctx context.Context, db *gorm.DB) error {
 		&models.Team{},
 		&models.TaskDaily{},
 		&models.User{},
-	); err != nil {
+		&models.RecoveryCode{},
+		&models.RefreshToken{},
+		&models.LoginThrottle{},
+		&models.LoginFailure{},
+		&models.APIKey{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.AuditLog{},
+		&models.TaskRevision{},
+	}
+}
+
+// MigrateModels creates or updates the tables of Models without data loss. Tasks
+// reported before TaskDaily.Status existed are marked approved when the column is
+// added; new tasks default to draft.
+func MigrateModels(db *gorm.DB) error {
+	backfillStatus := db.Migrator().HasTable(&models.TaskDaily{}) &&
+		!db.Migrator().HasColumn(&models.TaskDaily{}, "status")
+
+	if err := db.AutoMigrate(Models()...); err != nil {
 		return fmt.Errorf("failed to auto-migrate database: %w", err)
 	}
+
+	if backfillStatus {
+		if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().
+			Model(&models.TaskDaily{}).
+			UpdateColumn("status", models.TaskStatusApproved).Error; err != nil {
+			return fmt.Errorf("failed to backfill task status: %w", err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrate runs MigrateModels and protects the audit log.
+// Returns an error if migration fails for any model.
+func AutoMigrate(ctx context.Context, db *gorm.DB) error {
+	if err := MigrateModels(db.WithContext(ctx)); err != nil {
+		return err
+	}
+
+	// AuditLog is append-only: UPDATE, DELETE and TRUNCATE on it raise an error, so an
+	// attempt to rewrite history fails visibly instead of appearing to succeed.
+	for _, stmt := range []string{
+		`DROP RULE IF EXISTS "AuditLog_no_update" ON "AuditLog"`,
+		`DROP RULE IF EXISTS "AuditLog_no_delete" ON "AuditLog"`,
+		`CREATE OR REPLACE FUNCTION "AuditLog_append_only"() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'AuditLog is append-only: % is not allowed', TG_OP
+		USING ERRCODE = 'insufficient_privilege';
+END
+$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS "AuditLog_append_only" ON "AuditLog"`,
+		`CREATE TRIGGER "AuditLog_append_only" BEFORE UPDATE OR DELETE ON "AuditLog"
+	FOR EACH ROW EXECUTE FUNCTION "AuditLog_append_only"()`,
+		`DROP TRIGGER IF EXISTS "AuditLog_no_truncate" ON "AuditLog"`,
+		`CREATE TRIGGER "AuditLog_no_truncate" BEFORE TRUNCATE ON "AuditLog"
+	FOR EACH STATEMENT EXECUTE FUNCTION "AuditLog_append_only"()`,
+	} {
+		if err := db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to protect audit log: %w", err)
+		}
+	}
 	return nil
 }
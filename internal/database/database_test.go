@@ -0,0 +1,49 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/database"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+)
+
+// TestMigrateModelsBackfillsTaskStatus migrates a database from before the workflow:
+// its tasks become approved once, while tasks created afterwards start as drafts.
+func TestMigrateModelsBackfillsTaskStatus(t *testing.T) {
+	db := testutil.NewDB(t)
+	if err := db.Migrator().DropColumn(&models.TaskDaily{}, "status"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO "TaskDaily" (workdate, "jobTypeId", "jobDetailId", "teamId", createdat, updatedat, version)
+		VALUES (?, 1, 1, 1, ?, ?, 1)`, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Now(), time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.MigrateModels(db); err != nil {
+		t.Fatal(err)
+	}
+	fresh := models.TaskDaily{WorkDate: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), JobTypeID: 1, JobDetailID: 1, TeamID: 1}
+	if err := db.Create(&fresh).Error; err != nil {
+		t.Fatal(err)
+	}
+	// A second run leaves the new task alone
+	if err := database.MigrateModels(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []models.TaskDaily
+	if err := db.Order("id").Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("%d tasks, want 2", len(tasks))
+	}
+	if tasks[0].Status != models.TaskStatusApproved {
+		t.Errorf("existing task is %q, want approved", tasks[0].Status)
+	}
+	if tasks[1].Status != models.TaskStatusDraft {
+		t.Errorf("new task is %q, want draft", tasks[1].Status)
+	}
+}
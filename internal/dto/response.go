@@ -1,5 +1,7 @@
 package dto
 
+import "encoding/json"
+
 // StandardResponse - Standard API response format
 type StandardResponse struct {
 	Success bool        `json:"success"`
@@ -30,17 +32,19 @@ type Count struct {
 // === Team DTOs ===
 
 type TeamResponse struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name"`
-	Count *Count `json:"_count,omitempty"`
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	DeletedAt *string `json:"deletedAt,omitempty"`
+	Count     *Count  `json:"_count,omitempty"`
 }
 
 // === JobType DTOs ===
 
 type JobTypeResponse struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name"`
-	Count *Count `json:"_count,omitempty"`
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	DeletedAt *string `json:"deletedAt,omitempty"`
+	Count     *Count  `json:"_count,omitempty"`
 }
 
 // === JobDetail DTOs ===
@@ -52,15 +56,20 @@ type JobDetailResponse struct {
 	CreatedAt string  `json:"createdAt"`
 	UpdatedAt string  `json:"updatedAt"`
 	DeletedAt *string `json:"deletedAt"`
+	Version   int     `json:"version"`
 	Count     *Count  `json:"_count,omitempty"`
 }
 
 // === Feeder DTOs ===
 
 type FeederResponse struct {
-	ID        int64          `json:"id"`
-	Code      string         `json:"code"`
-	StationID int64          `json:"stationId"`
+	ID        int64  `json:"id"`
+	Code      string `json:"code"`
+	StationID int64  `json:"stationId"`
+	Version   int    `json:"version"`
+	// Route is the feeder's line as [lng, lat] pairs, as in a GeoJSON LineString
+	Route     [][2]float64   `json:"route,omitempty"`
+	DeletedAt *string        `json:"deletedAt,omitempty"`
 	Station   *StationNested `json:"station,omitempty"`
 	Count     *Count         `json:"_count,omitempty"`
 }
@@ -69,6 +78,8 @@ type StationNested struct {
 	ID              int64                  `json:"id"`
 	Name            string                 `json:"name"`
 	CodeName        string                 `json:"codeName"`
+	Latitude        *float64               `json:"latitude,omitempty"`
+	Longitude       *float64               `json:"longitude,omitempty"`
 	OperationCenter *OperationCenterNested `json:"operationCenter,omitempty"`
 }
 
@@ -84,6 +95,10 @@ type StationResponse struct {
 	Name            string                 `json:"name"`
 	CodeName        string                 `json:"codeName"`
 	OperationID     int64                  `json:"operationId"`
+	Latitude        *float64               `json:"latitude"`
+	Longitude       *float64               `json:"longitude"`
+	Version         int                    `json:"version"`
+	DeletedAt       *string                `json:"deletedAt,omitempty"`
 	OperationCenter *OperationCenterNested `json:"operationCenter,omitempty"`
 }
 
@@ -94,14 +109,16 @@ type PEAResponse struct {
 	Shortname       string                 `json:"shortname"`
 	Fullname        string                 `json:"fullname"`
 	OperationID     int64                  `json:"operationId"`
+	DeletedAt       *string                `json:"deletedAt,omitempty"`
 	OperationCenter *OperationCenterNested `json:"operationCenter,omitempty"`
 }
 
 // === OperationCenter DTOs ===
 
 type OperationCenterResponse struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	DeletedAt *string `json:"deletedAt,omitempty"`
 }
 
 // === Task DTOs ===
@@ -119,6 +136,8 @@ type CreateTaskRequest struct {
 	URLsAfter   []string `json:"urlsAfter"`
 	Latitude    *float64 `json:"latitude"`
 	Longitude   *float64 `json:"longitude"`
+	// Submit sends the new task for review straight away instead of keeping it as a draft
+	Submit bool `json:"submit"`
 }
 
 type UpdateTaskRequest struct {
@@ -136,6 +155,47 @@ type UpdateTaskRequest struct {
 	Longitude   *float64 `json:"longitude"`
 }
 
+// RevertTaskRequest - POST /v1/tasks/:id/revert
+type RevertTaskRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// RejectTaskRequest - POST /v1/tasks/:id/reject
+type RejectTaskRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// TaskImportResponse - result of POST /v1/tasks/import
+type TaskImportResponse struct {
+	Format      string            `json:"format"`
+	Mode        string            `json:"mode"`
+	DryRun      bool              `json:"dryRun"`
+	TotalRows   int               `json:"totalRows"`
+	ValidRows   int               `json:"validRows"`
+	InvalidRows int               `json:"invalidRows"`
+	Inserted    int               `json:"inserted"`
+	TaskIDs     []int64           `json:"taskIds"`
+	Errors      []TaskImportError `json:"errors"`
+	// Warnings flag valid rows that were imported anyway but may need a second look
+	Warnings []TaskImportWarning `json:"warnings"`
+}
+
+// TaskImportError is one problem found in a row of an import file. Row is the line or
+// sheet row number as shown by a spreadsheet, header included.
+type TaskImportError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// TaskImportWarning is a FeederWarning for one row of an import file, as returned by
+// create for a single task.
+type TaskImportWarning struct {
+	Row int `json:"row"`
+	FeederWarning
+}
+
 type TaskResponse struct {
 	ID          int64                `json:"id"`
 	WorkDate    string               `json:"workDate"`
@@ -157,6 +217,59 @@ type TaskResponse struct {
 	CreatedAt   string               `json:"createdAt"`
 	UpdatedAt   string               `json:"updatedAt"`
 	DeletedAt   *string              `json:"deletedAt"`
+	Version     int                  `json:"version"`
+	// Workflow state; submitted* describe the last submission, reviewedBy* and
+	// reviewedAt the last approval or rejection
+	Status          string  `json:"status"`
+	SubmittedAt     *string `json:"submittedAt"`
+	SubmittedByID   *uint   `json:"submittedById"`
+	ReviewedByID    *uint   `json:"reviewedById"`
+	ReviewedByName  *string `json:"reviewedByName"`
+	ReviewedAt      *string `json:"reviewedAt"`
+	RejectionReason *string `json:"rejectionReason"`
+	// DistanceKm is set when the list is searched with ?near=lat,lng
+	DistanceKm *float64 `json:"distanceKm,omitempty"`
+	// FeederWarning is set on create when the feeder is far from where its tasks usually are
+	FeederWarning *FeederWarning `json:"feederWarning,omitempty"`
+	// Revision is set when the task is shown as of a past point in time (?asOf=)
+	Revision *int `json:"revision,omitempty"`
+}
+
+// FeederWarning flags a task whose coordinates are implausibly far from the area of
+// its feeder's earlier tasks. The task is saved regardless.
+type FeederWarning struct {
+	Code        string             `json:"code"`
+	Message     string             `json:"message"`
+	DistanceKm  float64            `json:"distanceKm"`
+	Suggestions []FeederSuggestion `json:"suggestions"`
+}
+
+// FeederSuggestion - GET /v1/feeders/suggest
+// DistanceKm is the distance to the area where most of the feeder's tasks lie (zero
+// inside it) and CentroidDistanceKm the distance to their median position. A feeder
+// with too few located tasks is placed at its station instead, with Source "station".
+type FeederSuggestion struct {
+	FeederID           int64   `json:"feederId"`
+	Code               string  `json:"code"`
+	StationID          int64   `json:"stationId"`
+	StationName        string  `json:"stationName"`
+	DistanceKm         float64 `json:"distanceKm"`
+	CentroidDistanceKm float64 `json:"centroidDistanceKm"`
+	Latitude           float64 `json:"latitude"`
+	Longitude          float64 `json:"longitude"`
+	Tasks              int64   `json:"tasks"`
+	Source             string  `json:"source"` // tasks | station
+}
+
+// TaskRevisionResponse is one entry of GET /v1/tasks/:id/history.
+type TaskRevisionResponse struct {
+	Version       int      `json:"version"`
+	Action        string   `json:"action"`
+	AuthorID      *uint    `json:"authorId"`
+	AuthorName    string   `json:"authorName"`
+	ChangedFields []string `json:"changedFields"`
+	RevertedFrom  *int     `json:"revertedFrom,omitempty"`
+	CreatedAt     string   `json:"createdAt"`
 }
 
 type TeamNested struct {
@@ -213,6 +326,8 @@ type DashboardSummaryResponse struct {
 	TotalJobTypes int64    `json:"totalJobTypes"`
 	TotalFeeders  int64    `json:"totalFeeders"`
 	TopTeam       *TopTeam `json:"topTeam"`
+	// ByStatus counts the filtered tasks in each workflow status, regardless of approvedOnly
+	ByStatus map[string]int64 `json:"byStatus"`
 }
 
 type TopTeam struct {
@@ -260,6 +375,8 @@ type DashboardStatsSummary struct {
 	ActiveTeams int64  `json:"activeTeams"`
 	TopJobType  string `json:"topJobType"`
 	TopFeeder   string `json:"topFeeder"`
+	// ByStatus counts the filtered tasks in each workflow status, regardless of approvedOnly
+	ByStatus map[string]int64 `json:"byStatus"`
 }
 
 type DashboardCharts struct {
@@ -269,6 +386,51 @@ type DashboardCharts struct {
 	TasksByDate    []DateChartItem `json:"tasksByDate"`
 }
 
+// DashboardHeatmapResponse - GET /v1/dashboard/heatmap
+// Cells are squares of CellSizeDeg degrees; only cells with tasks are listed.
+type DashboardHeatmapResponse struct {
+	Zoom        int           `json:"zoom"`
+	CellSizeDeg float64       `json:"cellSizeDeg"`
+	Total       int64         `json:"total"`
+	Truncated   bool          `json:"truncated"`
+	Cells       []HeatmapCell `json:"cells"`
+}
+
+// HeatmapCell is one grid cell. Lat/Lng is the mean position of its tasks; Bounds is
+// the cell as minLng,minLat,maxLng,maxLat.
+type HeatmapCell struct {
+	Lat       float64               `json:"lat"`
+	Lng       float64               `json:"lng"`
+	Bounds    [4]float64            `json:"bounds"`
+	Count     int64                 `json:"count"`
+	ByJobType []HeatmapJobTypeCount `json:"byJobType"`
+}
+
+type HeatmapJobTypeCount struct {
+	JobTypeID int64  `json:"jobTypeId"`
+	Name      string `json:"name"`
+	Count     int64  `json:"count"`
+}
+
+// DashboardClustersResponse - GET /v1/dashboard/clusters
+type DashboardClustersResponse struct {
+	Zoom      int          `json:"zoom"`
+	Total     int64        `json:"total"`
+	Truncated bool         `json:"truncated"`
+	Clusters  []MapCluster `json:"clusters"`
+}
+
+// MapCluster is a group of nearby tasks drawn as one marker at Lat/Lng, the mean
+// position of its tasks. Bounds (minLng,minLat,maxLng,maxLat) spans the tasks, for
+// zooming in on a click. TaskID is set when the cluster is a single task.
+type MapCluster struct {
+	Lat    float64    `json:"lat"`
+	Lng    float64    `json:"lng"`
+	Count  int64      `json:"count"`
+	Bounds [4]float64 `json:"bounds"`
+	TaskID *int64     `json:"taskId,omitempty"`
+}
+
 type ChartItem struct {
 	Name  string `json:"name"`
 	Value int64  `json:"value"`
@@ -299,6 +461,46 @@ type LoginResponse struct {
 	User         UserResponse `json:"user"`
 }
 
+// TwoFactorChallengeResponse is returned by login instead of LoginResponse when a
+// second factor is still needed. SetupRequired means the user must enrol first.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	SetupRequired     bool   `json:"setupRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+	ExpiresAt         string `json:"expiresAt"`
+}
+
+type TwoFactorSetupRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+}
+
+// TwoFactorVerifyRequest completes login with either a TOTP code or a recovery code.
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// TwoFactorLoginResponse is LoginResponse plus the recovery codes generated when
+// enrolment is completed as part of login.
+type TwoFactorLoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
 type RefreshRequest struct {
 	RefreshToken string `json:"refreshToken" binding:"required"`
 }
@@ -308,27 +510,145 @@ type RefreshResponse struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
+type SessionResponse struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"userAgent"`
+	IPAddress  string `json:"ipAddress"`
+	LastSeenAt string `json:"lastSeenAt"`
+	ExpiresAt  string `json:"expiresAt"`
+	Current    bool   `json:"current"`
+}
+
 type UserResponse struct {
-	ID        uint    `json:"id"`
-	Username  string  `json:"username"`
-	Role      string  `json:"role"`
-	TeamID    *int64  `json:"teamId,omitempty"`
-	IsActive  bool    `json:"isActive"`
-	LastLogin *string `json:"lastLogin,omitempty"`
-	CreatedAt string  `json:"createdAt"`
+	ID               uint    `json:"id"`
+	Username         string  `json:"username"`
+	Role             string  `json:"role"`
+	TeamID           *int64  `json:"teamId,omitempty"`
+	IsActive         bool    `json:"isActive"`
+	TwoFactorEnabled bool    `json:"twoFactorEnabled"`
+	LastLogin        *string `json:"lastLogin,omitempty"`
+	CreatedAt        string  `json:"createdAt"`
+	DeletedAt        *string `json:"deletedAt,omitempty"`
+}
+
+type LoginFailureResponse struct {
+	ID        int64  `json:"id"`
+	IPAddress string `json:"ipAddress"`
+	UserAgent string `json:"userAgent"`
+	Reason    string `json:"reason"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type LoginFailuresResponse struct {
+	Failures       int                    `json:"failures"`
+	Locked         bool                   `json:"locked"`
+	RetryAfterSecs int64                  `json:"retryAfterSeconds"`
+	History        []LoginFailureResponse `json:"history"`
+}
+
+// CreateAPIKeyRequest - POST /v1/api-keys
+// Scopes: "*", "read", "<group>" or "<group>:read" where group is the path segment after /v1/.
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name" binding:"required,max=100"`
+	Role      string   `json:"role" binding:"required,max=50"`
+	Scopes    []string `json:"scopes" binding:"required,min=1"`
+	TeamID    *int64   `json:"teamId"`
+	ExpiresAt *string  `json:"expiresAt"` // RFC3339, optional
+}
+
+type APIKeyResponse struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Prefix      string   `json:"prefix"`
+	Role        string   `json:"role"`
+	Scopes      []string `json:"scopes"`
+	TeamID      *int64   `json:"teamId,omitempty"`
+	CreatedByID *uint    `json:"createdById,omitempty"`
+	ExpiresAt   *string  `json:"expiresAt,omitempty"`
+	LastUsedAt  *string  `json:"lastUsedAt,omitempty"`
+	LastUsedIP  string   `json:"lastUsedIp,omitempty"`
+	RevokedAt   *string  `json:"revokedAt,omitempty"`
+	CreatedAt   string   `json:"createdAt"`
+}
+
+// CreateAPIKeyResponse carries the raw key, which is shown only once.
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// AuditLogResponse - GET /v1/audit
+type AuditLogResponse struct {
+	ID            int64           `json:"id"`
+	OccurredAt    string          `json:"occurredAt"`
+	ActorUserID   *uint           `json:"actorUserId,omitempty"`
+	ActorAPIKeyID *uint           `json:"actorApiKeyId,omitempty"`
+	ActorName     string          `json:"actorName"`
+	Action        string          `json:"action"`
+	EntityType    string          `json:"entityType"`
+	EntityID      string          `json:"entityId"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	Changes       json.RawMessage `json:"changes,omitempty"`
+	IPAddress     string          `json:"ipAddress"`
+	RequestID     string          `json:"requestId"`
+}
+
+// TrashItemResponse - GET /v1/trash
+type TrashItemResponse struct {
+	Type          string `json:"type"`
+	ID            int64  `json:"id"`
+	Label         string `json:"label"`
+	DeletedAt     string `json:"deletedAt"`
+	DeletedByID   *uint  `json:"deletedById,omitempty"`
+	DeletedByName string `json:"deletedByName,omitempty"`
+	PurgeAfter    string `json:"purgeAfter,omitempty"` // empty for users, which are never purged
+}
+
+// PurgeTrashResponse - POST /v1/trash/purge
+// Skipped counts items that could not be removed, usually because live rows still reference them.
+type PurgeTrashResponse struct {
+	Cutoff        string         `json:"cutoff"`
+	Purged        map[string]int `json:"purged"`
+	Skipped       map[string]int `json:"skipped"`
+	PhotosRemoved int            `json:"photosRemoved"`
+	PhotosFailed  int            `json:"photosFailed"`
+}
+
+// CreateRoleRequest - POST /v1/roles
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=2,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest - PUT /v1/roles/:id (Permissions replaces the whole list)
+type UpdateRoleRequest struct {
+	Description *string   `json:"description" binding:"omitempty,max=255"`
+	Permissions *[]string `json:"permissions"`
+}
+
+type RoleResponse struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	IsSystem    bool     `json:"isSystem"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
 }
 
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required,len=6,numeric"`
 	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required,oneof=admin user viewer"`
+	Role     string `json:"role" binding:"required,max=50"`
 	TeamID   *int64 `json:"teamId"`
 	IsActive *bool  `json:"isActive"`
 }
 
 type UpdateUserRequest struct {
 	Username *string `json:"username" binding:"omitempty,len=6,numeric"`
-	Role     *string `json:"role" binding:"omitempty,oneof=admin user viewer"`
+	Role     *string `json:"role" binding:"omitempty,max=50"`
 	TeamID   *int64  `json:"teamId"`
 	IsActive *bool   `json:"isActive"`
 }
@@ -337,3 +657,42 @@ type ChangePasswordRequest struct {
 	OldPassword string `json:"oldPassword" binding:"required"`
 	NewPassword string `json:"newPassword" binding:"required,min=6"`
 }
+
+// === Delete impact DTOs ===
+
+// DeleteImpactResponse reports the rows that reference a deleted (or, with dryRun, a
+// would-be deleted) reference row and what the chosen strategy does to them.
+type DeleteImpactResponse struct {
+	EntityType string           `json:"entityType"`
+	EntityID   int64            `json:"entityId"`
+	Strategy   string           `json:"strategy"`
+	ReassignTo *int64           `json:"reassignTo,omitempty"`
+	DryRun     bool             `json:"dryRun"`
+	Dependents []DependentCount `json:"dependents"`
+	Total      int64            `json:"total"`
+}
+
+// DependentCount is the number of rows in Table whose Column points at a deleted row.
+type DependentCount struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Count  int64  `json:"count"`
+	Action string `json:"action"`
+}
+
+// === Merge DTOs ===
+
+// MergeRequest - body of POST /v1/{feeders,stations,job-details,teams}/merge
+type MergeRequest struct {
+	SourceIDs []int64 `json:"sourceIds" binding:"required,min=1"`
+	TargetID  int64   `json:"targetId" binding:"required"`
+}
+
+// MergeResponse summarizes the rows re-pointed from the merged sources to the target.
+type MergeResponse struct {
+	EntityType string           `json:"entityType"`
+	TargetID   int64            `json:"targetId"`
+	SourceIDs  []int64          `json:"sourceIds"`
+	Moved      []DependentCount `json:"moved"`
+	Total      int64            `json:"total"`
+}
@@ -0,0 +1,132 @@
+// Package geo holds the coordinate helpers used by the task location queries.
+// Distances are great-circle (haversine) on a spherical earth, which is accurate to
+// well under a percent at the scale of a distribution network and needs no PostGIS.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadiusKm is the mean earth radius used by DistanceKm and DistanceSQL.
+const EarthRadiusKm = 6371.0
+
+// kmPerDegreeLat is the length of one degree of latitude.
+const kmPerDegreeLat = 111.32
+
+// BBox is a longitude/latitude bounding box in the GeoJSON order
+// minLng,minLat,maxLng,maxLat.
+type BBox struct {
+	MinLng, MinLat, MaxLng, MaxLat float64
+}
+
+// Thailand bounds the coordinates a task may carry, with a small margin around the
+// land border so that offshore and border-line work is still accepted.
+var Thailand = BBox{MinLng: 97.3, MinLat: 5.6, MaxLng: 105.7, MaxLat: 20.5}
+
+// Contains reports whether the point lies inside b, edges included.
+func (b BBox) Contains(lat, lng float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lng >= b.MinLng && lng <= b.MaxLng
+}
+
+// Around returns the smallest box holding every point within radiusKm of lat,lng.
+// It is used to narrow a radius search to the latitude/longitude index first.
+func Around(lat, lng, radiusKm float64) BBox {
+	dLat := radiusKm / kmPerDegreeLat
+	dLng := 180.0
+	if cos := math.Cos(lat * math.Pi / 180); cos > 1e-6 {
+		dLng = math.Min(radiusKm/(kmPerDegreeLat*cos), 180)
+	}
+	return BBox{MinLng: lng - dLng, MinLat: lat - dLat, MaxLng: lng + dLng, MaxLat: lat + dLat}
+}
+
+// MaxZoom is the deepest web map zoom level accepted by the map endpoints.
+const MaxZoom = 20
+
+// CellSizeDeg is the width in degrees of cellPx pixels on a 256px web map tile at
+// zoom. Grids built from it are square in degrees, which at Thailand's latitudes is
+// within a few percent of square on the map.
+func CellSizeDeg(zoom, cellPx int) float64 {
+	return float64(cellPx) * 360 / (256 * math.Exp2(float64(zoom)))
+}
+
+// ParseBBox reads "minLng,minLat,maxLng,maxLat".
+func ParseBBox(s string) (BBox, error) {
+	v, err := parseFloats(s, 4)
+	if err != nil {
+		return BBox{}, fmt.Errorf("bbox must be minLng,minLat,maxLng,maxLat: %w", err)
+	}
+	b := BBox{MinLng: v[0], MinLat: v[1], MaxLng: v[2], MaxLat: v[3]}
+	if b.MinLng > b.MaxLng || b.MinLat > b.MaxLat {
+		return BBox{}, fmt.Errorf("bbox minimum must not exceed maximum")
+	}
+	if !ValidLatLng(b.MinLat, b.MinLng) || !ValidLatLng(b.MaxLat, b.MaxLng) {
+		return BBox{}, fmt.Errorf("bbox is out of range")
+	}
+	return b, nil
+}
+
+// ParsePoint reads "lat,lng".
+func ParsePoint(s string) (lat, lng float64, err error) {
+	v, err := parseFloats(s, 2)
+	if err != nil {
+		return 0, 0, fmt.Errorf("point must be lat,lng: %w", err)
+	}
+	if !ValidLatLng(v[0], v[1]) {
+		return 0, 0, fmt.Errorf("point is out of range")
+	}
+	return v[0], v[1], nil
+}
+
+// ValidLatLng reports whether lat and lng are valid WGS84 degrees.
+func ValidLatLng(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d numbers, got %d", n, len(parts))
+	}
+	v := make([]float64, n)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%q is not a number", p)
+		}
+		v[i] = f
+	}
+	return v, nil
+}
+
+// DistanceKm is the great-circle distance between two points.
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// DistanceToBBoxKm is the distance from a point to the nearest point of b, zero when
+// the point lies inside.
+func DistanceToBBoxKm(lat, lng float64, b BBox) float64 {
+	return DistanceKm(lat, lng, math.Min(math.Max(lat, b.MinLat), b.MaxLat), math.Min(math.Max(lng, b.MinLng), b.MaxLng))
+}
+
+// DistanceSQL returns a SQL expression for the distance in km from the point in
+// latCol/lngCol to a point bound through the placeholders, in the order lat, lat, lng
+// (see DistanceArgs).
+func DistanceSQL(latCol, lngCol string) string {
+	return fmt.Sprintf("(2 * %g * ASIN(SQRT(LEAST(1, "+
+		"POWER(SIN(RADIANS(%s - ?) / 2), 2) + "+
+		"COS(RADIANS(?)) * COS(RADIANS(%s)) * POWER(SIN(RADIANS(%s - ?) / 2), 2)))))",
+		EarthRadiusKm, latCol, latCol, lngCol)
+}
+
+// DistanceArgs returns the placeholder values of DistanceSQL for the point lat,lng.
+func DistanceArgs(lat, lng float64) []interface{} {
+	return []interface{}{lat, lat, lng}
+}
@@ -0,0 +1,88 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// polylinePrecision is the number of decimals kept by EncodePolyline, the same as the
+// decimal(9,6) coordinate columns.
+const polylinePrecision = 1e6
+
+// Point is a WGS84 position.
+type Point struct {
+	Lat, Lng float64
+}
+
+// EncodePolyline encodes points with the encoded polyline algorithm at six decimals
+// ("polyline6"): each coordinate is stored as the zig-zag, base-64 varint of its
+// difference from the previous point.
+func EncodePolyline(points []Point) string {
+	var b strings.Builder
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * polylinePrecision))
+		lng := int64(math.Round(p.Lng * polylinePrecision))
+		encodePolylineValue(&b, lat-prevLat)
+		encodePolylineValue(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return b.String()
+}
+
+func encodePolylineValue(b *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
+
+// ErrInvalidPolyline is returned by DecodePolyline for malformed input.
+var ErrInvalidPolyline = errors.New("invalid encoded polyline")
+
+// DecodePolyline reverses EncodePolyline.
+func DecodePolyline(s string) ([]Point, error) {
+	var points []Point
+	var lat, lng int64
+	for i := 0; i < len(s); {
+		dLat, n, err := decodePolylineValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		dLng, n, err := decodePolylineValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		lat += dLat
+		lng += dLng
+		points = append(points, Point{Lat: float64(lat) / polylinePrecision, Lng: float64(lng) / polylinePrecision})
+	}
+	return points, nil
+}
+
+func decodePolylineValue(s string) (int64, int, error) {
+	var u uint64
+	for i, shift := 0, uint(0); i < len(s) && shift < 64; i, shift = i+1, shift+5 {
+		c := int64(s[i]) - 63
+		if c < 0 || c > 0x3f {
+			return 0, 0, ErrInvalidPolyline
+		}
+		u |= uint64(c&0x1f) << shift
+		if c < 0x20 {
+			v := int64(u >> 1)
+			if u&1 != 0 {
+				v = ^v
+			}
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, ErrInvalidPolyline
+}
@@ -0,0 +1,72 @@
+package geo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncodePolyline(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   string
+	}{
+		{"empty", nil, ""},
+		{"origin", []Point{{0, 0}}, "??"},
+		// -17998321 is the worked example of the encoded polyline algorithm
+		{"negative", []Point{{Lat: -17.998321, Lng: 0}}, "`~oia@?"},
+		{"rounded to six decimals", []Point{{Lat: -17.9983214, Lng: 0}}, "`~oia@?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodePolyline(tt.points); got != tt.want {
+				t.Errorf("EncodePolyline = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolylineRoundTrip(t *testing.T) {
+	// Deltas go up and down on both axes, including across zero
+	points := []Point{
+		{Lat: 13.756331, Lng: 100.501765},
+		{Lat: 13.736717, Lng: 100.523186},
+		{Lat: 18.788344, Lng: 98.985300},
+		{Lat: 7.008870, Lng: 100.474700},
+		{Lat: -0.000001, Lng: -0.000002},
+		{Lat: 0.000001, Lng: 0},
+		{Lat: 0.000001, Lng: 0},
+	}
+	decoded, err := DecodePolyline(EncodePolyline(points))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(points) {
+		t.Fatalf("decoded %d points, want %d", len(decoded), len(points))
+	}
+	for i := range points {
+		if decoded[i] != points[i] {
+			t.Errorf("point %d = %v, want %v", i, decoded[i], points[i])
+		}
+	}
+}
+
+func TestDecodePolylineMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"below the alphabet", "!?"},
+		{"above the alphabet", "?\x7f"},
+		{"unterminated value", "`~oia"},
+		{"latitude without longitude", "??`~oia@"},
+		{"value too long", "~~~~~~~~~~~~~~?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if points, err := DecodePolyline(tt.in); !errors.Is(err, ErrInvalidPolyline) {
+				t.Errorf("DecodePolyline(%q) = %v, %v; want ErrInvalidPolyline", tt.in, points, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/apikey"
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/models"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	db   *gorm.DB
+	keys *apikey.Store
+}
+
+func NewAPIKeyHandler(db *gorm.DB, keys *apikey.Store) *APIKeyHandler {
+	return &APIKeyHandler{db: db, keys: keys}
+}
+
+func convertAPIKeyToResponse(key *models.APIKey) dto.APIKeyResponse {
+	formatTime := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		s := t.Format(time.RFC3339)
+		return &s
+	}
+
+	return dto.APIKeyResponse{
+		ID:          key.ID,
+		Name:        key.Name,
+		Prefix:      apikey.KeyPrefix + key.Prefix,
+		Role:        key.Role,
+		Scopes:      strings.Split(key.Scopes, ","),
+		TeamID:      key.TeamID,
+		CreatedByID: key.CreatedByID,
+		ExpiresAt:   formatTime(key.ExpiresAt),
+		LastUsedAt:  formatTime(key.LastUsedAt),
+		LastUsedIP:  key.LastUsedIP,
+		RevokedAt:   formatTime(key.RevokedAt),
+		CreatedAt:   key.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// List - GET /v1/api-keys
+func (h *APIKeyHandler) List(c *gin.Context) {
+	keys, err := h.keys.List(c.Request.Context())
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to list API keys",
+			},
+		})
+		return
+	}
+
+	responses := make([]dto.APIKeyResponse, len(keys))
+	for i := range keys {
+		responses[i] = convertAPIKeyToResponse(&keys[i])
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    responses,
+	})
+}
+
+// Create - POST /v1/api-keys
+// The raw key is returned only in this response.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+
+	scopes, err := apikey.ParseScopes(strings.Join(req.Scopes, ","))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "VALIDATION_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	if !validateRole(c, h.db, req.Role) {
+		return
+	}
+
+	// Same rule as user registration: role 'user' is always bound to a team
+	if req.Role == "user" && req.TeamID == nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "TEAM_ID_REQUIRED",
+				Message: "TeamID is required for role 'user'",
+			},
+		})
+		return
+	}
+
+	key := models.APIKey{
+		Name:   req.Name,
+		Role:   req.Role,
+		Scopes: strings.Join(scopes, ","),
+		TeamID: req.TeamID,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil || !expiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "VALIDATION_ERROR",
+					Message: "expiresAt must be a future RFC3339 timestamp",
+				},
+			})
+			return
+		}
+		key.ExpiresAt = &expiresAt
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		id := userID.(uint)
+		key.CreatedByID = &id
+	}
+
+	var raw string
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if raw, err = h.keys.WithTx(tx).Create(c.Request.Context(), &key); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.APIKey{}.TableName(),
+			EntityID:   key.ID,
+			After:      &key,
+		})
+	}); err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to create API key",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.StandardResponse{
+		Success: true,
+		Data: dto.CreateAPIKeyResponse{
+			APIKeyResponse: convertAPIKeyToResponse(&key),
+			Key:            raw,
+		},
+	})
+}
+
+// Revoke - DELETE /v1/api-keys/:id
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid API key ID",
+			},
+		})
+		return
+	}
+
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		found, err := h.keys.WithTx(tx).Revoke(c.Request.Context(), uint(id))
+		if err != nil {
+			return err
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRevoke,
+			EntityType: models.APIKey{}.TableName(),
+			EntityID:   id,
+		})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "API key not found or already revoked",
+			},
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to revoke API key",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    gin.H{"message": "API key revoked"},
+	})
+}
@@ -0,0 +1,189 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// auditActor builds the audit actor from what AuthMiddleware and RequestID put in the context.
+func auditActor(c *gin.Context) audit.Actor {
+	actor := audit.Actor{
+		Name:      c.GetString("username"),
+		IPAddress: c.ClientIP(),
+		RequestID: c.GetString("request_id"),
+	}
+	if id, ok := c.Get("user_id"); ok {
+		if uid, ok := id.(uint); ok {
+			actor.UserID = &uid
+		}
+	}
+	if id, ok := c.Get("api_key_id"); ok {
+		if kid, ok := id.(uint); ok {
+			actor.APIKeyID = &kid
+		}
+	}
+	return actor
+}
+
+// recordAudit writes the audit entry of a change. It must run in the transaction that
+// makes the change, so the change is rolled back and the request fails when the entry
+// cannot be written.
+func recordAudit(c *gin.Context, tx *gorm.DB, entry audit.Entry) error {
+	return audit.Record(c.Request.Context(), tx, auditActor(c), entry)
+}
+
+type AuditHandler struct {
+	db *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+// parseTimeQuery parses a time query parameter given as RFC3339 or YYYY-MM-DD.
+// A bare date used as an upper bound covers the whole day.
+func parseTimeQuery(value string, endOfDay bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+func rawJSON(s *string) json.RawMessage {
+	if s == nil {
+		return nil
+	}
+	return json.RawMessage(*s)
+}
+
+// List - GET /v1/audit
+// Filters: entityType, entityId, actorId, apiKeyId, action, from, to (RFC3339 or YYYY-MM-DD).
+func (h *AuditHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	query := h.db.WithContext(c.Request.Context()).Model(&models.AuditLog{})
+
+	if entityType := c.Query("entityType"); entityType != "" {
+		query = query.Where(models.AuditLogCol.EntityType+" = ?", entityType)
+	}
+	if entityID := c.Query("entityId"); entityID != "" {
+		query = query.Where(models.AuditLogCol.EntityID+" = ?", entityID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where(models.AuditLogCol.Action+" = ?", action)
+	}
+	if actorID := c.Query("actorId"); actorID != "" {
+		id, err := strconv.ParseUint(actorID, 10, 32)
+		if err != nil {
+			h.badFilter(c, "actorId must be a number")
+			return
+		}
+		query = query.Where(models.AuditLogCol.ActorUserID+" = ?", id)
+	}
+	if apiKeyID := c.Query("apiKeyId"); apiKeyID != "" {
+		id, err := strconv.ParseUint(apiKeyID, 10, 32)
+		if err != nil {
+			h.badFilter(c, "apiKeyId must be a number")
+			return
+		}
+		query = query.Where(models.AuditLogCol.ActorAPIKeyID+" = ?", id)
+	}
+	if from := c.Query("from"); from != "" {
+		t, ok := parseTimeQuery(from, false)
+		if !ok {
+			h.badFilter(c, "from must be RFC3339 or YYYY-MM-DD")
+			return
+		}
+		query = query.Where(models.AuditLogCol.OccurredAt+" >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, ok := parseTimeQuery(to, true)
+		if !ok {
+			h.badFilter(c, "to must be RFC3339 or YYYY-MM-DD")
+			return
+		}
+		query = query.Where(models.AuditLogCol.OccurredAt+" < ?", t)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var rows []models.AuditLog
+	if err := query.Order(models.AuditLogCol.OccurredAt + " DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&rows).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the audit log",
+			},
+		})
+		return
+	}
+
+	response := make([]dto.AuditLogResponse, len(rows))
+	for i, row := range rows {
+		response[i] = dto.AuditLogResponse{
+			ID:            row.ID,
+			OccurredAt:    row.OccurredAt.Format(time.RFC3339),
+			ActorUserID:   row.ActorUserID,
+			ActorAPIKeyID: row.ActorAPIKeyID,
+			ActorName:     row.ActorName,
+			Action:        row.Action,
+			EntityType:    row.EntityType,
+			EntityID:      row.EntityID,
+			Before:        rawJSON(row.Before),
+			After:         rawJSON(row.After),
+			Changes:       rawJSON(row.Changes),
+			IPAddress:     row.IPAddress,
+			RequestID:     row.RequestID,
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+		Meta: &dto.Meta{
+			Page:  page,
+			Limit: limit,
+			Total: total,
+		},
+	})
+}
+
+func (h *AuditHandler) badFilter(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "VALIDATION_ERROR",
+			Message: message,
+		},
+	})
+}
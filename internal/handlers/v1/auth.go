@@ -1,28 +1,57 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/config"
 	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/lockout"
 	"backend-hotlines3/internal/middleware"
 	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/session"
 	"backend-hotlines3/pkg/jwt"
 	"backend-hotlines3/pkg/password"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
 	db         *gorm.DB
 	jwtManager *jwt.JWTManager
+	sessions   *session.Store
+	loginGuard *lockout.Guard
+	twoFactor  twoFactorSettings
+	now        func() time.Time
 }
 
-func NewAuthHandler(db *gorm.DB, jwtManager *jwt.JWTManager) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, jwtManager *jwt.JWTManager, sessions *session.Store, loginGuard *lockout.Guard, twoFactorCfg config.TwoFactorConfig) *AuthHandler {
 	return &AuthHandler{
 		db:         db,
 		jwtManager: jwtManager,
+		sessions:   sessions,
+		loginGuard: loginGuard,
+		twoFactor:  newTwoFactorSettings(twoFactorCfg),
+		now:        time.Now,
+	}
+}
+
+// refreshTokenRecord builds the row persisted for a newly issued refresh token.
+func refreshTokenRecord(c *gin.Context, userID uint, sessionID string, pair *jwt.TokenPair) *models.RefreshToken {
+	return &models.RefreshToken{
+		ID:        pair.RefreshTokenID,
+		SessionID: sessionID,
+		UserID:    userID,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+		IssuedAt:  time.Now(),
+		ExpiresAt: pair.RefreshExpiresAt,
 	}
 }
 
@@ -34,9 +63,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
+	if !validateRole(c, h.db, req.Role) {
+		return
+	}
+
+	// Check if user already exists; deleted users keep their username
 	var existingUser models.User
-	if err := h.db.WithContext(c.Request.Context()).Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
@@ -86,7 +119,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		user.IsActive = *req.IsActive
 	}
 
-	if err := h.db.WithContext(c.Request.Context()).Create(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+			After:      &user,
+		})
+	}); err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -118,9 +161,17 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Brute-force protection: per-username and per-IP backoff/lockout
+	attempt, ok := h.beginLoginAttempt(c, req.Username)
+	if !ok {
+		return
+	}
+	defer h.releaseLoginAttempt(c, attempt)
+
 	var user models.User
 	// Login by username only
 	if err := h.db.WithContext(c.Request.Context()).Where("username = ?", req.Username).First(&user).Error; err != nil {
+		h.recordLoginFailure(c, attempt, req.Username, nil, "unknown_user")
 		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
@@ -132,6 +183,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	if !password.CheckPassword(req.Password, user.Password) {
+		h.recordLoginFailure(c, attempt, req.Username, &user.ID, "invalid_password")
 		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
@@ -153,11 +205,82 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Second factor: enrolled users, and users whose role requires 2FA, get a challenge instead of tokens
+	if user.TOTPEnabled || h.twoFactorRequired(user.Role) {
+		h.respondTwoFactorChallenge(c, &user)
+		return
+	}
+
+	response, ok := h.issueLogin(c, &user, attempt)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// beginLoginAttempt counts a login attempt for username from the client IP. It rejects the
+// request with 429 while either is backing off or locked out, and with 503 when the
+// throttle cannot be reached, since letting logins through unthrottled would open the
+// door to brute force. It returns false when the response has been written.
+func (h *AuthHandler) beginLoginAttempt(c *gin.Context, username string) (*lockout.Attempt, bool) {
+	decision, attempt, err := h.loginGuard.Begin(c.Request.Context(), username, c.ClientIP())
+	if err != nil {
+		log.Printf("Login throttle check failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "LOGIN_UNAVAILABLE",
+				Message: "Login is temporarily unavailable. Try again later",
+			},
+		})
+		return nil, false
+	}
+	if decision.Allowed {
+		return attempt, true
+	}
+
+	seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+	c.Header("Retry-After", fmt.Sprintf("%d", seconds))
+	code, message := "TOO_MANY_ATTEMPTS", "Too many failed login attempts. Try again later"
+	if decision.Locked {
+		code, message = "ACCOUNT_LOCKED", "Too many failed login attempts. Login is temporarily locked"
+	}
+	c.JSON(http.StatusTooManyRequests, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    code,
+			Message: message,
+			Details: gin.H{"retryAfterSeconds": seconds},
+		},
+	})
+	return nil, false
+}
+
+// releaseLoginAttempt takes back an attempt that ended without a failure or a successful
+// login, such as one answered with a second-factor challenge.
+func (h *AuthHandler) releaseLoginAttempt(c *gin.Context, attempt *lockout.Attempt) {
+	if err := attempt.Release(c.Request.Context()); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+}
+
+// issueLogin finishes a successful login: it clears the throttle, records the login time and
+// starts a new session. On failure it writes the error response and returns false.
+func (h *AuthHandler) issueLogin(c *gin.Context, user *models.User, attempt *lockout.Attempt) (*dto.LoginResponse, bool) {
+	if err := attempt.Succeed(c.Request.Context()); err != nil {
+		log.Printf("Failed to reset login attempts for %s: %v", user.Username, err)
+	}
+
 	now := time.Now()
 	user.LastLogin = &now
-	h.db.WithContext(c.Request.Context()).Save(&user)
+	h.db.WithContext(c.Request.Context()).Model(user).Update("lastLogin", now)
 
-	accessToken, refreshToken, err := h.jwtManager.GenerateTokenPair(user.ID, user.Username, user.Role)
+	sessionID := uuid.New().String()
+	pair, err := h.jwtManager.GenerateTokenPair(user.ID, user.Username, user.Role, user.TeamID, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -166,36 +289,73 @@ func (h *AuthHandler) Login(c *gin.Context) {
 				Message: "Failed to generate tokens",
 			},
 		})
-		return
+		return nil, false
 	}
 
-	lastLoginStr := ""
-	if user.LastLogin != nil {
-		lastLoginStr = user.LastLogin.Format(time.RFC3339)
+	if err := h.sessions.Issue(c.Request.Context(), refreshTokenRecord(c, user.ID, sessionID, pair)); err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "TOKEN_GENERATION_ERROR",
+				Message: "Failed to generate tokens",
+			},
+		})
+		return nil, false
 	}
 
-	response := dto.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+	lastLoginStr := user.LastLogin.Format(time.RFC3339)
+
+	return &dto.LoginResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
 		User: dto.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			Role:      user.Role,
-			TeamID:    user.TeamID,
-			IsActive:  user.IsActive,
-			LastLogin: &lastLoginStr,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
+			ID:               user.ID,
+			Username:         user.Username,
+			Role:             user.Role,
+			TeamID:           user.TeamID,
+			IsActive:         user.IsActive,
+			TwoFactorEnabled: user.TOTPEnabled,
+			LastLogin:        &lastLoginStr,
+			CreatedAt:        user.CreatedAt.Format(time.RFC3339),
 		},
-	}
+	}, true
+}
 
-	c.JSON(http.StatusOK, dto.StandardResponse{
-		Success: true,
-		Data:    response,
-	})
+// recordLoginFailure keeps attempt counted against the throttle and keeps a history row.
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, attempt *lockout.Attempt, username string, userID *uint, reason string) {
+	attempt.Fail()
+
+	failure := models.LoginFailure{
+		Username:  username,
+		UserID:    userID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if err := h.db.WithContext(c.Request.Context()).Create(&failure).Error; err != nil {
+		log.Printf("Database error: %v", err)
+	}
 }
 
 // Logout - POST /v1/auth/logout
+// Revokes the session the access token belongs to, so its refresh token stops working.
 func (h *AuthHandler) Logout(c *gin.Context) {
+	if sessionID := c.GetString("session_id"); sessionID != "" {
+		if err := h.sessions.RevokeSession(c.Request.Context(), sessionID); err != nil {
+			log.Printf("Database error: %v", err)
+			c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INTERNAL_ERROR",
+					Message: "Failed to revoke session",
+				},
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    gin.H{"message": "Logged out successfully"},
@@ -210,7 +370,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	claims, err := h.jwtManager.ValidateToken(req.RefreshToken)
+	claims, err := h.jwtManager.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
 			Success: false,
@@ -245,7 +405,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, err := h.jwtManager.GenerateTokenPair(user.ID, user.Username, user.Role)
+	pair, err := h.jwtManager.GenerateTokenPair(user.ID, user.Username, user.Role, user.TeamID, claims.SessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -257,9 +417,43 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
+	// Rotate: the presented refresh token is revoked and replaced by the new one.
+	// Presenting a token that was already rotated revokes the whole session.
+	if err := h.sessions.Rotate(c.Request.Context(), claims.ID, refreshTokenRecord(c, user.ID, claims.SessionID, pair)); err != nil {
+		switch {
+		case errors.Is(err, session.ErrReused):
+			log.Printf("Refresh token reuse detected for user %d, session %s revoked", user.ID, claims.SessionID)
+			c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "TOKEN_REUSED",
+					Message: "Refresh token has already been used; session revoked",
+				},
+			})
+		case errors.Is(err, session.ErrNotFound), errors.Is(err, session.ErrExpired):
+			c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INVALID_TOKEN",
+					Message: "Invalid or expired refresh token",
+				},
+			})
+		default:
+			log.Printf("Database error: %v", err)
+			c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INTERNAL_ERROR",
+					Message: "Failed to refresh session",
+				},
+			})
+		}
+		return
+	}
+
 	response := dto.RefreshResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
 	}
 
 	c.JSON(http.StatusOK, dto.StandardResponse{
@@ -300,13 +494,14 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	}
 
 	response := dto.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		TeamID:    user.TeamID,
-		IsActive:  user.IsActive,
-		LastLogin: &lastLoginStr,
-		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+		ID:               user.ID,
+		Username:         user.Username,
+		Role:             user.Role,
+		TeamID:           user.TeamID,
+		IsActive:         user.IsActive,
+		TwoFactorEnabled: user.TOTPEnabled,
+		LastLogin:        &lastLoginStr,
+		CreatedAt:        user.CreatedAt.Format(time.RFC3339),
 	}
 
 	c.JSON(http.StatusOK, dto.StandardResponse{
@@ -314,3 +509,86 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		Data:    response,
 	})
 }
+
+// ListSessions - GET /v1/auth/sessions
+// Lists the caller's active sessions (one per logged-in device).
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	tokens, err := h.sessions.ListActive(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to list sessions",
+			},
+		})
+		return
+	}
+
+	currentSessionID := c.GetString("session_id")
+	response := []dto.SessionResponse{}
+	for _, t := range tokens {
+		// Tokens issued before uses were recorded show their issue instead
+		lastSeen, userAgent, ip := t.IssuedAt, t.UserAgent, t.IPAddress
+		if t.LastSeenAt != nil {
+			lastSeen, userAgent, ip = *t.LastSeenAt, t.LastSeenUserAgent, t.LastSeenIP
+		}
+		response = append(response, dto.SessionResponse{
+			ID:         t.SessionID,
+			UserAgent:  userAgent,
+			IPAddress:  ip,
+			LastSeenAt: lastSeen.Format(time.RFC3339),
+			ExpiresAt:  t.ExpiresAt.Format(time.RFC3339),
+			Current:    t.SessionID == currentSessionID,
+		})
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// RevokeSession - DELETE /v1/auth/sessions/:id
+// Signs out one of the caller's own sessions.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		found, err := h.sessions.WithTx(tx).RevokeUserSession(c.Request.Context(), c.GetUint("user_id"), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRevoke,
+			EntityType: models.RefreshToken{}.TableName(),
+			EntityID:   c.Param("id"),
+		})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "Session not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to revoke session",
+			},
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/lockout"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/session"
+	"backend-hotlines3/internal/testutil"
+	"backend-hotlines3/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// unavailableStore is a login throttle store whose backend is down.
+type unavailableStore struct{}
+
+func (unavailableStore) Get(context.Context, string) (lockout.Attempts, error) {
+	return lockout.Attempts{}, errors.New("store unavailable")
+}
+
+func (unavailableStore) Update(context.Context, string, time.Duration, func(lockout.Attempts) lockout.Attempts) (lockout.Attempts, error) {
+	return lockout.Attempts{}, errors.New("store unavailable")
+}
+
+func (unavailableStore) Delete(context.Context, string) error {
+	return errors.New("store unavailable")
+}
+
+func TestLoginThrottle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		store  lockout.Store
+		logins []string // passwords tried in order
+		want   []int
+	}{
+		{"wrong then right", lockout.NewMemoryStore(), []string{"wrong", "secret"}, []int{http.StatusUnauthorized, http.StatusOK}},
+		{"locked after failures", lockout.NewMemoryStore(), []string{"wrong", "wrong", "secret"}, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}},
+		{"store unavailable", unavailableStore{}, []string{"secret"}, []int{http.StatusServiceUnavailable}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			cfg := lockout.DefaultConfig()
+			cfg.BaseDelay = 0
+			cfg.MaxUserFailures = 2
+			h := NewAuthHandler(db, jwt.NewJWTManager("test-secret", time.Hour, 24*time.Hour), session.NewStore(db), lockout.NewGuard(tt.store, cfg), config.TwoFactorConfig{})
+
+			hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&models.User{Username: "123456", Password: string(hash), Role: "user", IsActive: true}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			for i, password := range tt.logins {
+				body, _ := json.Marshal(gin.H{"username": "123456", "password": password})
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest(http.MethodPost, "/v1/auth/login", bytes.NewReader(body))
+				c.Request.Header.Set("Content-Type", "application/json")
+				h.Login(c)
+				if w.Code != tt.want[i] {
+					t.Errorf("login %d: status = %d, want %d: %s", i+1, w.Code, tt.want[i], w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// issueSessions stores one active session per id for user 1, each last seen a minute
+// after the one before.
+func issueSessions(t *testing.T, sessions *session.Store, ids ...string) {
+	t.Helper()
+	issued := time.Now().Add(-time.Hour)
+	for i, id := range ids {
+		if err := sessions.Issue(context.Background(), &models.RefreshToken{
+			ID:        "token-" + id,
+			SessionID: id,
+			UserID:    1,
+			UserAgent: "agent-" + id,
+			IPAddress: "10.0.0.1",
+			IssuedAt:  issued.Add(time.Duration(i) * time.Minute),
+			ExpiresAt: issued.Add(24 * time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	sessions := session.NewStore(db)
+	h := NewAuthHandler(db, jwt.NewJWTManager("test-secret", time.Hour, 24*time.Hour), sessions, lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig()), config.TwoFactorConfig{})
+	issueSessions(t, sessions, "phone", "laptop", "revoked")
+	if err := sessions.RevokeSession(context.Background(), "revoked"); err != nil {
+		t.Fatal(err)
+	}
+	// The phone is used after the laptop logged in, from elsewhere
+	if _, err := sessions.Touch(context.Background(), "phone", "10.0.0.9", "phone-browser"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/auth/sessions", nil)
+	c.Set("user_id", uint(1))
+	c.Set("session_id", "laptop")
+	h.ListSessions(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data []dto.SessionResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("sessions = %+v, want phone and laptop", resp.Data)
+	}
+	phone, laptop := resp.Data[0], resp.Data[1]
+	if phone.ID != "phone" || phone.IPAddress != "10.0.0.9" || phone.UserAgent != "phone-browser" || phone.Current {
+		t.Errorf("first session = %+v, want the phone as last seen", phone)
+	}
+	if laptop.ID != "laptop" || laptop.IPAddress != "10.0.0.1" || laptop.UserAgent != "agent-laptop" || !laptop.Current {
+		t.Errorf("second session = %+v, want the current laptop as logged in", laptop)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		session string
+		status  int
+	}{
+		{"own session", "laptop", http.StatusNoContent},
+		{"already revoked", "revoked", http.StatusNotFound},
+		{"other user's session", "other", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testutil.NewDB(t)
+			sessions := session.NewStore(db)
+			h := NewAuthHandler(db, jwt.NewJWTManager("test-secret", time.Hour, 24*time.Hour), sessions, lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig()), config.TwoFactorConfig{})
+			issueSessions(t, sessions, "phone", "laptop", "revoked")
+			if err := sessions.RevokeSession(ctx, "revoked"); err != nil {
+				t.Fatal(err)
+			}
+			if err := sessions.Issue(ctx, &models.RefreshToken{
+				ID:        "token-other",
+				SessionID: "other",
+				UserID:    2,
+				IssuedAt:  time.Now(),
+				ExpiresAt: time.Now().Add(time.Hour),
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/v1/auth/sessions/"+tt.session, nil)
+			c.Params = gin.Params{{Key: "id", Value: tt.session}}
+			c.Set("user_id", uint(1))
+			c.Set("session_id", "phone")
+			h.RevokeSession(c)
+			c.Writer.WriteHeaderNow()
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+
+			want := map[string]bool{"phone": true, "laptop": tt.session != "laptop", "other": true}
+			for id, active := range want {
+				if got, err := sessions.IsActive(ctx, id); err != nil || got != active {
+					t.Errorf("session %s active = %v (%v), want %v", id, got, err, active)
+				}
+			}
+			if tt.status == http.StatusNoContent {
+				var count int64
+				db.Model(&models.AuditLog{}).Where(models.AuditLogCol.Action+" = ?", audit.ActionRevoke).Count(&count)
+				if count != 1 {
+					t.Errorf("%d revoke audit entries, want 1", count)
+				}
+			}
+		})
+	}
+}
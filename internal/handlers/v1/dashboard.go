@@ -19,19 +19,24 @@ func NewDashboardHandler(db *gorm.DB) *DashboardHandler {
 }
 
 // Summary - GET /v1/dashboard/summary
+// With ?approvedOnly=true only approved tasks are counted.
 func (h *DashboardHandler) Summary(c *gin.Context) {
 	year := c.Query("year")
 	month := c.Query("month")
 	teamID := c.Query("teamId")
 	jobTypeID := c.Query("jobTypeId")
+	status := dashboardStatus(c)
 
 	// Build base query
 	query := h.db.Model(&models.TaskDaily{}).
 		Scopes(models.ApplyDashboardFilters(year, month, teamID, jobTypeID))
 
+	// Tasks per workflow status
+	byStatus := countTasksByStatus(query.Session(&gorm.Session{}))
+
 	// Total tasks
 	var totalTasks int64
-	query.Count(&totalTasks)
+	query.Scopes(models.TaskByStatus(status)).Count(&totalTasks)
 
 	// Total job types used
 	var totalJobTypes int64
@@ -49,7 +54,7 @@ func (h *DashboardHandler) Summary(c *gin.Context) {
 	var topTeamResult TeamCount
 	h.db.Model(&models.TaskDaily{}).
 		Select(models.TaskCol.TeamID+" as TeamID, count(*) as count").
-		Scopes(models.TaskByYear(year), models.TaskByMonth(month)).
+		Scopes(models.TaskByYear(year), models.TaskByMonth(month), models.TaskByStatus(status)).
 		Group(models.TaskCol.TeamID).
 		Order("count DESC").
 		Limit(1).
@@ -73,6 +78,7 @@ func (h *DashboardHandler) Summary(c *gin.Context) {
 			TotalJobTypes: totalJobTypes,
 			TotalFeeders:  totalFeeders,
 			TopTeam:       topTeam,
+			ByStatus:      byStatus,
 		},
 	})
 }
@@ -281,11 +287,13 @@ func (h *DashboardHandler) FeederMatrix(c *gin.Context) {
 }
 
 // Stats - GET /v1/dashboard/stats
+// With ?approvedOnly=true only approved tasks are counted.
 func (h *DashboardHandler) Stats(c *gin.Context) {
 	startDate := c.Query("startDate")
 	endDate := c.Query("endDate")
 	teamID := c.Query("teamId")
 	feederID := c.Query("feederId")
+	status := dashboardStatus(c)
 
 	// Build base query
 	query := h.db.Model(&models.TaskDaily{}).
@@ -293,14 +301,18 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 		Scopes(models.TaskByTeam(teamID)).
 		Scopes(models.TaskByFeeder(feederID))
 
+	// Tasks per workflow status
+	byStatus := countTasksByStatus(query.Session(&gorm.Session{}))
+
 	// Total tasks
 	var totalTasks int64
-	query.Count(&totalTasks)
+	query.Scopes(models.TaskByStatus(status)).Count(&totalTasks)
 
 	// Active teams
 	var activeTeams int64
 	h.db.Model(&models.TaskDaily{}).
 		Select("DISTINCT " + models.TaskCol.TeamID).
+		Scopes(models.TaskByStatus(status)).
 		Count(&activeTeams)
 
 	// Top job type
@@ -311,6 +323,7 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 	var topJobTypeResult JobTypeCount
 	h.db.Model(&models.TaskDaily{}).
 		Select(models.TaskCol.JobTypeID + " as JobTypeID, count(*) as count").
+		Scopes(models.TaskByStatus(status)).
 		Group(models.TaskCol.JobTypeID).
 		Order("count DESC").
 		Limit(1).
@@ -332,6 +345,7 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 	h.db.Model(&models.TaskDaily{}).
 		Select(models.TaskCol.FeederID + " as FeederID, count(*) as count").
 		Scopes(models.TaskFeederNotNull).
+		Scopes(models.TaskByStatus(status)).
 		Group(models.TaskCol.FeederID).
 		Order("count DESC").
 		Limit(1).
@@ -359,6 +373,7 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 	h.db.Model(&models.TaskDaily{}).
 		Select(models.TaskCol.FeederID + " as FeederID, count(*) as count").
 		Scopes(models.TaskFeederNotNull).
+		Scopes(models.TaskByStatus(status)).
 		Group(models.TaskCol.FeederID).
 		Order("count DESC").
 		Limit(10).
@@ -381,6 +396,7 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 	}
 	h.db.Model(&models.TaskDaily{}).
 		Select(models.TaskCol.JobTypeID + " as JobTypeID, count(*) as count").
+		Scopes(models.TaskByStatus(status)).
 		Group(models.TaskCol.JobTypeID).
 		Order("count DESC").
 		Find(&jobTypeResults)
@@ -402,6 +418,7 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 	}
 	h.db.Model(&models.TaskDaily{}).
 		Select(models.TaskCol.TeamID + " as TeamID, count(*) as count").
+		Scopes(models.TaskByStatus(status)).
 		Group(models.TaskCol.TeamID).
 		Order("count DESC").
 		Find(&teamResults)
@@ -423,7 +440,8 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 	}
 	dateQuery := h.db.Model(&models.TaskDaily{}).
 		Select("TO_CHAR(" + models.TaskCol.WorkDate + ", 'YYYY-MM-DD') as date, count(*) as count").
-		Scopes(models.TaskByDateRange(startDate, endDate))
+		Scopes(models.TaskByDateRange(startDate, endDate)).
+		Scopes(models.TaskByStatus(status))
 
 	dateQuery.Group("date").
 		Order("date ASC").
@@ -444,6 +462,7 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 				ActiveTeams: activeTeams,
 				TopJobType:  topJobType,
 				TopFeeder:   topFeeder,
+				ByStatus:    byStatus,
 			},
 			Charts: dto.DashboardCharts{
 				TasksByFeeder:  tasksByFeeder,
@@ -454,3 +473,33 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 		},
 	})
 }
+
+// dashboardStatus returns the status filter of ?approvedOnly=true, which restricts the
+// counts to approved tasks. Without it tasks count whatever their status.
+func dashboardStatus(c *gin.Context) string {
+	if c.Query("approvedOnly") == "true" {
+		return models.TaskStatusApproved
+	}
+	return ""
+}
+
+// countTasksByStatus counts the tasks matched by query in each workflow status.
+// Every status is listed, with zero when no task has it.
+func countTasksByStatus(query *gorm.DB) map[string]int64 {
+	var rows []struct {
+		Status string `gorm:"column:status"`
+		Count  int64  `gorm:"column:count"`
+	}
+	query.Select(models.TaskCol.Status + " AS status, count(*) AS count").
+		Group(models.TaskCol.Status).
+		Find(&rows)
+
+	counts := make(map[string]int64, len(models.TaskStatuses))
+	for _, status := range models.TaskStatuses {
+		counts[status] = 0
+	}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts
+}
@@ -0,0 +1,281 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/geo"
+	"backend-hotlines3/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	dashboardMapDefaultZoom = 6
+	// heatmapCellPx and clusterCellPx are the on-screen sizes of a heatmap cell and of
+	// the area merged into one marker.
+	heatmapCellPx = 32
+	clusterCellPx = 64
+	// dashboardMapMaxCells caps the cells or clusters returned; the densest are kept.
+	dashboardMapMaxCells = 5000
+)
+
+// dashboardMapQuery reads ?zoom= (0-20, default 6) and the optional viewport ?bbox=,
+// and builds the query of located tasks under the ApplyDashboardFilters filters.
+// It writes the 400 and reports false when a parameter is invalid.
+func (h *DashboardHandler) dashboardMapQuery(c *gin.Context) (*gorm.DB, int, bool) {
+	zoom := dashboardMapDefaultZoom
+	if z := c.Query("zoom"); z != "" {
+		var err error
+		zoom, err = strconv.Atoi(z)
+		if err != nil || zoom < 0 || zoom > geo.MaxZoom {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "VALIDATION_ERROR",
+					Message: fmt.Sprintf("zoom must be an integer from 0 to %d", geo.MaxZoom),
+				},
+			})
+			return nil, 0, false
+		}
+	}
+
+	query := h.db.WithContext(c.Request.Context()).Model(&models.TaskDaily{}).
+		Scopes(models.ApplyDashboardFilters(c.Query("year"), c.Query("month"), c.Query("teamId"), c.Query("jobTypeId"))).
+		Scopes(models.TaskWithCoordinates)
+
+	if bbox := c.Query("bbox"); bbox != "" {
+		b, err := geo.ParseBBox(bbox)
+		if err != nil {
+			respondInvalidLocationFilter(c, err)
+			return nil, 0, false
+		}
+		query = query.Scopes(models.TaskInBBox(b))
+	}
+	return query, zoom, true
+}
+
+// gridCell returns the grid row and column expressions of a task for cells of size degrees.
+func gridCell(size float64) (y, x string) {
+	return fmt.Sprintf("CAST(FLOOR(%s / %g) AS bigint)", models.TaskCol.Latitude, size),
+		fmt.Sprintf("CAST(FLOOR(%s / %g) AS bigint)", models.TaskCol.Longitude, size)
+}
+
+// gridCellSQL selects the grid row and column of a task for cells of size degrees.
+func gridCellSQL(size float64) string {
+	y, x := gridCell(size)
+	return y + " AS cell_y, " + x + " AS cell_x"
+}
+
+// densestCellsOrder sorts grouped cells densest first; the cell breaks ties so the
+// same cells are kept by every query.
+const densestCellsOrder = "count DESC, cell_y, cell_x"
+
+func respondDashboardMapError(c *gin.Context, err error) {
+	log.Printf("Database error: %v", err)
+	c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "INTERNAL_ERROR",
+			Message: "An error occurred while aggregating task locations",
+		},
+	})
+}
+
+// Heatmap - GET /v1/dashboard/heatmap
+// Counts located tasks per square grid cell, overall and per job type. The cell size
+// follows ?zoom= so a cell is about heatmapCellPx pixels on screen. Accepts the
+// Summary filters (year, month, teamId, jobTypeId) and a viewport ?bbox=.
+func (h *DashboardHandler) Heatmap(c *gin.Context) {
+	query, zoom, ok := h.dashboardMapQuery(c)
+	if !ok {
+		return
+	}
+	size := geo.CellSizeDeg(zoom, heatmapCellPx)
+
+	// Total counts every located task, including those in cells beyond the cap
+	query = query.Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondDashboardMapError(c, err)
+		return
+	}
+
+	var cellRows []struct {
+		CellY int64   `gorm:"column:cell_y"`
+		CellX int64   `gorm:"column:cell_x"`
+		Count int64   `gorm:"column:count"`
+		Lat   float64 `gorm:"column:lat"`
+		Lng   float64 `gorm:"column:lng"`
+	}
+	if err := query.
+		Select(gridCellSQL(size) + ", count(*) AS count, " +
+			"AVG(" + models.TaskCol.Latitude + ") AS lat, AVG(" + models.TaskCol.Longitude + ") AS lng").
+		Group("cell_y, cell_x").
+		Order(densestCellsOrder).
+		Limit(dashboardMapMaxCells + 1).
+		Find(&cellRows).Error; err != nil {
+		respondDashboardMapError(c, err)
+		return
+	}
+	truncated := len(cellRows) > dashboardMapMaxCells
+	if truncated {
+		cellRows = cellRows[:dashboardMapMaxCells]
+	}
+
+	// Per job type counts, only for the kept cells when some were cut
+	var rows []struct {
+		CellY     int64 `gorm:"column:cell_y"`
+		CellX     int64 `gorm:"column:cell_x"`
+		JobTypeID int64 `gorm:"column:job_type_id"`
+		Count     int64 `gorm:"column:count"`
+	}
+	byJobType := query.
+		Select(gridCellSQL(size) + ", " + models.TaskCol.JobTypeID + " AS job_type_id, count(*) AS count").
+		Group("cell_y, cell_x, " + models.TaskCol.JobTypeID)
+	if truncated {
+		y, x := gridCell(size)
+		kept := query.
+			Select(y + " AS kept_y, " + x + " AS kept_x").
+			Group("kept_y, kept_x").
+			Order("count(*) DESC, kept_y, kept_x").
+			Limit(dashboardMapMaxCells)
+		byJobType = byJobType.Joins("JOIN (?) AS kept ON kept.kept_y = "+y+" AND kept.kept_x = "+x, kept)
+	}
+	if err := byJobType.Find(&rows).Error; err != nil {
+		respondDashboardMapError(c, err)
+		return
+	}
+
+	// Job type names
+	jobTypeNames := make(map[int64]string)
+	var jobTypeIDs []int64
+	for _, r := range rows {
+		if _, seen := jobTypeNames[r.JobTypeID]; !seen {
+			jobTypeNames[r.JobTypeID] = ""
+			jobTypeIDs = append(jobTypeIDs, r.JobTypeID)
+		}
+	}
+	if len(jobTypeIDs) > 0 {
+		var jobTypes []models.JobType
+		h.db.WithContext(c.Request.Context()).Unscoped().Where("id IN ?", jobTypeIDs).Find(&jobTypes)
+		for _, jt := range jobTypes {
+			jobTypeNames[jt.ID] = jt.Name
+		}
+	}
+
+	type cellKey struct{ y, x int64 }
+	response := dto.DashboardHeatmapResponse{
+		Zoom:        zoom,
+		CellSizeDeg: size,
+		Total:       total,
+		Truncated:   truncated,
+		Cells:       make([]dto.HeatmapCell, len(cellRows)),
+	}
+	cells := make(map[cellKey]*dto.HeatmapCell, len(cellRows))
+	for i, r := range cellRows {
+		minLat, minLng := float64(r.CellY)*size, float64(r.CellX)*size
+		response.Cells[i] = dto.HeatmapCell{
+			Lat:    r.Lat,
+			Lng:    r.Lng,
+			Count:  r.Count,
+			Bounds: [4]float64{minLng, minLat, minLng + size, minLat + size},
+		}
+		cells[cellKey{r.CellY, r.CellX}] = &response.Cells[i]
+	}
+	for _, r := range rows {
+		if cell, ok := cells[cellKey{r.CellY, r.CellX}]; ok {
+			cell.ByJobType = append(cell.ByJobType, dto.HeatmapJobTypeCount{
+				JobTypeID: r.JobTypeID,
+				Name:      jobTypeNames[r.JobTypeID],
+				Count:     r.Count,
+			})
+		}
+	}
+	for i := range response.Cells {
+		byJobType := response.Cells[i].ByJobType
+		sort.Slice(byJobType, func(a, b int) bool { return byJobType[a].Count > byJobType[b].Count })
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// Clusters - GET /v1/dashboard/clusters
+// Groups located tasks into map markers: tasks within the same clusterCellPx square at
+// ?zoom= become one cluster at their mean position. A cluster of one task carries its
+// taskId. Accepts the same parameters as Heatmap.
+func (h *DashboardHandler) Clusters(c *gin.Context) {
+	query, zoom, ok := h.dashboardMapQuery(c)
+	if !ok {
+		return
+	}
+	size := geo.CellSizeDeg(zoom, clusterCellPx)
+
+	query = query.Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondDashboardMapError(c, err)
+		return
+	}
+
+	var rows []struct {
+		CellY  int64   `gorm:"column:cell_y"`
+		CellX  int64   `gorm:"column:cell_x"`
+		Count  int64   `gorm:"column:count"`
+		Lat    float64 `gorm:"column:lat"`
+		Lng    float64 `gorm:"column:lng"`
+		MinLat float64 `gorm:"column:min_lat"`
+		MinLng float64 `gorm:"column:min_lng"`
+		MaxLat float64 `gorm:"column:max_lat"`
+		MaxLng float64 `gorm:"column:max_lng"`
+		TaskID int64   `gorm:"column:task_id"`
+	}
+	lat, lng := models.TaskCol.Latitude, models.TaskCol.Longitude
+	if err := query.
+		Select(gridCellSQL(size) + ", count(*) AS count, " +
+			"AVG(" + lat + ") AS lat, AVG(" + lng + ") AS lng, " +
+			"MIN(" + lat + ") AS min_lat, MIN(" + lng + ") AS min_lng, " +
+			"MAX(" + lat + ") AS max_lat, MAX(" + lng + ") AS max_lng, MIN(id) AS task_id").
+		Group("cell_y, cell_x").
+		Order(densestCellsOrder).
+		Limit(dashboardMapMaxCells + 1).
+		Find(&rows).Error; err != nil {
+		respondDashboardMapError(c, err)
+		return
+	}
+
+	response := dto.DashboardClustersResponse{
+		Zoom:      zoom,
+		Total:     total,
+		Truncated: len(rows) > dashboardMapMaxCells,
+	}
+	if response.Truncated {
+		rows = rows[:dashboardMapMaxCells]
+	}
+	response.Clusters = make([]dto.MapCluster, 0, len(rows))
+	for _, r := range rows {
+		cluster := dto.MapCluster{
+			Lat:    r.Lat,
+			Lng:    r.Lng,
+			Count:  r.Count,
+			Bounds: [4]float64{r.MinLng, r.MinLat, r.MaxLng, r.MaxLat},
+		}
+		if r.Count == 1 {
+			id := r.TaskID
+			cluster.TaskID = &id
+		}
+		response.Clusters = append(response.Clusters, cluster)
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// TestDashboardMapKeepsDensestCells fills one cell with three tasks and
+// dashboardMapMaxCells more cells with one task each, so exactly one cell is cut.
+func TestDashboardMapKeepsDensestCells(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+
+	task := func(lat, lng float64, jobTypeID int64) models.TaskDaily {
+		la, ln := decimal.NewFromFloat(lat), decimal.NewFromFloat(lng)
+		return models.TaskDaily{
+			WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			JobTypeID:   jobTypeID,
+			JobDetailID: 1,
+			TeamID:      1,
+			Status:      models.TaskStatusApproved,
+			Latitude:    &la,
+			Longitude:   &ln,
+		}
+	}
+	tasks := []models.TaskDaily{task(13.5, 100.5, 1), task(13.5, 100.5, 2), task(13.5, 100.5, 2)}
+	for i := 0; i < dashboardMapMaxCells; i++ {
+		tasks = append(tasks, task(14+float64(i/100)*0.001, 100+float64(i%100)*0.001, 1))
+	}
+	if err := db.CreateInBatches(tasks, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := NewDashboardHandler(db)
+
+	get := func(handler gin.HandlerFunc, target string, data interface{}) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		handler(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, w.Code, w.Body.String())
+		}
+		resp := dto.StandardResponse{Data: data}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var heatmap dto.DashboardHeatmapResponse
+	get(h.Heatmap, "/v1/dashboard/heatmap?zoom=20", &heatmap)
+	if !heatmap.Truncated || len(heatmap.Cells) != dashboardMapMaxCells {
+		t.Errorf("heatmap truncated = %v with %d cells, want true with %d", heatmap.Truncated, len(heatmap.Cells), dashboardMapMaxCells)
+	}
+	if want := int64(len(tasks)); heatmap.Total != want {
+		t.Errorf("heatmap total = %d, want %d", heatmap.Total, want)
+	}
+	if len(heatmap.Cells) > 0 {
+		dense := heatmap.Cells[0]
+		if dense.Count != 3 || len(dense.ByJobType) != 2 || dense.ByJobType[0].JobTypeID != 2 || dense.ByJobType[0].Count != 2 {
+			t.Errorf("densest cell = %+v, want 3 tasks split 2/1 by job type", dense)
+		}
+	}
+	for _, cell := range heatmap.Cells[1:] {
+		if cell.Count != 1 || len(cell.ByJobType) != 1 {
+			t.Fatalf("cell %+v, want one task of one job type", cell)
+		}
+	}
+
+	var clusters dto.DashboardClustersResponse
+	get(h.Clusters, "/v1/dashboard/clusters?zoom=20", &clusters)
+	if !clusters.Truncated || len(clusters.Clusters) != dashboardMapMaxCells {
+		t.Errorf("clusters truncated = %v with %d clusters, want true with %d", clusters.Truncated, len(clusters.Clusters), dashboardMapMaxCells)
+	}
+	if want := int64(len(tasks)); clusters.Total != want {
+		t.Errorf("clusters total = %d, want %d", clusters.Total, want)
+	}
+	if len(clusters.Clusters) > 0 && clusters.Clusters[0].Count != 3 {
+		t.Errorf("first cluster has %d tasks, want the densest with 3", clusters.Clusters[0].Count)
+	}
+
+	// A viewport holding fewer cells than the cap is not truncated
+	get(h.Heatmap, "/v1/dashboard/heatmap?zoom=20&bbox=100.4,13.4,100.6,13.6", &heatmap)
+	if heatmap.Truncated || len(heatmap.Cells) != 1 || heatmap.Total != 3 {
+		t.Errorf("viewport heatmap truncated = %v, %d cells, total %d; want false, 1, 3", heatmap.Truncated, len(heatmap.Cells), heatmap.Total)
+	}
+}
@@ -0,0 +1,520 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Referential-integrity aware deletes: before a reference row (station, feeder, job type,
+// ...) is soft-deleted, the rows pointing at it are looked up and handled according to
+// ?strategy=:
+//
+//	block    (default) refuse with 409 while live rows reference it
+//	reassign point every referencing row at ?reassignTo= instead
+//	cascade  soft-delete the referencing rows too, recursively; users are detached
+//	         from the team and team API keys are revoked instead of being deleted
+//
+// ?dryRun=true returns the impact report without changing anything.
+
+const (
+	deleteStrategyBlock    = "block"
+	deleteStrategyReassign = "reassign"
+	deleteStrategyCascade  = "cascade"
+)
+
+// What happens to a dependent row, as shown in the impact report.
+const (
+	dependentBlock      = "block"
+	dependentReassign   = "reassign"
+	dependentSoftDelete = "soft-delete"
+	dependentDetach     = "detach"
+	dependentRevoke     = "revoke"
+)
+
+// referenceTable describes a table that takes part in dependency handling.
+type referenceTable struct {
+	newSlice  func() interface{} // pointer to an empty slice of the model
+	noun      string             // used in error messages
+	versioned bool               // has an optimistic-concurrency version column
+	active    string             // extra condition for rows that still count, besides soft delete
+	mergeKey  string             // quoted column that merged rows must agree on, if any
+}
+
+// dependentEdge is a foreign key from table.column to the id of the parent table.
+type dependentEdge struct {
+	table   string
+	column  string // quoted column
+	field   string // struct field of column, for updates
+	cascade string // dependentSoftDelete, dependentDetach or dependentRevoke
+}
+
+var referenceTables = map[string]referenceTable{
+	models.OperationCenter{}.TableName(): {
+		newSlice: func() interface{} { return &[]models.OperationCenter{} },
+		noun:     "operation center",
+	},
+	models.PEA{}.TableName(): {
+		newSlice: func() interface{} { return &[]models.PEA{} },
+		noun:     "PEA",
+	},
+	models.Station{}.TableName(): {
+		newSlice:  func() interface{} { return &[]models.Station{} },
+		noun:      "station",
+		versioned: true,
+	},
+	models.Feeder{}.TableName(): {
+		newSlice:  func() interface{} { return &[]models.Feeder{} },
+		noun:      "feeder",
+		versioned: true,
+	},
+	models.JobType{}.TableName(): {
+		newSlice: func() interface{} { return &[]models.JobType{} },
+		noun:     "job type",
+	},
+	models.JobDetail{}.TableName(): {
+		newSlice:  func() interface{} { return &[]models.JobDetail{} },
+		noun:      "job detail",
+		versioned: true,
+		mergeKey:  models.JobDetailCol.JobTypeID, // tasks keep their job type
+	},
+	models.Team{}.TableName(): {
+		newSlice: func() interface{} { return &[]models.Team{} },
+		noun:     "team",
+	},
+	models.TaskDaily{}.TableName(): {
+		newSlice:  func() interface{} { return &[]models.TaskDaily{} },
+		noun:      "task",
+		versioned: true,
+	},
+	models.User{}.TableName(): {
+		newSlice: func() interface{} { return &[]models.User{} },
+		noun:     "user",
+	},
+	models.APIKey{}.TableName(): {
+		newSlice: func() interface{} { return &[]models.APIKey{} },
+		noun:     "API key",
+		active:   models.APIKeyCol.RevokedAt + " IS NULL",
+	},
+}
+
+// dependentEdges lists, per parent table, the foreign keys that point at it.
+var dependentEdges = map[string][]dependentEdge{
+	models.OperationCenter{}.TableName(): {
+		{table: models.Station{}.TableName(), column: models.StationCol.OperationID, field: "OperationID", cascade: dependentSoftDelete},
+		{table: models.PEA{}.TableName(), column: models.PEACol.OperationID, field: "OperationID", cascade: dependentSoftDelete},
+	},
+	models.Station{}.TableName(): {
+		{table: models.Feeder{}.TableName(), column: models.FeederCol.StationID, field: "StationID", cascade: dependentSoftDelete},
+	},
+	models.Feeder{}.TableName(): {
+		{table: models.TaskDaily{}.TableName(), column: models.TaskCol.FeederID, field: "FeederID", cascade: dependentSoftDelete},
+	},
+	models.JobType{}.TableName(): {
+		{table: models.TaskDaily{}.TableName(), column: models.TaskCol.JobTypeID, field: "JobTypeID", cascade: dependentSoftDelete},
+		{table: models.JobDetail{}.TableName(), column: models.JobDetailCol.JobTypeID, field: "JobTypeID", cascade: dependentSoftDelete},
+	},
+	models.JobDetail{}.TableName(): {
+		{table: models.TaskDaily{}.TableName(), column: models.TaskCol.JobDetailID, field: "JobDetailID", cascade: dependentSoftDelete},
+	},
+	models.Team{}.TableName(): {
+		{table: models.TaskDaily{}.TableName(), column: models.TaskCol.TeamID, field: "TeamID", cascade: dependentSoftDelete},
+		{table: models.User{}.TableName(), column: models.UserCol.TeamID, field: "TeamID", cascade: dependentDetach},
+		{table: models.APIKey{}.TableName(), column: models.APIKeyCol.TeamID, field: "TeamID", cascade: dependentRevoke},
+	},
+}
+
+// deleteOptions are the query parameters of a reference Delete.
+type deleteOptions struct {
+	strategy   string
+	reassignTo *int64
+	dryRun     bool
+}
+
+// dependentStep is one set of dependent rows reached through edge, and the action
+// taken on them.
+type dependentStep struct {
+	edge   dependentEdge
+	ids    []int64
+	action string
+}
+
+// rows returns table's rows as the given strategy sees them: reassign also moves
+// soft-deleted and inactive rows, so a later restore does not bring back a dangling
+// reference; block and cascade only consider live rows.
+func (t referenceTable) rows(tx *gorm.DB, strategy string) *gorm.DB {
+	q := tx.Model(t.newSlice())
+	if strategy == deleteStrategyReassign {
+		return q.Unscoped()
+	}
+	if t.active != "" {
+		q = q.Where(t.active)
+	}
+	return q
+}
+
+// parseDeleteOptions reads ?strategy=, ?reassignTo= and ?dryRun=, writing a 400 when
+// they do not form a valid combination.
+func parseDeleteOptions(c *gin.Context) (deleteOptions, bool) {
+	opts := deleteOptions{
+		strategy: c.DefaultQuery("strategy", deleteStrategyBlock),
+		dryRun:   c.Query("dryRun") == "true",
+	}
+
+	invalid := func(message string) (deleteOptions, bool) {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_STRATEGY",
+				Message: message,
+			},
+		})
+		return opts, false
+	}
+
+	switch opts.strategy {
+	case deleteStrategyBlock, deleteStrategyReassign, deleteStrategyCascade:
+	default:
+		return invalid("strategy must be one of block, reassign, cascade")
+	}
+
+	if raw := c.Query("reassignTo"); raw != "" {
+		target, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return invalid("reassignTo must be an ID")
+		}
+		opts.reassignTo = &target
+	}
+	if opts.strategy == deleteStrategyReassign && opts.reassignTo == nil {
+		return invalid("strategy=reassign requires reassignTo")
+	}
+	if opts.strategy != deleteStrategyReassign && opts.reassignTo != nil {
+		return invalid("reassignTo is only used with strategy=reassign")
+	}
+	return opts, true
+}
+
+// planDependents finds the rows affected by deleting id from table. Block and reassign
+// look at direct references only; cascade follows soft-deleted rows down to their own
+// dependents, visiting every row at most once.
+func planDependents(tx *gorm.DB, table string, id int64, strategy string) ([]dependentStep, error) {
+	var steps []dependentStep
+
+	if strategy != deleteStrategyCascade {
+		action := dependentBlock
+		if strategy == deleteStrategyReassign {
+			action = dependentReassign
+		}
+		for _, edge := range dependentEdges[table] {
+			var ids []int64
+			if err := referenceTables[edge.table].rows(tx, strategy).
+				Where(edge.column+" = ?", id).
+				Order("id").
+				Pluck("id", &ids).Error; err != nil {
+				return nil, err
+			}
+			if len(ids) > 0 {
+				steps = append(steps, dependentStep{edge: edge, ids: ids, action: action})
+			}
+		}
+		return steps, nil
+	}
+
+	type level struct {
+		table string
+		ids   []int64
+	}
+	seen := map[string]map[int64]bool{table: {id: true}}
+	queue := []level{{table: table, ids: []int64{id}}}
+
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range dependentEdges[parent.table] {
+			var found []int64
+			if err := referenceTables[edge.table].rows(tx, strategy).
+				Where(edge.column+" IN ?", parent.ids).
+				Order("id").
+				Pluck("id", &found).Error; err != nil {
+				return nil, err
+			}
+
+			if seen[edge.table] == nil {
+				seen[edge.table] = make(map[int64]bool)
+			}
+			var ids []int64
+			for _, childID := range found {
+				if !seen[edge.table][childID] {
+					seen[edge.table][childID] = true
+					ids = append(ids, childID)
+				}
+			}
+			if len(ids) == 0 {
+				continue
+			}
+
+			steps = append(steps, dependentStep{edge: edge, ids: ids, action: edge.cascade})
+			if edge.cascade == dependentSoftDelete {
+				queue = append(queue, level{table: edge.table, ids: ids})
+			}
+		}
+	}
+	return steps, nil
+}
+
+// applyStep carries out step inside tx and returns the audit entries for the changed
+// rows, which the caller records in the same transaction. Changed tasks also get a
+// revision.
+func applyStep(c *gin.Context, tx *gorm.DB, step dependentStep, now time.Time, reassignTo *int64) ([]audit.Entry, error) {
+	table := referenceTables[step.edge.table]
+
+	updates := map[string]interface{}{}
+	auditAction := audit.ActionUpdate
+	switch step.action {
+	case dependentReassign:
+		updates[step.edge.field] = *reassignTo
+	case dependentSoftDelete:
+		updates["DeletedAt"] = now
+		auditAction = audit.ActionDelete
+	case dependentDetach:
+		updates[step.edge.field] = nil
+	case dependentRevoke:
+		updates["RevokedAt"] = now
+		auditAction = audit.ActionRevoke
+	default:
+		return nil, fmt.Errorf("unexpected dependent action %q", step.action)
+	}
+	if table.versioned {
+		updates["Version"] = gorm.Expr("version + 1")
+	}
+
+	strategy := deleteStrategyCascade
+	if step.action == dependentReassign {
+		strategy = deleteStrategyReassign
+	}
+
+	before := table.newSlice()
+	if err := table.rows(tx, strategy).Where("id IN ?", step.ids).Order("id").Find(before).Error; err != nil {
+		return nil, err
+	}
+	if err := table.rows(tx, strategy).Where("id IN ?", step.ids).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	after := table.newSlice()
+	if err := tx.Unscoped().Where("id IN ?", step.ids).Order("id").Find(after).Error; err != nil {
+		return nil, err
+	}
+
+	beforeRows := reflect.ValueOf(before).Elem()
+	afterRows := reflect.ValueOf(after).Elem()
+	if beforeRows.Len() != afterRows.Len() {
+		return nil, fmt.Errorf("%s rows changed while updating dependents", step.edge.table)
+	}
+
+	entries := make([]audit.Entry, 0, beforeRows.Len())
+	for i := 0; i < beforeRows.Len(); i++ {
+		b := beforeRows.Index(i).Addr().Interface()
+		a := afterRows.Index(i).Addr().Interface()
+
+		if task, ok := a.(*models.TaskDaily); ok {
+			if err := recordTaskRevision(c, tx, b.(*models.TaskDaily), task, auditAction, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		entry := audit.Entry{
+			Action:     auditAction,
+			EntityType: step.edge.table,
+			EntityID:   afterRows.Index(i).FieldByName("ID").Interface(),
+			Before:     b,
+		}
+		if auditAction != audit.ActionDelete {
+			entry.After = a
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// impactReport sums steps per dependent table and column.
+func impactReport(table string, id int64, opts deleteOptions, steps []dependentStep) dto.DeleteImpactResponse {
+	report := dto.DeleteImpactResponse{
+		EntityType: table,
+		EntityID:   id,
+		Strategy:   opts.strategy,
+		ReassignTo: opts.reassignTo,
+		DryRun:     opts.dryRun,
+		Dependents: []dto.DependentCount{},
+	}
+
+	index := make(map[string]int)
+	for _, step := range steps {
+		column := strings.Trim(step.edge.column, `"`)
+		key := step.edge.table + "." + column
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Dependents)
+			index[key] = i
+			report.Dependents = append(report.Dependents, dto.DependentCount{
+				Table:  step.edge.table,
+				Column: column,
+				Action: step.action,
+			})
+		}
+		report.Dependents[i].Count += int64(len(step.ids))
+		report.Total += int64(len(step.ids))
+	}
+	return report
+}
+
+// deleteReference soft-deletes row (a pointer to the loaded model with the given id)
+// after handling its dependents as requested, and answers with the impact report.
+// version points at the row's version for versioned tables, which then require If-Match;
+// it is nil otherwise.
+func deleteReference(c *gin.Context, db *gorm.DB, row interface{ TableName() string }, id int64, version *int, current currentLoader) {
+	tableName := row.TableName()
+	table := referenceTables[tableName]
+	noun := strings.ToUpper(table.noun[:1]) + table.noun[1:]
+
+	opts, ok := parseDeleteOptions(c)
+	if !ok {
+		return
+	}
+
+	internalError := func(err error) {
+		log.Printf("Failed to delete %s %d: %v", table.noun, id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while deleting the " + table.noun,
+			},
+		})
+	}
+
+	ctxDB := db.WithContext(c.Request.Context())
+
+	if opts.reassignTo != nil {
+		var count int64
+		if err := table.rows(ctxDB, deleteStrategyCascade).Where("id = ?", *opts.reassignTo).Count(&count).Error; err != nil {
+			internalError(err)
+			return
+		}
+		if count == 0 || *opts.reassignTo == id {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INVALID_REASSIGN_TARGET",
+					Message: "reassignTo must be the ID of another existing " + table.noun,
+				},
+			})
+			return
+		}
+	}
+
+	steps, err := planDependents(ctxDB, tableName, id, opts.strategy)
+	if err != nil {
+		internalError(err)
+		return
+	}
+	report := impactReport(tableName, id, opts, steps)
+
+	if opts.dryRun {
+		c.JSON(http.StatusOK, dto.StandardResponse{
+			Success: true,
+			Data:    report,
+		})
+		return
+	}
+
+	if opts.strategy == deleteStrategyBlock && report.Total > 0 {
+		c.JSON(http.StatusConflict, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "HAS_DEPENDENTS",
+				Message: fmt.Sprintf("%s is referenced by %d rows; delete with strategy=reassign or strategy=cascade", noun, report.Total),
+				Details: report,
+			},
+		})
+		return
+	}
+
+	if version != nil && !requireIfMatch(c, *version, current) {
+		return
+	}
+
+	var entries []audit.Entry
+	now := time.Now()
+	err = ctxDB.Transaction(func(tx *gorm.DB) error {
+		for _, step := range steps {
+			stepEntries, err := applyStep(c, tx, step, now, opts.reassignTo)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, stepEntries...)
+		}
+
+		updates := map[string]interface{}{"DeletedAt": now}
+		q := tx.Model(table.newSlice()).Where("id = ?", id)
+		if version != nil {
+			updates["Version"] = gorm.Expr("version + 1")
+			q = q.Where("version = ?", *version)
+		}
+		result := q.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if version != nil {
+				return errVersionConflict
+			}
+			return gorm.ErrRecordNotFound
+		}
+
+		for _, entry := range entries {
+			if err := recordAudit(c, tx, entry); err != nil {
+				return err
+			}
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: tableName,
+			EntityID:   id,
+			Before:     row,
+		})
+	})
+	switch {
+	case errors.Is(err, errVersionConflict):
+		respondVersionConflict(c, current)
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: noun + " not found",
+			},
+		})
+		return
+	case err != nil:
+		internalError(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    report,
+	})
+}
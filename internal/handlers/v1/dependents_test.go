@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// dependentsFixture is a job type referenced by one job detail and one task, and a
+// second job type to reassign them to.
+type dependentsFixture struct {
+	db        *gorm.DB
+	jobType   models.JobType
+	other     models.JobType
+	jobDetail models.JobDetail
+	task      models.TaskDaily
+	target    string
+}
+
+func newDependentsFixture(t *testing.T) *dependentsFixture {
+	t.Helper()
+	f := &dependentsFixture{
+		db:      testutil.NewDB(t),
+		jobType: models.JobType{Name: "Tree trimming"},
+		other:   models.JobType{Name: "Pole repair"},
+	}
+	for _, jt := range []*models.JobType{&f.jobType, &f.other} {
+		if err := f.db.Create(jt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.jobDetail = models.JobDetail{Name: "Branches on line", JobTypeID: &f.jobType.ID}
+	if err := f.db.Create(&f.jobDetail).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.task = models.TaskDaily{
+		WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		JobTypeID:   f.jobType.ID,
+		JobDetailID: f.jobDetail.ID,
+		TeamID:      1,
+	}
+	if err := f.db.Create(&f.task).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.target = "/v1/job-types/" + strconv.FormatInt(f.jobType.ID, 10)
+	return f
+}
+
+func (f *dependentsFixture) delete(query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, f.target+query, nil)
+	c.Params = gin.Params{{Key: "id", Value: strconv.FormatInt(f.jobType.ID, 10)}}
+	c.Set("user_id", uint(1))
+	NewJobTypeHandler(f.db).Delete(c)
+	return w
+}
+
+// stored reloads the job type, job detail and task, soft-deleted or not.
+func (f *dependentsFixture) stored(t *testing.T) (models.JobType, models.JobDetail, models.TaskDaily) {
+	t.Helper()
+	var jobType models.JobType
+	var jobDetail models.JobDetail
+	var task models.TaskDaily
+	for _, err := range []error{
+		f.db.Unscoped().First(&jobType, f.jobType.ID).Error,
+		f.db.Unscoped().First(&jobDetail, f.jobDetail.ID).Error,
+		f.db.Unscoped().First(&task, f.task.ID).Error,
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return jobType, jobDetail, task
+}
+
+func decodeImpact(t *testing.T, w *httptest.ResponseRecorder) dto.DeleteImpactResponse {
+	t.Helper()
+	var resp struct {
+		Data dto.DeleteImpactResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data
+}
+
+func TestDeleteReferenceBlock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	f := newDependentsFixture(t)
+
+	for _, query := range []string{"", "?strategy=block"} {
+		if w := f.delete(query); w.Code != http.StatusConflict {
+			t.Errorf("delete%s: status = %d, want 409: %s", query, w.Code, w.Body.String())
+		}
+	}
+	if w := f.delete("?strategy=reassign"); w.Code != http.StatusBadRequest {
+		t.Errorf("reassign without reassignTo: status = %d, want 400", w.Code)
+	}
+	if w := f.delete("?strategy=reassign&reassignTo=" + strconv.FormatInt(f.jobType.ID, 10)); w.Code != http.StatusBadRequest {
+		t.Errorf("reassign to itself: status = %d, want 400", w.Code)
+	}
+
+	jobType, jobDetail, task := f.stored(t)
+	if jobType.DeletedAt.Valid || jobDetail.DeletedAt.Valid || task.DeletedAt.Valid {
+		t.Error("a refused delete changed rows")
+	}
+}
+
+func TestDeleteReferenceDryRun(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	f := newDependentsFixture(t)
+
+	w := f.delete("?strategy=cascade&dryRun=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("dry run: status = %d: %s", w.Code, w.Body.String())
+	}
+	report := decodeImpact(t, w)
+	if !report.DryRun || report.Total != 2 || len(report.Dependents) != 2 {
+		t.Errorf("report = %+v, want a dry run over the task and the job detail", report)
+	}
+	for _, d := range report.Dependents {
+		if d.Count != 1 || d.Action != dependentSoftDelete {
+			t.Errorf("dependent %+v, want 1 row to soft-delete", d)
+		}
+	}
+
+	jobType, jobDetail, task := f.stored(t)
+	if jobType.DeletedAt.Valid || jobDetail.DeletedAt.Valid || task.DeletedAt.Valid {
+		t.Error("a dry run changed rows")
+	}
+}
+
+func TestDeleteReferenceReassign(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	f := newDependentsFixture(t)
+
+	w := f.delete("?strategy=reassign&reassignTo=" + strconv.FormatInt(f.other.ID, 10))
+	if w.Code != http.StatusOK {
+		t.Fatalf("reassign: status = %d: %s", w.Code, w.Body.String())
+	}
+	if report := decodeImpact(t, w); report.Total != 2 {
+		t.Errorf("report total = %d, want 2", report.Total)
+	}
+
+	jobType, jobDetail, task := f.stored(t)
+	if !jobType.DeletedAt.Valid {
+		t.Error("job type was not deleted")
+	}
+	if jobDetail.DeletedAt.Valid || jobDetail.JobTypeID == nil || *jobDetail.JobTypeID != f.other.ID {
+		t.Errorf("job detail = %+v, want it live under job type %d", jobDetail, f.other.ID)
+	}
+	if task.DeletedAt.Valid || task.JobTypeID != f.other.ID {
+		t.Errorf("task = %+v, want it live under job type %d", task, f.other.ID)
+	}
+	if task.Version != f.task.Version+1 {
+		t.Errorf("task version = %d, want %d", task.Version, f.task.Version+1)
+	}
+}
+
+func TestDeleteReferenceCascade(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	f := newDependentsFixture(t)
+
+	if w := f.delete("?strategy=cascade"); w.Code != http.StatusOK {
+		t.Fatalf("cascade: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	jobType, jobDetail, task := f.stored(t)
+	if !jobType.DeletedAt.Valid || !jobDetail.DeletedAt.Valid || !task.DeletedAt.Valid {
+		t.Errorf("deleted: job type %v, job detail %v, task %v; want all", jobType.DeletedAt.Valid, jobDetail.DeletedAt.Valid, task.DeletedAt.Valid)
+	}
+
+	// Each soft-deleted row is audited, and the task also gets a revision
+	var audits, revisions int64
+	f.db.Model(&models.AuditLog{}).Count(&audits)
+	f.db.Model(&models.TaskRevision{}).Where(models.TaskRevisionCol.TaskID+" = ?", f.task.ID).Count(&revisions)
+	if audits != 3 || revisions == 0 {
+		t.Errorf("%d audit entries and %d task revisions, want 3 and at least 1", audits, revisions)
+	}
+}
@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/dto"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Optimistic concurrency: tasks, feeders, stations and job details carry a version column
+// that every write increments. GET /:id returns it as a strong ETag, and PUT/DELETE must
+// send it back in If-Match so an edit based on a stale copy is refused instead of
+// silently overwriting someone else's change.
+
+// errVersionConflict is returned by saveVersioned when the row changed after it was read.
+var errVersionConflict = errors.New("row was modified concurrently")
+
+// currentLoader loads a row as the API shows it, together with its version.
+// It is only called to build the body of a 412 response.
+type currentLoader func() (interface{}, int, error)
+
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", etag(version))
+}
+
+// requireIfMatch checks the If-Match header against the version of the row about to be
+// written. It answers 428 when the header is missing and 412 with the current
+// representation when no listed tag matches; "*" matches any version.
+func requireIfMatch(c *gin.Context, version int, current currentLoader) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "PRECONDITION_REQUIRED",
+				Message: "If-Match header with the ETag from GET is required",
+			},
+		})
+		return false
+	}
+
+	want := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == want {
+			return true
+		}
+	}
+	respondVersionConflict(c, current)
+	return false
+}
+
+// respondVersionConflict answers 412 with the current representation and its ETag, so
+// the client can merge its edit and retry.
+func respondVersionConflict(c *gin.Context, current currentLoader) {
+	resp := dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "PRECONDITION_FAILED",
+			Message: "The resource has been modified; reload it and retry with the new ETag",
+		},
+	}
+
+	data, version, err := current()
+	switch {
+	case err == nil:
+		setETag(c, version)
+		resp.Data = data
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		log.Printf("Failed to load current representation: %v", err)
+	}
+	c.JSON(http.StatusPreconditionFailed, resp)
+}
+
+// saveVersioned writes every column of row (a pointer to a model) if its stored version
+// still equals *version, and increments *version. It returns errVersionConflict when
+// the row was changed or removed in the meantime. Associations are not saved.
+func saveVersioned(tx *gorm.DB, row interface{}, version *int) error {
+	expected := *version
+	*version = expected + 1
+
+	result := tx.Model(row).
+		Where("version = ?", expected).
+		Select("*").
+		Omit(clause.Associations).
+		Updates(row)
+	if result.Error != nil || result.RowsAffected == 0 {
+		*version = expected
+		if result.Error != nil {
+			return result.Error
+		}
+		return errVersionConflict
+	}
+	return nil
+}
@@ -1,13 +1,16 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/internal/models"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -19,25 +22,77 @@ func NewFeederHandler(db *gorm.DB) *FeederHandler {
 	return &FeederHandler{db: db}
 }
 
+// convertFeederToResponse converts a Feeder, with Station.OperationCenter preloaded, to its DTO.
+func convertFeederToResponse(feeder *models.Feeder, tasks int64) dto.FeederResponse {
+	response := dto.FeederResponse{
+		ID:        feeder.ID,
+		Code:      feeder.Code,
+		StationID: feeder.StationID,
+		Version:   feeder.Version,
+		Route:     decodeRoute(feeder.Route),
+		DeletedAt: formatDeletedAt(feeder.DeletedAt),
+		Count: &dto.Count{
+			Tasks: tasks,
+		},
+	}
+
+	if feeder.Station != nil {
+		response.Station = &dto.StationNested{
+			ID:        feeder.Station.ID,
+			Name:      feeder.Station.Name,
+			CodeName:  feeder.Station.CodeName,
+			Latitude:  floatCoordinate(feeder.Station.Latitude),
+			Longitude: floatCoordinate(feeder.Station.Longitude),
+		}
+		if feeder.Station.OperationCenter != nil {
+			response.Station.OperationCenter = &dto.OperationCenterNested{
+				ID:   feeder.Station.OperationCenter.ID,
+				Name: feeder.Station.OperationCenter.Name,
+			}
+		}
+	}
+	return response
+}
+
+// currentFeeder loads the feeder as GET /v1/feeders/:id shows it, for a 412 response.
+func (h *FeederHandler) currentFeeder(c *gin.Context, id int64) currentLoader {
+	return func() (interface{}, int, error) {
+		var feeder models.Feeder
+		if err := h.db.WithContext(c.Request.Context()).Preload("Station.OperationCenter").First(&feeder, id).Error; err != nil {
+			return nil, 0, err
+		}
+		count := models.CountTasksFor(h.db, models.TaskCol.FeederID, id)
+		return convertFeederToResponse(&feeder, count), feeder.Version, nil
+	}
+}
+
 // List retrieves all feeders with their station and operation center information, and task counts.
 func (h *FeederHandler) List(c *gin.Context) {
 	// ใช้ Joins แทน Preload เพื่อลดจาก 3 queries เป็น 1 query
 	type feederRow struct {
-		ID              int64  `gorm:"column:id"`
-		Code            string `gorm:"column:code"`
-		StationID       int64  `gorm:"column:stationId"`
-		StationName     string `gorm:"column:station_name"`
-		StationCodeName string `gorm:"column:station_code_name"`
-		OpCenterID      int64  `gorm:"column:op_center_id"`
-		OpCenterName    string `gorm:"column:op_center_name"`
+		ID               int64            `gorm:"column:id"`
+		Code             string           `gorm:"column:code"`
+		StationID        int64            `gorm:"column:stationId"`
+		Version          int              `gorm:"column:version"`
+		Route            *string          `gorm:"column:route"`
+		DeletedAt        gorm.DeletedAt   `gorm:"column:deletedAt"`
+		StationName      string           `gorm:"column:station_name"`
+		StationCodeName  string           `gorm:"column:station_code_name"`
+		StationLatitude  *decimal.Decimal `gorm:"column:station_latitude"`
+		StationLongitude *decimal.Decimal `gorm:"column:station_longitude"`
+		OpCenterID       int64            `gorm:"column:op_center_id"`
+		OpCenterName     string           `gorm:"column:op_center_name"`
 	}
 
-	var rows []feederRow
-	err := h.db.WithContext(c.Request.Context()).Table(`"Feeder"`).
-		Select(`"Feeder"."id", "Feeder"."code", "Feeder"."stationId", "Station"."name" as station_name, "Station"."codeName" as station_code_name, "OperationCenter"."id" as op_center_id, "OperationCenter"."name" as op_center_name`).
+	// feederRow's DeletedAt brings the soft-delete scope, so readDB decides whether
+	// deleted feeders are included
+	query := readDB(c, h.db).Table(models.Feeder{}.TableName()).
+		Select(`"Feeder"."id", "Feeder"."code", "Feeder"."stationId", "Feeder"."version", "Feeder"."route", "Feeder"."deletedAt", "Station"."name" as station_name, "Station"."codeName" as station_code_name, "Station"."latitude" as station_latitude, "Station"."longitude" as station_longitude, "OperationCenter"."id" as op_center_id, "OperationCenter"."name" as op_center_name`).
 		Joins(`LEFT JOIN "Station" ON "Station"."id" = "Feeder"."stationId"`).
-		Joins(`LEFT JOIN "OperationCenter" ON "OperationCenter"."id" = "Station"."operationId"`).
-		Find(&rows).Error
+		Joins(`LEFT JOIN "OperationCenter" ON "OperationCenter"."id" = "Station"."operationId"`)
+
+	var rows []feederRow
+	err := query.Find(&rows).Error
 
 	if err != nil {
 		log.Printf("Failed to fetch feeders: %v", err)
@@ -66,6 +121,9 @@ func (h *FeederHandler) List(c *gin.Context) {
 			ID:        f.ID,
 			Code:      f.Code,
 			StationID: f.StationID,
+			Version:   f.Version,
+			Route:     decodeRoute(f.Route),
+			DeletedAt: formatDeletedAt(f.DeletedAt),
 			Count: &dto.Count{
 				Tasks: countMap[f.ID],
 			},
@@ -73,9 +131,11 @@ func (h *FeederHandler) List(c *gin.Context) {
 
 		if f.StationID != 0 {
 			feederResp.Station = &dto.StationNested{
-				ID:       f.StationID,
-				Name:     f.StationName,
-				CodeName: f.StationCodeName,
+				ID:        f.StationID,
+				Name:      f.StationName,
+				CodeName:  f.StationCodeName,
+				Latitude:  floatCoordinate(f.StationLatitude),
+				Longitude: floatCoordinate(f.StationLongitude),
 			}
 			if f.OpCenterID != 0 {
 				feederResp.Station.OperationCenter = &dto.OperationCenterNested{
@@ -109,7 +169,7 @@ func (h *FeederHandler) GetByID(c *gin.Context) {
 	}
 
 	var feeder models.Feeder
-	if err := h.db.WithContext(c.Request.Context()).Preload("Station.OperationCenter").First(&feeder, id).Error; err != nil {
+	if err := readDB(c, h.db).Preload("Station.OperationCenter").First(&feeder, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -133,40 +193,22 @@ func (h *FeederHandler) GetByID(c *gin.Context) {
 
 	count := models.CountTasksFor(h.db, models.TaskCol.FeederID, id)
 
-	response := dto.FeederResponse{
-		ID:        feeder.ID,
-		Code:      feeder.Code,
-		StationID: feeder.StationID,
-		Count: &dto.Count{
-			Tasks: count,
-		},
-	}
-
-	if feeder.Station != nil {
-		response.Station = &dto.StationNested{
-			ID:       feeder.Station.ID,
-			Name:     feeder.Station.Name,
-			CodeName: feeder.Station.CodeName,
-		}
-		if feeder.Station.OperationCenter != nil {
-			response.Station.OperationCenter = &dto.OperationCenterNested{
-				ID:   feeder.Station.OperationCenter.ID,
-				Name: feeder.Station.OperationCenter.Name,
-			}
-		}
-	}
+	response := convertFeederToResponse(&feeder, count)
 
+	setETag(c, feeder.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
 
-// Create creates a new feeder with the provided code and station ID.
+// Create creates a new feeder with the provided code, station ID and optional route
+// given as [lng, lat] pairs.
 func (h *FeederHandler) Create(c *gin.Context) {
 	var req struct {
-		Code      string `json:"code" binding:"required"`
-		StationID int64  `json:"stationId" binding:"required"`
+		Code      string       `json:"code" binding:"required"`
+		StationID int64        `json:"stationId" binding:"required"`
+		Route     [][2]float64 `json:"route"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.StandardResponse{
@@ -179,11 +221,28 @@ func (h *FeederHandler) Create(c *gin.Context) {
 		return
 	}
 
+	route, msg := encodeRoute(req.Route)
+	if msg != "" {
+		respondInvalidCoordinates(c, msg)
+		return
+	}
+
 	feeder := models.Feeder{
 		Code:      req.Code,
 		StationID: req.StationID,
+		Route:     route,
 	}
-	if err := h.db.WithContext(c.Request.Context()).Create(&feeder).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&feeder).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.Feeder{}.TableName(),
+			EntityID:   feeder.ID,
+			After:      &feeder,
+		})
+	}); err != nil {
 		log.Printf("Failed to create feeder: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -198,36 +257,17 @@ func (h *FeederHandler) Create(c *gin.Context) {
 	// Reload with relations
 	h.db.WithContext(c.Request.Context()).Preload("Station.OperationCenter").First(&feeder, feeder.ID)
 
-	response := dto.FeederResponse{
-		ID:        feeder.ID,
-		Code:      feeder.Code,
-		StationID: feeder.StationID,
-		Count: &dto.Count{
-			Tasks: 0,
-		},
-	}
-
-	if feeder.Station != nil {
-		response.Station = &dto.StationNested{
-			ID:       feeder.Station.ID,
-			Name:     feeder.Station.Name,
-			CodeName: feeder.Station.CodeName,
-		}
-		if feeder.Station.OperationCenter != nil {
-			response.Station.OperationCenter = &dto.OperationCenterNested{
-				ID:   feeder.Station.OperationCenter.ID,
-				Name: feeder.Station.OperationCenter.Name,
-			}
-		}
-	}
+	response := convertFeederToResponse(&feeder, 0)
 
+	setETag(c, feeder.Version)
 	c.JSON(http.StatusCreated, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
 
-// Update updates an existing feeder's code and/or station ID.
+// Update updates an existing feeder's code, station ID and/or route. An empty route
+// ("route": []) removes it.
 func (h *FeederHandler) Update(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -265,8 +305,9 @@ func (h *FeederHandler) Update(c *gin.Context) {
 	}
 
 	var req struct {
-		Code      string `json:"code"`
-		StationID int64  `json:"stationId"`
+		Code      string       `json:"code"`
+		StationID int64        `json:"stationId"`
+		Route     [][2]float64 `json:"route"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.StandardResponse{
@@ -279,14 +320,45 @@ func (h *FeederHandler) Update(c *gin.Context) {
 		return
 	}
 
+	route, msg := encodeRoute(req.Route)
+	if msg != "" {
+		respondInvalidCoordinates(c, msg)
+		return
+	}
+
+	if !requireIfMatch(c, feeder.Version, h.currentFeeder(c, id)) {
+		return
+	}
+
+	before := feeder
+
 	if req.Code != "" {
 		feeder.Code = req.Code
 	}
 	if req.StationID != 0 {
 		feeder.StationID = req.StationID
 	}
+	if req.Route != nil {
+		feeder.Route = route
+	}
 
-	if err := h.db.WithContext(c.Request.Context()).Save(&feeder).Error; err != nil {
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &feeder, &feeder.Version); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.Feeder{}.TableName(),
+			EntityID:   feeder.ID,
+			Before:     &before,
+			After:      &feeder,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentFeeder(c, id))
+		return
+	}
+	if err != nil {
 		log.Printf("Failed to update feeder %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -303,36 +375,17 @@ func (h *FeederHandler) Update(c *gin.Context) {
 
 	count := models.CountTasksFor(h.db, models.TaskCol.FeederID, id)
 
-	response := dto.FeederResponse{
-		ID:        feeder.ID,
-		Code:      feeder.Code,
-		StationID: feeder.StationID,
-		Count: &dto.Count{
-			Tasks: count,
-		},
-	}
-
-	if feeder.Station != nil {
-		response.Station = &dto.StationNested{
-			ID:       feeder.Station.ID,
-			Name:     feeder.Station.Name,
-			CodeName: feeder.Station.CodeName,
-		}
-		if feeder.Station.OperationCenter != nil {
-			response.Station.OperationCenter = &dto.OperationCenterNested{
-				ID:   feeder.Station.OperationCenter.ID,
-				Name: feeder.Station.OperationCenter.Name,
-			}
-		}
-	}
+	response := convertFeederToResponse(&feeder, count)
 
+	setETag(c, feeder.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
 
-// Delete removes a feeder by ID.
+// Delete soft deletes a feeder by ID. Rows that reference it are handled by
+// ?strategy=block|reassign|cascade (see deleteReference); ?dryRun=true only reports them.
 func (h *FeederHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -346,29 +399,120 @@ func (h *FeederHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.WithContext(c.Request.Context()).Delete(&models.Feeder{}, id)
-	if result.Error != nil {
-		log.Printf("Failed to delete feeder %d: %v", id, result.Error)
+	var before models.Feeder
+	if err := h.db.WithContext(c.Request.Context()).First(&before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Feeder not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch feeder %d for deletion: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the feeder",
+			},
+		})
+		return
+	}
+
+	deleteReference(c, h.db, &before, id, &before.Version, h.currentFeeder(c, id))
+}
+
+// Restore restores a soft-deleted feeder by ID.
+func (h *FeederHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid feeder ID",
+			},
+		})
+		return
+	}
+
+	var feeder models.Feeder
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&feeder, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Feeder not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch feeder %d for restore: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
 				Code:    "INTERNAL_ERROR",
-				Message: "An error occurred while deleting the feeder",
+				Message: "An error occurred while fetching the feeder",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, dto.StandardResponse{
+	if !feeder.DeletedAt.Valid {
+		respondNotDeleted(c, "Feeder is not deleted")
+		return
+	}
+
+	before := feeder
+
+	feeder.DeletedAt = gorm.DeletedAt{}
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx.Unscoped(), &feeder, &feeder.Version); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.Feeder{}.TableName(),
+			EntityID:   feeder.ID,
+			Before:     &before,
+			After:      &feeder,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentFeeder(c, id))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to restore feeder %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
-				Code:    "NOT_FOUND",
-				Message: "Feeder not found",
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the feeder",
 			},
 		})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	// Reload with relations
+	h.db.WithContext(c.Request.Context()).Preload("Station.OperationCenter").First(&feeder, feeder.ID)
+
+	count := models.CountTasksFor(h.db, models.TaskCol.FeederID, id)
+
+	setETag(c, feeder.Version)
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    convertFeederToResponse(&feeder, count),
+	})
+}
+
+// Merge - POST /v1/feeders/merge
+// Merges duplicate feeders: rows referencing any of sourceIds are moved to targetId and the
+// sources are soft-deleted (see mergeReferences).
+func (h *FeederHandler) Merge(c *gin.Context) {
+	mergeReferences(c, h.db, models.Feeder{}.TableName())
 }
@@ -0,0 +1,333 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/geo"
+	"backend-hotlines3/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// feederSuggestMinTasks is the number of located tasks a feeder needs before its
+	// area is trusted.
+	feederSuggestMinTasks = 3
+	// feederAreaLow and feederAreaHigh are the percentiles bounding a feeder's area, so
+	// a few tasks filed under the wrong feeder do not stretch it.
+	feederAreaLow  = 0.1
+	feederAreaHigh = 0.9
+
+	feederSuggestDefaultRadiusKm = 20.0
+	feederSuggestMaxRadiusKm     = 100.0
+	feederSuggestDefaultLimit    = 5
+	feederSuggestMaxLimit        = 20
+
+	// feederFarKm is how far outside its feeder's area a new task may be before
+	// Create flags it.
+	feederFarKm = 10.0
+	// feederStationFarKm replaces feederFarKm for a feeder placed at its station, as
+	// its lines may run well away from it.
+	feederStationFarKm = 30.0
+	// feederWarningSuggestions is the number of alternatives listed in a FeederWarning.
+	feederWarningSuggestions = 3
+)
+
+// feederArea summarizes the located tasks of one feeder: the median position and the
+// box between the feederAreaLow and feederAreaHigh percentiles of each coordinate.
+// For a feeder with too few located tasks it is the point of its station instead.
+type feederArea struct {
+	FeederID int64   `gorm:"column:feeder_id"`
+	Tasks    int64   `gorm:"column:tasks"`
+	Lat      float64 `gorm:"column:lat"`
+	Lng      float64 `gorm:"column:lng"`
+	MinLat   float64 `gorm:"column:min_lat"`
+	MinLng   float64 `gorm:"column:min_lng"`
+	MaxLat   float64 `gorm:"column:max_lat"`
+	MaxLng   float64 `gorm:"column:max_lng"`
+	Station  bool    `gorm:"-"`
+}
+
+func (a *feederArea) bbox() geo.BBox {
+	return geo.BBox{MinLng: a.MinLng, MinLat: a.MinLat, MaxLng: a.MaxLng, MaxLat: a.MaxLat}
+}
+
+// loadFeederAreas returns the areas of the feeders selected by scope (applied to the
+// TaskDaily query) that have at least feederSuggestMinTasks located tasks.
+func loadFeederAreas(db *gorm.DB, scope func(*gorm.DB) *gorm.DB) ([]feederArea, error) {
+	percentile := func(fraction float64, col string) string {
+		return fmt.Sprintf("PERCENTILE_CONT(%g) WITHIN GROUP (ORDER BY CAST(%s AS double precision))", fraction, col)
+	}
+	lat, lng := models.TaskCol.Latitude, models.TaskCol.Longitude
+
+	var areas []feederArea
+	err := db.Model(&models.TaskDaily{}).
+		Select(models.TaskCol.FeederID+" AS feeder_id, count(*) AS tasks, "+
+			percentile(0.5, lat)+" AS lat, "+percentile(0.5, lng)+" AS lng, "+
+			percentile(feederAreaLow, lat)+" AS min_lat, "+percentile(feederAreaLow, lng)+" AS min_lng, "+
+			percentile(feederAreaHigh, lat)+" AS max_lat, "+percentile(feederAreaHigh, lng)+" AS max_lng").
+		Scopes(models.TaskFeederNotNull, models.TaskWithCoordinates, scope).
+		Group(models.TaskCol.FeederID).
+		Having("count(*) >= ?", feederSuggestMinTasks).
+		Find(&areas).Error
+	return areas, err
+}
+
+// loadStationAreas returns the point area of each feeder of the stations selected by
+// scope (applied to the Station query) that have coordinates.
+func loadStationAreas(db *gorm.DB, scope func(*gorm.DB) *gorm.DB) ([]feederArea, error) {
+	var stations []models.Station
+	if err := db.Preload("Feeders").
+		Where(models.StationCol.Latitude + " IS NOT NULL").
+		Where(models.StationCol.Longitude + " IS NOT NULL").
+		Scopes(scope).
+		Find(&stations).Error; err != nil {
+		return nil, err
+	}
+
+	var areas []feederArea
+	for _, station := range stations {
+		lat, lng := station.Latitude.InexactFloat64(), station.Longitude.InexactFloat64()
+		for _, feeder := range station.Feeders {
+			areas = append(areas, feederArea{
+				FeederID: feeder.ID,
+				Lat:      lat,
+				Lng:      lng,
+				MinLat:   lat,
+				MinLng:   lng,
+				MaxLat:   lat,
+				MaxLng:   lng,
+				Station:  true,
+			})
+		}
+	}
+	return areas, nil
+}
+
+// suggestFeeders ranks the feeders with tasks within radiusKm of lat,lng by distance to
+// their area, then to their median position. Feeders with too few located tasks are
+// ranked by their station when it lies within radiusKm. Deleted feeders are left out.
+func suggestFeeders(ctx context.Context, db *gorm.DB, lat, lng, radiusKm float64, limit int) ([]dto.FeederSuggestion, error) {
+	db = db.WithContext(ctx)
+	nearby := db.Model(&models.TaskDaily{}).
+		Select(models.TaskCol.FeederID).
+		Scopes(models.TaskFeederNotNull, models.TaskNear(lat, lng, radiusKm))
+
+	areas, err := loadFeederAreas(db, func(q *gorm.DB) *gorm.DB {
+		return q.Where(models.TaskCol.FeederID+" IN (?)", nearby)
+	})
+	if err != nil {
+		return nil, err
+	}
+	stationAreas, err := loadStationAreas(db, models.StationNear(lat, lng, radiusKm))
+	if err != nil {
+		return nil, err
+	}
+	located := make(map[int64]bool, len(areas))
+	for _, a := range areas {
+		located[a.FeederID] = true
+	}
+	for _, a := range stationAreas {
+		if !located[a.FeederID] {
+			areas = append(areas, a)
+		}
+	}
+	if len(areas) == 0 {
+		return []dto.FeederSuggestion{}, nil
+	}
+
+	ids := make([]int64, len(areas))
+	for i, a := range areas {
+		ids[i] = a.FeederID
+	}
+	var feeders []models.Feeder
+	if err := db.Preload("Station").Where("id IN ?", ids).Find(&feeders).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*models.Feeder, len(feeders))
+	for i := range feeders {
+		byID[feeders[i].ID] = &feeders[i]
+	}
+
+	suggestions := make([]dto.FeederSuggestion, 0, len(areas))
+	for _, a := range areas {
+		feeder, ok := byID[a.FeederID]
+		if !ok {
+			continue
+		}
+		s := dto.FeederSuggestion{
+			FeederID:           feeder.ID,
+			Code:               feeder.Code,
+			StationID:          feeder.StationID,
+			DistanceKm:         geo.DistanceToBBoxKm(lat, lng, a.bbox()),
+			CentroidDistanceKm: geo.DistanceKm(lat, lng, a.Lat, a.Lng),
+			Latitude:           a.Lat,
+			Longitude:          a.Lng,
+			Tasks:              a.Tasks,
+			Source:             "tasks",
+		}
+		if a.Station {
+			s.Source = "station"
+		}
+		if feeder.Station != nil {
+			s.StationName = feeder.Station.Name
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].DistanceKm != suggestions[j].DistanceKm {
+			return suggestions[i].DistanceKm < suggestions[j].DistanceKm
+		}
+		return suggestions[i].CentroidDistanceKm < suggestions[j].CentroidDistanceKm
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// checkTaskFeeder returns a FeederWarning when lat,lng lies more than feederFarKm
+// outside the area of feederID's earlier tasks, or nil. A feeder with too few located
+// tasks is checked against feederStationFarKm around its station, and not at all when
+// the station has no coordinates. Errors are logged and yield no warning.
+func checkTaskFeeder(ctx context.Context, db *gorm.DB, feederID int64, lat, lng float64) *dto.FeederWarning {
+	return newFeederChecker(ctx, db).check(feederID, lat, lng)
+}
+
+// feederChecker runs checkTaskFeeder for many tasks, loading each feeder's area once.
+type feederChecker struct {
+	ctx   context.Context
+	db    *gorm.DB
+	areas map[int64]*feederArea // nil when the feeder has no area to check against
+}
+
+func newFeederChecker(ctx context.Context, db *gorm.DB) *feederChecker {
+	return &feederChecker{ctx: ctx, db: db, areas: make(map[int64]*feederArea)}
+}
+
+func (fc *feederChecker) area(feederID int64) (*feederArea, error) {
+	if area, ok := fc.areas[feederID]; ok {
+		return area, nil
+	}
+	areas, err := loadFeederAreas(fc.db.WithContext(fc.ctx), func(q *gorm.DB) *gorm.DB {
+		return q.Where(models.TaskCol.FeederID+" = ?", feederID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(areas) == 0 {
+		station := fc.db.Model(&models.Feeder{}).Select(models.FeederCol.StationID).Where("id = ?", feederID)
+		areas, err = loadStationAreas(fc.db.WithContext(fc.ctx), func(q *gorm.DB) *gorm.DB {
+			return q.Where("id IN (?)", station)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var area *feederArea
+	for i := range areas {
+		if areas[i].FeederID == feederID {
+			area = &areas[i]
+		}
+	}
+	fc.areas[feederID] = area
+	return area, nil
+}
+
+func (fc *feederChecker) check(feederID int64, lat, lng float64) *dto.FeederWarning {
+	area, err := fc.area(feederID)
+	if err != nil {
+		log.Printf("Feeder check error: %v", err)
+		return nil
+	}
+	if area == nil {
+		return nil
+	}
+
+	distance := geo.DistanceToBBoxKm(lat, lng, area.bbox())
+	farKm, message := feederFarKm, "The task is %.1f km from where this feeder's tasks usually are"
+	if area.Station {
+		farKm, message = feederStationFarKm, "The task is %.1f km from this feeder's station"
+	}
+	if distance <= farKm {
+		return nil
+	}
+
+	suggestions, err := suggestFeeders(fc.ctx, fc.db, lat, lng, feederSuggestDefaultRadiusKm, feederWarningSuggestions)
+	if err != nil {
+		log.Printf("Feeder suggestion error: %v", err)
+		suggestions = []dto.FeederSuggestion{}
+	}
+	return &dto.FeederWarning{
+		Code:        "FEEDER_FAR",
+		Message:     fmt.Sprintf(message, distance),
+		DistanceKm:  distance,
+		Suggestions: suggestions,
+	}
+}
+
+// Suggest - GET /v1/feeders/suggest?lat=&lng=
+// Lists the feeders most likely to serve a GPS position, nearest first, judged by where
+// their earlier tasks were recorded, or by their station for feeders with few located
+// tasks. Only feeders with a task or station within ?radiusKm= (default 20) are
+// considered; ?limit= defaults to 5.
+func (h *FeederHandler) Suggest(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil || !geo.ValidLatLng(lat, lng) {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "VALIDATION_ERROR",
+				Message: "lat and lng are required and must be valid coordinates",
+			},
+		})
+		return
+	}
+
+	radiusKm := feederSuggestDefaultRadiusKm
+	if r := c.Query("radiusKm"); r != "" {
+		v, err := strconv.ParseFloat(r, 64)
+		if err != nil || !(v > 0 && v <= feederSuggestMaxRadiusKm) {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "VALIDATION_ERROR",
+					Message: fmt.Sprintf("radiusKm must be a number greater than 0 and at most %g", feederSuggestMaxRadiusKm),
+				},
+			})
+			return
+		}
+		radiusKm = v
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(feederSuggestDefaultLimit)))
+	if limit < 1 || limit > feederSuggestMaxLimit {
+		limit = feederSuggestDefaultLimit
+	}
+
+	suggestions, err := suggestFeeders(c.Request.Context(), h.db, lat, lng, radiusKm, limit)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while suggesting feeders",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    suggestions,
+	})
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func TestSuggestFeedersValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewFeederHandler(testutil.NewDB(t))
+
+	for _, query := range []string{
+		"",
+		"lat=13.75",
+		"lat=north&lng=100.5",
+		"lat=95&lng=100.5",
+		"lat=13.75&lng=100.5&radiusKm=0",
+		"lat=13.75&lng=100.5&radiusKm=500",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v1/feeders/suggest?"+query, nil)
+		handler.Suggest(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("suggest?%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestFeederCheckerDistance(t *testing.T) {
+	fc := newFeederChecker(context.Background(), testutil.NewDB(t))
+	// Areas are loaded with PostgreSQL percentiles, so seed the checker's cache instead
+	fc.areas[1] = &feederArea{FeederID: 1, Tasks: 5, MinLat: 13.7, MinLng: 100.4, MaxLat: 13.8, MaxLng: 100.6}
+	fc.areas[2] = nil
+	fc.areas[3] = &feederArea{FeederID: 3, Station: true, Lat: 13.75, Lng: 100.5, MinLat: 13.75, MinLng: 100.5, MaxLat: 13.75, MaxLng: 100.5}
+
+	if w := fc.check(1, 13.75, 100.5); w != nil {
+		t.Errorf("inside the area: warning %+v", w)
+	}
+	if w := fc.check(1, 13.85, 100.5); w != nil {
+		t.Errorf("within %g km of the area: warning %+v", feederFarKm, w)
+	}
+	if w := fc.check(2, 18.79, 98.98); w != nil {
+		t.Errorf("feeder without an area: warning %+v", w)
+	}
+	if w := fc.check(3, 13.9, 100.5); w != nil {
+		t.Errorf("within %g km of the station: warning %+v", feederStationFarKm, w)
+	}
+
+	w := fc.check(1, 18.79, 98.98)
+	if w == nil {
+		t.Fatal("far from the area: no warning")
+	}
+	if w.Code != "FEEDER_FAR" || w.DistanceKm < 500 || w.Suggestions == nil {
+		t.Errorf("warning = %+v, want FEEDER_FAR over 500 km away", w)
+	}
+	if w := fc.check(3, 14.2, 100.5); w == nil || w.DistanceKm < feederStationFarKm {
+		t.Errorf("far from the station: warning %+v, want one", w)
+	}
+}
+
+func TestLoadStationAreas(t *testing.T) {
+	db := testutil.NewDB(t)
+	lat, lng := decimal.RequireFromString("13.75"), decimal.RequireFromString("100.5")
+	located := models.Station{Name: "Located", CodeName: "LOC", OperationID: 1, Latitude: &lat, Longitude: &lng}
+	unlocated := models.Station{Name: "Unlocated", CodeName: "UNL", OperationID: 1}
+	for _, station := range []*models.Station{&located, &unlocated} {
+		if err := db.Create(station).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	live := models.Feeder{Code: "LOC01", StationID: located.ID}
+	gone := models.Feeder{Code: "LOC02", StationID: located.ID}
+	other := models.Feeder{Code: "UNL01", StationID: unlocated.ID}
+	for _, feeder := range []*models.Feeder{&live, &gone, &other} {
+		if err := db.Create(feeder).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Delete(&gone).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	areas, err := loadStationAreas(db, func(q *gorm.DB) *gorm.DB { return q })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(areas) != 1 {
+		t.Fatalf("areas = %+v, want one for %s", areas, live.Code)
+	}
+	a := areas[0]
+	if a.FeederID != live.ID || !a.Station || a.Lat != 13.75 || a.Lng != 100.5 {
+		t.Errorf("area = %+v, want the point of station %s", a, located.CodeName)
+	}
+	if d := a.bbox(); d.MinLat != d.MaxLat || d.MinLng != d.MaxLng {
+		t.Errorf("station area bbox = %+v, want a point", d)
+	}
+}
@@ -0,0 +1,99 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/geo"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// routeMaxPoints caps the vertices of a feeder route.
+const routeMaxPoints = 10000
+
+// validateCoordinates checks that latitude and longitude are given together and fall
+// inside Thailand. It returns an empty string when they are valid.
+func validateCoordinates(lat, lng *float64) string {
+	if lat == nil && lng == nil {
+		return ""
+	}
+	if lat == nil || lng == nil {
+		return "Latitude and longitude must be given together"
+	}
+	if !geo.Thailand.Contains(*lat, *lng) {
+		return "Coordinates must be inside Thailand"
+	}
+	return ""
+}
+
+// respondInvalidCoordinates writes the 400 returned when validateCoordinates or
+// encodeRoute fails.
+func respondInvalidCoordinates(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "INVALID_COORDINATES",
+			Message: message,
+		},
+	})
+}
+
+// decimalCoordinate converts a validated coordinate for a decimal(9,6) column.
+func decimalCoordinate(v float64) *decimal.Decimal {
+	d := decimal.NewFromFloat(v).Round(6)
+	return &d
+}
+
+// floatCoordinate converts a stored coordinate for a response; nil stays nil.
+func floatCoordinate(d *decimal.Decimal) *float64 {
+	if d == nil {
+		return nil
+	}
+	f, _ := d.Float64()
+	return &f
+}
+
+// encodeRoute validates a route given as [lng, lat] pairs and encodes it for
+// Feeder.Route. An empty route encodes to nil, which clears it. The message is empty
+// when the route is valid.
+func encodeRoute(pairs [][2]float64) (*string, string) {
+	if len(pairs) == 0 {
+		return nil, ""
+	}
+	if len(pairs) < 2 {
+		return nil, "A route needs at least two points"
+	}
+	if len(pairs) > routeMaxPoints {
+		return nil, fmt.Sprintf("A route may have at most %d points", routeMaxPoints)
+	}
+	points := make([]geo.Point, len(pairs))
+	for i, p := range pairs {
+		if !geo.Thailand.Contains(p[1], p[0]) {
+			return nil, fmt.Sprintf("Route point %d must be [lng, lat] inside Thailand", i)
+		}
+		points[i] = geo.Point{Lat: p[1], Lng: p[0]}
+	}
+	encoded := geo.EncodePolyline(points)
+	return &encoded, ""
+}
+
+// decodeRoute turns a stored Feeder.Route back into [lng, lat] pairs. A route that
+// cannot be decoded is logged and left out.
+func decodeRoute(route *string) [][2]float64 {
+	if route == nil || *route == "" {
+		return nil
+	}
+	points, err := geo.DecodePolyline(*route)
+	if err != nil {
+		log.Printf("Invalid feeder route %q: %v", *route, err)
+		return nil
+	}
+	pairs := make([][2]float64, len(points))
+	for i, p := range points {
+		pairs[i] = [2]float64{p.Lng, p.Lat}
+	}
+	return pairs
+}
@@ -1,8 +1,10 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/internal/models"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -20,11 +22,40 @@ func NewJobDetailHandler(db *gorm.DB) *JobDetailHandler {
 	return &JobDetailHandler{db: db}
 }
 
-// List retrieves all non-deleted job details with their task counts.
+// convertJobDetailToResponse converts a JobDetail and its task count to its DTO.
+func convertJobDetailToResponse(jobDetail *models.JobDetail, tasks int64) dto.JobDetailResponse {
+	response := dto.JobDetailResponse{
+		ID:        jobDetail.ID,
+		Name:      jobDetail.Name,
+		JobTypeID: jobDetail.JobTypeID,
+		CreatedAt: jobDetail.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: jobDetail.UpdatedAt.Format(time.RFC3339),
+		DeletedAt: formatDeletedAt(jobDetail.DeletedAt),
+		Version:   jobDetail.Version,
+		Count: &dto.Count{
+			Tasks: tasks,
+		},
+	}
+	return response
+}
+
+// currentJobDetail loads the job detail as GET /v1/job-details/:id shows it, for a 412 response.
+func (h *JobDetailHandler) currentJobDetail(c *gin.Context, id int64) currentLoader {
+	return func() (interface{}, int, error) {
+		var jobDetail models.JobDetail
+		if err := h.db.WithContext(c.Request.Context()).First(&jobDetail, id).Error; err != nil {
+			return nil, 0, err
+		}
+		count := models.CountTasksFor(h.db, models.TaskCol.JobDetailID, id)
+		return convertJobDetailToResponse(&jobDetail, count), jobDetail.Version, nil
+	}
+}
+
+// List retrieves all job details with their task counts.
+// Soft-deleted ones are only included with includeDeleted=true.
 func (h *JobDetailHandler) List(c *gin.Context) {
 	var jobDetails []models.JobDetail
-	// Only get non-deleted records
-	if err := h.db.WithContext(c.Request.Context()).Scopes(models.JobDetailNotDeleted).Find(&jobDetails).Error; err != nil {
+	if err := readDB(c, h.db).Find(&jobDetails).Error; err != nil {
 		log.Printf("Failed to fetch job details: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -46,24 +77,8 @@ func (h *JobDetailHandler) List(c *gin.Context) {
 
 	// Build response
 	var response []dto.JobDetailResponse
-	for _, jd := range jobDetails {
-		var deletedAt *string
-		if jd.DeletedAt != nil {
-			formatted := jd.DeletedAt.Format(time.RFC3339)
-			deletedAt = &formatted
-		}
-
-		response = append(response, dto.JobDetailResponse{
-			ID:        jd.ID,
-			Name:      jd.Name,
-			JobTypeID: jd.JobTypeID,
-			CreatedAt: jd.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: jd.UpdatedAt.Format(time.RFC3339),
-			DeletedAt: deletedAt,
-			Count: &dto.Count{
-				Tasks: countMap[jd.ID],
-			},
-		})
+	for i := range jobDetails {
+		response = append(response, convertJobDetailToResponse(&jobDetails[i], countMap[jobDetails[i].ID]))
 	}
 
 	c.JSON(http.StatusOK, dto.StandardResponse{
@@ -87,7 +102,7 @@ func (h *JobDetailHandler) GetByID(c *gin.Context) {
 	}
 
 	var jobDetail models.JobDetail
-	if err := h.db.WithContext(c.Request.Context()).First(&jobDetail, id).Error; err != nil {
+	if err := readDB(c, h.db).First(&jobDetail, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -111,24 +126,9 @@ func (h *JobDetailHandler) GetByID(c *gin.Context) {
 
 	count := models.CountTasksFor(h.db, models.TaskCol.JobDetailID, id)
 
-	var deletedAt *string
-	if jobDetail.DeletedAt != nil {
-		formatted := jobDetail.DeletedAt.Format(time.RFC3339)
-		deletedAt = &formatted
-	}
-
-	response := dto.JobDetailResponse{
-		ID:        jobDetail.ID,
-		Name:      jobDetail.Name,
-		JobTypeID: jobDetail.JobTypeID,
-		CreatedAt: jobDetail.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: jobDetail.UpdatedAt.Format(time.RFC3339),
-		DeletedAt: deletedAt,
-		Count: &dto.Count{
-			Tasks: count,
-		},
-	}
+	response := convertJobDetailToResponse(&jobDetail, count)
 
+	setETag(c, jobDetail.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    response,
@@ -159,7 +159,17 @@ func (h *JobDetailHandler) Create(c *gin.Context) {
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
-	if err := h.db.WithContext(c.Request.Context()).Create(&jobDetail).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&jobDetail).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.JobDetail{}.TableName(),
+			EntityID:   jobDetail.ID,
+			After:      &jobDetail,
+		})
+	}); err != nil {
 		log.Printf("Failed to create job detail: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -171,18 +181,9 @@ func (h *JobDetailHandler) Create(c *gin.Context) {
 		return
 	}
 
-	response := dto.JobDetailResponse{
-		ID:        jobDetail.ID,
-		Name:      jobDetail.Name,
-		JobTypeID: jobDetail.JobTypeID,
-		CreatedAt: jobDetail.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: jobDetail.UpdatedAt.Format(time.RFC3339),
-		DeletedAt: nil,
-		Count: &dto.Count{
-			Tasks: 0,
-		},
-	}
+	response := convertJobDetailToResponse(&jobDetail, 0)
 
+	setETag(c, jobDetail.Version)
 	c.JSON(http.StatusCreated, dto.StandardResponse{
 		Success: true,
 		Data:    response,
@@ -241,6 +242,12 @@ func (h *JobDetailHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if !requireIfMatch(c, jobDetail.Version, h.currentJobDetail(c, id)) {
+		return
+	}
+
+	before := jobDetail
+
 	if req.Name != "" {
 		jobDetail.Name = req.Name
 	}
@@ -249,7 +256,23 @@ func (h *JobDetailHandler) Update(c *gin.Context) {
 	}
 	jobDetail.UpdatedAt = time.Now()
 
-	if err := h.db.WithContext(c.Request.Context()).Save(&jobDetail).Error; err != nil {
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &jobDetail, &jobDetail.Version); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.JobDetail{}.TableName(),
+			EntityID:   jobDetail.ID,
+			Before:     &before,
+			After:      &jobDetail,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentJobDetail(c, id))
+		return
+	}
+	if err != nil {
 		log.Printf("Failed to update job detail %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -263,31 +286,17 @@ func (h *JobDetailHandler) Update(c *gin.Context) {
 
 	count := models.CountTasksFor(h.db, models.TaskCol.JobDetailID, id)
 
-	var deletedAt *string
-	if jobDetail.DeletedAt != nil {
-		formatted := jobDetail.DeletedAt.Format(time.RFC3339)
-		deletedAt = &formatted
-	}
-
-	response := dto.JobDetailResponse{
-		ID:        jobDetail.ID,
-		Name:      jobDetail.Name,
-		JobTypeID: jobDetail.JobTypeID,
-		CreatedAt: jobDetail.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: jobDetail.UpdatedAt.Format(time.RFC3339),
-		DeletedAt: deletedAt,
-		Count: &dto.Count{
-			Tasks: count,
-		},
-	}
+	response := convertJobDetailToResponse(&jobDetail, count)
 
+	setETag(c, jobDetail.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
 
-// Delete soft deletes a job detail by ID.
+// Delete soft deletes a job detail by ID. Rows that reference it are handled by
+// ?strategy=block|reassign|cascade (see deleteReference); ?dryRun=true only reports them.
 func (h *JobDetailHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -324,22 +333,7 @@ func (h *JobDetailHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	// Soft delete
-	now := time.Now()
-	jobDetail.DeletedAt = &now
-	if err := h.db.WithContext(c.Request.Context()).Save(&jobDetail).Error; err != nil {
-		log.Printf("Failed to delete job detail %d: %v", id, err)
-		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
-			Success: false,
-			Error: &dto.ErrorInfo{
-				Code:    "INTERNAL_ERROR",
-				Message: "An error occurred while deleting the job detail",
-			},
-		})
-		return
-	}
-
-	c.Status(http.StatusNoContent)
+	deleteReference(c, h.db, &jobDetail, id, &jobDetail.Version, h.currentJobDetail(c, id))
 }
 
 // Restore restores a soft-deleted job detail by ID.
@@ -380,7 +374,7 @@ func (h *JobDetailHandler) Restore(c *gin.Context) {
 		return
 	}
 
-	if jobDetail.DeletedAt == nil {
+	if !jobDetail.DeletedAt.Valid {
 		c.JSON(http.StatusBadRequest, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
@@ -391,10 +385,28 @@ func (h *JobDetailHandler) Restore(c *gin.Context) {
 		return
 	}
 
+	before := jobDetail
+
 	// Restore
-	jobDetail.DeletedAt = nil
+	jobDetail.DeletedAt = gorm.DeletedAt{}
 	jobDetail.UpdatedAt = time.Now()
-	if err := h.db.WithContext(c.Request.Context()).Save(&jobDetail).Error; err != nil {
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx.Unscoped(), &jobDetail, &jobDetail.Version); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.JobDetail{}.TableName(),
+			EntityID:   jobDetail.ID,
+			Before:     &before,
+			After:      &jobDetail,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentJobDetail(c, id))
+		return
+	}
+	if err != nil {
 		log.Printf("Failed to restore job detail %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -408,20 +420,18 @@ func (h *JobDetailHandler) Restore(c *gin.Context) {
 
 	count := models.CountTasksFor(h.db, models.TaskCol.JobDetailID, id)
 
-	response := dto.JobDetailResponse{
-		ID:        jobDetail.ID,
-		Name:      jobDetail.Name,
-		JobTypeID: jobDetail.JobTypeID,
-		CreatedAt: jobDetail.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: jobDetail.UpdatedAt.Format(time.RFC3339),
-		DeletedAt: nil,
-		Count: &dto.Count{
-			Tasks: count,
-		},
-	}
+	response := convertJobDetailToResponse(&jobDetail, count)
 
+	setETag(c, jobDetail.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
+
+// Merge - POST /v1/job-details/merge
+// Merges duplicate job details: rows referencing any of sourceIds are moved to targetId and the
+// sources are soft-deleted (see mergeReferences).
+func (h *JobDetailHandler) Merge(c *gin.Context) {
+	mergeReferences(c, h.db, models.JobDetail{}.TableName())
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/internal/models"
 
@@ -23,7 +24,7 @@ func NewJobTypeHandler(db *gorm.DB) *JobTypeHandler {
 // List retrieves all job types with their task counts.
 func (h *JobTypeHandler) List(c *gin.Context) {
 	var jobTypes []models.JobType
-	if err := h.db.WithContext(c.Request.Context()).Find(&jobTypes).Error; err != nil {
+	if err := readDB(c, h.db).Find(&jobTypes).Error; err != nil {
 		log.Printf("Failed to fetch job types: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -46,8 +47,9 @@ func (h *JobTypeHandler) List(c *gin.Context) {
 	var response []dto.JobTypeResponse
 	for _, jt := range jobTypes {
 		response = append(response, dto.JobTypeResponse{
-			ID:   jt.ID,
-			Name: jt.Name,
+			ID:        jt.ID,
+			Name:      jt.Name,
+			DeletedAt: formatDeletedAt(jt.DeletedAt),
 			Count: &dto.Count{
 				Tasks: countMap[jt.ID],
 			},
@@ -75,7 +77,7 @@ func (h *JobTypeHandler) GetByID(c *gin.Context) {
 	}
 
 	var jobType models.JobType
-	if err := h.db.WithContext(c.Request.Context()).First(&jobType, id).Error; err != nil {
+	if err := readDB(c, h.db).First(&jobType, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -100,8 +102,9 @@ func (h *JobTypeHandler) GetByID(c *gin.Context) {
 	count := models.CountTasksFor(h.db, models.TaskCol.JobTypeID, id)
 
 	response := dto.JobTypeResponse{
-		ID:   jobType.ID,
-		Name: jobType.Name,
+		ID:        jobType.ID,
+		Name:      jobType.Name,
+		DeletedAt: formatDeletedAt(jobType.DeletedAt),
 		Count: &dto.Count{
 			Tasks: count,
 		},
@@ -130,7 +133,17 @@ func (h *JobTypeHandler) Create(c *gin.Context) {
 	}
 
 	jobType := models.JobType{Name: req.Name}
-	if err := h.db.WithContext(c.Request.Context()).Create(&jobType).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&jobType).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.JobType{}.TableName(),
+			EntityID:   jobType.ID,
+			After:      &jobType,
+		})
+	}); err != nil {
 		log.Printf("Failed to create job type: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -207,8 +220,21 @@ func (h *JobTypeHandler) Update(c *gin.Context) {
 		return
 	}
 
+	before := jobType
+
 	jobType.Name = req.Name
-	if err := h.db.WithContext(c.Request.Context()).Save(&jobType).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&jobType).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.JobType{}.TableName(),
+			EntityID:   jobType.ID,
+			Before:     &before,
+			After:      &jobType,
+		})
+	}); err != nil {
 		log.Printf("Failed to update job type %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -236,7 +262,8 @@ func (h *JobTypeHandler) Update(c *gin.Context) {
 	})
 }
 
-// Delete removes a job type by ID.
+// Delete soft deletes a job type by ID. Rows that reference it are handled by
+// ?strategy=block|reassign|cascade (see deleteReference); ?dryRun=true only reports them.
 func (h *JobTypeHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -250,29 +277,110 @@ func (h *JobTypeHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.WithContext(c.Request.Context()).Delete(&models.JobType{}, id)
-	if result.Error != nil {
-		log.Printf("Failed to delete job type %d: %v", id, result.Error)
+	var before models.JobType
+	if err := h.db.WithContext(c.Request.Context()).First(&before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Job type not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch job type %d for deletion: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the job type",
+			},
+		})
+		return
+	}
+
+	deleteReference(c, h.db, &before, id, nil, nil)
+}
+
+// Restore restores a soft-deleted job type by ID.
+func (h *JobTypeHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid job type ID",
+			},
+		})
+		return
+	}
+
+	var jobType models.JobType
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&jobType, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Job type not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch job type %d for restore: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
 				Code:    "INTERNAL_ERROR",
-				Message: "An error occurred while deleting the job type",
+				Message: "An error occurred while fetching the job type",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, dto.StandardResponse{
+	if !jobType.DeletedAt.Valid {
+		respondNotDeleted(c, "Job type is not deleted")
+		return
+	}
+
+	before := jobType
+
+	jobType.DeletedAt = gorm.DeletedAt{}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&jobType).Update("deletedAt", nil).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.JobType{}.TableName(),
+			EntityID:   jobType.ID,
+			Before:     &before,
+			After:      &jobType,
+		})
+	}); err != nil {
+		log.Printf("Failed to restore job type %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
-				Code:    "NOT_FOUND",
-				Message: "Job type not found",
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the job type",
 			},
 		})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	count := models.CountTasksFor(h.db, models.TaskCol.JobTypeID, id)
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data: dto.JobTypeResponse{
+			ID:   jobType.ID,
+			Name: jobType.Name,
+			Count: &dto.Count{
+				Tasks: count,
+			},
+		},
+	})
 }
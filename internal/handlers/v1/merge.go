@@ -0,0 +1,207 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	// errMergeSourceMissing is returned when a merge source does not exist or is already deleted.
+	errMergeSourceMissing = errors.New("merge source not found")
+	// errMergeKeyMismatch is returned when the sources and target differ in the table's mergeKey.
+	errMergeKeyMismatch = errors.New("merge rows differ in merge key")
+)
+
+// mergeReferences merges duplicate rows of table into one: every row referencing one
+// of the sources is re-pointed at the target, then the sources are soft-deleted, all in
+// one transaction. The moves, the deletions and the merge itself go to the audit log.
+func mergeReferences(c *gin.Context, db *gorm.DB, tableName string) {
+	table := referenceTables[tableName]
+
+	var req dto.MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "VALIDATION_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	seen := make(map[int64]bool)
+	var sourceIDs []int64
+	for _, id := range req.SourceIDs {
+		if id == req.TargetID {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "VALIDATION_ERROR",
+					Message: "targetId must not be one of sourceIds",
+				},
+			})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			sourceIDs = append(sourceIDs, id)
+		}
+	}
+
+	ctxDB := db.WithContext(c.Request.Context())
+
+	var targets int64
+	if err := table.rows(ctxDB, deleteStrategyCascade).Where("id = ?", req.TargetID).Count(&targets).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while merging " + table.noun + "s",
+			},
+		})
+		return
+	}
+	if targets == 0 {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "Target " + table.noun + " not found",
+			},
+		})
+		return
+	}
+
+	var steps []dependentStep
+	var entries []audit.Entry
+	var response dto.MergeResponse
+	sources := table.newSlice()
+	now := time.Now()
+	err := ctxDB.Transaction(func(tx *gorm.DB) error {
+		if err := table.rows(tx, deleteStrategyCascade).Where("id IN ?", sourceIDs).Order("id").Find(sources).Error; err != nil {
+			return err
+		}
+		if reflect.ValueOf(sources).Elem().Len() != len(sourceIDs) {
+			return errMergeSourceMissing
+		}
+
+		if table.mergeKey != "" {
+			var keys []*int64
+			if err := table.rows(tx, deleteStrategyCascade).
+				Where("id IN ?", append([]int64{req.TargetID}, sourceIDs...)).
+				Distinct(table.mergeKey).
+				Pluck(table.mergeKey, &keys).Error; err != nil {
+				return err
+			}
+			if len(keys) > 1 {
+				return errMergeKeyMismatch
+			}
+		}
+
+		for _, sourceID := range sourceIDs {
+			sourceSteps, err := planDependents(tx, tableName, sourceID, deleteStrategyReassign)
+			if err != nil {
+				return err
+			}
+			for _, step := range sourceSteps {
+				stepEntries, err := applyStep(c, tx, step, now, &req.TargetID)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, stepEntries...)
+			}
+			steps = append(steps, sourceSteps...)
+		}
+
+		updates := map[string]interface{}{"DeletedAt": now}
+		if table.versioned {
+			updates["Version"] = gorm.Expr("version + 1")
+		}
+		result := table.rows(tx, deleteStrategyCascade).Where("id IN ?", sourceIDs).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(sourceIDs)) {
+			return errMergeSourceMissing
+		}
+
+		report := impactReport(tableName, req.TargetID, deleteOptions{strategy: deleteStrategyReassign, reassignTo: &req.TargetID}, steps)
+		response = dto.MergeResponse{
+			EntityType: tableName,
+			TargetID:   req.TargetID,
+			SourceIDs:  sourceIDs,
+			Moved:      report.Dependents,
+			Total:      report.Total,
+		}
+
+		for _, entry := range entries {
+			if err := recordAudit(c, tx, entry); err != nil {
+				return err
+			}
+		}
+		rows := reflect.ValueOf(sources).Elem()
+		for i := 0; i < rows.Len(); i++ {
+			if err := recordAudit(c, tx, audit.Entry{
+				Action:     audit.ActionDelete,
+				EntityType: tableName,
+				EntityID:   rows.Index(i).FieldByName("ID").Interface(),
+				Before:     rows.Index(i).Addr().Interface(),
+			}); err != nil {
+				return err
+			}
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionMerge,
+			EntityType: tableName,
+			EntityID:   req.TargetID,
+			After:      &response,
+		})
+	})
+	if errors.Is(err, errMergeSourceMissing) {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "One or more source " + table.noun + "s not found",
+			},
+		})
+		return
+	}
+	if errors.Is(err, errMergeKeyMismatch) {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "MERGE_MISMATCH",
+				Message: "Source and target " + table.noun + "s must share the same " + strings.Trim(table.mergeKey, `"`),
+			},
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to merge %s %v into %d: %v", tableName, sourceIDs, req.TargetID, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while merging " + table.noun + "s",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
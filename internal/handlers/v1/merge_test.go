@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func mergeJobDetails(db *gorm.DB, body gin.H) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/job-details/merge", bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", uint(1))
+	NewJobDetailHandler(db).Merge(c)
+	return w
+}
+
+func TestMergeJobDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+
+	jobType, otherType := models.JobType{Name: "Tree trimming"}, models.JobType{Name: "Pole repair"}
+	for _, jt := range []*models.JobType{&jobType, &otherType} {
+		if err := db.Create(jt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := models.JobDetail{Name: "Branches on line", JobTypeID: &jobType.ID}
+	duplicate := models.JobDetail{Name: "Branch on the line", JobTypeID: &jobType.ID}
+	foreign := models.JobDetail{Name: "Leaning pole", JobTypeID: &otherType.ID}
+	for _, jd := range []*models.JobDetail{&target, &duplicate, &foreign} {
+		if err := db.Create(jd).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	task := models.TaskDaily{
+		WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		JobTypeID:   jobType.ID,
+		JobDetailID: duplicate.ID,
+		TeamID:      1,
+	}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	refused := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"target among sources", gin.H{"sourceIds": []int64{duplicate.ID, target.ID}, "targetId": target.ID}, http.StatusBadRequest},
+		{"missing target", gin.H{"sourceIds": []int64{duplicate.ID}, "targetId": 999}, http.StatusNotFound},
+		{"missing source", gin.H{"sourceIds": []int64{999}, "targetId": target.ID}, http.StatusNotFound},
+		{"other job type", gin.H{"sourceIds": []int64{foreign.ID}, "targetId": target.ID}, http.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		if w := mergeJobDetails(db, tt.body); w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+
+	w := mergeJobDetails(db, gin.H{"sourceIds": []int64{duplicate.ID}, "targetId": target.ID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("merge: status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data dto.MergeResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Total != 1 {
+		t.Errorf("moved %d rows, want the one task", resp.Data.Total)
+	}
+
+	var stored models.TaskDaily
+	if err := db.First(&stored, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.JobDetailID != target.ID {
+		t.Errorf("task job detail = %d, want %d", stored.JobDetailID, target.ID)
+	}
+	var merged models.JobDetail
+	if err := db.Unscoped().First(&merged, duplicate.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !merged.DeletedAt.Valid {
+		t.Error("merged job detail was not deleted")
+	}
+	var kept models.JobDetail
+	if err := db.Unscoped().First(&kept, foreign.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if kept.DeletedAt.Valid {
+		t.Error("a refused merge deleted its source")
+	}
+}
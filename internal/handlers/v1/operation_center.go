@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/internal/models"
 	"log"
@@ -22,7 +23,7 @@ func NewOperationCenterHandler(db *gorm.DB) *OperationCenterHandler {
 // List retrieves all operation centers.
 func (h *OperationCenterHandler) List(c *gin.Context) {
 	var operationCenters []models.OperationCenter
-	if err := h.db.WithContext(c.Request.Context()).Find(&operationCenters).Error; err != nil {
+	if err := readDB(c, h.db).Find(&operationCenters).Error; err != nil {
 		log.Printf("Failed to fetch operation centers: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -37,8 +38,9 @@ func (h *OperationCenterHandler) List(c *gin.Context) {
 	var response []dto.OperationCenterResponse
 	for _, oc := range operationCenters {
 		response = append(response, dto.OperationCenterResponse{
-			ID:   oc.ID,
-			Name: oc.Name,
+			ID:        oc.ID,
+			Name:      oc.Name,
+			DeletedAt: formatDeletedAt(oc.DeletedAt),
 		})
 	}
 
@@ -63,7 +65,7 @@ func (h *OperationCenterHandler) GetByID(c *gin.Context) {
 	}
 
 	var operationCenter models.OperationCenter
-	if err := h.db.WithContext(c.Request.Context()).First(&operationCenter, id).Error; err != nil {
+	if err := readDB(c, h.db).First(&operationCenter, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -88,8 +90,9 @@ func (h *OperationCenterHandler) GetByID(c *gin.Context) {
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data: dto.OperationCenterResponse{
-			ID:   operationCenter.ID,
-			Name: operationCenter.Name,
+			ID:        operationCenter.ID,
+			Name:      operationCenter.Name,
+			DeletedAt: formatDeletedAt(operationCenter.DeletedAt),
 		},
 	})
 }
@@ -111,7 +114,17 @@ func (h *OperationCenterHandler) Create(c *gin.Context) {
 	}
 
 	operationCenter := models.OperationCenter{Name: req.Name}
-	if err := h.db.WithContext(c.Request.Context()).Create(&operationCenter).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&operationCenter).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.OperationCenter{}.TableName(),
+			EntityID:   operationCenter.ID,
+			After:      &operationCenter,
+		})
+	}); err != nil {
 		log.Printf("Failed to create operation center: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -183,8 +196,21 @@ func (h *OperationCenterHandler) Update(c *gin.Context) {
 		return
 	}
 
+	before := operationCenter
+
 	operationCenter.Name = req.Name
-	if err := h.db.WithContext(c.Request.Context()).Save(&operationCenter).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&operationCenter).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.OperationCenter{}.TableName(),
+			EntityID:   operationCenter.ID,
+			Before:     &before,
+			After:      &operationCenter,
+		})
+	}); err != nil {
 		log.Printf("Failed to update operation center %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -205,7 +231,8 @@ func (h *OperationCenterHandler) Update(c *gin.Context) {
 	})
 }
 
-// Delete removes an operation center by ID.
+// Delete soft deletes an operation center by ID. Rows that reference it are handled by
+// ?strategy=block|reassign|cascade (see deleteReference); ?dryRun=true only reports them.
 func (h *OperationCenterHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -219,29 +246,105 @@ func (h *OperationCenterHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.WithContext(c.Request.Context()).Delete(&models.OperationCenter{}, id)
-	if result.Error != nil {
-		log.Printf("Failed to delete operation center %d: %v", id, result.Error)
+	var before models.OperationCenter
+	if err := h.db.WithContext(c.Request.Context()).First(&before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Operation center not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch operation center %d for deletion: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
 				Code:    "INTERNAL_ERROR",
-				Message: "An error occurred while deleting the operation center",
+				Message: "An error occurred while fetching the operation center",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, dto.StandardResponse{
+	deleteReference(c, h.db, &before, id, nil, nil)
+}
+
+// Restore restores a soft-deleted operation center by ID.
+func (h *OperationCenterHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
-				Code:    "NOT_FOUND",
-				Message: "Operation center not found",
+				Code:    "INVALID_ID",
+				Message: "Invalid operation center ID",
 			},
 		})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	var operationCenter models.OperationCenter
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&operationCenter, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Operation center not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch operation center %d for restore: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the operation center",
+			},
+		})
+		return
+	}
+
+	if !operationCenter.DeletedAt.Valid {
+		respondNotDeleted(c, "Operation center is not deleted")
+		return
+	}
+
+	before := operationCenter
+
+	operationCenter.DeletedAt = gorm.DeletedAt{}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&operationCenter).Update("deletedAt", nil).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.OperationCenter{}.TableName(),
+			EntityID:   operationCenter.ID,
+			Before:     &before,
+			After:      &operationCenter,
+		})
+	}); err != nil {
+		log.Printf("Failed to restore operation center %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the operation center",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data: dto.OperationCenterResponse{
+			ID:   operationCenter.ID,
+			Name: operationCenter.Name,
+		},
+	})
 }
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/internal/models"
 	"log"
@@ -22,7 +23,7 @@ func NewPEAHandler(db *gorm.DB) *PEAHandler {
 // List retrieves all PEAs with their operation center information.
 func (h *PEAHandler) List(c *gin.Context) {
 	var peas []models.PEA
-	if err := h.db.WithContext(c.Request.Context()).Preload("OperationCenter").Find(&peas).Error; err != nil {
+	if err := readDB(c, h.db).Preload("OperationCenter").Find(&peas).Error; err != nil {
 		log.Printf("Failed to fetch PEAs: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -41,6 +42,7 @@ func (h *PEAHandler) List(c *gin.Context) {
 			Shortname:   p.Shortname,
 			Fullname:    p.Fullname,
 			OperationID: p.OperationID,
+			DeletedAt:   formatDeletedAt(p.DeletedAt),
 		}
 		if p.OperationCenter != nil {
 			peaResp.OperationCenter = &dto.OperationCenterNested{
@@ -72,7 +74,7 @@ func (h *PEAHandler) GetByID(c *gin.Context) {
 	}
 
 	var pea models.PEA
-	if err := h.db.WithContext(c.Request.Context()).Preload("OperationCenter").First(&pea, id).Error; err != nil {
+	if err := readDB(c, h.db).Preload("OperationCenter").First(&pea, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -99,6 +101,7 @@ func (h *PEAHandler) GetByID(c *gin.Context) {
 		Shortname:   pea.Shortname,
 		Fullname:    pea.Fullname,
 		OperationID: pea.OperationID,
+		DeletedAt:   formatDeletedAt(pea.DeletedAt),
 	}
 	if pea.OperationCenter != nil {
 		response.OperationCenter = &dto.OperationCenterNested{
@@ -136,7 +139,17 @@ func (h *PEAHandler) Create(c *gin.Context) {
 		Fullname:    req.Fullname,
 		OperationID: req.OperationID,
 	}
-	if err := h.db.WithContext(c.Request.Context()).Create(&pea).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&pea).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.PEA{}.TableName(),
+			EntityID:   pea.ID,
+			After:      &pea,
+		})
+	}); err != nil {
 		log.Printf("Failed to create PEA: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -208,7 +221,22 @@ func (h *PEAHandler) BulkCreate(c *gin.Context) {
 		})
 	}
 
-	if err := h.db.WithContext(c.Request.Context()).Create(&peas).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&peas).Error; err != nil {
+			return err
+		}
+		for i := range peas {
+			if err := recordAudit(c, tx, audit.Entry{
+				Action:     audit.ActionCreate,
+				EntityType: models.PEA{}.TableName(),
+				EntityID:   peas[i].ID,
+				After:      &peas[i],
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
 		log.Printf("Failed to bulk create PEAs: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -303,6 +331,8 @@ func (h *PEAHandler) Update(c *gin.Context) {
 		return
 	}
 
+	before := pea
+
 	if req.Shortname != "" {
 		pea.Shortname = req.Shortname
 	}
@@ -313,7 +343,18 @@ func (h *PEAHandler) Update(c *gin.Context) {
 		pea.OperationID = req.OperationID
 	}
 
-	if err := h.db.WithContext(c.Request.Context()).Save(&pea).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&pea).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.PEA{}.TableName(),
+			EntityID:   pea.ID,
+			Before:     &before,
+			After:      &pea,
+		})
+	}); err != nil {
 		log.Printf("Failed to update PEA %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -347,7 +388,8 @@ func (h *PEAHandler) Update(c *gin.Context) {
 	})
 }
 
-// Delete removes a PEA by ID.
+// Delete soft deletes a PEA by ID. Rows that reference it are handled by
+// ?strategy=block|reassign|cascade (see deleteReference); ?dryRun=true only reports them.
 func (h *PEAHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -361,29 +403,116 @@ func (h *PEAHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.WithContext(c.Request.Context()).Delete(&models.PEA{}, id)
-	if result.Error != nil {
-		log.Printf("Failed to delete PEA %d: %v", id, result.Error)
+	var before models.PEA
+	if err := h.db.WithContext(c.Request.Context()).First(&before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "PEA not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch PEA %d for deletion: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the PEA",
+			},
+		})
+		return
+	}
+
+	deleteReference(c, h.db, &before, id, nil, nil)
+}
+
+// Restore restores a soft-deleted PEA by ID.
+func (h *PEAHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid PEA ID",
+			},
+		})
+		return
+	}
+
+	var pea models.PEA
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&pea, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "PEA not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch PEA %d for restore: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
 				Code:    "INTERNAL_ERROR",
-				Message: "An error occurred while deleting the PEA",
+				Message: "An error occurred while fetching the PEA",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, dto.StandardResponse{
+	if !pea.DeletedAt.Valid {
+		respondNotDeleted(c, "PEA is not deleted")
+		return
+	}
+
+	before := pea
+
+	pea.DeletedAt = gorm.DeletedAt{}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&pea).Update("deletedAt", nil).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.PEA{}.TableName(),
+			EntityID:   pea.ID,
+			Before:     &before,
+			After:      &pea,
+		})
+	}); err != nil {
+		log.Printf("Failed to restore PEA %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
-				Code:    "NOT_FOUND",
-				Message: "PEA not found",
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the PEA",
 			},
 		})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	response := dto.PEAResponse{
+		ID:          pea.ID,
+		Shortname:   pea.Shortname,
+		Fullname:    pea.Fullname,
+		OperationID: pea.OperationID,
+	}
+	var operationCenter models.OperationCenter
+	if err := h.db.WithContext(c.Request.Context()).First(&operationCenter, pea.OperationID).Error; err == nil {
+		response.OperationCenter = &dto.OperationCenterNested{
+			ID:   operationCenter.ID,
+			Name: operationCenter.Name,
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
 }
@@ -0,0 +1,413 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+type RoleHandler struct {
+	db          *gorm.DB
+	permissions *permission.Resolver
+}
+
+func NewRoleHandler(db *gorm.DB, permissions *permission.Resolver) *RoleHandler {
+	return &RoleHandler{db: db, permissions: permissions}
+}
+
+// validateRole writes a 400 and returns false when name is not an existing role.
+func validateRole(c *gin.Context, db *gorm.DB, name string) bool {
+	var count int64
+	if err := db.WithContext(c.Request.Context()).Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to verify role",
+			},
+		})
+		return false
+	}
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ROLE",
+				Message: fmt.Sprintf("Role %q does not exist", name),
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// validatePermissions writes a 400 and returns false when any name is not in the catalog.
+func validatePermissions(c *gin.Context, names []string) bool {
+	for _, name := range names {
+		if !permission.Valid(name) {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INVALID_PERMISSION",
+					Message: fmt.Sprintf("Unknown permission %q", name),
+				},
+			})
+			return false
+		}
+	}
+	return true
+}
+
+func convertRoleToResponse(role *models.Role) dto.RoleResponse {
+	perms := make([]string, 0, len(role.Permissions))
+	for _, p := range role.Permissions {
+		perms = append(perms, p.Permission)
+	}
+	sort.Strings(perms)
+
+	return dto.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		IsSystem:    role.IsSystem,
+		Permissions: perms,
+		CreatedAt:   role.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   role.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// loadRole fetches the role in the :id param with its permissions, writing the error response on failure.
+func (h *RoleHandler) loadRole(c *gin.Context) (*models.Role, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid role ID",
+			},
+		})
+		return nil, false
+	}
+
+	var role models.Role
+	if err := h.db.WithContext(c.Request.Context()).Preload("Permissions").First(&role, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Role not found",
+				},
+			})
+			return nil, false
+		}
+		log.Printf("Failed to fetch role %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the role",
+			},
+		})
+		return nil, false
+	}
+	return &role, true
+}
+
+// ListPermissions - GET /v1/permissions
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    permission.Catalog,
+	})
+}
+
+// List - GET /v1/roles
+func (h *RoleHandler) List(c *gin.Context) {
+	var roles []models.Role
+	if err := h.db.WithContext(c.Request.Context()).Preload("Permissions").Order("id ASC").Find(&roles).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching roles",
+			},
+		})
+		return
+	}
+
+	responses := make([]dto.RoleResponse, len(roles))
+	for i := range roles {
+		responses[i] = convertRoleToResponse(&roles[i])
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    responses,
+	})
+}
+
+// GetByID - GET /v1/roles/:id
+func (h *RoleHandler) GetByID(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    convertRoleToResponse(role),
+	})
+}
+
+// Create - POST /v1/roles
+func (h *RoleHandler) Create(c *gin.Context) {
+	var req dto.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "VALIDATION_ERROR",
+				Message: "Role name must be lowercase letters, digits, '-' or '_'",
+			},
+		})
+		return
+	}
+	if !validatePermissions(c, req.Permissions) {
+		return
+	}
+
+	var count int64
+	h.db.WithContext(c.Request.Context()).Model(&models.Role{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "ROLE_EXISTS",
+				Message: "Role name already taken",
+			},
+		})
+		return
+	}
+
+	role := models.Role{Name: req.Name, Description: req.Description}
+	for _, p := range permission.NewSet(req.Permissions...).List() {
+		role.Permissions = append(role.Permissions, models.RolePermission{Permission: p})
+	}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.Role{}.TableName(),
+			EntityID:   role.ID,
+			After:      convertRoleToResponse(&role),
+		})
+	}); err != nil {
+		log.Printf("Failed to create role: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while creating the role",
+			},
+		})
+		return
+	}
+	h.permissions.Invalidate()
+
+	c.JSON(http.StatusCreated, dto.StandardResponse{
+		Success: true,
+		Data:    convertRoleToResponse(&role),
+	})
+}
+
+// Update - PUT /v1/roles/:id
+// The admin role always keeps every permission, so its list cannot be changed. Other
+// built-in roles may gain permissions but not lose their defaults, which Seed restores.
+func (h *RoleHandler) Update(c *gin.Context) {
+	var req dto.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	if req.Permissions != nil {
+		if role.Name == permission.RoleAdmin {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "SYSTEM_ROLE",
+					Message: "Permissions of the admin role cannot be changed",
+				},
+			})
+			return
+		}
+		if !validatePermissions(c, *req.Permissions) {
+			return
+		}
+		if role.IsSystem {
+			requested := permission.NewSet(*req.Permissions...)
+			var missing []string
+			for _, p := range permission.Defaults(role.Name) {
+				if !requested.Has(p) {
+					missing = append(missing, p)
+				}
+			}
+			if len(missing) > 0 {
+				c.JSON(http.StatusBadRequest, dto.StandardResponse{
+					Success: false,
+					Error: &dto.ErrorInfo{
+						Code:    "SYSTEM_ROLE",
+						Message: "Built-in roles keep their default permissions",
+						Details: gin.H{"missing": missing},
+					},
+				})
+				return
+			}
+		}
+	}
+
+	before := convertRoleToResponse(role)
+
+	err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if req.Description != nil {
+			role.Description = *req.Description
+		}
+		if err := tx.Model(role).Updates(map[string]interface{}{
+			"description": role.Description,
+			"updatedAt":   time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if req.Permissions != nil {
+			if err := tx.Where(models.RolePermissionCol.RoleID+" = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+				return err
+			}
+			role.Permissions = nil
+			for _, p := range permission.NewSet(*req.Permissions...).List() {
+				role.Permissions = append(role.Permissions, models.RolePermission{RoleID: role.ID, Permission: p})
+			}
+			if len(role.Permissions) > 0 {
+				if err := tx.Create(&role.Permissions).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.Role{}.TableName(),
+			EntityID:   role.ID,
+			Before:     before,
+			After:      convertRoleToResponse(role),
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to update role %d: %v", role.ID, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while updating the role",
+			},
+		})
+		return
+	}
+	h.permissions.Invalidate()
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    convertRoleToResponse(role),
+	})
+}
+
+// Delete - DELETE /v1/roles/:id
+// System roles and roles still assigned to users or API keys cannot be deleted.
+func (h *RoleHandler) Delete(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "SYSTEM_ROLE",
+				Message: "Built-in roles cannot be deleted",
+			},
+		})
+		return
+	}
+
+	var users, keys int64
+	h.db.WithContext(c.Request.Context()).Model(&models.User{}).Where("role = ?", role.Name).Count(&users)
+	h.db.WithContext(c.Request.Context()).Model(&models.APIKey{}).Where("role = ?", role.Name).Count(&keys)
+	if users > 0 || keys > 0 {
+		c.JSON(http.StatusConflict, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "ROLE_IN_USE",
+				Message: "Role is still assigned",
+				Details: gin.H{"users": users, "apiKeys": keys},
+			},
+		})
+		return
+	}
+
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("Permissions").Delete(role).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: models.Role{}.TableName(),
+			EntityID:   role.ID,
+			Before:     convertRoleToResponse(role),
+		})
+	}); err != nil {
+		log.Printf("Failed to delete role %d: %v", role.ID, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while deleting the role",
+			},
+		})
+		return
+	}
+	h.permissions.Invalidate()
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    gin.H{"message": "Role deleted successfully"},
+	})
+}
@@ -0,0 +1,41 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/middleware"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// readDB returns db bound to the request for a read. Soft-deleted rows are left out
+// unless the caller passed includeDeleted=true and holds permission.DeletedRead.
+func readDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	db = db.WithContext(c.Request.Context())
+	if middleware.IncludeDeleted(c) {
+		return db.Unscoped()
+	}
+	return db
+}
+
+// formatDeletedAt renders a soft-delete timestamp for a response, nil when the row is live.
+func formatDeletedAt(deletedAt gorm.DeletedAt) *string {
+	if !deletedAt.Valid {
+		return nil
+	}
+	formatted := deletedAt.Time.Format(time.RFC3339)
+	return &formatted
+}
+
+// respondNotDeleted writes the 400 returned when restoring a row that is not deleted.
+func respondNotDeleted(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "NOT_DELETED",
+			Message: message,
+		},
+	})
+}
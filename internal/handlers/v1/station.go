@@ -1,8 +1,10 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/internal/models"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -19,10 +21,42 @@ func NewStationHandler(db *gorm.DB) *StationHandler {
 	return &StationHandler{db: db}
 }
 
+// convertStationToResponse converts a Station, with its OperationCenter preloaded, to its DTO.
+func convertStationToResponse(station *models.Station) dto.StationResponse {
+	response := dto.StationResponse{
+		ID:          station.ID,
+		Name:        station.Name,
+		CodeName:    station.CodeName,
+		OperationID: station.OperationID,
+		Latitude:    floatCoordinate(station.Latitude),
+		Longitude:   floatCoordinate(station.Longitude),
+		Version:     station.Version,
+		DeletedAt:   formatDeletedAt(station.DeletedAt),
+	}
+	if station.OperationCenter != nil {
+		response.OperationCenter = &dto.OperationCenterNested{
+			ID:   station.OperationCenter.ID,
+			Name: station.OperationCenter.Name,
+		}
+	}
+	return response
+}
+
+// currentStation loads the station as GET /v1/stations/:id shows it, for a 412 response.
+func (h *StationHandler) currentStation(c *gin.Context, id int64) currentLoader {
+	return func() (interface{}, int, error) {
+		var station models.Station
+		if err := h.db.WithContext(c.Request.Context()).Preload("OperationCenter").First(&station, id).Error; err != nil {
+			return nil, 0, err
+		}
+		return convertStationToResponse(&station), station.Version, nil
+	}
+}
+
 // List retrieves all stations with their operation center information.
 func (h *StationHandler) List(c *gin.Context) {
 	var stations []models.Station
-	if err := h.db.WithContext(c.Request.Context()).Preload("OperationCenter").Find(&stations).Error; err != nil {
+	if err := readDB(c, h.db).Preload("OperationCenter").Find(&stations).Error; err != nil {
 		log.Printf("Failed to fetch stations: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -35,20 +69,8 @@ func (h *StationHandler) List(c *gin.Context) {
 	}
 
 	var response []dto.StationResponse
-	for _, s := range stations {
-		stationResp := dto.StationResponse{
-			ID:          s.ID,
-			Name:        s.Name,
-			CodeName:    s.CodeName,
-			OperationID: s.OperationID,
-		}
-		if s.OperationCenter != nil {
-			stationResp.OperationCenter = &dto.OperationCenterNested{
-				ID:   s.OperationCenter.ID,
-				Name: s.OperationCenter.Name,
-			}
-		}
-		response = append(response, stationResp)
+	for i := range stations {
+		response = append(response, convertStationToResponse(&stations[i]))
 	}
 
 	c.JSON(http.StatusOK, dto.StandardResponse{
@@ -72,7 +94,7 @@ func (h *StationHandler) GetByID(c *gin.Context) {
 	}
 
 	var station models.Station
-	if err := h.db.WithContext(c.Request.Context()).Preload("OperationCenter").First(&station, id).Error; err != nil {
+	if err := readDB(c, h.db).Preload("OperationCenter").First(&station, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -94,31 +116,24 @@ func (h *StationHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	response := dto.StationResponse{
-		ID:          station.ID,
-		Name:        station.Name,
-		CodeName:    station.CodeName,
-		OperationID: station.OperationID,
-	}
-	if station.OperationCenter != nil {
-		response.OperationCenter = &dto.OperationCenterNested{
-			ID:   station.OperationCenter.ID,
-			Name: station.OperationCenter.Name,
-		}
-	}
+	response := convertStationToResponse(&station)
 
+	setETag(c, station.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
 
-// Create creates a new station with the provided name, code name, and operation ID.
+// Create creates a new station with the provided name, code name, operation ID and
+// optional coordinates.
 func (h *StationHandler) Create(c *gin.Context) {
 	var req struct {
-		Name        string `json:"name" binding:"required"`
-		CodeName    string `json:"codeName" binding:"required"`
-		OperationID int64  `json:"operationId" binding:"required"`
+		Name        string   `json:"name" binding:"required"`
+		CodeName    string   `json:"codeName" binding:"required"`
+		OperationID int64    `json:"operationId" binding:"required"`
+		Latitude    *float64 `json:"latitude"`
+		Longitude   *float64 `json:"longitude"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.StandardResponse{
@@ -131,12 +146,31 @@ func (h *StationHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if msg := validateCoordinates(req.Latitude, req.Longitude); msg != "" {
+		respondInvalidCoordinates(c, msg)
+		return
+	}
+
 	station := models.Station{
 		Name:        req.Name,
 		CodeName:    req.CodeName,
 		OperationID: req.OperationID,
 	}
-	if err := h.db.WithContext(c.Request.Context()).Create(&station).Error; err != nil {
+	if req.Latitude != nil {
+		station.Latitude = decimalCoordinate(*req.Latitude)
+		station.Longitude = decimalCoordinate(*req.Longitude)
+	}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&station).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.Station{}.TableName(),
+			EntityID:   station.ID,
+			After:      &station,
+		})
+	}); err != nil {
 		log.Printf("Failed to create station: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -151,26 +185,17 @@ func (h *StationHandler) Create(c *gin.Context) {
 	// Reload with relations
 	h.db.WithContext(c.Request.Context()).Preload("OperationCenter").First(&station, station.ID)
 
-	response := dto.StationResponse{
-		ID:          station.ID,
-		Name:        station.Name,
-		CodeName:    station.CodeName,
-		OperationID: station.OperationID,
-	}
-	if station.OperationCenter != nil {
-		response.OperationCenter = &dto.OperationCenterNested{
-			ID:   station.OperationCenter.ID,
-			Name: station.OperationCenter.Name,
-		}
-	}
+	response := convertStationToResponse(&station)
 
+	setETag(c, station.Version)
 	c.JSON(http.StatusCreated, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
 
-// Update updates an existing station's name, code name, and/or operation ID.
+// Update updates an existing station's name, code name, operation ID and/or coordinates.
+// "clearLocation": true removes the coordinates.
 func (h *StationHandler) Update(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -208,9 +233,12 @@ func (h *StationHandler) Update(c *gin.Context) {
 	}
 
 	var req struct {
-		Name        string `json:"name"`
-		CodeName    string `json:"codeName"`
-		OperationID int64  `json:"operationId"`
+		Name          string   `json:"name"`
+		CodeName      string   `json:"codeName"`
+		OperationID   int64    `json:"operationId"`
+		Latitude      *float64 `json:"latitude"`
+		Longitude     *float64 `json:"longitude"`
+		ClearLocation bool     `json:"clearLocation"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.StandardResponse{
@@ -223,6 +251,17 @@ func (h *StationHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if msg := validateCoordinates(req.Latitude, req.Longitude); msg != "" {
+		respondInvalidCoordinates(c, msg)
+		return
+	}
+
+	if !requireIfMatch(c, station.Version, h.currentStation(c, id)) {
+		return
+	}
+
+	before := station
+
 	if req.Name != "" {
 		station.Name = req.Name
 	}
@@ -232,8 +271,31 @@ func (h *StationHandler) Update(c *gin.Context) {
 	if req.OperationID != 0 {
 		station.OperationID = req.OperationID
 	}
+	if req.Latitude != nil {
+		station.Latitude = decimalCoordinate(*req.Latitude)
+		station.Longitude = decimalCoordinate(*req.Longitude)
+	} else if req.ClearLocation {
+		station.Latitude = nil
+		station.Longitude = nil
+	}
 
-	if err := h.db.WithContext(c.Request.Context()).Save(&station).Error; err != nil {
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &station, &station.Version); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.Station{}.TableName(),
+			EntityID:   station.ID,
+			Before:     &before,
+			After:      &station,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentStation(c, id))
+		return
+	}
+	if err != nil {
 		log.Printf("Failed to update station %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -248,26 +310,17 @@ func (h *StationHandler) Update(c *gin.Context) {
 	// Reload with relations
 	h.db.WithContext(c.Request.Context()).Preload("OperationCenter").First(&station, station.ID)
 
-	response := dto.StationResponse{
-		ID:          station.ID,
-		Name:        station.Name,
-		CodeName:    station.CodeName,
-		OperationID: station.OperationID,
-	}
-	if station.OperationCenter != nil {
-		response.OperationCenter = &dto.OperationCenterNested{
-			ID:   station.OperationCenter.ID,
-			Name: station.OperationCenter.Name,
-		}
-	}
+	response := convertStationToResponse(&station)
 
+	setETag(c, station.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    response,
 	})
 }
 
-// Delete removes a station by ID.
+// Delete soft deletes a station by ID. Rows that reference it are handled by
+// ?strategy=block|reassign|cascade (see deleteReference); ?dryRun=true only reports them.
 func (h *StationHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -281,29 +334,118 @@ func (h *StationHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.WithContext(c.Request.Context()).Delete(&models.Station{}, id)
-	if result.Error != nil {
-		log.Printf("Failed to delete station %d: %v", id, result.Error)
+	var before models.Station
+	if err := h.db.WithContext(c.Request.Context()).First(&before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Station not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch station %d for deletion: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
 				Code:    "INTERNAL_ERROR",
-				Message: "An error occurred while deleting the station",
+				Message: "An error occurred while fetching the station",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, dto.StandardResponse{
+	deleteReference(c, h.db, &before, id, &before.Version, h.currentStation(c, id))
+}
+
+// Restore restores a soft-deleted station by ID.
+func (h *StationHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
-				Code:    "NOT_FOUND",
-				Message: "Station not found",
+				Code:    "INVALID_ID",
+				Message: "Invalid station ID",
 			},
 		})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	var station models.Station
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&station, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Station not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch station %d for restore: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the station",
+			},
+		})
+		return
+	}
+
+	if !station.DeletedAt.Valid {
+		respondNotDeleted(c, "Station is not deleted")
+		return
+	}
+
+	before := station
+
+	station.DeletedAt = gorm.DeletedAt{}
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx.Unscoped(), &station, &station.Version); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.Station{}.TableName(),
+			EntityID:   station.ID,
+			Before:     &before,
+			After:      &station,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentStation(c, id))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to restore station %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the station",
+			},
+		})
+		return
+	}
+
+	// Reload with relations
+	h.db.WithContext(c.Request.Context()).Preload("OperationCenter").First(&station, station.ID)
+
+	setETag(c, station.Version)
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    convertStationToResponse(&station),
+	})
+}
+
+// Merge - POST /v1/stations/merge
+// Merges duplicate stations: rows referencing any of sourceIds are moved to targetId and the
+// sources are soft-deleted (see mergeReferences).
+func (h *StationHandler) Merge(c *gin.Context) {
+	mergeReferences(c, h.db, models.Station{}.TableName())
 }
@@ -1,8 +1,14 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/geo"
+	"backend-hotlines3/internal/middleware"
 	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -11,6 +17,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// taskNearDefaultRadiusKm is the search radius of ?near= when radiusKm is not given.
+	taskNearDefaultRadiusKm = 5.0
+	// taskNearMaxRadiusKm caps radiusKm; it spans the whole of Thailand.
+	taskNearMaxRadiusKm = 1500.0
 )
 
 type TaskHandler struct {
@@ -37,6 +51,15 @@ func convertTaskToResponse(task *models.TaskDaily) dto.TaskResponse {
 		URLsAfter:   []string(task.URLsAfter),
 		CreatedAt:   task.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:   task.UpdatedAt.Format(time.RFC3339),
+		Version:     task.Version,
+
+		Status:          task.Status,
+		SubmittedAt:     formatTaskTime(task.SubmittedAt),
+		SubmittedByID:   task.SubmittedByID,
+		ReviewedByID:    task.ReviewedByID,
+		ReviewedByName:  task.ReviewedByName,
+		ReviewedAt:      formatTaskTime(task.ReviewedAt),
+		RejectionReason: task.RejectionReason,
 	}
 
 	// Handle coordinates
@@ -50,10 +73,7 @@ func convertTaskToResponse(task *models.TaskDaily) dto.TaskResponse {
 	}
 
 	// Handle deleted_at
-	if task.DeletedAt != nil {
-		formatted := task.DeletedAt.Format(time.RFC3339)
-		response.DeletedAt = &formatted
-	}
+	response.DeletedAt = formatDeletedAt(task.DeletedAt)
 
 	// Handle relations
 	if task.Team != nil {
@@ -98,7 +118,175 @@ func convertTaskToResponse(task *models.TaskDaily) dto.TaskResponse {
 	return response
 }
 
+// canWriteTeamTask reports whether the authenticated caller may create, edit or delete
+// tasks belonging to teamID: anywhere with task:write:any-team, otherwise only within
+// the team carried in their token with task:write:own-team.
+func canWriteTeamTask(c *gin.Context, teamID int64) bool {
+	perms := middleware.Permissions(c)
+	if perms.Has(permission.TaskWriteAnyTeam) {
+		return true
+	}
+	if !perms.Has(permission.TaskWriteOwnTeam) {
+		return false
+	}
+	callerTeam, _ := c.Get("team_id")
+	id, ok := callerTeam.(*int64)
+	return ok && id != nil && *id == teamID
+}
+
+// respondTeamForbidden writes the 403 returned when canWriteTeamTask fails.
+func respondTeamForbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "TEAM_FORBIDDEN",
+			Message: "You can only modify tasks of your own team",
+		},
+	})
+}
+
+// currentTask loads the task as GET /v1/tasks/:id shows it, for a 412 response.
+func (h *TaskHandler) currentTask(c *gin.Context, id int64) currentLoader {
+	return func() (interface{}, int, error) {
+		var task models.TaskDaily
+		if err := h.db.WithContext(c.Request.Context()).
+			Preload("Team").
+			Preload("JobType").
+			Preload("JobDetail").
+			Preload("Feeder.Station.OperationCenter").
+			First(&task, id).Error; err != nil {
+			return nil, 0, err
+		}
+		return convertTaskToResponse(&task), task.Version, nil
+	}
+}
+
+// taskFilters applies the task list filters from the query string: workDate, teamId,
+// jobTypeId, feederId and status, plus year and month as accepted by ListByFilter.
+func taskFilters(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if workDate := c.Query("workDate"); workDate != "" {
+			parsedDate, _ := time.Parse("2006-01-02", workDate)
+			query = query.Where("WorkDate = ?", parsedDate)
+		}
+		if teamID := c.Query("teamId"); teamID != "" {
+			id, _ := strconv.ParseInt(teamID, 10, 64)
+			query = query.Where(models.TaskCol.TeamID+" = ?", id)
+		}
+		if jobTypeID := c.Query("jobTypeId"); jobTypeID != "" {
+			id, _ := strconv.ParseInt(jobTypeID, 10, 64)
+			query = query.Where(models.TaskCol.JobTypeID+" = ?", id)
+		}
+		if feederID := c.Query("feederId"); feederID != "" {
+			id, _ := strconv.ParseInt(feederID, 10, 64)
+			query = query.Where(models.TaskCol.FeederID+" = ?", id)
+		}
+		query = query.Scopes(models.TaskByStatus(c.Query("status")))
+		if year := c.Query("year"); year != "" {
+			y, _ := strconv.Atoi(year)
+			query = query.Where("EXTRACT(YEAR FROM WorkDate) = ?", y)
+		}
+		if month := c.Query("month"); month != "" {
+			m, _ := strconv.Atoi(month)
+			query = query.Where("EXTRACT(MONTH FROM WorkDate) = ?", m)
+		}
+		return query
+	}
+}
+
+// taskLocationQuery is the location filter of the task list: ?bbox=minLng,minLat,maxLng,maxLat
+// and/or ?near=lat,lng with ?radiusKm=. Both may be combined.
+type taskLocationQuery struct {
+	bbox     *geo.BBox
+	near     bool
+	lat, lng float64
+	radiusKm float64
+}
+
+// parseTaskLocationQuery reads the location filter; it returns nil when none is given.
+func parseTaskLocationQuery(c *gin.Context) (*taskLocationQuery, error) {
+	q := &taskLocationQuery{}
+	if bbox := c.Query("bbox"); bbox != "" {
+		b, err := geo.ParseBBox(bbox)
+		if err != nil {
+			return nil, err
+		}
+		q.bbox = &b
+	}
+	if near := c.Query("near"); near != "" {
+		lat, lng, err := geo.ParsePoint(near)
+		if err != nil {
+			return nil, err
+		}
+		q.near, q.lat, q.lng, q.radiusKm = true, lat, lng, taskNearDefaultRadiusKm
+		if radius := c.Query("radiusKm"); radius != "" {
+			r, err := strconv.ParseFloat(radius, 64)
+			if err != nil || !(r > 0 && r <= taskNearMaxRadiusKm) {
+				return nil, fmt.Errorf("radiusKm must be a number greater than 0 and at most %g", taskNearMaxRadiusKm)
+			}
+			q.radiusKm = r
+		}
+	} else if c.Query("radiusKm") != "" {
+		return nil, errors.New("radiusKm requires near=lat,lng")
+	}
+	if q.bbox == nil && !q.near {
+		return nil, nil
+	}
+	return q, nil
+}
+
+// scope applies the location filter. It is a no-op on a nil query.
+func (q *taskLocationQuery) scope(db *gorm.DB) *gorm.DB {
+	if q == nil {
+		return db
+	}
+	if q.bbox != nil {
+		db = db.Scopes(models.TaskInBBox(*q.bbox))
+	}
+	if q.near {
+		db = db.Scopes(models.TaskNear(q.lat, q.lng, q.radiusKm))
+	}
+	return db
+}
+
+// order sorts nearest first when ?near= is given and by work date otherwise.
+func (q *taskLocationQuery) order(db *gorm.DB) *gorm.DB {
+	if q == nil || !q.near {
+		return db.Order("WorkDate DESC, CreatedAt DESC")
+	}
+	return db.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                models.TaskDistanceSQL() + " ASC, WorkDate DESC, CreatedAt DESC",
+		Vars:               geo.DistanceArgs(q.lat, q.lng),
+		WithoutParentheses: true,
+	}})
+}
+
+// distanceKm returns the distance from the ?near= point to task, or nil.
+func (q *taskLocationQuery) distanceKm(task *models.TaskDaily) *float64 {
+	if q == nil || !q.near || task.Latitude == nil || task.Longitude == nil {
+		return nil
+	}
+	lat, _ := task.Latitude.Float64()
+	lng, _ := task.Longitude.Float64()
+	d := geo.DistanceKm(q.lat, q.lng, lat, lng)
+	return &d
+}
+
+// respondInvalidLocationFilter writes the 400 returned when parseTaskLocationQuery fails.
+func respondInvalidLocationFilter(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "INVALID_LOCATION_FILTER",
+			Message: err.Error(),
+		},
+	})
+}
+
 // List - GET /v1/tasks
+// Besides the taskFilters, ?bbox=minLng,minLat,maxLng,maxLat keeps tasks inside the box
+// and ?near=lat,lng&radiusKm= keeps tasks within the radius (default 5 km), sorted
+// nearest first with distanceKm set on each task.
 func (h *TaskHandler) List(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -111,27 +299,15 @@ func (h *TaskHandler) List(c *gin.Context) {
 	}
 	offset := (page - 1) * limit
 
-	// Build query
-	query := h.db.Model(&models.TaskDaily{})
-
-	// Apply filters
-	if workDate := c.Query("workDate"); workDate != "" {
-		parsedDate, _ := time.Parse("2006-01-02", workDate)
-		query = query.Where("WorkDate = ?", parsedDate)
-	}
-	if teamID := c.Query("teamId"); teamID != "" {
-		id, _ := strconv.ParseInt(teamID, 10, 64)
-		query = query.Where(models.TaskCol.TeamID+" = ?", id)
-	}
-	if jobTypeID := c.Query("jobTypeId"); jobTypeID != "" {
-		id, _ := strconv.ParseInt(jobTypeID, 10, 64)
-		query = query.Where(models.TaskCol.JobTypeID+" = ?", id)
-	}
-	if feederID := c.Query("feederId"); feederID != "" {
-		id, _ := strconv.ParseInt(feederID, 10, 64)
-		query = query.Where(models.TaskCol.FeederID+" = ?", id)
+	location, err := parseTaskLocationQuery(c)
+	if err != nil {
+		respondInvalidLocationFilter(c, err)
+		return
 	}
 
+	// Build query
+	query := readDB(c, h.db).Model(&models.TaskDaily{}).Scopes(taskFilters(c), location.scope)
+
 	// Get total count
 	var total int64
 	query.Count(&total)
@@ -143,7 +319,7 @@ func (h *TaskHandler) List(c *gin.Context) {
 		Preload("JobType").
 		Preload("JobDetail").
 		Preload("Feeder.Station.OperationCenter").
-		Order("WorkDate DESC, CreatedAt DESC").
+		Scopes(location.order).
 		Offset(offset).
 		Limit(limit).
 		Find(&tasks).Error; err != nil {
@@ -161,7 +337,9 @@ func (h *TaskHandler) List(c *gin.Context) {
 	// Convert to response
 	var response []dto.TaskResponse
 	for _, task := range tasks {
-		response = append(response, convertTaskToResponse(&task))
+		item := convertTaskToResponse(&task)
+		item.DistanceKm = location.distanceKm(&task)
+		response = append(response, item)
 	}
 
 	c.JSON(http.StatusOK, dto.StandardResponse{
@@ -176,6 +354,8 @@ func (h *TaskHandler) List(c *gin.Context) {
 }
 
 // GetByID - GET /v1/tasks/:id
+// The ETag header carries the task version; send it as If-Match on PUT/DELETE.
+// With ?asOf= the task is shown as it was at that time (see getTaskAsOf).
 func (h *TaskHandler) GetByID(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -189,8 +369,13 @@ func (h *TaskHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	if asOf := c.Query(middleware.AsOfParam); asOf != "" {
+		h.getTaskAsOf(c, id, asOf)
+		return
+	}
+
 	var task models.TaskDaily
-	if err := h.db.
+	if err := readDB(c, h.db).
 		Preload("Team").
 		Preload("JobType").
 		Preload("JobDetail").
@@ -206,6 +391,7 @@ func (h *TaskHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	setETag(c, task.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    convertTaskToResponse(&task),
@@ -213,6 +399,9 @@ func (h *TaskHandler) GetByID(c *gin.Context) {
 }
 
 // Create - POST /v1/tasks
+// The task starts as a draft, or as submitted when the request sets submit.
+// When the task's coordinates lie far outside the usual area of the chosen feeder the
+// task is still created and the response carries a feederWarning with alternatives.
 func (h *TaskHandler) Create(c *gin.Context) {
 	var req dto.CreateTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -239,6 +428,16 @@ func (h *TaskHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if msg := validateCoordinates(req.Latitude, req.Longitude); msg != "" {
+		respondInvalidCoordinates(c, msg)
+		return
+	}
+
+	if !canWriteTeamTask(c, req.TeamID) {
+		respondTeamForbidden(c)
+		return
+	}
+
 	now := time.Now()
 	task := models.TaskDaily{
 		WorkDate:    workDate,
@@ -253,6 +452,12 @@ func (h *TaskHandler) Create(c *gin.Context) {
 		URLsAfter:   models.StringArray(req.URLsAfter),
 		CreatedAt:   now,
 		UpdatedAt:   now,
+		Status:      models.TaskStatusDraft,
+	}
+	if req.Submit {
+		task.Status = models.TaskStatusSubmitted
+		task.SubmittedAt = &now
+		task.SubmittedByID = auditActor(c).UserID
 	}
 
 	// Handle coordinates
@@ -263,7 +468,27 @@ func (h *TaskHandler) Create(c *gin.Context) {
 		task.Longitude = &lng
 	}
 
-	if err := h.db.WithContext(c.Request.Context()).Create(&task).Error; err != nil {
+	// Flag a feeder that is implausibly far from the reported position
+	var feederWarning *dto.FeederWarning
+	if req.FeederID != nil && req.Latitude != nil && req.Longitude != nil {
+		feederWarning = checkTaskFeeder(c.Request.Context(), h.db, *req.FeederID, *req.Latitude, *req.Longitude)
+	}
+
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		if err := recordTaskRevision(c, tx, nil, &task, audit.ActionCreate, nil); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.TaskDaily{}.TableName(),
+			EntityID:   task.ID,
+			After:      &task,
+		})
+	})
+	if err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -283,13 +508,18 @@ func (h *TaskHandler) Create(c *gin.Context) {
 		Preload("Feeder.Station.OperationCenter").
 		First(&task, task.ID)
 
+	response := convertTaskToResponse(&task)
+	response.FeederWarning = feederWarning
+
+	setETag(c, task.Version)
 	c.JSON(http.StatusCreated, dto.StandardResponse{
 		Success: true,
-		Data:    convertTaskToResponse(&task),
+		Data:    response,
 	})
 }
 
 // Update - PUT /v1/tasks/:id
+// Editing a task under review or approved makes it corrected, submitted by the editor.
 func (h *TaskHandler) Update(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -327,6 +557,37 @@ func (h *TaskHandler) Update(c *gin.Context) {
 		return
 	}
 
+	// A coordinate left out of the request keeps its stored value, so one of them can
+	// be corrected on its own
+	lat, lng := req.Latitude, req.Longitude
+	if lat == nil && lng != nil {
+		lat = floatCoordinate(task.Latitude)
+	}
+	if lng == nil && lat != nil {
+		lng = floatCoordinate(task.Longitude)
+	}
+	if msg := validateCoordinates(lat, lng); msg != "" {
+		respondInvalidCoordinates(c, msg)
+		return
+	}
+
+	// Caller must own both the current team and, when moving the task, the new one
+	if !canWriteTeamTask(c, task.TeamID) || (req.TeamID != nil && !canWriteTeamTask(c, *req.TeamID)) {
+		respondTeamForbidden(c)
+		return
+	}
+
+	if !canEditTaskInStatus(c, task.Status) {
+		respondTaskLocked(c, task.Status)
+		return
+	}
+
+	if !requireIfMatch(c, task.Version, h.currentTask(c, id)) {
+		return
+	}
+
+	before := task
+
 	// Update fields if provided
 	if req.WorkDate != nil {
 		workDate, err := time.Parse("2006-01-02", *req.WorkDate)
@@ -369,16 +630,37 @@ func (h *TaskHandler) Update(c *gin.Context) {
 	if req.URLsAfter != nil {
 		task.URLsAfter = models.StringArray(req.URLsAfter)
 	}
-	if req.Latitude != nil && req.Longitude != nil {
-		lat := decimal.NewFromFloat(*req.Latitude)
-		lng := decimal.NewFromFloat(*req.Longitude)
-		task.Latitude = &lat
-		task.Longitude = &lng
+	if lat != nil && lng != nil {
+		latitude := decimal.NewFromFloat(*lat)
+		longitude := decimal.NewFromFloat(*lng)
+		task.Latitude = &latitude
+		task.Longitude = &longitude
 	}
 
-	task.UpdatedAt = time.Now()
+	now := time.Now()
+	reopenEditedTask(c, &task, now)
+	task.UpdatedAt = now
 
-	if err := h.db.WithContext(c.Request.Context()).Save(&task).Error; err != nil {
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &task, &task.Version); err != nil {
+			return err
+		}
+		if err := recordTaskRevision(c, tx, &before, &task, audit.ActionUpdate, nil); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.TaskDaily{}.TableName(),
+			EntityID:   task.ID,
+			Before:     &before,
+			After:      &task,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentTask(c, id))
+		return
+	}
+	if err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -398,6 +680,7 @@ func (h *TaskHandler) Update(c *gin.Context) {
 		Preload("Feeder.Station.OperationCenter").
 		First(&task, task.ID)
 
+	setETag(c, task.Version)
 	c.JSON(http.StatusOK, dto.StandardResponse{
 		Success: true,
 		Data:    convertTaskToResponse(&task),
@@ -430,10 +713,43 @@ func (h *TaskHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	if !canWriteTeamTask(c, task.TeamID) {
+		respondTeamForbidden(c)
+		return
+	}
+
+	if !canEditTaskInStatus(c, task.Status) {
+		respondTaskLocked(c, task.Status)
+		return
+	}
+
+	if !requireIfMatch(c, task.Version, h.currentTask(c, id)) {
+		return
+	}
+
+	before := task
+
 	// Soft delete
-	now := time.Now()
-	task.DeletedAt = &now
-	if err := h.db.WithContext(c.Request.Context()).Save(&task).Error; err != nil {
+	task.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &task, &task.Version); err != nil {
+			return err
+		}
+		if err := recordTaskRevision(c, tx, &before, &task, audit.ActionDelete, nil); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: models.TaskDaily{}.TableName(),
+			EntityID:   task.ID,
+			Before:     &before,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentTask(c, id))
+		return
+	}
+	if err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -448,6 +764,87 @@ func (h *TaskHandler) Delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// Restore - POST /v1/tasks/:id/restore
+// Brings a soft-deleted task back and records it as a new revision.
+func (h *TaskHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	var task models.TaskDaily
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&task, id).Error; err != nil {
+		respondTaskNotFound(c)
+		return
+	}
+
+	if !task.DeletedAt.Valid {
+		respondNotDeleted(c, "Task is not deleted")
+		return
+	}
+
+	if !canWriteTeamTask(c, task.TeamID) {
+		respondTeamForbidden(c)
+		return
+	}
+
+	before := task
+
+	task.DeletedAt = gorm.DeletedAt{}
+	task.UpdatedAt = time.Now()
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx.Unscoped(), &task, &task.Version); err != nil {
+			return err
+		}
+		if err := recordTaskRevision(c, tx, &before, &task, audit.ActionRestore, nil); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.TaskDaily{}.TableName(),
+			EntityID:   task.ID,
+			Before:     &before,
+			After:      &task,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentTask(c, id))
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the task",
+			},
+		})
+		return
+	}
+
+	// Reload with relations
+	h.db.
+		Preload("Team").
+		Preload("JobType").
+		Preload("JobDetail").
+		Preload("Feeder.Station.OperationCenter").
+		First(&task, task.ID)
+
+	setETag(c, task.Version)
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    convertTaskToResponse(&task),
+	})
+}
+
 // ListByFilter - GET /v1/tasks/by-filter
 func (h *TaskHandler) ListByFilter(c *gin.Context) {
 	year := c.Query("year")
@@ -465,7 +862,7 @@ func (h *TaskHandler) ListByFilter(c *gin.Context) {
 	}
 
 	// Build query
-	query := h.db.Model(&models.TaskDaily{}).
+	query := readDB(c, h.db).Model(&models.TaskDaily{}).
 		Where("EXTRACT(YEAR FROM WorkDate) = ?", year).
 		Where("EXTRACT(MONTH FROM WorkDate) = ?", month)
 
@@ -529,7 +926,7 @@ func (h *TaskHandler) ListByFilter(c *gin.Context) {
 func (h *TaskHandler) ListByTeam(c *gin.Context) {
 	// Get tasks
 	var tasks []models.TaskDaily
-	if err := h.db.
+	if err := readDB(c, h.db).
 		Preload("Team").
 		Preload("JobType").
 		Preload("JobDetail").
@@ -0,0 +1,682 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/xuri/excelize/v2"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+const (
+	taskExportBatchSize  = 500
+	taskExportPDFMaxRows = 500
+	taskExportPhotoMax   = 10 * 1024 * 1024
+	taskExportThumbPx    = 320
+	taskExportThumbsEach = 3
+	// taskExportPhotoPixels caps the size of a photo decoded for a thumbnail (about a
+	// 48 MP camera), so a small file with huge dimensions cannot exhaust memory.
+	taskExportPhotoPixels = 50_000_000
+	// taskExportPhotoWorkers photos are fetched at a time, and all of them within
+	// taskExportPhotoDeadline; photos not ready by then are left out of the PDF.
+	taskExportPhotoWorkers  = 8
+	taskExportPhotoDeadline = 30 * time.Second
+)
+
+// taskExportHeaders are the Thai column headers of CSV and XLSX exports: the import
+// columns (so an export can be edited and imported again) framed by the task ID and
+// the feeder's station and operation center.
+var taskExportHeaders = func() []string {
+	headers := []string{"รหัส"}
+	for _, col := range taskSheetColumns {
+		headers = append(headers, col.thai)
+	}
+	return append(headers, "สถานีไฟฟ้า", "ศูนย์ปฏิบัติการ")
+}()
+
+type TaskExportHandler struct {
+	db            *gorm.DB
+	pdfFont       []byte
+	photoPrefix   string
+	client        *http.Client
+	photoDeadline time.Duration
+}
+
+// NewTaskExportHandler creates the export handler. Without a readable PDF font the CSV
+// and XLSX exports still work and PDF requests answer 503.
+func NewTaskExportHandler(cfg *config.Config, db *gorm.DB) *TaskExportHandler {
+	h := &TaskExportHandler{
+		db:            db,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		photoDeadline: taskExportPhotoDeadline,
+	}
+	if publicURL := strings.TrimSuffix(cfg.Cloudflare.R2.PublicURL, "/"); publicURL != "" {
+		h.photoPrefix = publicURL + "/"
+	}
+
+	if cfg.Export.PDFFont != "" {
+		font, err := os.ReadFile(cfg.Export.PDFFont)
+		if err != nil {
+			log.Printf("Warning: PDF font for task export failed to load: %v", err)
+		} else {
+			h.pdfFont = font
+		}
+	}
+	return h
+}
+
+// Export - GET /v1/tasks/export?format=csv|xlsx|pdf
+// Exports the tasks matching the List filters (workDate, teamId, jobTypeId, feederId,
+// year, month, bbox, near/radiusKm). CSV is streamed as it is read; XLSX is built with a streaming writer.
+// PDF is a printable sheet per team and day with before/after photo thumbnails and is
+// limited to taskExportPDFMaxRows tasks.
+func (h *TaskExportHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_FORMAT",
+				Message: "format must be one of csv, xlsx, pdf",
+			},
+		})
+		return
+	}
+
+	query, ok := h.exportQuery(c)
+	if !ok {
+		return
+	}
+	filename := taskExportFilename(c, format)
+
+	switch format {
+	case "csv":
+		h.exportCSV(c, query, filename)
+	case "xlsx":
+		h.exportXLSX(c, query, filename)
+	case "pdf":
+		h.exportPDF(c, query, filename)
+	}
+}
+
+// exportQuery builds the query of the tasks matching the List filters. It writes the
+// 400 and reports false when the location filter is invalid.
+func (h *TaskExportHandler) exportQuery(c *gin.Context) (*gorm.DB, bool) {
+	location, err := parseTaskLocationQuery(c)
+	if err != nil {
+		respondInvalidLocationFilter(c, err)
+		return nil, false
+	}
+	return readDB(c, h.db).Model(&models.TaskDaily{}).Scopes(taskFilters(c), location.scope).Session(&gorm.Session{}), true
+}
+
+// taskExportFilename names the file after the date and team filters, e.g.
+// tasks-2026-10-team3.xlsx, or after today when there is no date filter.
+func taskExportFilename(c *gin.Context, ext string) string {
+	name := "tasks"
+	switch {
+	case c.Query("workDate") != "":
+		name += "-" + c.Query("workDate")
+	case c.Query("year") != "" && c.Query("month") != "":
+		month, _ := strconv.Atoi(c.Query("month"))
+		name += fmt.Sprintf("-%s-%02d", c.Query("year"), month)
+	case c.Query("year") != "":
+		name += "-" + c.Query("year")
+	default:
+		name += "-" + time.Now().Format("20060102")
+	}
+	if teamID := c.Query("teamId"); teamID != "" {
+		id, _ := strconv.ParseInt(teamID, 10, 64)
+		name += fmt.Sprintf("-team%d", id)
+	}
+	return name + "." + ext
+}
+
+func setAttachment(c *gin.Context, contentType, filename string) {
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Cache-Control", "private, no-store")
+}
+
+// eachTaskBatch reads the tasks of query in work date order, taskExportBatchSize at a
+// time, so an export never holds more than one batch.
+func eachTaskBatch(query *gorm.DB, fn func([]models.TaskDaily) error) error {
+	var lastDate time.Time
+	var lastID int64
+	for first := true; ; first = false {
+		q := query.
+			Preload("Team").
+			Preload("JobType").
+			Preload("JobDetail").
+			Preload("Feeder.Station.OperationCenter")
+		if !first {
+			q = q.Where("("+models.TaskCol.WorkDate+", id) > (?, ?)", lastDate, lastID)
+		}
+
+		var batch []models.TaskDaily
+		if err := q.Order(models.TaskCol.WorkDate + " ASC, id ASC").Limit(taskExportBatchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < taskExportBatchSize {
+			return nil
+		}
+		lastDate = batch[len(batch)-1].WorkDate
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// taskExportNames are the names of a task's preloaded relations, empty when missing.
+type taskExportNames struct {
+	team, jobType, jobDetail, feeder, station, operationCenter string
+}
+
+func namesOf(task *models.TaskDaily) taskExportNames {
+	var n taskExportNames
+	if task.Team != nil {
+		n.team = task.Team.Name
+	}
+	if task.JobType != nil {
+		n.jobType = task.JobType.Name
+	}
+	if task.JobDetail != nil {
+		n.jobDetail = task.JobDetail.Name
+	}
+	if task.Feeder != nil {
+		n.feeder = task.Feeder.Code
+		if task.Feeder.Station != nil {
+			n.station = task.Feeder.Station.Name
+			if task.Feeder.Station.OperationCenter != nil {
+				n.operationCenter = task.Feeder.Station.OperationCenter.Name
+			}
+		}
+	}
+	return n
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// taskExportRow returns the cells of task in taskExportHeaders order. Missing values
+// are empty strings; coordinates stay numbers for XLSX.
+func taskExportRow(task *models.TaskDaily) []interface{} {
+	n := namesOf(task)
+
+	var lat, lng interface{} = "", ""
+	if task.Latitude != nil {
+		lat, _ = task.Latitude.Float64()
+	}
+	if task.Longitude != nil {
+		lng, _ = task.Longitude.Float64()
+	}
+
+	return []interface{}{
+		task.ID,
+		task.WorkDate.Format("2006-01-02"),
+		n.team,
+		n.jobType,
+		n.jobDetail,
+		n.feeder,
+		stringOrEmpty(task.NumPole),
+		stringOrEmpty(task.DeviceCode),
+		stringOrEmpty(task.Detail),
+		lat,
+		lng,
+		strings.Join(task.URLsBefore, " "),
+		strings.Join(task.URLsAfter, " "),
+		n.station,
+		n.operationCenter,
+	}
+}
+
+func respondExportError(c *gin.Context, err error) {
+	log.Printf("Database error: %v", err)
+	c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "INTERNAL_ERROR",
+			Message: "An error occurred while exporting tasks",
+		},
+	})
+}
+
+// exportCSV streams a UTF-8 CSV with a byte order mark, so Excel shows the Thai text.
+// Once rows are sent an error can only cut the file short; it is logged.
+func (h *TaskExportHandler) exportCSV(c *gin.Context, query *gorm.DB, filename string) {
+	var w *csv.Writer
+	writeHeader := func() {
+		setAttachment(c, "text/csv; charset=utf-8", filename)
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("\xef\xbb\xbf")
+		w = csv.NewWriter(c.Writer)
+		w.Write(taskExportHeaders)
+	}
+
+	err := eachTaskBatch(query, func(batch []models.TaskDaily) error {
+		if w == nil {
+			writeHeader()
+		}
+		for i := range batch {
+			cells := taskExportRow(&batch[i])
+			record := make([]string, len(cells))
+			for j, v := range cells {
+				record[j] = fmt.Sprint(v)
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	})
+
+	switch {
+	case err != nil && w == nil:
+		respondExportError(c, err)
+	case err != nil:
+		log.Printf("Task CSV export cut short: %v", err)
+		c.Abort()
+	case w == nil:
+		writeHeader()
+		w.Flush()
+	}
+}
+
+// exportXLSX writes the tasks through excelize's stream writer, which keeps only a
+// small buffer of rows in memory and spills the rest to a temporary file.
+func (h *TaskExportHandler) exportXLSX(c *gin.Context, query *gorm.DB, filename string) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const sheet = "งาน"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		respondExportError(c, err)
+		return
+	}
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	header := make([]interface{}, len(taskExportHeaders))
+	for i, name := range taskExportHeaders {
+		header[i] = name
+	}
+	if err := sw.SetRow("A1", header, excelize.RowOpts{StyleID: bold}); err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	row := 2
+	err = eachTaskBatch(query, func(batch []models.TaskDaily) error {
+		for i := range batch {
+			cell, err := excelize.CoordinatesToCellName(1, row)
+			if err != nil {
+				return err
+			}
+			if err := sw.SetRow(cell, taskExportRow(&batch[i])); err != nil {
+				return err
+			}
+			row++
+		}
+		return nil
+	})
+	if err == nil {
+		err = sw.Flush()
+	}
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	setAttachment(c, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", filename)
+	c.Status(http.StatusOK)
+	if err := f.Write(c.Writer); err != nil {
+		log.Printf("Task XLSX export cut short: %v", err)
+		c.Abort()
+	}
+}
+
+// exportPDF renders one section per team and work date with the tasks of that day and
+// thumbnails of their before/after photos.
+func (h *TaskExportHandler) exportPDF(c *gin.Context, query *gorm.DB, filename string) {
+	if h.pdfFont == nil {
+		c.JSON(http.StatusServiceUnavailable, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "PDF_UNAVAILABLE",
+				Message: "PDF export is not configured",
+			},
+		})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondExportError(c, err)
+		return
+	}
+	if total > taskExportPDFMaxRows {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "TOO_MANY_ROWS",
+				Message: fmt.Sprintf("PDF export is limited to %d tasks; narrow the filters (e.g. workDate and teamId) or use xlsx", taskExportPDFMaxRows),
+			},
+		})
+		return
+	}
+
+	var tasks []models.TaskDaily
+	if err := eachTaskBatch(query, func(batch []models.TaskDaily) error {
+		tasks = append(tasks, batch...)
+		return nil
+	}); err != nil {
+		respondExportError(c, err)
+		return
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := &tasks[i], &tasks[j]
+		if an, bn := namesOf(a).team, namesOf(b).team; an != bn {
+			return an < bn
+		}
+		if a.TeamID != b.TeamID {
+			return a.TeamID < b.TeamID
+		}
+		return a.WorkDate.Before(b.WorkDate)
+	})
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("thai", "", h.pdfFont)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("thai", "", 9)
+		pdf.CellFormat(0, 5, fmt.Sprintf("หน้า %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	thumbs := h.pdfThumbnails(c.Request.Context(), pdf, tasks)
+	for start := 0; start < len(tasks); {
+		end := start
+		for end < len(tasks) && tasks[end].TeamID == tasks[start].TeamID && tasks[end].WorkDate.Equal(tasks[start].WorkDate) {
+			end++
+		}
+		h.pdfSection(pdf, tasks[start:end], thumbs)
+		start = end
+	}
+	if len(tasks) == 0 {
+		pdf.AddPage()
+		pdf.SetFont("thai", "", 14)
+		pdf.Cell(0, 10, "ไม่มีงานตามเงื่อนไขที่เลือก")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		log.Printf("Failed to render task PDF: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while rendering the PDF",
+			},
+		})
+		return
+	}
+
+	setAttachment(c, "application/pdf", filename)
+	c.Status(http.StatusOK)
+	c.Writer.Write(buf.Bytes())
+}
+
+// pdfSection starts a page for one team's tasks of one day. thumbs holds the photo
+// URLs registered by pdfThumbnails.
+func (h *TaskExportHandler) pdfSection(pdf *fpdf.Fpdf, tasks []models.TaskDaily, thumbs map[string]bool) {
+	const (
+		thumbW = 28.0
+		thumbH = 21.0
+		lineH  = 6.0
+	)
+
+	pdf.AddPage()
+	pdf.SetFont("thai", "", 16)
+	pdf.CellFormat(0, 9, "รายงานการปฏิบัติงานประจำวัน", "", 1, "C", false, 0, "")
+	pdf.SetFont("thai", "", 12)
+	pdf.CellFormat(0, lineH, fmt.Sprintf("ทีม: %s    วันที่: %s    จำนวนงาน: %d",
+		namesOf(&tasks[0]).team, tasks[0].WorkDate.Format("2006-01-02"), len(tasks)), "", 1, "C", false, 0, "")
+	pdf.Ln(3)
+
+	_, pageH := pdf.GetPageSize()
+	for i := range tasks {
+		task := &tasks[i]
+		n := namesOf(task)
+
+		hasPhotos := len(task.URLsBefore) > 0 || len(task.URLsAfter) > 0
+		needed := 3 * lineH
+		if hasPhotos {
+			needed += thumbH + lineH
+		}
+		if pdf.GetY()+needed > pageH-20 {
+			pdf.AddPage()
+		}
+
+		pdf.SetFont("thai", "", 12)
+		pdf.MultiCell(0, lineH, fmt.Sprintf("%d. %s / %s", i+1, n.jobType, n.jobDetail), "", "L", false)
+		pdf.SetFont("thai", "", 10)
+		info := fmt.Sprintf("ฟีดเดอร์: %s", orDash(n.feeder))
+		if n.station != "" {
+			info += fmt.Sprintf(" (%s)", n.station)
+		}
+		info += fmt.Sprintf("    หมายเลขเสา: %s    รหัสอุปกรณ์: %s", orDash(stringOrEmpty(task.NumPole)), orDash(stringOrEmpty(task.DeviceCode)))
+		pdf.MultiCell(0, lineH-1, info, "", "L", false)
+		if task.Detail != nil && *task.Detail != "" {
+			pdf.MultiCell(0, lineH-1, fmt.Sprintf("รายละเอียด: %s", *task.Detail), "", "L", false)
+		}
+
+		if hasPhotos {
+			left, _, _, _ := pdf.GetMargins()
+			y := pdf.GetY() + 1
+			pdf.SetXY(left, y)
+			pdf.Cell(90, lineH-1, "ก่อนปฏิบัติงาน")
+			pdf.Cell(0, lineH-1, "หลังปฏิบัติงาน")
+			y += lineH
+
+			for side, urls := range [][]string{task.URLsBefore, task.URLsAfter} {
+				x := left + float64(side)*90
+				for n, url := range urls {
+					if n == taskExportThumbsEach {
+						break
+					}
+					if !thumbs[url] {
+						continue
+					}
+					info := pdf.GetImageInfo(url)
+					w, hgt := thumbW, thumbW*info.Height()/info.Width()
+					if hgt > thumbH {
+						w, hgt = thumbH*info.Width()/info.Height(), thumbH
+					}
+					pdf.ImageOptions(url, x, y, w, hgt, false, fpdf.ImageOptions{ImageType: "JPG"}, 0, "")
+					x += thumbW + 2
+				}
+			}
+			pdf.SetY(y + thumbH + 1)
+		}
+
+		left, _, right, _ := pdf.GetMargins()
+		pageW, _ := pdf.GetPageSize()
+		pdf.Line(left, pdf.GetY()+1, pageW-right, pdf.GetY()+1)
+		pdf.Ln(3)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// pdfThumbnails fetches the photos the PDF shows, taskExportPhotoWorkers at a time and
+// all within the handler's photo deadline, and registers a downscaled JPEG of each with
+// pdf under its URL. Only photos in the R2 bucket are fetched. It returns the URLs that
+// were registered; the others are left out of the document.
+func (h *TaskExportHandler) pdfThumbnails(ctx context.Context, pdf *fpdf.Fpdf, tasks []models.TaskDaily) map[string]bool {
+	var urls []string
+	seen := make(map[string]bool)
+	for i := range tasks {
+		for _, side := range [][]string{tasks[i].URLsBefore, tasks[i].URLsAfter} {
+			for n, url := range side {
+				if n == taskExportThumbsEach {
+					break
+				}
+				if seen[url] || h.photoPrefix == "" || !strings.HasPrefix(url, h.photoPrefix) {
+					continue
+				}
+				seen[url] = true
+				urls = append(urls, url)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.photoDeadline)
+	defer cancel()
+
+	jpegs := make([][]byte, len(urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < taskExportPhotoWorkers && w < len(urls); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				jpegs[i] = h.fetchThumbnail(ctx, urls[i])
+			}
+		}()
+	}
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	registered := make(map[string]bool, len(urls))
+	for i, url := range urls {
+		if jpegs[i] == nil {
+			continue
+		}
+		pdf.RegisterImageOptionsReader(url, fpdf.ImageOptions{ImageType: "JPG"}, bytes.NewReader(jpegs[i]))
+		if pdf.Err() {
+			log.Printf("Failed to embed photo %s in PDF: %v", url, pdf.Error())
+			pdf.ClearError()
+			continue
+		}
+		registered[url] = true
+	}
+	return registered
+}
+
+// fetchThumbnail downloads the photo at url and returns it as a JPEG at most
+// taskExportThumbPx on its longer side, or nil if it cannot be used.
+func (h *TaskExportHandler) fetchThumbnail(ctx context.Context, url string) []byte {
+	if ctx.Err() != nil {
+		log.Printf("Skipped photo %s for PDF: %v", url, ctx.Err())
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		log.Printf("Failed to fetch photo %s for PDF: %v", url, err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to fetch photo %s for PDF: status %d", url, resp.StatusCode)
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, taskExportPhotoMax))
+	if err != nil {
+		log.Printf("Failed to fetch photo %s for PDF: %v", url, err)
+		return nil
+	}
+
+	// Check the dimensions in the header before decoding allocates the pixels
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to decode photo %s for PDF: %v", url, err)
+		return nil
+	}
+	if cfg.Width < 1 || cfg.Height < 1 || int64(cfg.Width)*int64(cfg.Height) > taskExportPhotoPixels {
+		log.Printf("Skipped photo %s for PDF: %dx%d pixels", url, cfg.Width, cfg.Height)
+		return nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to decode photo %s for PDF: %v", url, err)
+		return nil
+	}
+
+	bounds := src.Bounds()
+	w, hgt := bounds.Dx(), bounds.Dy()
+	if w > taskExportThumbPx || hgt > taskExportThumbPx {
+		if w >= hgt {
+			w, hgt = taskExportThumbPx, hgt*taskExportThumbPx/w
+		} else {
+			w, hgt = w*taskExportThumbPx/hgt, taskExportThumbPx
+		}
+	}
+	if w < 1 || hgt < 1 {
+		return nil
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, hgt))
+	xdraw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, bounds, xdraw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 70}); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// newPhotoServer serves a 640x480 PNG at /photo.png, a GIF whose header claims
+// 60000x60000 pixels at /huge.gif, and blocks on /slow.png until the test ends.
+func newPhotoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 640, 480))); err != nil {
+		t.Fatal(err)
+	}
+	var huge bytes.Buffer
+	if err := gif.Encode(&huge, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+	hugeGIF := huge.Bytes()
+	binary.LittleEndian.PutUint16(hugeGIF[6:], 60000)
+	binary.LittleEndian.PutUint16(hugeGIF[8:], 60000)
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.png":
+			w.Write(photo.Bytes())
+		case "/huge.gif":
+			w.Write(hugeGIF)
+		case "/slow.png":
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(func() {
+		close(done)
+		srv.Close()
+	})
+	return srv
+}
+
+func TestFetchThumbnail(t *testing.T) {
+	srv := newPhotoServer(t)
+	h := &TaskExportHandler{client: srv.Client(), photoPrefix: srv.URL + "/", photoDeadline: time.Second}
+
+	thumb := h.fetchThumbnail(context.Background(), srv.URL+"/photo.png")
+	if thumb == nil {
+		t.Fatal("no thumbnail for a valid photo")
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if cfg.Width != taskExportThumbPx || cfg.Height != 240 {
+		t.Errorf("thumbnail is %dx%d, want %dx240", cfg.Width, cfg.Height, taskExportThumbPx)
+	}
+
+	if h.fetchThumbnail(context.Background(), srv.URL+"/huge.gif") != nil {
+		t.Error("photo with oversized dimensions was decoded")
+	}
+	if h.fetchThumbnail(context.Background(), srv.URL+"/missing.png") != nil {
+		t.Error("thumbnail returned for a 404")
+	}
+}
+
+func TestPDFThumbnailsDeadline(t *testing.T) {
+	srv := newPhotoServer(t)
+	h := &TaskExportHandler{client: srv.Client(), photoPrefix: srv.URL + "/", photoDeadline: 200 * time.Millisecond}
+
+	// Many slow photos must not add up: the deadline covers the whole document
+	var slow models.StringArray
+	for i := 0; i < 2*taskExportPhotoWorkers; i++ {
+		slow = append(slow, srv.URL+"/slow.png?"+string(rune('a'+i)))
+	}
+	tasks := []models.TaskDaily{
+		{URLsBefore: models.StringArray{srv.URL + "/photo.png", "https://elsewhere.example/photo.png"}},
+	}
+	for i := 0; i < len(slow); i += taskExportThumbsEach {
+		end := min(i+taskExportThumbsEach, len(slow))
+		tasks = append(tasks, models.TaskDaily{URLsAfter: slow[i:end]})
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	start := time.Now()
+	thumbs := h.pdfThumbnails(context.Background(), pdf, tasks)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("pdfThumbnails took %s with a %s deadline", elapsed, h.photoDeadline)
+	}
+
+	if !thumbs[srv.URL+"/photo.png"] {
+		t.Error("fast photo missing")
+	}
+	if thumbs["https://elsewhere.example/photo.png"] {
+		t.Error("photo outside the bucket was fetched")
+	}
+	for _, url := range slow {
+		if thumbs[url] {
+			t.Errorf("%s registered after the deadline", url)
+		}
+	}
+	if info := pdf.GetImageInfo(srv.URL + "/photo.png"); info == nil {
+		t.Error("fast photo not registered with the PDF")
+	}
+}
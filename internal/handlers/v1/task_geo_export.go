@@ -0,0 +1,213 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/models"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// taskFeatureProperties are the GeoJSON properties of one task.
+type taskFeatureProperties struct {
+	ID              int64    `json:"id"`
+	WorkDate        string   `json:"workDate"`
+	Team            string   `json:"team"`
+	JobType         string   `json:"jobType"`
+	JobDetail       string   `json:"jobDetail"`
+	FeederCode      string   `json:"feederCode,omitempty"`
+	Station         string   `json:"station,omitempty"`
+	OperationCenter string   `json:"operationCenter,omitempty"`
+	NumPole         *string  `json:"numPole,omitempty"`
+	DeviceCode      *string  `json:"deviceCode,omitempty"`
+	Detail          *string  `json:"detail,omitempty"`
+	URLsBefore      []string `json:"urlsBefore"`
+	URLsAfter       []string `json:"urlsAfter"`
+}
+
+type taskFeature struct {
+	Type       string                `json:"type"`
+	ID         int64                 `json:"id"`
+	Geometry   taskPointGeometry     `json:"geometry"`
+	Properties taskFeatureProperties `json:"properties"`
+}
+
+type taskPointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // lng, lat
+}
+
+// taskCoordinates returns the task's coordinates; callers only pass located tasks.
+func taskCoordinates(task *models.TaskDaily) (lat, lng float64) {
+	lat, _ = task.Latitude.Float64()
+	lng, _ = task.Longitude.Float64()
+	return lat, lng
+}
+
+func taskToFeature(task *models.TaskDaily) taskFeature {
+	n := namesOf(task)
+	lat, lng := taskCoordinates(task)
+	return taskFeature{
+		Type:     "Feature",
+		ID:       task.ID,
+		Geometry: taskPointGeometry{Type: "Point", Coordinates: [2]float64{lng, lat}},
+		Properties: taskFeatureProperties{
+			ID:              task.ID,
+			WorkDate:        task.WorkDate.Format("2006-01-02"),
+			Team:            n.team,
+			JobType:         n.jobType,
+			JobDetail:       n.jobDetail,
+			FeederCode:      n.feeder,
+			Station:         n.station,
+			OperationCenter: n.operationCenter,
+			NumPole:         task.NumPole,
+			DeviceCode:      task.DeviceCode,
+			Detail:          task.Detail,
+			URLsBefore:      []string(task.URLsBefore),
+			URLsAfter:       []string(task.URLsAfter),
+		},
+	}
+}
+
+// streamLocatedTasks writes the tasks of query that have coordinates, batch by batch,
+// between header and footer. The headers go out with the first batch, so a query
+// error before that is still answered with a JSON 500; after it the response can
+// only be cut short and the error is logged.
+func streamLocatedTasks(c *gin.Context, query *gorm.DB, contentType, filename, header, footer string, write func(io.Writer, *models.TaskDaily) error) {
+	started := false
+	start := func() {
+		setAttachment(c, contentType, filename)
+		c.Status(http.StatusOK)
+		io.WriteString(c.Writer, header)
+		started = true
+	}
+
+	located := query.
+		Where(models.TaskCol.Latitude + " IS NOT NULL").
+		Where(models.TaskCol.Longitude + " IS NOT NULL").
+		Session(&gorm.Session{})
+	err := eachTaskBatch(located, func(batch []models.TaskDaily) error {
+		if !started {
+			start()
+		}
+		for i := range batch {
+			if err := write(c.Writer, &batch[i]); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+
+	switch {
+	case err != nil && !started:
+		respondExportError(c, err)
+		return
+	case err != nil:
+		log.Printf("Task %s export cut short: %v", contentType, err)
+		c.Abort()
+		return
+	case !started:
+		start()
+	}
+	io.WriteString(c.Writer, footer)
+}
+
+// GeoJSON - GET /v1/tasks/geojson
+// Streams the located tasks matching the List filters as a GeoJSON FeatureCollection
+// of points carrying job type, detail, feeder code, team and photo URLs.
+func (h *TaskExportHandler) GeoJSON(c *gin.Context) {
+	query, ok := h.exportQuery(c)
+	if !ok {
+		return
+	}
+
+	first := true
+	streamLocatedTasks(c, query, "application/geo+json", taskExportFilename(c, "geojson"),
+		`{"type":"FeatureCollection","features":[`, "]}\n",
+		func(w io.Writer, task *models.TaskDaily) error {
+			data, err := json.Marshal(taskToFeature(task))
+			if err != nil {
+				return err
+			}
+			if !first {
+				io.WriteString(w, ",")
+			}
+			first = false
+			_, err = w.Write(data)
+			return err
+		})
+}
+
+const taskKMLHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+<name>Hotline tasks</name>
+`
+
+const taskKMLFooter = "</Document>\n</kml>\n"
+
+// KML - GET /v1/tasks/kml
+// Streams the located tasks matching the List filters as KML Placemarks. The task
+// fields are in ExtendedData under the same names as the GeoJSON properties.
+func (h *TaskExportHandler) KML(c *gin.Context) {
+	query, ok := h.exportQuery(c)
+	if !ok {
+		return
+	}
+
+	streamLocatedTasks(c, query, "application/vnd.google-earth.kml+xml", taskExportFilename(c, "kml"),
+		taskKMLHeader, taskKMLFooter, writeTaskPlacemark)
+}
+
+// writeTaskPlacemark writes one task as a KML Placemark named after its job type and
+// detail.
+func writeTaskPlacemark(w io.Writer, task *models.TaskDaily) error {
+	p := taskToFeature(task).Properties
+	lat, lng := taskCoordinates(task)
+
+	var b strings.Builder
+	b.WriteString("<Placemark>\n<name>")
+	xml.EscapeText(&b, []byte(strings.TrimSuffix(p.JobType+" - "+p.JobDetail, " - ")))
+	b.WriteString("</name>\n")
+	if p.Detail != nil {
+		b.WriteString("<description>")
+		xml.EscapeText(&b, []byte(*p.Detail))
+		b.WriteString("</description>\n")
+	}
+
+	b.WriteString("<ExtendedData>\n")
+	data := [][2]string{
+		{"id", fmt.Sprint(p.ID)},
+		{"workDate", p.WorkDate},
+		{"team", p.Team},
+		{"jobType", p.JobType},
+		{"jobDetail", p.JobDetail},
+		{"feederCode", p.FeederCode},
+		{"station", p.Station},
+		{"operationCenter", p.OperationCenter},
+		{"numPole", stringOrEmpty(p.NumPole)},
+		{"deviceCode", stringOrEmpty(p.DeviceCode)},
+		{"urlsBefore", strings.Join(p.URLsBefore, " ")},
+		{"urlsAfter", strings.Join(p.URLsAfter, " ")},
+	}
+	for _, d := range data {
+		if d[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `<Data name="%s"><value>`, d[0])
+		xml.EscapeText(&b, []byte(d[1]))
+		b.WriteString("</value></Data>\n")
+	}
+	b.WriteString("</ExtendedData>\n")
+
+	fmt.Fprintf(&b, "<Point><coordinates>%g,%g</coordinates></Point>\n</Placemark>\n", lng, lat)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// newGeoExportHandler stores a located and an unlocated task and returns the export
+// handler over them.
+func newGeoExportHandler(t *testing.T) *TaskExportHandler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+
+	jobType := models.JobType{Name: "Overhead"}
+	if err := db.Create(&jobType).Error; err != nil {
+		t.Fatal(err)
+	}
+	jobDetail := models.JobDetail{Name: "Replace pole", JobTypeID: &jobType.ID}
+	if err := db.Create(&jobDetail).Error; err != nil {
+		t.Fatal(err)
+	}
+	lat, lng := decimal.RequireFromString("13.75"), decimal.RequireFromString("100.5")
+	detail := "Pole <B> & stay"
+	for _, task := range []*models.TaskDaily{
+		{JobTypeID: jobType.ID, JobDetailID: jobDetail.ID, Detail: &detail, Latitude: &lat, Longitude: &lng},
+		{JobTypeID: jobType.ID, JobDetailID: jobDetail.ID},
+	} {
+		task.WorkDate = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		task.TeamID = 1
+		if err := db.Create(task).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewTaskExportHandler(&config.Config{}, db)
+}
+
+func geoExport(handler func(*gin.Context), target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	handler(c)
+	return w
+}
+
+func TestTaskGeoJSON(t *testing.T) {
+	h := newGeoExportHandler(t)
+
+	w := geoExport(h.GeoJSON, "/v1/tasks/geojson")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var fc struct {
+		Type     string        `json:"type"`
+		Features []taskFeature `json:"features"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &fc); err != nil {
+		t.Fatalf("decode: %v: %s", err, w.Body.String())
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+		t.Fatalf("collection = %+v, want the located task only", fc)
+	}
+	f := fc.Features[0]
+	if f.Geometry.Coordinates != [2]float64{100.5, 13.75} {
+		t.Errorf("coordinates = %v, want [lng, lat]", f.Geometry.Coordinates)
+	}
+	if f.Properties.JobType != "Overhead" || f.Properties.JobDetail != "Replace pole" {
+		t.Errorf("properties = %+v", f.Properties)
+	}
+
+	if w := geoExport(h.GeoJSON, "/v1/tasks/geojson?bbox=1,2"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid bbox: status = %d, want 400", w.Code)
+	}
+	w = geoExport(h.GeoJSON, "/v1/tasks/geojson?bbox=98,18,99,19")
+	if body := strings.TrimSpace(w.Body.String()); body != `{"type":"FeatureCollection","features":[]}` {
+		t.Errorf("empty export = %s", body)
+	}
+}
+
+func TestTaskKML(t *testing.T) {
+	h := newGeoExportHandler(t)
+
+	w := geoExport(h.KML, "/v1/tasks/kml")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if n := strings.Count(body, "<Placemark>"); n != 1 {
+		t.Errorf("%d placemarks, want the located task only", n)
+	}
+	for _, want := range []string{
+		"<name>Overhead - Replace pole</name>",
+		"<description>Pole &lt;B&gt; &amp; stay</description>",
+		"<coordinates>100.5,13.75</coordinates>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("KML lacks %s:\n%s", want, body)
+		}
+	}
+	if !strings.HasSuffix(body, taskKMLFooter) {
+		t.Error("KML is not closed")
+	}
+}
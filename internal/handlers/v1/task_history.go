@@ -0,0 +1,429 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// revisionBaseline marks the version stored for a task that existed before revisions
+// were kept, holding its state just before the first recorded change.
+const revisionBaseline = "baseline"
+
+// taskSnapshot encodes task as stored in TaskRevision.snapshot, without relations.
+func taskSnapshot(task models.TaskDaily) (string, error) {
+	task.Team, task.JobType, task.JobDetail, task.Feeder = nil, nil, nil, nil
+	data, err := json.Marshal(task)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode task snapshot: %w", err)
+	}
+	return string(data), nil
+}
+
+// changedTaskFields returns the sorted JSON field names that differ between two snapshots.
+// updatedAt and version are left out since they change on every save.
+func changedTaskFields(previous, current string) ([]string, error) {
+	var before, after map[string]interface{}
+	if err := json.Unmarshal([]byte(previous), &before); err != nil {
+		return nil, fmt.Errorf("failed to decode task snapshot: %w", err)
+	}
+	if err := json.Unmarshal([]byte(current), &after); err != nil {
+		return nil, fmt.Errorf("failed to decode task snapshot: %w", err)
+	}
+
+	fields := []string{}
+	for name := range audit.Diff(before, after) {
+		if name != "updatedAt" && name != "version" {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// recordTaskRevision appends the next version of task. It must run in the transaction
+// that saved task, so the row lock taken by that write serialises version numbers.
+// When the task has no history yet and before is given, before is stored first as a
+// baseline version so the original report is not lost.
+func recordTaskRevision(c *gin.Context, tx *gorm.DB, before, task *models.TaskDaily, action string, revertedFrom *int) error {
+	var last *models.TaskRevision
+	var latest models.TaskRevision
+	err := tx.Where(models.TaskRevisionCol.TaskID+" = ?", task.ID).
+		Order(models.TaskRevisionCol.Version + " DESC").
+		First(&latest).Error
+	switch {
+	case err == nil:
+		last = &latest
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to load task revision: %w", err)
+	}
+
+	if last == nil && before != nil {
+		snapshot, err := taskSnapshot(*before)
+		if err != nil {
+			return err
+		}
+		baseline := models.TaskRevision{
+			TaskID:    task.ID,
+			Version:   1,
+			Action:    revisionBaseline,
+			Snapshot:  snapshot,
+			CreatedAt: before.UpdatedAt,
+		}
+		if err := tx.Create(&baseline).Error; err != nil {
+			return fmt.Errorf("failed to save task revision: %w", err)
+		}
+		last = &baseline
+	}
+
+	snapshot, err := taskSnapshot(*task)
+	if err != nil {
+		return err
+	}
+	actor := auditActor(c)
+	revision := models.TaskRevision{
+		TaskID:       task.ID,
+		Version:      1,
+		Action:       action,
+		Snapshot:     snapshot,
+		RevertedFrom: revertedFrom,
+		AuthorID:     actor.UserID,
+		AuthorName:   actor.Name,
+		CreatedAt:    time.Now(),
+	}
+	if last != nil {
+		revision.Version = last.Version + 1
+		fields, err := changedTaskFields(last.Snapshot, snapshot)
+		if err != nil {
+			return err
+		}
+		revision.ChangedFields = models.StringArray(fields)
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return fmt.Errorf("failed to save task revision: %w", err)
+	}
+	return nil
+}
+
+// loadTaskRelations fills the relations of a task decoded from a snapshot, using the
+// related rows as they are now. Missing rows are left nil.
+func loadTaskRelations(db *gorm.DB, task *models.TaskDaily) {
+	var team models.Team
+	if db.First(&team, task.TeamID).Error == nil {
+		task.Team = &team
+	}
+	var jobType models.JobType
+	if db.First(&jobType, task.JobTypeID).Error == nil {
+		task.JobType = &jobType
+	}
+	var jobDetail models.JobDetail
+	if db.First(&jobDetail, task.JobDetailID).Error == nil {
+		task.JobDetail = &jobDetail
+	}
+	if task.FeederID != nil {
+		var feeder models.Feeder
+		if db.Preload("Station.OperationCenter").First(&feeder, *task.FeederID).Error == nil {
+			task.Feeder = &feeder
+		}
+	}
+}
+
+// getTaskAsOf handles GET /v1/tasks/:id?asOf=: the task as it was at the given time
+// (RFC3339 or YYYY-MM-DD, a bare date meaning the end of that day).
+// Tasks never changed since revisions were kept are shown as they are now.
+func (h *TaskHandler) getTaskAsOf(c *gin.Context, id int64, asOf string) {
+	at, ok := parseTimeQuery(asOf, true)
+	if !ok {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_DATE",
+				Message: "asOf must be RFC3339 or YYYY-MM-DD",
+			},
+		})
+		return
+	}
+
+	var current models.TaskDaily
+	if err := readDB(c, h.db).First(&current, id).Error; err != nil {
+		respondTaskNotFound(c)
+		return
+	}
+
+	var revisionCount int64
+	h.db.WithContext(c.Request.Context()).Model(&models.TaskRevision{}).
+		Where(models.TaskRevisionCol.TaskID+" = ?", id).
+		Count(&revisionCount)
+
+	task := current
+	var revisionNumber *int
+	if revisionCount > 0 {
+		var revision models.TaskRevision
+		err := h.db.WithContext(c.Request.Context()).
+			Where(models.TaskRevisionCol.TaskID+" = ? AND "+models.TaskRevisionCol.CreatedAt+" <= ?", id, at).
+			Order(models.TaskRevisionCol.Version + " DESC").
+			First(&revision).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondTaskNotFound(c)
+			return
+		}
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INTERNAL_ERROR",
+					Message: "An error occurred while fetching the task history",
+				},
+			})
+			return
+		}
+		task = models.TaskDaily{}
+		if err := json.Unmarshal([]byte(revision.Snapshot), &task); err != nil {
+			log.Printf("Failed to decode revision %d of task %d: %v", revision.Version, id, err)
+			c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INTERNAL_ERROR",
+					Message: "An error occurred while reading the task history",
+				},
+			})
+			return
+		}
+		revisionNumber = &revision.Version
+	} else if at.Before(current.CreatedAt) {
+		respondTaskNotFound(c)
+		return
+	}
+
+	loadTaskRelations(h.db.WithContext(c.Request.Context()), &task)
+	response := convertTaskToResponse(&task)
+	response.Revision = revisionNumber
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// History - GET /v1/tasks/:id/history
+// Lists every version of the task, newest first.
+func (h *TaskHandler) History(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	var task models.TaskDaily
+	if err := readDB(c, h.db).Select("id").First(&task, id).Error; err != nil {
+		respondTaskNotFound(c)
+		return
+	}
+
+	var revisions []models.TaskRevision
+	if err := h.db.WithContext(c.Request.Context()).
+		Where(models.TaskRevisionCol.TaskID+" = ?", id).
+		Order(models.TaskRevisionCol.Version + " DESC").
+		Find(&revisions).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the task history",
+			},
+		})
+		return
+	}
+
+	response := make([]dto.TaskRevisionResponse, len(revisions))
+	for i, r := range revisions {
+		fields := []string(r.ChangedFields)
+		if fields == nil {
+			fields = []string{}
+		}
+		response[i] = dto.TaskRevisionResponse{
+			Version:       r.Version,
+			Action:        r.Action,
+			AuthorID:      r.AuthorID,
+			AuthorName:    r.AuthorName,
+			ChangedFields: fields,
+			RevertedFrom:  r.RevertedFrom,
+			CreatedAt:     r.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// Revert - POST /v1/tasks/:id/revert
+// Restores the fields of an earlier version as a new version; history is never rewritten.
+// The workflow status is kept, except that a task under review or approved is corrected
+// again as for Update. As for Update, the If-Match header must hold the current version
+// and the restored coordinates must lie within Thailand.
+func (h *TaskHandler) Revert(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	var req dto.RevertTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+
+	var task models.TaskDaily
+	if err := h.db.WithContext(c.Request.Context()).First(&task, id).Error; err != nil {
+		respondTaskNotFound(c)
+		return
+	}
+
+	var revision models.TaskRevision
+	if err := h.db.WithContext(c.Request.Context()).
+		Where(models.TaskRevisionCol.TaskID+" = ? AND "+models.TaskRevisionCol.Version+" = ?", id, req.Version).
+		First(&revision).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "REVISION_NOT_FOUND",
+				Message: fmt.Sprintf("Task has no version %d", req.Version),
+			},
+		})
+		return
+	}
+
+	var target models.TaskDaily
+	if err := json.Unmarshal([]byte(revision.Snapshot), &target); err != nil {
+		log.Printf("Failed to decode revision %d of task %d: %v", revision.Version, id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while reading the task history",
+			},
+		})
+		return
+	}
+
+	if msg := validateCoordinates(floatCoordinate(target.Latitude), floatCoordinate(target.Longitude)); msg != "" {
+		respondInvalidCoordinates(c, msg)
+		return
+	}
+
+	if !canWriteTeamTask(c, task.TeamID) || !canWriteTeamTask(c, target.TeamID) {
+		respondTeamForbidden(c)
+		return
+	}
+
+	if !canEditTaskInStatus(c, task.Status) {
+		respondTaskLocked(c, task.Status)
+		return
+	}
+
+	if !requireIfMatch(c, task.Version, h.currentTask(c, id)) {
+		return
+	}
+
+	before := task
+
+	task.WorkDate = target.WorkDate
+	task.TeamID = target.TeamID
+	task.JobTypeID = target.JobTypeID
+	task.JobDetailID = target.JobDetailID
+	task.FeederID = target.FeederID
+	task.NumPole = target.NumPole
+	task.DeviceCode = target.DeviceCode
+	task.Detail = target.Detail
+	task.URLsBefore = target.URLsBefore
+	task.URLsAfter = target.URLsAfter
+	task.Latitude = target.Latitude
+	task.Longitude = target.Longitude
+	now := time.Now()
+	reopenEditedTask(c, &task, now)
+	task.UpdatedAt = now
+
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &task, &task.Version); err != nil {
+			return err
+		}
+		if err := recordTaskRevision(c, tx, &before, &task, audit.ActionRevert, &revision.Version); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRevert,
+			EntityType: models.TaskDaily{}.TableName(),
+			EntityID:   task.ID,
+			Before:     &before,
+			After:      &task,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentTask(c, id))
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while reverting the task",
+			},
+		})
+		return
+	}
+
+	// Reload with relations
+	h.db.
+		Preload("Team").
+		Preload("JobType").
+		Preload("JobDetail").
+		Preload("Feeder.Station.OperationCenter").
+		First(&task, task.ID)
+
+	setETag(c, task.Version)
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    convertTaskToResponse(&task),
+	})
+}
+
+func respondTaskNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "NOT_FOUND",
+			Message: "Task not found",
+		},
+	})
+}
@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// historyRequest runs handler for task as an editor allowed to write any team's tasks.
+func historyRequest(db *gorm.DB, handler func(*TaskHandler, *gin.Context), method, target string, task models.TaskDaily, body interface{}, ifMatch string) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	id := strconv.FormatInt(task.ID, 10)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		c.Request.Header.Set("If-Match", ifMatch)
+	}
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Set("user_id", uint(1))
+	c.Set("permissions", permission.NewSet(permission.TaskWriteAnyTeam))
+	handler(NewTaskHandler(db), c)
+	return w
+}
+
+// historyTask stores a draft task last changed on 1 March 2026, before any revision.
+func historyTask(t *testing.T, db *gorm.DB, detail string, lat, lng string) models.TaskDaily {
+	t.Helper()
+	changed := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	latitude, longitude := decimal.RequireFromString(lat), decimal.RequireFromString(lng)
+	task := models.TaskDaily{
+		WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		JobTypeID:   1,
+		JobDetailID: 1,
+		TeamID:      1,
+		Detail:      &detail,
+		Latitude:    &latitude,
+		Longitude:   &longitude,
+		Status:      models.TaskStatusDraft,
+		CreatedAt:   changed,
+		UpdatedAt:   changed,
+	}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func reloadTask(t *testing.T, db *gorm.DB, id int64) models.TaskDaily {
+	t.Helper()
+	var task models.TaskDaily
+	if err := db.First(&task, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestTaskHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	task := historyTask(t, db, "reported", "13.75", "100.5")
+	path := "/v1/tasks/" + strconv.FormatInt(task.ID, 10)
+
+	// The first update keeps the original report as a baseline version
+	w := historyRequest(db, (*TaskHandler).Update, http.MethodPut, path, task, gin.H{"detail": "checked"}, etag(task.Version))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+	task = reloadTask(t, db, task.ID)
+	w = historyRequest(db, (*TaskHandler).Update, http.MethodPut, path, task, gin.H{"latitude": 14.0}, etag(task.Version))
+	if w.Code != http.StatusOK {
+		t.Fatalf("second update: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	w = historyRequest(db, (*TaskHandler).History, http.MethodGet, path+"/history", task, nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("history: status = %d: %s", w.Code, w.Body.String())
+	}
+	var history struct {
+		Data []dto.TaskRevisionResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		version int
+		action  string
+		fields  []string
+	}{
+		{3, audit.ActionUpdate, []string{"latitude"}},
+		{2, audit.ActionUpdate, []string{"detail"}},
+		{1, revisionBaseline, []string{}},
+	}
+	if len(history.Data) != len(want) {
+		t.Fatalf("history = %+v, want %d versions", history.Data, len(want))
+	}
+	for i, r := range history.Data {
+		if r.Version != want[i].version || r.Action != want[i].action || !slices.Equal(r.ChangedFields, want[i].fields) {
+			t.Errorf("version %d = %+v, want %+v", i, r, want[i])
+		}
+	}
+	if history.Data[0].AuthorID == nil || *history.Data[0].AuthorID != 1 {
+		t.Errorf("author = %v, want user 1", history.Data[0].AuthorID)
+	}
+
+	// asOf before the first update shows the baseline; before the task existed, nothing
+	tests := []struct {
+		asOf     string
+		status   int
+		detail   string
+		revision int
+	}{
+		{"2026-03-02", http.StatusOK, "reported", 1},
+		{time.Now().Add(time.Minute).Format(time.RFC3339), http.StatusOK, "checked", 3},
+		{"2026-02-28", http.StatusNotFound, "", 0},
+		{"yesterday", http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		w := historyRequest(db, (*TaskHandler).GetByID, http.MethodGet, path+"?asOf="+tt.asOf, task, nil, "")
+		if w.Code != tt.status {
+			t.Errorf("asOf %s: status = %d, want %d: %s", tt.asOf, w.Code, tt.status, w.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Data dto.TaskResponse `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Data.Detail == nil || *resp.Data.Detail != tt.detail || resp.Data.Revision == nil || *resp.Data.Revision != tt.revision {
+			t.Errorf("asOf %s: detail %v, revision %v; want %q at version %d", tt.asOf, resp.Data.Detail, resp.Data.Revision, tt.detail, tt.revision)
+		}
+	}
+}
+
+func TestRevertTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	task := historyTask(t, db, "reported", "13.75", "100.5")
+	path := "/v1/tasks/" + strconv.FormatInt(task.ID, 10)
+
+	w := historyRequest(db, (*TaskHandler).Update, http.MethodPut, path, task, gin.H{"detail": "checked", "latitude": 14.0}, etag(task.Version))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+	task = reloadTask(t, db, task.ID)
+
+	revert := gin.H{"version": 1}
+	if w := historyRequest(db, (*TaskHandler).Revert, http.MethodPost, path+"/revert", task, revert, ""); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("revert without If-Match: status = %d, want 428", w.Code)
+	}
+	if w := historyRequest(db, (*TaskHandler).Revert, http.MethodPost, path+"/revert", task, revert, etag(task.Version-1)); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("revert with a stale If-Match: status = %d, want 412", w.Code)
+	}
+	if w := historyRequest(db, (*TaskHandler).Revert, http.MethodPost, path+"/revert", task, gin.H{"version": 9}, etag(task.Version)); w.Code != http.StatusNotFound {
+		t.Errorf("revert to a missing version: status = %d, want 404", w.Code)
+	}
+	if stored := reloadTask(t, db, task.ID); stored.Version != task.Version {
+		t.Fatalf("refused reverts changed the task to version %d", stored.Version)
+	}
+
+	w = historyRequest(db, (*TaskHandler).Revert, http.MethodPost, path+"/revert", task, revert, etag(task.Version))
+	if w.Code != http.StatusOK {
+		t.Fatalf("revert: status = %d: %s", w.Code, w.Body.String())
+	}
+	stored := reloadTask(t, db, task.ID)
+	if stored.Detail == nil || *stored.Detail != "reported" || stored.Latitude.String() != "13.75" {
+		t.Errorf("after revert: detail %v, latitude %s; want the original report", stored.Detail, stored.Latitude)
+	}
+	if stored.Version != task.Version+1 {
+		t.Errorf("version = %d, want %d", stored.Version, task.Version+1)
+	}
+
+	// The revert is a new version; the one it undid stays in the history
+	var revisions []models.TaskRevision
+	if err := db.Where(models.TaskRevisionCol.TaskID+" = ?", task.ID).Order(models.TaskRevisionCol.Version).Find(&revisions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("%d revisions, want 3", len(revisions))
+	}
+	last := revisions[2]
+	if last.Action != audit.ActionRevert || last.RevertedFrom == nil || *last.RevertedFrom != 1 ||
+		!slices.Equal([]string(last.ChangedFields), []string{"detail", "latitude"}) {
+		t.Errorf("revert revision = %+v", last)
+	}
+}
+
+// TestRevertValidatesCoordinates checks that a version saved before coordinates were
+// validated cannot be restored.
+func TestRevertValidatesCoordinates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	task := historyTask(t, db, "reported", "35.68", "139.69")
+	path := "/v1/tasks/" + strconv.FormatInt(task.ID, 10)
+
+	w := historyRequest(db, (*TaskHandler).Update, http.MethodPut, path, task, gin.H{"latitude": 13.75, "longitude": 100.5}, etag(task.Version))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+	task = reloadTask(t, db, task.ID)
+
+	w = historyRequest(db, (*TaskHandler).Revert, http.MethodPost, path+"/revert", task, gin.H{"version": 1}, etag(task.Version))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("revert outside Thailand: status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if stored := reloadTask(t, db, task.ID); stored.Latitude.String() != "13.75" || stored.Version != task.Version {
+		t.Errorf("refused revert changed the task: latitude %s, version %d", stored.Latitude, stored.Version)
+	}
+}
@@ -0,0 +1,532 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/geo"
+	"backend-hotlines3/internal/models"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	taskImportMaxSize = 10 * 1024 * 1024
+	taskImportMaxRows = 5000
+
+	// taskImportModeAll inserts nothing unless every row is valid; taskImportModeValid
+	// inserts the valid rows and reports the rest.
+	taskImportModeAll   = "all"
+	taskImportModeValid = "valid"
+)
+
+// taskSheetColumn is a column of a task spreadsheet. Imports accept either header.
+type taskSheetColumn struct {
+	key  string
+	thai string
+}
+
+var taskSheetColumns = []taskSheetColumn{
+	{key: "workDate", thai: "วันที่ปฏิบัติงาน"},
+	{key: "team", thai: "ทีม"},
+	{key: "jobType", thai: "ประเภทงาน"},
+	{key: "jobDetail", thai: "รายการงาน"},
+	{key: "feeder", thai: "ฟีดเดอร์"},
+	{key: "numPole", thai: "หมายเลขเสา"},
+	{key: "deviceCode", thai: "รหัสอุปกรณ์"},
+	{key: "detail", thai: "รายละเอียด"},
+	{key: "latitude", thai: "ละติจูด"},
+	{key: "longitude", thai: "ลองจิจูด"},
+	{key: "urlsBefore", thai: "รูปก่อนปฏิบัติงาน"},
+	{key: "urlsAfter", thai: "รูปหลังปฏิบัติงาน"},
+}
+
+// taskImportRequired are the columns an import file must have.
+var taskImportRequired = []string{"workDate", "team", "jobType", "jobDetail"}
+
+// normalizeSheetKey folds a header or reference name for lookups.
+func normalizeSheetKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// taskSheetHeaders maps normalized English and Thai headers to column keys.
+var taskSheetHeaders = func() map[string]string {
+	m := make(map[string]string, 2*len(taskSheetColumns))
+	for _, col := range taskSheetColumns {
+		m[normalizeSheetKey(col.key)] = col.key
+		m[normalizeSheetKey(col.thai)] = col.key
+	}
+	return m
+}()
+
+// taskImportLookups resolves the names used in an import file to IDs.
+type taskImportLookups struct {
+	teams      map[string]int64
+	jobTypes   map[string]int64
+	jobDetails map[string]models.JobDetail
+	feeders    map[string]int64
+}
+
+func loadTaskImportLookups(db *gorm.DB) (*taskImportLookups, error) {
+	l := &taskImportLookups{
+		teams:      make(map[string]int64),
+		jobTypes:   make(map[string]int64),
+		jobDetails: make(map[string]models.JobDetail),
+		feeders:    make(map[string]int64),
+	}
+
+	var teams []models.Team
+	if err := db.Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		l.teams[normalizeSheetKey(t.Name)] = t.ID
+	}
+
+	var jobTypes []models.JobType
+	if err := db.Find(&jobTypes).Error; err != nil {
+		return nil, err
+	}
+	for _, jt := range jobTypes {
+		l.jobTypes[normalizeSheetKey(jt.Name)] = jt.ID
+	}
+
+	var jobDetails []models.JobDetail
+	if err := db.Find(&jobDetails).Error; err != nil {
+		return nil, err
+	}
+	for _, jd := range jobDetails {
+		l.jobDetails[normalizeSheetKey(jd.Name)] = jd
+	}
+
+	var feeders []models.Feeder
+	if err := db.Select("id", "code").Find(&feeders).Error; err != nil {
+		return nil, err
+	}
+	for _, f := range feeders {
+		l.feeders[normalizeSheetKey(f.Code)] = f.ID
+	}
+	return l, nil
+}
+
+// readTaskSheet returns the rows of a CSV file or of the first sheet of an XLSX file.
+// XLSX cells are read raw, so dates arrive as Excel serial numbers.
+func readTaskSheet(r io.Reader, format string) ([][]string, error) {
+	if format == "xlsx" {
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.GetRows(f.GetSheetName(0), excelize.Options{RawCellValue: true})
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader.ReadAll()
+}
+
+// parseSheetDate accepts YYYY-MM-DD or, from XLSX, an Excel date serial number.
+func parseSheetDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	serial, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := excelize.ExcelDateToTime(serial, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// splitSheetURLs splits a cell holding photo URLs separated by whitespace or semicolons.
+func splitSheetURLs(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+// buildImportedTask turns one sheet row into a task, returning the problems found.
+func buildImportedTask(c *gin.Context, lookups *taskImportLookups, cell func(string) string, rowNum int) (*models.TaskDaily, []dto.TaskImportError) {
+	var errs []dto.TaskImportError
+	fail := func(column, value, message string) {
+		errs = append(errs, dto.TaskImportError{Row: rowNum, Column: column, Value: value, Message: message})
+	}
+
+	var req dto.CreateTaskRequest
+	req.WorkDate = cell("workDate")
+
+	if name := cell("team"); name != "" {
+		if id, ok := lookups.teams[normalizeSheetKey(name)]; ok {
+			req.TeamID = id
+		} else {
+			fail("team", name, "Unknown team")
+		}
+	}
+	if name := cell("jobType"); name != "" {
+		if id, ok := lookups.jobTypes[normalizeSheetKey(name)]; ok {
+			req.JobTypeID = id
+		} else {
+			fail("jobType", name, "Unknown job type")
+		}
+	}
+	if name := cell("jobDetail"); name != "" {
+		if jd, ok := lookups.jobDetails[normalizeSheetKey(name)]; ok {
+			req.JobDetailID = jd.ID
+			if jd.JobTypeID != nil && req.JobTypeID != 0 && *jd.JobTypeID != req.JobTypeID {
+				fail("jobDetail", name, "Job detail does not belong to the job type")
+			}
+		} else {
+			fail("jobDetail", name, "Unknown job detail")
+		}
+	}
+	if code := cell("feeder"); code != "" {
+		if id, ok := lookups.feeders[normalizeSheetKey(code)]; ok {
+			req.FeederID = &id
+		} else {
+			fail("feeder", code, "Unknown feeder")
+		}
+	}
+
+	optional := func(key string) *string {
+		if v := cell(key); v != "" {
+			return &v
+		}
+		return nil
+	}
+	req.NumPole = optional("numPole")
+	req.DeviceCode = optional("deviceCode")
+	req.Detail = optional("detail")
+	req.URLsBefore = splitSheetURLs(cell("urlsBefore"))
+	req.URLsAfter = splitSheetURLs(cell("urlsAfter"))
+
+	for _, key := range []string{"latitude", "longitude"} {
+		value := cell(key)
+		if value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			fail(key, value, "Must be a number")
+			continue
+		}
+		if key == "latitude" {
+			req.Latitude = &f
+		} else {
+			req.Longitude = &f
+		}
+	}
+	if (cell("latitude") == "") != (cell("longitude") == "") {
+		fail("", "", "Latitude and longitude must be given together")
+	} else if req.Latitude != nil && req.Longitude != nil && !geo.Thailand.Contains(*req.Latitude, *req.Longitude) {
+		fail("", "", "Coordinates must be inside Thailand")
+	}
+
+	for _, key := range taskImportRequired {
+		if cell(key) == "" {
+			fail(key, "", "Required")
+		}
+	}
+	if len(errs) == 0 {
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			fail("", "", err.Error())
+		}
+	}
+
+	var workDate time.Time
+	if req.WorkDate != "" {
+		var err error
+		if workDate, err = parseSheetDate(req.WorkDate); err != nil {
+			fail("workDate", req.WorkDate, "Invalid work date format. Use YYYY-MM-DD")
+		}
+	}
+
+	if req.TeamID != 0 && !canWriteTeamTask(c, req.TeamID) {
+		fail("team", cell("team"), "You can only create tasks for your own team")
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	task := &models.TaskDaily{
+		WorkDate:    workDate,
+		TeamID:      req.TeamID,
+		JobTypeID:   req.JobTypeID,
+		JobDetailID: req.JobDetailID,
+		FeederID:    req.FeederID,
+		NumPole:     req.NumPole,
+		DeviceCode:  req.DeviceCode,
+		Detail:      req.Detail,
+		URLsBefore:  models.StringArray(req.URLsBefore),
+		URLsAfter:   models.StringArray(req.URLsAfter),
+		Status:      models.TaskStatusDraft,
+	}
+	if req.Latitude != nil && req.Longitude != nil {
+		lat := decimal.NewFromFloat(*req.Latitude)
+		lng := decimal.NewFromFloat(*req.Longitude)
+		task.Latitude = &lat
+		task.Longitude = &lng
+	}
+	return task, nil
+}
+
+// Import - POST /v1/tasks/import (multipart/form-data, field "file")
+// Creates tasks from a CSV or XLSX file whose header row names the columns (English
+// keys or the Thai headers of the export). Teams, job types and job details are given
+// by name and feeders by code. ?mode=all (default) inserts nothing if any row is
+// invalid, ?mode=valid inserts the valid rows; ?dryRun=true only validates. The
+// response lists every problem per row, and warns about rows whose feeder is far from
+// their coordinates like create does.
+func (h *TaskHandler) Import(c *gin.Context) {
+	mode := c.DefaultQuery("mode", taskImportModeAll)
+	if mode != taskImportModeAll && mode != taskImportModeValid {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_MODE",
+				Message: "mode must be all or valid",
+			},
+		})
+		return
+	}
+	dryRun := c.Query("dryRun") == "true"
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NO_FILE",
+				Message: "No file uploaded",
+			},
+		})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > taskImportMaxSize {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "FILE_TOO_LARGE",
+				Message: "File size exceeds 10MB limit",
+			},
+		})
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_FILE_TYPE",
+				Message: "Only .csv and .xlsx files are supported",
+			},
+		})
+		return
+	}
+
+	rows, err := readTaskSheet(file, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_FILE",
+				Message: "Could not read the file: " + err.Error(),
+			},
+		})
+		return
+	}
+	if len(rows) < 2 {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "EMPTY_FILE",
+				Message: "The file needs a header row and at least one task row",
+			},
+		})
+		return
+	}
+	if len(rows)-1 > taskImportMaxRows {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "TOO_MANY_ROWS",
+				Message: fmt.Sprintf("At most %d rows can be imported at once", taskImportMaxRows),
+			},
+		})
+		return
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		if key, ok := taskSheetHeaders[normalizeSheetKey(name)]; ok {
+			columns[key] = i
+		}
+	}
+	var missing []string
+	for _, key := range taskImportRequired {
+		if _, ok := columns[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "MISSING_COLUMNS",
+				Message: "Missing columns: " + strings.Join(missing, ", "),
+			},
+		})
+		return
+	}
+
+	lookups, err := loadTaskImportLookups(h.db.WithContext(c.Request.Context()))
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while importing tasks",
+			},
+		})
+		return
+	}
+
+	report := dto.TaskImportResponse{
+		Format:   format,
+		Mode:     mode,
+		DryRun:   dryRun,
+		TaskIDs:  []int64{},
+		Errors:   []dto.TaskImportError{},
+		Warnings: []dto.TaskImportWarning{},
+	}
+	feeders := newFeederChecker(c.Request.Context(), h.db)
+	var tasks []*models.TaskDaily
+	for i, row := range rows[1:] {
+		cell := func(key string) string {
+			idx, ok := columns[key]
+			if !ok || idx >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[idx])
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		report.TotalRows++
+		task, errs := buildImportedTask(c, lookups, cell, i+2)
+		if len(errs) > 0 {
+			report.InvalidRows++
+			report.Errors = append(report.Errors, errs...)
+			continue
+		}
+		report.ValidRows++
+		tasks = append(tasks, task)
+
+		// Flag a feeder that is implausibly far from the row's position, as create does
+		if task.FeederID != nil && task.Latitude != nil && task.Longitude != nil {
+			lat, _ := task.Latitude.Float64()
+			lng, _ := task.Longitude.Float64()
+			if warning := feeders.check(*task.FeederID, lat, lng); warning != nil {
+				report.Warnings = append(report.Warnings, dto.TaskImportWarning{Row: i + 2, FeederWarning: *warning})
+			}
+		}
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, dto.StandardResponse{
+			Success: true,
+			Data:    report,
+		})
+		return
+	}
+
+	if mode == taskImportModeAll && report.InvalidRows > 0 {
+		c.JSON(http.StatusUnprocessableEntity, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "IMPORT_INVALID",
+				Message: fmt.Sprintf("%d of %d rows are invalid; nothing was imported", report.InvalidRows, report.TotalRows),
+				Details: report,
+			},
+		})
+		return
+	}
+
+	now := time.Now()
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		for _, task := range tasks {
+			task.CreatedAt = now
+			task.UpdatedAt = now
+			if err := tx.Create(task).Error; err != nil {
+				return err
+			}
+			if err := recordTaskRevision(c, tx, nil, task, audit.ActionCreate, nil); err != nil {
+				return err
+			}
+			if err := recordAudit(c, tx, audit.Entry{
+				Action:     audit.ActionCreate,
+				EntityType: models.TaskDaily{}.TableName(),
+				EntityID:   task.ID,
+				After:      task,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while importing tasks; nothing was imported",
+			},
+		})
+		return
+	}
+
+	for _, task := range tasks {
+		report.Inserted++
+		report.TaskIDs = append(report.TaskIDs, task.ID)
+	}
+
+	status := http.StatusOK
+	if report.Inserted > 0 {
+		status = http.StatusCreated
+	}
+	c.JSON(status, dto.StandardResponse{
+		Success: true,
+		Data:    report,
+	})
+}
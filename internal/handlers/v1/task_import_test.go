@@ -0,0 +1,223 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+func TestParseSheetDate(t *testing.T) {
+	want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, value := range []string{
+		"2026-03-01",
+		"46082",      // Excel serial for 2026-03-01
+		"46082.75",   // the time of day is dropped
+		"46082.0001", // and does not round to the next day
+	} {
+		got, err := parseSheetDate(value)
+		if err != nil {
+			t.Errorf("parseSheetDate(%q): %v", value, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("parseSheetDate(%q) = %s, want %s", value, got, want)
+		}
+	}
+
+	for _, value := range []string{"01/03/2026", "2026-13-01", "yesterday"} {
+		if _, err := parseSheetDate(value); err == nil {
+			t.Errorf("parseSheetDate(%q) accepted", value)
+		}
+	}
+}
+
+func TestTaskSheetHeaders(t *testing.T) {
+	tests := map[string]string{
+		"workDate": "workDate",
+		"WORKDATE": "workDate",
+		"  วันที่ปฏิบัติงาน ": "workDate",
+		"Job  Type": "",
+		"jobtype":   "jobType",
+		"ประเภทงาน": "jobType",
+		"รูปหลังปฏิบัติงาน": "urlsAfter",
+		"unknown": "",
+	}
+	for header, want := range tests {
+		if got := taskSheetHeaders[normalizeSheetKey(header)]; got != want {
+			t.Errorf("header %q maps to %q, want %q", header, got, want)
+		}
+	}
+}
+
+type taskImportFixture struct {
+	db      *gorm.DB
+	handler *TaskHandler
+}
+
+func newTaskImportFixture(t *testing.T) *taskImportFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.NewDB(t)
+	jobType := models.JobType{Name: "Overhead"}
+	for _, row := range []interface{}{&models.Team{Name: "Team A"}, &jobType} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&models.JobDetail{Name: "Replace pole", JobTypeID: &jobType.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Feeder{Code: "KLA01", StationID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &taskImportFixture{db: db, handler: NewTaskHandler(db)}
+}
+
+// importFile posts data as filename to Import and returns the status and report.
+func (f *taskImportFixture) importFile(t *testing.T, filename string, data []byte, query string) (int, dto.TaskImportResponse) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/tasks/import?"+query, &body)
+	c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+	c.Set("user_id", uint(1))
+	c.Set("permissions", permission.NewSet(permission.TaskWriteAnyTeam))
+	f.handler.Import(c)
+
+	var resp struct {
+		Data  dto.TaskImportResponse `json:"data"`
+		Error *struct {
+			Details dto.TaskImportResponse `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v: %s", err, w.Body.String())
+	}
+	if resp.Error != nil {
+		return w.Code, resp.Error.Details
+	}
+	return w.Code, resp.Data
+}
+
+func (f *taskImportFixture) taskCount(t *testing.T) int64 {
+	t.Helper()
+	var n int64
+	if err := f.db.Model(&models.TaskDaily{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// importCSV mixes Thai and English headers and holds one invalid row (unknown team).
+const importCSV = "\xef\xbb\xbfวันที่ปฏิบัติงาน,Team,ประเภทงาน,JOBDETAIL,feeder,latitude,longitude\n" +
+	"2026-03-01,team a,Overhead,Replace pole,KLA01,13.75,100.5\n" +
+	"2026-03-02,Team B,Overhead,Replace pole,,,\n"
+
+func TestImportModes(t *testing.T) {
+	tests := []struct {
+		query    string
+		status   int
+		inserted int
+	}{
+		{"mode=all", http.StatusUnprocessableEntity, 0},
+		{"mode=all&dryRun=true", http.StatusOK, 0},
+		{"mode=valid&dryRun=true", http.StatusOK, 0},
+		{"mode=valid", http.StatusCreated, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			f := newTaskImportFixture(t)
+			status, report := f.importFile(t, "tasks.csv", []byte(importCSV), tt.query)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if report.TotalRows != 2 || report.ValidRows != 1 || report.InvalidRows != 1 {
+				t.Errorf("rows total/valid/invalid = %d/%d/%d, want 2/1/1", report.TotalRows, report.ValidRows, report.InvalidRows)
+			}
+			if len(report.Errors) != 1 || report.Errors[0].Row != 3 || report.Errors[0].Column != "team" {
+				t.Errorf("errors = %+v, want one for the team of row 3", report.Errors)
+			}
+			if report.Inserted != tt.inserted || len(report.TaskIDs) != tt.inserted {
+				t.Errorf("inserted = %d with ids %v, want %d", report.Inserted, report.TaskIDs, tt.inserted)
+			}
+			if got := f.taskCount(t); got != int64(tt.inserted) {
+				t.Errorf("%d tasks stored, want %d", got, tt.inserted)
+			}
+		})
+	}
+}
+
+func TestImportXLSXSerialDate(t *testing.T) {
+	f := newTaskImportFixture(t)
+
+	book := excelize.NewFile()
+	sheet := book.GetSheetName(0)
+	rows := [][]interface{}{
+		{"workDate", "ทีม", "jobType", "รายการงาน"},
+		{46082, "Team A", "Overhead", "Replace pole"},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := book.SetSheetRow(sheet, cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	status, report := f.importFile(t, "tasks.xlsx", buf.Bytes(), "")
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d, errors %+v", status, report.Errors)
+	}
+	if report.Format != "xlsx" || report.Inserted != 1 {
+		t.Fatalf("report = %+v, want one xlsx row inserted", report)
+	}
+
+	var task models.TaskDaily
+	if err := f.db.First(&task, report.TaskIDs[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := task.WorkDate.Format("2006-01-02"); got != "2026-03-01" {
+		t.Errorf("workDate = %s, want 2026-03-01", got)
+	}
+	if task.Status != models.TaskStatusDraft {
+		t.Errorf("status = %s, want draft", task.Status)
+	}
+}
+
+func TestImportMissingColumns(t *testing.T) {
+	f := newTaskImportFixture(t)
+	data := "workDate,team,jobType\n2026-03-01,Team A,Overhead\n"
+	status, _ := f.importFile(t, "tasks.csv", []byte(data), "")
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+	if got := f.taskCount(t); got != 0 {
+		t.Errorf("%d tasks stored, want 0", got)
+	}
+}
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+func TestUpdateTaskSingleCoordinate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lat, lng := decimal.RequireFromString("13.75"), decimal.RequireFromString("100.5")
+
+	tests := []struct {
+		name    string
+		located bool
+		body    gin.H
+		status  int
+		lat     string
+		lng     string
+	}{
+		{"latitude only", true, gin.H{"latitude": 14.0}, http.StatusOK, "14", "100.5"},
+		{"longitude only", true, gin.H{"longitude": 101.25}, http.StatusOK, "13.75", "101.25"},
+		{"both", true, gin.H{"latitude": 14.0, "longitude": 101.25}, http.StatusOK, "14", "101.25"},
+		{"neither", true, gin.H{"detail": "checked"}, http.StatusOK, "13.75", "100.5"},
+		{"merged outside Thailand", true, gin.H{"longitude": 110.0}, http.StatusBadRequest, "13.75", "100.5"},
+		{"one coordinate of an unlocated task", false, gin.H{"latitude": 14.0}, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			task := models.TaskDaily{
+				WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+				JobTypeID:   1,
+				JobDetailID: 1,
+				TeamID:      1,
+				Status:      models.TaskStatusDraft,
+			}
+			if tt.located {
+				task.Latitude, task.Longitude = &lat, &lng
+			}
+			if err := db.Create(&task).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			payload, _ := json.Marshal(tt.body)
+			id := strconv.FormatInt(task.ID, 10)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPut, "/v1/tasks/"+id, bytes.NewReader(payload))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Request.Header.Set("If-Match", etag(task.Version))
+			c.Params = gin.Params{{Key: "id", Value: id}}
+			c.Set("user_id", uint(1))
+			c.Set("permissions", permission.NewSet(permission.TaskWriteAnyTeam))
+			NewTaskHandler(db).Update(c)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+
+			var stored models.TaskDaily
+			if err := db.First(&stored, task.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			gotLat, gotLng := "", ""
+			if stored.Latitude != nil {
+				gotLat = stored.Latitude.String()
+			}
+			if stored.Longitude != nil {
+				gotLng = stored.Longitude.String()
+			}
+			if gotLat != tt.lat || gotLng != tt.lng {
+				t.Errorf("stored %s,%s, want %s,%s", gotLat, gotLng, tt.lat, tt.lng)
+			}
+		})
+	}
+}
+
+func TestDeleteTaskTeamScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ownTeam, otherTeam := int64(1), int64(2)
+
+	tests := []struct {
+		name   string
+		perms  permission.Set
+		team   *int64
+		status int
+	}{
+		{"own team of own-team writer", permission.NewSet(permission.TaskWriteOwnTeam), &ownTeam, http.StatusNoContent},
+		{"other team of own-team writer", permission.NewSet(permission.TaskWriteOwnTeam), &otherTeam, http.StatusForbidden},
+		{"own-team writer without a team", permission.NewSet(permission.TaskWriteOwnTeam), nil, http.StatusForbidden},
+		{"other team of any-team writer", permission.NewSet(permission.TaskWriteAnyTeam), &otherTeam, http.StatusNoContent},
+		{"any-team writer without a team", permission.NewSet(permission.TaskWriteAnyTeam), nil, http.StatusNoContent},
+		{"own team without task permissions", permission.NewSet(permission.UploadWrite), &ownTeam, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			task := models.TaskDaily{
+				WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+				JobTypeID:   1,
+				JobDetailID: 1,
+				TeamID:      ownTeam,
+			}
+			if err := db.Create(&task).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			id := strconv.FormatInt(task.ID, 10)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/v1/tasks/"+id, nil)
+			c.Request.Header.Set("If-Match", etag(task.Version))
+			c.Params = gin.Params{{Key: "id", Value: id}}
+			c.Set("permissions", tt.perms)
+			c.Set("team_id", tt.team)
+			NewTaskHandler(db).Delete(c)
+			c.Writer.WriteHeaderNow()
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			var stored models.TaskDaily
+			if err := db.Unscoped().First(&stored, task.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if deleted := stored.DeletedAt.Valid; deleted != (tt.status == http.StatusNoContent) {
+				t.Errorf("deleted = %v after status %d", deleted, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// taskTransition is one workflow action on a task.
+type taskTransition struct {
+	// next maps each status the action may start from to the status it leads to.
+	next map[string]string
+	// review actions are gated by task:approve in the route policy and are refused to
+	// the user who submitted the task; the others need write access to the task's team.
+	review bool
+}
+
+// taskTransitions is the task workflow: draft → submitted → approved or rejected, and a
+// rejected task that has been fixed is submitted again as corrected for another review.
+var taskTransitions = map[string]taskTransition{
+	audit.ActionSubmit: {next: map[string]string{
+		models.TaskStatusDraft:    models.TaskStatusSubmitted,
+		models.TaskStatusRejected: models.TaskStatusCorrected,
+	}},
+	audit.ActionApprove: {review: true, next: map[string]string{
+		models.TaskStatusSubmitted: models.TaskStatusApproved,
+		models.TaskStatusCorrected: models.TaskStatusApproved,
+	}},
+	audit.ActionReject: {review: true, next: map[string]string{
+		models.TaskStatusSubmitted: models.TaskStatusRejected,
+		models.TaskStatusCorrected: models.TaskStatusRejected,
+	}},
+}
+
+// canEditTaskInStatus reports whether the caller may edit, delete or revert a task in
+// status. Drafts and rejected tasks are open to their team; a task under review or
+// approved can only be changed with task:approve.
+func canEditTaskInStatus(c *gin.Context, status string) bool {
+	if status == models.TaskStatusDraft || status == models.TaskStatusRejected {
+		return true
+	}
+	return middleware.Permissions(c).Has(permission.TaskApprove)
+}
+
+// reopenEditedTask treats an edit of a task under review or approved (which needs
+// task:approve) as a new submission by the editor: the task is corrected again, so
+// someone other than the editor has to approve it.
+func reopenEditedTask(c *gin.Context, task *models.TaskDaily, now time.Time) {
+	switch task.Status {
+	case models.TaskStatusSubmitted, models.TaskStatusCorrected, models.TaskStatusApproved:
+		task.Status = models.TaskStatusCorrected
+		task.SubmittedAt = &now
+		task.SubmittedByID = auditActor(c).UserID
+	}
+}
+
+// respondTaskLocked writes the 409 returned when canEditTaskInStatus fails.
+func respondTaskLocked(c *gin.Context, status string) {
+	c.JSON(http.StatusConflict, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "TASK_LOCKED",
+			Message: fmt.Sprintf("The task is %s; only a reviewer can change it", status),
+		},
+	})
+}
+
+func formatTaskTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// Submit - POST /v1/tasks/:id/submit
+// Sends a draft for review, or a rejected task that has been fixed as corrected.
+func (h *TaskHandler) Submit(c *gin.Context) {
+	h.transition(c, audit.ActionSubmit, func(task *models.TaskDaily, now time.Time) {
+		task.SubmittedAt = &now
+		task.SubmittedByID = auditActor(c).UserID
+	})
+}
+
+// Approve - POST /v1/tasks/:id/approve
+// Accepts a submitted or corrected task; only approved tasks count with ?approvedOnly=true
+// on the dashboard. A reviewer cannot approve a task they submitted.
+func (h *TaskHandler) Approve(c *gin.Context) {
+	h.transition(c, audit.ActionApprove, func(task *models.TaskDaily, now time.Time) {
+		setTaskReviewer(c, task, now)
+		task.RejectionReason = nil
+	})
+}
+
+// Reject - POST /v1/tasks/:id/reject
+// Returns a submitted or corrected task to its team with the reason it must be fixed.
+// Like Approve, it is refused to the user who submitted the task.
+func (h *TaskHandler) Reject(c *gin.Context) {
+	var req dto.RejectTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "VALIDATION_ERROR",
+				Message: "A rejection reason is required",
+			},
+		})
+		return
+	}
+
+	h.transition(c, audit.ActionReject, func(task *models.TaskDaily, now time.Time) {
+		setTaskReviewer(c, task, now)
+		task.RejectionReason = &reason
+	})
+}
+
+// setTaskReviewer records the caller as the reviewer of task.
+func setTaskReviewer(c *gin.Context, task *models.TaskDaily, now time.Time) {
+	actor := auditActor(c)
+	task.ReviewedByID = actor.UserID
+	task.ReviewedByName = nil
+	if actor.Name != "" {
+		task.ReviewedByName = &actor.Name
+	}
+	task.ReviewedAt = &now
+}
+
+// isTaskSubmitter reports whether the caller is the user who last submitted task.
+func isTaskSubmitter(c *gin.Context, task *models.TaskDaily) bool {
+	actor := auditActor(c)
+	return actor.UserID != nil && task.SubmittedByID != nil && *actor.UserID == *task.SubmittedByID
+}
+
+// transition applies the workflow action to the task :id. apply sets the fields that go
+// with the new status. An If-Match header is optional here, but when sent it must match
+// so a reviewer only approves the version they have seen.
+func (h *TaskHandler) transition(c *gin.Context, action string, apply func(task *models.TaskDaily, now time.Time)) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	var task models.TaskDaily
+	if err := h.db.WithContext(c.Request.Context()).First(&task, id).Error; err != nil {
+		respondTaskNotFound(c)
+		return
+	}
+
+	t := taskTransitions[action]
+	if !t.review && !canWriteTeamTask(c, task.TeamID) {
+		respondTeamForbidden(c)
+		return
+	}
+	if t.review && isTaskSubmitter(c, &task) {
+		c.JSON(http.StatusForbidden, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "SELF_REVIEW",
+				Message: "A task must be reviewed by someone other than the user who submitted it",
+			},
+		})
+		return
+	}
+
+	next, ok := t.next[task.Status]
+	if !ok {
+		c.JSON(http.StatusConflict, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_STATUS_TRANSITION",
+				Message: fmt.Sprintf("Cannot %s a task that is %s", action, task.Status),
+			},
+		})
+		return
+	}
+
+	if c.GetHeader("If-Match") != "" && !requireIfMatch(c, task.Version, h.currentTask(c, id)) {
+		return
+	}
+
+	before := task
+
+	now := time.Now()
+	apply(&task, now)
+	task.Status = next
+	task.UpdatedAt = now
+
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &task, &task.Version); err != nil {
+			return err
+		}
+		if err := recordTaskRevision(c, tx, &before, &task, action, nil); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     action,
+			EntityType: models.TaskDaily{}.TableName(),
+			EntityID:   task.ID,
+			Before:     &before,
+			After:      &task,
+		})
+	})
+	if errors.Is(err, errVersionConflict) {
+		respondVersionConflict(c, h.currentTask(c, id))
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while updating the task status",
+			},
+		})
+		return
+	}
+
+	// Reload with relations
+	h.db.
+		Preload("Team").
+		Preload("JobType").
+		Preload("JobDetail").
+		Preload("Feeder.Station.OperationCenter").
+		First(&task, task.ID)
+
+	setETag(c, task.Version)
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    convertTaskToResponse(&task),
+	})
+}
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReviewBySubmitterRefused(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	h := NewTaskHandler(db)
+	task := models.TaskDaily{
+		WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		JobTypeID:   1,
+		JobDetailID: 1,
+		TeamID:      1,
+		Status:      models.TaskStatusDraft,
+	}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(task.ID, 10)
+
+	call := func(handler gin.HandlerFunc, action string, userID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/tasks/"+id+"/"+action, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		c.Set("user_id", userID)
+		c.Set("permissions", permission.NewSet(permission.TaskWriteAnyTeam, permission.TaskApprove))
+		handler(c)
+		return w.Code
+	}
+
+	if status := call(h.Submit, "submit", 1); status != http.StatusOK {
+		t.Fatalf("submit status = %d", status)
+	}
+	var stored models.TaskDaily
+	if err := db.First(&stored, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.SubmittedByID == nil || *stored.SubmittedByID != 1 {
+		t.Fatalf("submittedById = %v, want 1", stored.SubmittedByID)
+	}
+
+	if status := call(h.Approve, "approve", 1); status != http.StatusForbidden {
+		t.Errorf("approve by the submitter: status = %d, want 403", status)
+	}
+	if status := call(h.Approve, "approve", 2); status != http.StatusOK {
+		t.Errorf("approve by another reviewer: status = %d, want 200", status)
+	}
+	if err := db.First(&stored, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.TaskStatusApproved || stored.ReviewedByID == nil || *stored.ReviewedByID != 2 {
+		t.Errorf("task is %s reviewed by %v, want approved by 2", stored.Status, stored.ReviewedByID)
+	}
+}
+
+// TestReviewByEditorRefused has a reviewer edit a submitted task: the edit needs another
+// reviewer's approval like a submission of their own.
+func TestReviewByEditorRefused(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	h := NewTaskHandler(db)
+	submitter := uint(1)
+	task := models.TaskDaily{
+		WorkDate:      time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		JobTypeID:     1,
+		JobDetailID:   1,
+		TeamID:        1,
+		Status:        models.TaskStatusSubmitted,
+		SubmittedByID: &submitter,
+	}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(task.ID, 10)
+	newContext := func(w *httptest.ResponseRecorder, method, target string, body []byte, userID uint) *gin.Context {
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, target, bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		c.Set("user_id", userID)
+		c.Set("permissions", permission.NewSet(permission.TaskWriteAnyTeam, permission.TaskApprove))
+		return c
+	}
+
+	w := httptest.NewRecorder()
+	c := newContext(w, http.MethodPut, "/v1/tasks/"+id, []byte(`{"detail":"edited by the reviewer"}`), 2)
+	c.Request.Header.Set("If-Match", etag(task.Version))
+	h.Update(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("edit status = %d: %s", w.Code, w.Body.String())
+	}
+	var stored models.TaskDaily
+	if err := db.First(&stored, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.TaskStatusCorrected || stored.SubmittedByID == nil || *stored.SubmittedByID != 2 {
+		t.Fatalf("edited task is %s submitted by %v, want corrected by 2", stored.Status, stored.SubmittedByID)
+	}
+
+	for _, tt := range []struct {
+		userID uint
+		status int
+	}{
+		{2, http.StatusForbidden},
+		{1, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		h.Approve(newContext(w, http.MethodPost, "/v1/tasks/"+id+"/approve", nil, tt.userID))
+		if w.Code != tt.status {
+			t.Errorf("approve by %d: status = %d, want %d", tt.userID, w.Code, tt.status)
+		}
+	}
+}
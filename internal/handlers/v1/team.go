@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/internal/models"
 
@@ -23,7 +24,7 @@ func NewTeamHandler(db *gorm.DB) *TeamHandler {
 // List retrieves all teams with their task counts.
 func (h *TeamHandler) List(c *gin.Context) {
 	var teams []models.Team
-	if err := h.db.WithContext(c.Request.Context()).Find(&teams).Error; err != nil {
+	if err := readDB(c, h.db).Find(&teams).Error; err != nil {
 		log.Printf("Failed to fetch teams: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -46,8 +47,9 @@ func (h *TeamHandler) List(c *gin.Context) {
 	var response []dto.TeamResponse
 	for _, t := range teams {
 		response = append(response, dto.TeamResponse{
-			ID:   t.ID,
-			Name: t.Name,
+			ID:        t.ID,
+			Name:      t.Name,
+			DeletedAt: formatDeletedAt(t.DeletedAt),
 			Count: &dto.Count{
 				Tasks: countMap[t.ID],
 			},
@@ -75,7 +77,7 @@ func (h *TeamHandler) GetByID(c *gin.Context) {
 	}
 
 	var team models.Team
-	if err := h.db.WithContext(c.Request.Context()).First(&team, id).Error; err != nil {
+	if err := readDB(c, h.db).First(&team, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -100,8 +102,9 @@ func (h *TeamHandler) GetByID(c *gin.Context) {
 	count := models.CountTasksFor(h.db, models.TaskCol.TeamID, id)
 
 	response := dto.TeamResponse{
-		ID:   team.ID,
-		Name: team.Name,
+		ID:        team.ID,
+		Name:      team.Name,
+		DeletedAt: formatDeletedAt(team.DeletedAt),
 		Count: &dto.Count{
 			Tasks: count,
 		},
@@ -130,7 +133,17 @@ func (h *TeamHandler) Create(c *gin.Context) {
 	}
 
 	team := models.Team{Name: req.Name}
-	if err := h.db.WithContext(c.Request.Context()).Create(&team).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&team).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.Team{}.TableName(),
+			EntityID:   team.ID,
+			After:      &team,
+		})
+	}); err != nil {
 		log.Printf("Failed to create team: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -207,8 +220,21 @@ func (h *TeamHandler) Update(c *gin.Context) {
 		return
 	}
 
+	before := team
+
 	team.Name = req.Name
-	if err := h.db.WithContext(c.Request.Context()).Save(&team).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&team).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.Team{}.TableName(),
+			EntityID:   team.ID,
+			Before:     &before,
+			After:      &team,
+		})
+	}); err != nil {
 		log.Printf("Failed to update team %d: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -236,7 +262,8 @@ func (h *TeamHandler) Update(c *gin.Context) {
 	})
 }
 
-// Delete removes a team by ID.
+// Delete soft deletes a team by ID. Rows that reference it are handled by
+// ?strategy=block|reassign|cascade (see deleteReference); ?dryRun=true only reports them.
 func (h *TeamHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -250,29 +277,117 @@ func (h *TeamHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.WithContext(c.Request.Context()).Delete(&models.Team{}, id)
-	if result.Error != nil {
-		log.Printf("Failed to delete team %d: %v", id, result.Error)
+	var before models.Team
+	if err := h.db.WithContext(c.Request.Context()).First(&before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Team not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch team %d for deletion: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the team",
+			},
+		})
+		return
+	}
+
+	deleteReference(c, h.db, &before, id, nil, nil)
+}
+
+// Restore restores a soft-deleted team by ID.
+func (h *TeamHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid team ID",
+			},
+		})
+		return
+	}
+
+	var team models.Team
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&team, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "NOT_FOUND",
+					Message: "Team not found",
+				},
+			})
+			return
+		}
+		log.Printf("Failed to fetch team %d for restore: %v", id, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
 				Code:    "INTERNAL_ERROR",
-				Message: "An error occurred while deleting the team",
+				Message: "An error occurred while fetching the team",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, dto.StandardResponse{
+	if !team.DeletedAt.Valid {
+		respondNotDeleted(c, "Team is not deleted")
+		return
+	}
+
+	before := team
+
+	team.DeletedAt = gorm.DeletedAt{}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&team).Update("deletedAt", nil).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.Team{}.TableName(),
+			EntityID:   team.ID,
+			Before:     &before,
+			After:      &team,
+		})
+	}); err != nil {
+		log.Printf("Failed to restore team %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
-				Code:    "NOT_FOUND",
-				Message: "Team not found",
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the team",
 			},
 		})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	count := models.CountTasksFor(h.db, models.TaskCol.TeamID, id)
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data: dto.TeamResponse{
+			ID:   team.ID,
+			Name: team.Name,
+			Count: &dto.Count{
+				Tasks: count,
+			},
+		},
+	})
+}
+
+// Merge - POST /v1/teams/merge
+// Merges duplicate teams: rows referencing any of sourceIds are moved to targetId and the
+// sources are soft-deleted (see mergeReferences).
+func (h *TeamHandler) Merge(c *gin.Context) {
+	mergeReferences(c, h.db, models.Team{}.TableName())
 }
@@ -0,0 +1,212 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/topology"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// topologyImportMaxSize caps the size of an uploaded topology document.
+const topologyImportMaxSize = 10 << 20
+
+type TopologyHandler struct {
+	db *gorm.DB
+}
+
+func NewTopologyHandler(db *gorm.DB) *TopologyHandler {
+	return &TopologyHandler{db: db}
+}
+
+// topologyBody returns the uploaded document: the multipart/form-data field "file" or
+// else the request body. format is ?format=, else the file extension or the body's
+// Content-Type. It writes the 400 and reports false when the file is too large.
+func topologyBody(c *gin.Context) (body io.Reader, format string, closeFn func(), ok bool) {
+	format = c.Query("format")
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		if header.Size > topologyImportMaxSize {
+			file.Close()
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "FILE_TOO_LARGE",
+					Message: "File size exceeds 10MB limit",
+				},
+			})
+			return nil, "", nil, false
+		}
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+		return file, format, func() { file.Close() }, true
+	}
+
+	if format == "" {
+		switch c.ContentType() {
+		case "application/json":
+			format = "json"
+		case "application/geo+json":
+			format = "geojson"
+		case "text/csv":
+			format = "csv"
+		}
+	}
+	return http.MaxBytesReader(c.Writer, c.Request.Body, topologyImportMaxSize), format, func() {}, true
+}
+
+// Import - POST /v1/topology/import
+// Upserts operation centers, PEAs, stations and feeders from a JSON document or a CSV
+// file, sent either as multipart/form-data (field "file") or as the request body with
+// Content-Type application/json or text/csv. Stations are matched by codeName and
+// feeders by code. ?dryRun=true returns the diff without writing anything.
+func (h *TopologyHandler) Import(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "true"
+
+	body, format, closeFn, ok := topologyBody(c)
+	if !ok {
+		return
+	}
+	defer closeFn()
+
+	var doc *topology.Document
+	var err error
+	switch format {
+	case "json":
+		doc, err = topology.ParseJSON(body)
+	case "csv":
+		doc, err = topology.ParseCSV(body)
+	default:
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_FILE_TYPE",
+				Message: "Only .json and .csv documents are supported",
+			},
+		})
+		return
+	}
+	if err != nil {
+		respondTopologyError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	var result *topology.Result
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if result, err = topology.Apply(c.Request.Context(), tx, doc, dryRun); err != nil {
+			return err
+		}
+		for _, entry := range result.Audit {
+			if err := recordAudit(c, tx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondTopologyError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// ImportGeometry - POST /v1/topology/geometry
+// Sets station locations and feeder routes from a GeoJSON FeatureCollection: Point
+// features with a "codeName" property for stations and LineString features with a
+// "code" property for feeders. Sent like Import, as a .geojson/.json file or as the
+// body. ?dryRun=true returns the diff without writing anything.
+func (h *TopologyHandler) ImportGeometry(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "true"
+
+	body, format, closeFn, ok := topologyBody(c)
+	if !ok {
+		return
+	}
+	defer closeFn()
+
+	if format != "geojson" && format != "json" {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_FILE_TYPE",
+				Message: "Only .geojson documents are supported",
+			},
+		})
+		return
+	}
+
+	geometry, err := topology.ParseGeoJSON(body)
+	if err != nil {
+		respondTopologyError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	var result *topology.Result
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if result, err = topology.ApplyGeometry(c.Request.Context(), tx, geometry, dryRun); err != nil {
+			return err
+		}
+		for _, entry := range result.Audit {
+			if err := recordAudit(c, tx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondTopologyError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// respondTopologyError reports the problems of a *topology.ProblemsError with 400 and any
+// other error with status.
+func respondTopologyError(c *gin.Context, err error, status int) {
+	var problems *topology.ProblemsError
+	if errors.As(err, &problems) {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "VALIDATION_ERROR",
+				Message: problems.Error(),
+				Details: problems.Problems,
+			},
+		})
+		return
+	}
+	if status == http.StatusBadRequest {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_FILE",
+				Message: "Could not read the document: " + err.Error(),
+			},
+		})
+		return
+	}
+	log.Printf("Database error: %v", err)
+	c.JSON(status, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "INTERNAL_ERROR",
+			Message: "An error occurred while importing the topology",
+		},
+	})
+}
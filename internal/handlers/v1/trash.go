@@ -0,0 +1,379 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/pkg/s3"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashKind describes one type of soft-deleted row shown in the trash bin.
+type trashKind struct {
+	newModel  func() interface{} // pointer to a zero model, for loading and hard-deleting a row
+	table     string
+	deletedAt string // quoted soft-delete column
+	label     string // SQL expression shown as the item label
+	keep      bool   // never purged, only restored
+}
+
+var trashKinds = map[string]trashKind{
+	"task": {
+		newModel:  func() interface{} { return &models.TaskDaily{} },
+		table:     models.TaskDaily{}.TableName(),
+		deletedAt: models.TaskCol.DeletedAt,
+		label:     models.TaskCol.WorkDate + "::text",
+	},
+	"job-detail": {
+		newModel:  func() interface{} { return &models.JobDetail{} },
+		table:     models.JobDetail{}.TableName(),
+		deletedAt: models.JobDetailCol.DeletedAt,
+		label:     `"name"`,
+	},
+	"feeder": {
+		newModel:  func() interface{} { return &models.Feeder{} },
+		table:     models.Feeder{}.TableName(),
+		deletedAt: models.FeederCol.DeletedAt,
+		label:     `"code"`,
+	},
+	"station": {
+		newModel:  func() interface{} { return &models.Station{} },
+		table:     models.Station{}.TableName(),
+		deletedAt: models.StationCol.DeletedAt,
+		label:     `"name"`,
+	},
+	"pea": {
+		newModel:  func() interface{} { return &models.PEA{} },
+		table:     models.PEA{}.TableName(),
+		deletedAt: models.PEACol.DeletedAt,
+		label:     `"name"`,
+	},
+	"team": {
+		newModel:  func() interface{} { return &models.Team{} },
+		table:     models.Team{}.TableName(),
+		deletedAt: models.TeamCol.DeletedAt,
+		label:     `"name"`,
+	},
+	"job-type": {
+		newModel:  func() interface{} { return &models.JobType{} },
+		table:     models.JobType{}.TableName(),
+		deletedAt: models.JobTypeCol.DeletedAt,
+		label:     `"name"`,
+	},
+	"operation-center": {
+		newModel:  func() interface{} { return &models.OperationCenter{} },
+		table:     models.OperationCenter{}.TableName(),
+		deletedAt: models.OperationCenterCol.DeletedAt,
+		label:     `"name"`,
+	},
+	// Users stay in the trash for good: the audit log, revisions and tasks keep
+	// referring to them by ID.
+	"user": {
+		newModel:  func() interface{} { return &models.User{} },
+		table:     models.User{}.TableName(),
+		deletedAt: models.UserCol.DeletedAt,
+		label:     `"username"`,
+		keep:      true,
+	},
+}
+
+// trashPurgeOrder lists the trash types referencing rows first, so a purge removes
+// tasks before the job details, feeders and teams they point at, and stations and PEAs
+// before their operation center.
+var trashPurgeOrder = []string{"task", "job-detail", "feeder", "station", "pea", "team", "job-type", "operation-center"}
+
+// trashTypes lists every trash type, the purged ones in trashPurgeOrder first.
+var trashTypes = append(append([]string{}, trashPurgeOrder...), "user")
+
+type TrashHandler struct {
+	db        *gorm.DB
+	r2Client  *s3.R2Client
+	retention time.Duration
+}
+
+// NewTrashHandler creates the trash bin handler. Without a working R2 client purges
+// still remove rows but report the photos of purged tasks as failed.
+func NewTrashHandler(cfg *config.Config, db *gorm.DB) *TrashHandler {
+	h := &TrashHandler{db: db, retention: config.DefaultTrashRetention}
+
+	// LoadConfig has already rejected an invalid retention
+	if d, err := cfg.Trash.RetentionDuration(); err == nil {
+		h.retention = d
+	}
+
+	r2Client, err := newR2Client(cfg)
+	if err != nil {
+		log.Printf("Warning: R2 client for trash purge failed to initialize: %v", err)
+	} else {
+		h.r2Client = r2Client
+	}
+	return h
+}
+
+// trashKindFromQuery resolves the ?type= parameter, writing a 400 when it is not a trash type.
+func trashKindFromQuery(c *gin.Context, value string) (trashKind, bool) {
+	kind, ok := trashKinds[value]
+	if !ok {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_TYPE",
+				Message: "type must be one of " + strings.Join(trashTypes, ", "),
+			},
+		})
+	}
+	return kind, ok
+}
+
+// List - GET /v1/trash?type=task|job-detail|feeder|station|pea|team|job-type|operation-center|user
+// Lists soft-deleted items of one type, most recently deleted first, with who deleted
+// them (from the audit log) and when they become eligible for purge.
+func (h *TrashHandler) List(c *gin.Context) {
+	typeName := c.Query("type")
+	kind, ok := trashKindFromQuery(c, typeName)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	query := h.db.WithContext(c.Request.Context()).Table(kind.table).
+		Where(kind.deletedAt + " IS NOT NULL")
+
+	var total int64
+	query.Count(&total)
+
+	type trashRow struct {
+		ID        int64     `gorm:"column:id"`
+		Label     string    `gorm:"column:label"`
+		DeletedAt time.Time `gorm:"column:deleted_at"`
+	}
+	var rows []trashRow
+	if err := query.
+		Select("id, " + kind.label + " AS label, " + kind.deletedAt + " AS deleted_at").
+		Order(kind.deletedAt + " DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while fetching the trash",
+			},
+		})
+		return
+	}
+
+	ids := make([]int64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	deleters := h.deleters(c.Request.Context(), kind.table, ids)
+
+	response := make([]dto.TrashItemResponse, 0, len(rows))
+	for _, r := range rows {
+		item := dto.TrashItemResponse{
+			Type:      typeName,
+			ID:        r.ID,
+			Label:     r.Label,
+			DeletedAt: r.DeletedAt.Format(time.RFC3339),
+		}
+		if !kind.keep {
+			item.PurgeAfter = r.DeletedAt.Add(h.retention).Format(time.RFC3339)
+		}
+		if entry, ok := deleters[r.ID]; ok {
+			item.DeletedByID = entry.ActorUserID
+			item.DeletedByName = entry.ActorName
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+		Meta: &dto.Meta{
+			Page:  page,
+			Limit: limit,
+			Total: total,
+		},
+	})
+}
+
+// deleters returns the latest delete entry in the audit log for each of ids.
+func (h *TrashHandler) deleters(ctx context.Context, entityType string, ids []int64) map[int64]models.AuditLog {
+	result := make(map[int64]models.AuditLog, len(ids))
+	if len(ids) == 0 {
+		return result
+	}
+
+	entityIDs := make([]string, len(ids))
+	for i, id := range ids {
+		entityIDs[i] = strconv.FormatInt(id, 10)
+	}
+
+	var entries []models.AuditLog
+	if err := h.db.WithContext(ctx).
+		Where(models.AuditLogCol.EntityType+" = ? AND "+models.AuditLogCol.Action+" = ?", entityType, audit.ActionDelete).
+		Where(models.AuditLogCol.EntityID+" IN ?", entityIDs).
+		Order(models.AuditLogCol.OccurredAt + " DESC").
+		Find(&entries).Error; err != nil {
+		log.Printf("Failed to look up deleters: %v", err)
+		return result
+	}
+
+	for _, e := range entries {
+		id, err := strconv.ParseInt(e.EntityID, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, seen := result[id]; !seen {
+			result[id] = e
+		}
+	}
+	return result
+}
+
+// Purge - POST /v1/trash/purge (admin only)
+// Hard-deletes items that have been in the trash longer than the configured retention,
+// including the history and R2 photos of purged tasks. ?type= limits the purge to one
+// type. Items that live rows still reference are skipped and stay in the trash. Deleted
+// users are never purged.
+func (h *TrashHandler) Purge(c *gin.Context) {
+	typeNames := trashPurgeOrder
+	if typeName := c.Query("type"); typeName != "" {
+		kind, ok := trashKindFromQuery(c, typeName)
+		if !ok {
+			return
+		}
+		if kind.keep {
+			c.JSON(http.StatusBadRequest, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INVALID_TYPE",
+					Message: "Deleted " + typeName + "s are kept and cannot be purged",
+				},
+			})
+			return
+		}
+		typeNames = []string{typeName}
+	}
+
+	cutoff := time.Now().Add(-h.retention)
+	response := dto.PurgeTrashResponse{
+		Cutoff:  cutoff.Format(time.RFC3339),
+		Purged:  make(map[string]int),
+		Skipped: make(map[string]int),
+	}
+
+	for _, typeName := range typeNames {
+		kind := trashKinds[typeName]
+
+		var ids []int64
+		if err := h.db.WithContext(c.Request.Context()).Table(kind.table).
+			Where(kind.deletedAt+" < ?", cutoff).
+			Order("id").
+			Pluck("id", &ids).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+				Success: false,
+				Error: &dto.ErrorInfo{
+					Code:    "INTERNAL_ERROR",
+					Message: "An error occurred while purging the trash",
+				},
+			})
+			return
+		}
+
+		for _, id := range ids {
+			row := kind.newModel()
+			if err := h.purgeRow(c, kind, row, id, cutoff); err != nil {
+				log.Printf("Failed to purge %s %d: %v", kind.table, id, err)
+				response.Skipped[typeName]++
+				continue
+			}
+			response.Purged[typeName]++
+
+			if task, ok := row.(*models.TaskDaily); ok {
+				removed, failed := h.removePhotos(c.Request.Context(), task)
+				response.PhotosRemoved += removed
+				response.PhotosFailed += failed
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// purgeRow loads row id into row and hard-deletes it, together with the revisions of a
+// task, unless it was restored or deleted again after cutoff in the meantime. The purge
+// is recorded in the audit log with the final snapshot.
+func (h *TrashHandler) purgeRow(c *gin.Context, kind trashKind, row interface{}, id int64, cutoff time.Time) error {
+	return h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where(kind.deletedAt+" < ?", cutoff).First(row, id).Error; err != nil {
+			return err
+		}
+		if _, ok := row.(*models.TaskDaily); ok {
+			if err := tx.Where(models.TaskRevisionCol.TaskID+" = ?", id).Delete(&models.TaskRevision{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Delete(row).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionPurge,
+			EntityType: kind.table,
+			EntityID:   id,
+			Before:     row,
+		})
+	})
+}
+
+// removePhotos deletes the R2 objects behind a purged task's photo URLs. URLs outside
+// the bucket are left alone.
+func (h *TrashHandler) removePhotos(ctx context.Context, task *models.TaskDaily) (removed, failed int) {
+	urls := append(append([]string{}, task.URLsBefore...), task.URLsAfter...)
+	for _, url := range urls {
+		if h.r2Client == nil {
+			failed++
+			continue
+		}
+		key, ok := h.r2Client.KeyFromURL(url)
+		if !ok {
+			continue
+		}
+
+		deleteCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := h.r2Client.DeleteObject(deleteCtx, key)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to delete photo %s of purged task %d: %v", key, task.ID, err)
+			failed++
+			continue
+		}
+		removed++
+	}
+	return removed, failed
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestTrashKeepsUsers checks that deleted users are listed in the trash but never purged,
+// while other types past the retention are.
+func TestTrashKeepsUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	h := NewTrashHandler(&config.Config{Trash: config.TrashConfig{Retention: "1d"}}, db)
+
+	longAgo := time.Now().Add(-48 * time.Hour)
+	user := models.User{Username: "123456", Password: "unused", Role: "user", IsActive: true}
+	team := models.Team{Name: "Gone"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Unscoped().Model(&user).Update("deletedAt", longAgo).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Unscoped().Model(&team).Update("deletedAt", longAgo).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(handler gin.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, target, nil)
+		handler(c)
+		return w
+	}
+
+	w := call(h.List, http.MethodGet, "/v1/trash?type=user")
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status = %d: %s", w.Code, w.Body.String())
+	}
+	var list struct {
+		Data []dto.TrashItemResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != int64(user.ID) || list.Data[0].Label != "123456" {
+		t.Fatalf("trashed users = %+v, want user %d", list.Data, user.ID)
+	}
+	if list.Data[0].PurgeAfter != "" {
+		t.Errorf("user purgeAfter = %q, want none", list.Data[0].PurgeAfter)
+	}
+
+	if w := call(h.Purge, http.MethodPost, "/v1/trash/purge?type=user"); w.Code != http.StatusBadRequest {
+		t.Errorf("purge users: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if w := call(h.Purge, http.MethodPost, "/v1/trash/purge?type=team"); w.Code != http.StatusOK {
+		t.Fatalf("purge teams: status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := call(h.Purge, http.MethodPost, "/v1/trash/purge"); w.Code != http.StatusOK {
+		t.Fatalf("purge: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var users, teams int64
+	db.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Count(&users)
+	db.Unscoped().Model(&models.Team{}).Where("id = ?", team.ID).Count(&teams)
+	if users != 1 {
+		t.Error("deleted user was purged")
+	}
+	if teams != 0 {
+		t.Error("deleted team past retention was not purged")
+	}
+}
@@ -0,0 +1,550 @@
+package v1
+
+import (
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/pkg/password"
+	"backend-hotlines3/pkg/totp"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTwoFactorIssuer  = "Hotline"
+	defaultChallengeExpiry  = 5 * time.Minute
+	recoveryCodeCount       = 10
+	invalidTwoFactorMessage = "Invalid two-factor code"
+)
+
+// twoFactorSettings is the parsed form of config.TwoFactorConfig.
+type twoFactorSettings struct {
+	issuer          string
+	requiredRoles   map[string]bool
+	challengeExpiry time.Duration
+}
+
+func newTwoFactorSettings(cfg config.TwoFactorConfig) twoFactorSettings {
+	s := twoFactorSettings{
+		issuer:          cfg.Issuer,
+		requiredRoles:   make(map[string]bool, len(cfg.RequiredRoles)),
+		challengeExpiry: defaultChallengeExpiry,
+	}
+	if s.issuer == "" {
+		s.issuer = defaultTwoFactorIssuer
+	}
+	for _, role := range cfg.RequiredRoles {
+		s.requiredRoles[role] = true
+	}
+	if cfg.ChallengeExpiry != "" {
+		if d, err := time.ParseDuration(cfg.ChallengeExpiry); err == nil && d > 0 {
+			s.challengeExpiry = d
+		} else {
+			log.Printf("Invalid two_factor.challenge_expiry %q, using %s", cfg.ChallengeExpiry, defaultChallengeExpiry)
+		}
+	}
+	return s
+}
+
+// twoFactorRequired reports whether users with role must use 2FA to log in.
+func (h *AuthHandler) twoFactorRequired(role string) bool {
+	return h.twoFactor.requiredRoles[role]
+}
+
+// respondTwoFactorChallenge answers a password-verified login with a challenge token
+// instead of the token pair.
+func (h *AuthHandler) respondTwoFactorChallenge(c *gin.Context, user *models.User) {
+	token, expiresAt, err := h.jwtManager.GenerateChallengeToken(user.ID, user.Username, user.Role, user.TeamID, h.twoFactor.challengeExpiry)
+	if err != nil {
+		respondAuthError(c, http.StatusInternalServerError, "TOKEN_GENERATION_ERROR", "Failed to generate tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data: dto.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			SetupRequired:     !user.TOTPEnabled,
+			ChallengeToken:    token,
+			ExpiresAt:         expiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// SetupTwoFactor - POST /v1/auth/2fa/setup
+// Starts enrolment for a user whose role requires 2FA but who has not enrolled yet.
+// Authorised by the login challenge token; finish with /2fa/verify.
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+
+	user, ok := h.challengeUser(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+
+	h.startEnrolment(c, user)
+}
+
+// VerifyTwoFactor - POST /v1/auth/2fa/verify
+// Completes login with a TOTP code or a recovery code. For a user in forced enrolment
+// the code confirms the new secret, 2FA is enabled and recovery codes are returned.
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		respondAuthError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Either code or recoveryCode is required")
+		return
+	}
+
+	user, ok := h.challengeUser(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+
+	// The second factor is throttled like the password
+	attempt, ok := h.beginLoginAttempt(c, user.Username)
+	if !ok {
+		return
+	}
+	defer h.releaseLoginAttempt(c, attempt)
+
+	ctx := c.Request.Context()
+	var recoveryCodes []string
+
+	if !user.TOTPEnabled {
+		if user.TOTPSecret == nil || req.Code == "" {
+			respondAuthError(c, http.StatusBadRequest, "TWO_FACTOR_NOT_ENROLLED", "Two-factor setup has not been started")
+			return
+		}
+		valid, err := h.checkTOTP(ctx, user, req.Code)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to verify two-factor code")
+			return
+		}
+		if !valid {
+			h.recordLoginFailure(c, attempt, user.Username, &user.ID, "invalid_2fa_code")
+			respondAuthError(c, http.StatusUnauthorized, "INVALID_2FA_CODE", invalidTwoFactorMessage)
+			return
+		}
+		recoveryCodes, err = h.enableTwoFactor(c, user)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enable two-factor authentication")
+			return
+		}
+	} else {
+		valid, err := h.checkSecondFactor(ctx, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to verify two-factor code")
+			return
+		}
+		if !valid {
+			h.recordLoginFailure(c, attempt, user.Username, &user.ID, "invalid_2fa_code")
+			respondAuthError(c, http.StatusUnauthorized, "INVALID_2FA_CODE", invalidTwoFactorMessage)
+			return
+		}
+	}
+
+	response, ok := h.issueLogin(c, user, attempt)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data: dto.TwoFactorLoginResponse{
+			LoginResponse: *response,
+			RecoveryCodes: recoveryCodes,
+		},
+	})
+}
+
+// EnrollTwoFactor - POST /v1/auth/2fa/enroll
+// Generates a new secret for the current user. 2FA stays off until /2fa/enable confirms a code.
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	h.startEnrolment(c, user)
+}
+
+// EnableTwoFactor - POST /v1/auth/2fa/enable
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		respondAuthError(c, http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED", "Two-factor authentication is already enabled")
+		return
+	}
+	if user.TOTPSecret == nil {
+		respondAuthError(c, http.StatusBadRequest, "TWO_FACTOR_NOT_ENROLLED", "Call /v1/auth/2fa/enroll first")
+		return
+	}
+
+	ctx := c.Request.Context()
+	valid, err := h.checkTOTP(ctx, user, req.Code)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to verify two-factor code")
+		return
+	}
+	if !valid {
+		respondAuthError(c, http.StatusBadRequest, "INVALID_2FA_CODE", invalidTwoFactorMessage)
+		return
+	}
+
+	codes, err := h.enableTwoFactor(c, user)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    dto.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// DisableTwoFactor - POST /v1/auth/2fa/disable
+// Not allowed for roles that require 2FA.
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if h.twoFactorRequired(user.Role) {
+		respondAuthError(c, http.StatusForbidden, "TWO_FACTOR_REQUIRED", "Two-factor authentication is required for your role")
+		return
+	}
+	if !user.TOTPEnabled {
+		respondAuthError(c, http.StatusBadRequest, "TWO_FACTOR_NOT_ENABLED", "Two-factor authentication is not enabled")
+		return
+	}
+
+	ctx := c.Request.Context()
+	valid, err := h.checkTOTP(ctx, user, req.Code)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to verify two-factor code")
+		return
+	}
+	if !valid {
+		respondAuthError(c, http.StatusBadRequest, "INVALID_2FA_CODE", invalidTwoFactorMessage)
+		return
+	}
+
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totpSecret":   nil,
+			"totpEnabled":  false,
+			"totpLastStep": 0,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(`"userId" = ?`, user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionDisable2FA,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+		})
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    gin.H{"message": "Two-factor authentication disabled"},
+	})
+}
+
+// RegenerateRecoveryCodes - POST /v1/auth/2fa/recovery-codes
+// Replaces all recovery codes; the old ones stop working.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleValidationError(c, err)
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		respondAuthError(c, http.StatusBadRequest, "TWO_FACTOR_NOT_ENABLED", "Two-factor authentication is not enabled")
+		return
+	}
+
+	ctx := c.Request.Context()
+	valid, err := h.checkTOTP(ctx, user, req.Code)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to verify two-factor code")
+		return
+	}
+	if !valid {
+		respondAuthError(c, http.StatusBadRequest, "INVALID_2FA_CODE", invalidTwoFactorMessage)
+		return
+	}
+
+	var codes []string
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRecoveryCodes,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+		})
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to generate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    dto.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// startEnrolment stores a fresh secret for user and returns it with its otpauth URI.
+func (h *AuthHandler) startEnrolment(c *gin.Context, user *models.User) {
+	if user.TOTPEnabled {
+		respondAuthError(c, http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED", "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to generate secret")
+		return
+	}
+	if err := h.db.WithContext(c.Request.Context()).Model(user).Updates(map[string]interface{}{
+		"totpSecret":   secret,
+		"totpLastStep": 0,
+	}).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		respondAuthError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to start two-factor enrolment")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data: dto.TwoFactorEnrollResponse{
+			Secret:     secret,
+			OtpauthURI: totp.URI(h.twoFactor.issuer, user.Username, secret),
+		},
+	})
+}
+
+// challengeUser resolves the user behind a login challenge token.
+func (h *AuthHandler) challengeUser(c *gin.Context, challengeToken string) (*models.User, bool) {
+	claims, err := h.jwtManager.ValidateChallengeToken(challengeToken)
+	if err != nil {
+		respondAuthError(c, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired challenge token")
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&user, claims.UserID).Error; err != nil || !user.IsActive {
+		respondAuthError(c, http.StatusUnauthorized, "INVALID_TOKEN", "User not found or inactive")
+		return nil, false
+	}
+	return &user, true
+}
+
+// currentUser loads the authenticated user.
+func (h *AuthHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&user, c.GetUint("user_id")).Error; err != nil {
+		respondAuthError(c, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return nil, false
+	}
+	return &user, true
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code.
+func (h *AuthHandler) checkSecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		return h.checkTOTP(ctx, user, code)
+	}
+	return useRecoveryCode(h.db.WithContext(ctx), user.ID, recoveryCode, h.now())
+}
+
+// checkTOTP validates code against the user's secret and records the matched step so the
+// same code cannot be used twice, even by concurrent requests.
+func (h *AuthHandler) checkTOTP(ctx context.Context, user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == nil {
+		return false, nil
+	}
+	step, ok := totp.Validate(*user.TOTPSecret, code, h.now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+
+	result := h.db.WithContext(ctx).Model(&models.User{}).
+		Where(`id = ? AND "totpLastStep" < ?`, user.ID, step).
+		Update("totpLastStep", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+// enableTwoFactor turns 2FA on for user, records it in the audit log and returns a
+// fresh set of recovery codes.
+func (h *AuthHandler) enableTwoFactor(c *gin.Context, user *models.User) ([]string, error) {
+	var codes []string
+	err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("totpEnabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionEnable2FA,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	return codes, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores new ones.
+// The plain codes are returned once; only bcrypt hashes are kept.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where(`"userId" = ?`, userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := password.HashPassword(normalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: hash, CreatedAt: time.Now()})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode marks a matching unused recovery code as used at now.
+func useRecoveryCode(db *gorm.DB, userID uint, code string, now time.Time) (bool, error) {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return false, nil
+	}
+
+	var rows []models.RecoveryCode
+	if err := db.Where(`"userId" = ? AND "usedAt" IS NULL`, userID).Find(&rows).Error; err != nil {
+		return false, err
+	}
+	for _, row := range rows {
+		if !password.CheckPassword(code, row.CodeHash) {
+			continue
+		}
+		// Conditional update so a code cannot be spent twice concurrently
+		result := db.Model(&models.RecoveryCode{}).
+			Where(`id = ? AND "usedAt" IS NULL`, row.ID).
+			Update("usedAt", now)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+	return false, nil
+}
+
+// generateRecoveryCode returns a random code formatted as "xxxx-xxxx".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	s := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return s[:4] + "-" + s[4:], nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces so users can type codes loosely.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func respondAuthError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,230 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/lockout"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/session"
+	"backend-hotlines3/internal/testutil"
+	"backend-hotlines3/pkg/jwt"
+	"backend-hotlines3/pkg/totp"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// fakeClock is a settable time source shared by the handler and the JWT manager.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+type twoFactorFixture struct {
+	db      *gorm.DB
+	handler *AuthHandler
+	clock   *fakeClock
+	user    *models.User
+}
+
+func newTwoFactorFixture(t *testing.T) *twoFactorFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.NewDB(t)
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 8, 0, 10, 0, time.UTC)}
+	jwtManager := jwt.NewJWTManager("test-secret", time.Hour, 24*time.Hour)
+	jwtManager.SetClock(clock.now)
+	// No backoff, so a rejected code does not throttle the next request
+	throttle := lockout.DefaultConfig()
+	throttle.BaseDelay = 0
+	guard := lockout.NewGuard(lockout.NewMemoryStore(), throttle)
+	h := NewAuthHandler(db, jwtManager, session.NewStore(db), guard, config.TwoFactorConfig{})
+	h.now = clock.now
+
+	secret := testTOTPSecret
+	user := &models.User{
+		Username:    "operator",
+		Password:    "unused",
+		Role:        "user",
+		IsActive:    true,
+		TOTPSecret:  &secret,
+		TOTPEnabled: true,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &twoFactorFixture{db: db, handler: h, clock: clock, user: user}
+}
+
+// challenge returns a login challenge token for the fixture user, as issued after the
+// password check.
+func (f *twoFactorFixture) challenge(t *testing.T) string {
+	t.Helper()
+	token, _, err := f.handler.jwtManager.GenerateChallengeToken(f.user.ID, f.user.Username, f.user.Role, nil, f.handler.twoFactor.challengeExpiry)
+	if err != nil {
+		t.Fatalf("generate challenge: %v", err)
+	}
+	return token
+}
+
+// code returns the TOTP code offset steps from the fake clock's current step.
+func (f *twoFactorFixture) code(t *testing.T, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(testTOTPSecret, totp.Step(f.clock.now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// verify calls VerifyTwoFactor and returns the status and error code.
+func (f *twoFactorFixture) verify(t *testing.T, body gin.H) (int, string) {
+	t.Helper()
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/auth/2fa/verify", bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	f.handler.VerifyTwoFactor(c)
+
+	var resp struct {
+		Error *struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Error != nil {
+		return w.Code, resp.Error.Code
+	}
+	return w.Code, ""
+}
+
+func TestVerifyTwoFactorSkew(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		status int
+	}{
+		{"previous step", -1, http.StatusOK},
+		{"next step", 1, http.StatusOK},
+		{"two steps behind", -2, http.StatusUnauthorized},
+		{"two steps ahead", 2, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTwoFactorFixture(t)
+			status, code := f.verify(t, gin.H{"challengeToken": f.challenge(t), "code": f.code(t, tt.offset)})
+			if status != tt.status {
+				t.Fatalf("status = %d (%s), want %d", status, code, tt.status)
+			}
+		})
+	}
+}
+
+func TestVerifyTwoFactorRejectsReplay(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	code := f.code(t, 0)
+
+	if status, errCode := f.verify(t, gin.H{"challengeToken": f.challenge(t), "code": code}); status != http.StatusOK {
+		t.Fatalf("first use: status = %d (%s), want 200", status, errCode)
+	}
+
+	// The same code is still inside the skew window 30s later, but its step is stored
+	f.clock.advance(totp.Period)
+	status, errCode := f.verify(t, gin.H{"challengeToken": f.challenge(t), "code": code})
+	if status != http.StatusUnauthorized || errCode != "INVALID_2FA_CODE" {
+		t.Fatalf("replay: status = %d (%s), want 401 INVALID_2FA_CODE", status, errCode)
+	}
+
+	// A new code for a later step is accepted
+	if status, errCode := f.verify(t, gin.H{"challengeToken": f.challenge(t), "code": f.code(t, 1)}); status != http.StatusOK {
+		t.Fatalf("next code: status = %d (%s), want 200", status, errCode)
+	}
+}
+
+// TestCheckTOTPConcurrentReplay covers two requests that loaded the user before either
+// stored the step: the conditional update lets only one of them through.
+func TestCheckTOTPConcurrentReplay(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	code := f.code(t, 0)
+
+	var first, second models.User
+	f.db.First(&first, f.user.ID)
+	f.db.First(&second, f.user.ID)
+
+	ok, err := f.handler.checkTOTP(ctx, &first, code)
+	if err != nil || !ok {
+		t.Fatalf("first checkTOTP = %v, %v; want true", ok, err)
+	}
+	ok, err = f.handler.checkTOTP(ctx, &second, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("stale copy of the user accepted the same code")
+	}
+
+	var stored models.User
+	f.db.First(&stored, f.user.ID)
+	if want := totp.Step(f.clock.now()); stored.TOTPLastStep != want {
+		t.Errorf("totpLastStep = %d, want %d", stored.TOTPLastStep, want)
+	}
+}
+
+func TestVerifyTwoFactorRecoveryCodeSingleUse(t *testing.T) {
+	f := newTwoFactorFixture(t)
+
+	// Cost 12 is too slow for tests; the check does not depend on the cost
+	const recovery = "abcd-efgh"
+	hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(recovery)), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.db.Create(&models.RecoveryCode{UserID: f.user.ID, CodeHash: string(hash), CreatedAt: f.clock.now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if status, errCode := f.verify(t, gin.H{"challengeToken": f.challenge(t), "recoveryCode": "ABCD EFGH"}); status != http.StatusOK {
+		t.Fatalf("first use: status = %d (%s), want 200", status, errCode)
+	}
+
+	var row models.RecoveryCode
+	f.db.Where(`"userId" = ?`, f.user.ID).First(&row)
+	if row.UsedAt == nil || !row.UsedAt.Equal(f.clock.now()) {
+		t.Errorf("usedAt = %v, want %v", row.UsedAt, f.clock.now())
+	}
+
+	status, errCode := f.verify(t, gin.H{"challengeToken": f.challenge(t), "recoveryCode": recovery})
+	if status != http.StatusUnauthorized || errCode != "INVALID_2FA_CODE" {
+		t.Fatalf("second use: status = %d (%s), want 401 INVALID_2FA_CODE", status, errCode)
+	}
+}
+
+func TestVerifyTwoFactorChallengeExpiry(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	token := f.challenge(t)
+
+	f.clock.advance(defaultChallengeExpiry - time.Second)
+	if status, errCode := f.verify(t, gin.H{"challengeToken": token, "code": f.code(t, 0)}); status != http.StatusOK {
+		t.Fatalf("before expiry: status = %d (%s), want 200", status, errCode)
+	}
+
+	token = f.challenge(t)
+	f.clock.advance(defaultChallengeExpiry + time.Second)
+	status, errCode := f.verify(t, gin.H{"challengeToken": token, "code": f.code(t, 0)})
+	if status != http.StatusUnauthorized || errCode != "INVALID_TOKEN" {
+		t.Fatalf("after expiry: status = %d (%s), want 401 INVALID_TOKEN", status, errCode)
+	}
+}
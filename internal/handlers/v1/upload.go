@@ -9,31 +9,38 @@ import (
 	"strings"
 	"time"
 
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/config"
 	"backend-hotlines3/internal/dto"
 	"backend-hotlines3/pkg/s3"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type UploadHandler struct {
+	db       *gorm.DB
 	r2Client *s3.R2Client
 }
 
-func NewUploadHandler(cfg *config.Config) (*UploadHandler, error) {
-	r2Client, err := s3.NewR2Client(s3.R2Config{
+func NewUploadHandler(cfg *config.Config, db *gorm.DB) (*UploadHandler, error) {
+	r2Client, err := newR2Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadHandler{db: db, r2Client: r2Client}, nil
+}
+
+func newR2Client(cfg *config.Config) (*s3.R2Client, error) {
+	return s3.NewR2Client(s3.R2Config{
 		AccountID:       cfg.Cloudflare.R2.AccountID,
 		AccessKeyID:     cfg.Cloudflare.R2.AccessKeyID,
 		SecretAccessKey: cfg.Cloudflare.R2.SecretAccessKey,
 		BucketName:      cfg.Cloudflare.R2.BucketName,
 		PublicURL:       cfg.Cloudflare.R2.PublicURL,
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	return &UploadHandler{r2Client: r2Client}, nil
 }
 
 // allowedImageTypes defines allowed MIME types for images
@@ -139,7 +146,18 @@ func (h *UploadHandler) DeleteFile(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := h.r2Client.DeleteObject(ctx, fileKey); err != nil {
+	// The object is only deleted once its audit entry is written; a failed delete rolls
+	// the entry back.
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: "R2Object",
+			EntityID:   fileKey,
+		}); err != nil {
+			return err
+		}
+		return h.r2Client.DeleteObject(ctx, fileKey)
+	}); err != nil {
 		log.Printf("Failed to delete file with key %s: %v", fileKey, err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
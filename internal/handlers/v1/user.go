@@ -1,8 +1,11 @@
 package v1
 
 import (
+	"backend-hotlines3/internal/audit"
 	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/lockout"
 	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/session"
 	"log"
 	"net/http"
 	"strconv"
@@ -14,17 +17,19 @@ import (
 )
 
 type UserHandler struct {
-	db *gorm.DB
+	db         *gorm.DB
+	sessions   *session.Store
+	loginGuard *lockout.Guard
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *gorm.DB, sessions *session.Store, loginGuard *lockout.Guard) *UserHandler {
+	return &UserHandler{db: db, sessions: sessions, loginGuard: loginGuard}
 }
 
 // List - GET /v1/users (admin only)
 func (h *UserHandler) List(c *gin.Context) {
 	var users []models.User
-	if err := h.db.WithContext(c.Request.Context()).Preload("Team").Find(&users).Error; err != nil {
+	if err := readDB(c, h.db).Preload("Team").Find(&users).Error; err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -51,6 +56,7 @@ func (h *UserHandler) List(c *gin.Context) {
 			IsActive:  user.IsActive,
 			LastLogin: &lastLoginStr,
 			CreatedAt: user.CreatedAt.Format(time.RFC3339),
+			DeletedAt: formatDeletedAt(user.DeletedAt),
 		})
 	}
 
@@ -75,7 +81,7 @@ func (h *UserHandler) GetByID(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := h.db.WithContext(c.Request.Context()).Preload("Team").First(&user, id).Error; err != nil {
+	if err := readDB(c, h.db).Preload("Team").First(&user, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, dto.StandardResponse{
 				Success: false,
@@ -109,6 +115,7 @@ func (h *UserHandler) GetByID(c *gin.Context) {
 		IsActive:  user.IsActive,
 		LastLogin: &lastLoginStr,
 		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+		DeletedAt: formatDeletedAt(user.DeletedAt),
 	}
 
 	c.JSON(http.StatusOK, dto.StandardResponse{
@@ -131,6 +138,10 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if !validateRole(c, h.db, req.Role) {
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
@@ -156,7 +167,17 @@ func (h *UserHandler) Create(c *gin.Context) {
 		IsActive: isActive,
 	}
 
-	if err := h.db.WithContext(c.Request.Context()).Create(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionCreate,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+			After:      &user,
+		})
+	}); err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -244,10 +265,15 @@ func (h *UserHandler) Update(c *gin.Context) {
 		return
 	}
 
+	before := user
+
 	if req.Username != nil {
 		user.Username = *req.Username
 	}
 	if req.Role != nil {
+		if !validateRole(c, h.db, *req.Role) {
+			return
+		}
 		user.Role = *req.Role
 	}
 	if req.TeamID != nil {
@@ -257,7 +283,25 @@ func (h *UserHandler) Update(c *gin.Context) {
 		user.IsActive = *req.IsActive
 	}
 
-	if err := h.db.WithContext(c.Request.Context()).Save(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		// Deactivated users are signed out everywhere, access tokens included. A new role or
+		// team also ends every session, since access tokens carry both until they expire.
+		if !user.IsActive || user.Role != before.Role || !sameTeam(user.TeamID, before.TeamID) {
+			if err := h.sessions.WithTx(tx).RevokeUser(c.Request.Context(), user.ID); err != nil {
+				return err
+			}
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUpdate,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+			Before:     &before,
+			After:      &user,
+		})
+	}); err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -302,7 +346,16 @@ func (h *UserHandler) Update(c *gin.Context) {
 	})
 }
 
+// sameTeam reports whether two optional team IDs are equal.
+func sameTeam(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 // Delete - DELETE /v1/users/:id (admin only)
+// The user is soft deleted: they can no longer sign in and their username stays taken.
 func (h *UserHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -328,19 +381,63 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.WithContext(c.Request.Context()).Delete(&models.User{}, id)
-	if result.Error != nil {
+	var before models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&before, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "User not found",
+			},
+		})
+		return
+	}
+
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.User{}, id).Error; err != nil {
+			return err
+		}
+		if err := h.sessions.WithTx(tx).RevokeUser(c.Request.Context(), uint(id)); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionDelete,
+			EntityType: models.User{}.TableName(),
+			EntityID:   id,
+			Before:     &before,
+		})
+	}); err != nil {
+		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
 				Code:    "INTERNAL_ERROR",
-				Message: result.Error.Error(),
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Restore - POST /v1/users/:id/restore (admin only)
+// Brings a deleted user back. Their sessions stay revoked, so they sign in again.
+func (h *UserHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid user ID",
 			},
 		})
 		return
 	}
 
-	if result.RowsAffected == 0 {
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, dto.StandardResponse{
 			Success: false,
 			Error: &dto.ErrorInfo{
@@ -351,7 +448,109 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	if !user.DeletedAt.Valid {
+		respondNotDeleted(c, "User is not deleted")
+		return
+	}
+
+	before := user
+
+	user.DeletedAt = gorm.DeletedAt{}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&user).Update("deletedAt", nil).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRestore,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+			Before:     &before,
+			After:      &user,
+		})
+	}); err != nil {
+		log.Printf("Failed to restore user %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "An error occurred while restoring the user",
+			},
+		})
+		return
+	}
+
+	lastLoginStr := ""
+	if user.LastLogin != nil {
+		lastLoginStr = user.LastLogin.Format(time.RFC3339)
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data: dto.UserResponse{
+			ID:        user.ID,
+			Username:  user.Username,
+			Role:      user.Role,
+			TeamID:    user.TeamID,
+			IsActive:  user.IsActive,
+			LastLogin: &lastLoginStr,
+			CreatedAt: user.CreatedAt.Format(time.RFC3339),
+			DeletedAt: formatDeletedAt(user.DeletedAt),
+		},
+	})
+}
+
+// RevokeSessions - POST /v1/users/:id/revoke-sessions (admin only)
+// Signs the user out of every device; their access tokens stop working immediately.
+func (h *UserHandler) RevokeSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid user ID",
+			},
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "User not found",
+			},
+		})
+		return
+	}
+
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := h.sessions.WithTx(tx).RevokeUser(c.Request.Context(), user.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionRevokeSessions,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+		})
+	}); err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    gin.H{"message": "All sessions revoked"},
+	})
 }
 
 // ChangePassword - PUT /v1/users/:id/password (authenticated user can change own password)
@@ -428,7 +627,16 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 	}
 
 	user.Password = string(hashedPassword)
-	if err := h.db.WithContext(c.Request.Context()).Save(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionPasswordChange,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+		})
+	}); err != nil {
 		log.Printf("Database error: %v", err)
 		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 			Success: false,
@@ -445,3 +653,137 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		Data:    gin.H{"message": "Password changed successfully"},
 	})
 }
+
+// Unlock - POST /v1/users/:id/unlock (admin only)
+// Clears failed-login counters and any lockout on the user's username.
+func (h *UserHandler) Unlock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid user ID",
+			},
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "User not found",
+			},
+		})
+		return
+	}
+
+	// The entry is written first so a failed unlock rolls it back, and the unlock is
+	// only done once the entry is in the transaction.
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := recordAudit(c, tx, audit.Entry{
+			Action:     audit.ActionUnlock,
+			EntityType: models.User{}.TableName(),
+			EntityID:   user.ID,
+		}); err != nil {
+			return err
+		}
+		return h.loginGuard.Unlock(c.Request.Context(), user.Username)
+	}); err != nil {
+		log.Printf("Failed to unlock user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    gin.H{"message": "User unlocked successfully"},
+	})
+}
+
+// LoginFailures - GET /v1/users/:id/login-failures (admin only)
+// Returns the user's most recent failed logins together with the current lockout state.
+func (h *UserHandler) LoginFailures(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_ID",
+				Message: "Invalid user ID",
+			},
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "NOT_FOUND",
+				Message: "User not found",
+			},
+		})
+		return
+	}
+
+	var failures []models.LoginFailure
+	if err := h.db.WithContext(c.Request.Context()).
+		Where("username = ?", user.Username).
+		Order(`"createdAt" DESC`).
+		Limit(limit).
+		Find(&failures).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	attempts, decision, err := h.loginGuard.Status(c.Request.Context(), user.Username)
+	if err != nil {
+		log.Printf("Login throttle status failed: %v", err)
+	}
+
+	history := []dto.LoginFailureResponse{}
+	for _, f := range failures {
+		history = append(history, dto.LoginFailureResponse{
+			ID:        f.ID,
+			IPAddress: f.IPAddress,
+			UserAgent: f.UserAgent,
+			Reason:    f.Reason,
+			CreatedAt: f.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	response := dto.LoginFailuresResponse{
+		Failures:       attempts.Failures,
+		Locked:         decision.Locked,
+		RetryAfterSecs: int64(decision.RetryAfter.Seconds()),
+		History:        history,
+	}
+
+	c.JSON(http.StatusOK, dto.StandardResponse{
+		Success: true,
+		Data:    response,
+	})
+}
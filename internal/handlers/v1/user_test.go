@@ -0,0 +1,256 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/lockout"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/session"
+	"backend-hotlines3/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestUpdateUserRevokesSessions checks that changing what an access token carries (role
+// or team) signs the user out, while other edits keep their sessions.
+func TestUpdateUserRevokesSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	team1, team2 := int64(1), int64(2)
+
+	tests := []struct {
+		name    string
+		body    gin.H
+		revoked bool
+	}{
+		{"rename", gin.H{"username": "654321"}, false},
+		{"same role", gin.H{"role": "user"}, false},
+		{"same team", gin.H{"teamId": team1}, false},
+		{"new role", gin.H{"role": "supervisor"}, true},
+		{"new team", gin.H{"teamId": team2}, true},
+		{"deactivated", gin.H{"isActive": false}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testutil.NewDB(t)
+			sessions := session.NewStore(db)
+			h := NewUserHandler(db, sessions, lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig()))
+
+			user := models.User{Username: "123456", Password: "unused", Role: "user", TeamID: &team1, IsActive: true}
+			if err := db.Create(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := sessions.Issue(ctx, &models.RefreshToken{
+				ID:        "token",
+				SessionID: "session",
+				UserID:    user.ID,
+				IssuedAt:  time.Now(),
+				ExpiresAt: time.Now().Add(time.Hour),
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			payload, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPut, "/v1/users/"+strconv.Itoa(int(user.ID)), bytes.NewReader(payload))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(user.ID))}}
+			h.Update(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+
+			active, err := sessions.IsActive(ctx, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if active == tt.revoked {
+				t.Errorf("session active = %v, want %v", active, !tt.revoked)
+			}
+		})
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	sessions := session.NewStore(db)
+	h := NewUserHandler(db, sessions, lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig()))
+
+	users := []models.User{
+		{Username: "123456", Password: "unused", Role: "user", IsActive: true},
+		{Username: "654321", Password: "unused", Role: "user", IsActive: true},
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"phone", "laptop", "other"} {
+		userID := users[0].ID
+		if id == "other" {
+			userID = users[1].ID
+		}
+		if err := sessions.Issue(ctx, &models.RefreshToken{
+			ID:        "token-" + id,
+			SessionID: id,
+			UserID:    userID,
+			IssuedAt:  time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revoke := func(id string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/users/"+id+"/revoke-sessions", nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		h.RevokeSessions(c)
+		return w.Code
+	}
+	if code := revoke("999"); code != http.StatusNotFound {
+		t.Errorf("unknown user: status = %d, want 404", code)
+	}
+	if code := revoke(strconv.Itoa(int(users[0].ID))); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	for id, want := range map[string]bool{"phone": false, "laptop": false, "other": true} {
+		if active, err := sessions.IsActive(ctx, id); err != nil || active != want {
+			t.Errorf("session %s active = %v (%v), want %v", id, active, err, want)
+		}
+	}
+	var count int64
+	db.Model(&models.AuditLog{}).Where(models.AuditLogCol.Action+" = ?", audit.ActionRevokeSessions).Count(&count)
+	if count != 1 {
+		t.Errorf("%d revoke-sessions audit entries, want 1", count)
+	}
+}
+
+// TestUpdateUserRollsBackWithoutAudit checks that a change whose audit entry cannot be
+// written fails and leaves the user and their sessions untouched.
+func TestUpdateUserRollsBackWithoutAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	sessions := session.NewStore(db)
+	h := NewUserHandler(db, sessions, lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig()))
+
+	user := models.User{Username: "123456", Password: "unused", Role: "user", IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.Issue(ctx, &models.RefreshToken{
+		ID:        "token",
+		SessionID: "session",
+		UserID:    user.ID,
+		IssuedAt:  time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().DropTable(&models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.Marshal(gin.H{"username": "654321", "isActive": false})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/v1/users/"+strconv.Itoa(int(user.ID)), bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(user.ID))}}
+	h.Update(c)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body.String())
+	}
+
+	var stored models.User
+	if err := db.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Username != "123456" || !stored.IsActive {
+		t.Errorf("user = %q active %v, want the update rolled back", stored.Username, stored.IsActive)
+	}
+	active, err := sessions.IsActive(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !active {
+		t.Error("session revoked, want the revocation rolled back")
+	}
+}
+
+// TestDeleteAndRestoreUser checks that a deleted user can be restored, and that their
+// sessions stay revoked after the restore.
+func TestDeleteAndRestoreUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	sessions := session.NewStore(db)
+	h := NewUserHandler(db, sessions, lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig()))
+
+	user := models.User{Username: "123456", Password: "unused", Role: "user", IsActive: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.Issue(ctx, &models.RefreshToken{
+		ID:        "token",
+		SessionID: "session",
+		UserID:    user.ID,
+		IssuedAt:  time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(int(user.ID))
+
+	call := func(handler gin.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, target, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		handler(c)
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	if w := call(h.Restore, http.MethodPost, "/v1/users/"+id+"/restore"); w.Code != http.StatusBadRequest {
+		t.Errorf("restore live user: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := call(h.Delete, http.MethodDelete, "/v1/users/"+id); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := call(h.GetByID, http.MethodGet, "/v1/users/"+id); w.Code != http.StatusNotFound {
+		t.Errorf("deleted lookup: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	if w := call(h.Restore, http.MethodPost, "/v1/users/"+id+"/restore"); w.Code != http.StatusOK {
+		t.Fatalf("restore: status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := call(h.GetByID, http.MethodGet, "/v1/users/"+id); w.Code != http.StatusOK {
+		t.Errorf("restored lookup: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	active, err := sessions.IsActive(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active {
+		t.Error("session active after restore, want it to stay revoked")
+	}
+
+	var restores int64
+	db.Model(&models.AuditLog{}).Where("action = ?", "restore").Count(&restores)
+	if restores != 1 {
+		t.Errorf("restore audit entries = %d, want 1", restores)
+	}
+}
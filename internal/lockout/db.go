@@ -0,0 +1,86 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore keeps attempts in the LoginThrottle table so every replica sees the same counters.
+type DBStore struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db, now: time.Now}
+}
+
+func (s *DBStore) Get(ctx context.Context, key string) (Attempts, error) {
+	var row models.LoginThrottle
+	err := s.db.WithContext(ctx).
+		Where(`"key" = ? AND "expiresAt" > ?`, key, s.now()).
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Attempts{}, nil
+	}
+	if err != nil {
+		return Attempts{}, err
+	}
+	return toAttempts(row), nil
+}
+
+func (s *DBStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(Attempts) Attempts) (Attempts, error) {
+	var next Attempts
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := s.now()
+
+		// Make sure the row exists so it can be locked, then lock it
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key, ExpiresAt: now}).Error; err != nil {
+			return err
+		}
+
+		var row models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&row, `"key" = ?`, key).Error; err != nil {
+			return err
+		}
+
+		current := Attempts{}
+		if row.ExpiresAt.After(now) {
+			current = toAttempts(row)
+		}
+		next = fn(current)
+
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"failures":      next.Failures,
+			"lastFailureAt": next.LastFailureAt,
+			"lockedUntil":   next.LockedUntil,
+			"expiresAt":     now.Add(ttl),
+		}).Error
+	})
+	return next, err
+}
+
+func (s *DBStore) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Delete(&models.LoginThrottle{}, `"key" = ?`, key).Error
+}
+
+// Purge removes expired rows. Call it periodically; rows are also ignored once expired.
+func (s *DBStore) Purge(ctx context.Context) error {
+	return s.db.WithContext(ctx).Delete(&models.LoginThrottle{}, `"expiresAt" <= ?`, s.now()).Error
+}
+
+func toAttempts(row models.LoginThrottle) Attempts {
+	return Attempts{
+		Failures:      row.Failures,
+		LastFailureAt: row.LastFailureAt,
+		LockedUntil:   row.LockedUntil,
+	}
+}
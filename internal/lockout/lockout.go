@@ -0,0 +1,267 @@
+// Package lockout throttles login attempts per username and per client IP.
+// Each failure pushes the next allowed attempt further out (exponential backoff);
+// after too many failures the key is locked for a fixed duration.
+// An attempt is counted as a failure when it starts and taken back when it succeeds, so
+// parallel guesses cannot all slip through before the first failure is recorded.
+// Counters live behind the Store interface: MemoryStore for a single instance,
+// DBStore when several replicas must share state.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Attempts is the throttling state of one key.
+type Attempts struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists Attempts by key. Update must apply fn atomically for a key.
+type Store interface {
+	Get(ctx context.Context, key string) (Attempts, error)
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(Attempts) Attempts) (Attempts, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config controls backoff and lockout.
+type Config struct {
+	MaxUserFailures int           // failures per username before lockout
+	MaxIPFailures   int           // failures per IP before lockout
+	BaseDelay       time.Duration // delay after the first failure, doubled per failure
+	MaxDelay        time.Duration // cap for the backoff delay
+	LockoutDuration time.Duration // how long a key stays locked
+	Window          time.Duration // failures older than this are forgotten
+}
+
+// DefaultConfig returns the settings used when config.yaml does not override them.
+func DefaultConfig() Config {
+	return Config{
+		MaxUserFailures: 5,
+		MaxIPFailures:   20,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+}
+
+// Guard applies Config to login attempts.
+type Guard struct {
+	store Store
+	cfg   Config
+	now   func() time.Time
+}
+
+func NewGuard(store Store, cfg Config) *Guard {
+	return &Guard{store: store, cfg: cfg, now: time.Now}
+}
+
+// SetClock replaces the time source used for backoff and lockout, so tests can move a
+// fake clock past a delay or a lockout.
+func (g *Guard) SetClock(now func() time.Time) {
+	g.now = now
+}
+
+// Decision is the result of Begin.
+type Decision struct {
+	Allowed    bool
+	Locked     bool          // true when a lockout (not just backoff) is in effect
+	RetryAfter time.Duration // wait before the next attempt when not allowed
+}
+
+func userKey(username string) string { return "user:" + username }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// limit is one counter an attempt is charged to.
+type limit struct {
+	key  string
+	max  int
+	user bool // cleared, rather than refunded, by a successful login
+}
+
+// Attempt is a login attempt that Begin has already counted as a failure. Exactly one
+// of Fail, Succeed or Release settles it; later calls do nothing.
+type Attempt struct {
+	guard   *Guard
+	limits  []limit
+	at      time.Time
+	prev    map[string]time.Time // LastFailureAt of each counter before the attempt
+	settled bool
+}
+
+// Begin checks whether a login for username from ip may be attempted now and, if so,
+// counts it as a failure in the same Store.Update, so a parallel attempt already sees
+// it. The returned Attempt is nil when the login is not allowed.
+func (g *Guard) Begin(ctx context.Context, username, ip string) (Decision, *Attempt, error) {
+	// Truncated to what DBStore keeps, so a refund recognises its own stamp
+	now := g.now().Truncate(time.Microsecond)
+	a := &Attempt{
+		guard: g,
+		at:    now,
+		prev:  make(map[string]time.Time, 2),
+	}
+
+	// The IP comes first so a locked IP cannot charge failures to a username
+	for _, l := range []limit{
+		{key: ipKey(ip), max: g.cfg.MaxIPFailures},
+		{key: userKey(username), max: g.cfg.MaxUserFailures, user: true},
+	} {
+		var blocked Decision
+		_, err := g.store.Update(ctx, l.key, g.ttl(), func(cur Attempts) Attempts {
+			if wait, locked := g.blockedFor(cur, now); wait > 0 {
+				blocked = Decision{Locked: locked, RetryAfter: wait}
+				return cur
+			}
+			blocked = Decision{}
+			a.prev[l.key] = cur.LastFailureAt
+			return g.fail(cur, now, l.max)
+		})
+		if err == nil && blocked.RetryAfter > 0 {
+			return blocked, nil, a.Release(ctx)
+		}
+		if err != nil {
+			if releaseErr := a.Release(ctx); releaseErr != nil {
+				err = errors.Join(err, releaseErr)
+			}
+			return Decision{}, nil, fmt.Errorf("failed to record login attempt: %w", err)
+		}
+		a.limits = append(a.limits, l)
+	}
+	return Decision{Allowed: true}, a, nil
+}
+
+// Fail keeps the attempt counted as a failed login.
+func (a *Attempt) Fail() {
+	a.settled = true
+}
+
+// Succeed clears the username counter and takes the attempt back from the IP counter.
+// Earlier failures from the IP stay, so one valid account cannot reset a password spray.
+func (a *Attempt) Succeed(ctx context.Context) error {
+	if a.settled {
+		return nil
+	}
+	a.settled = true
+	for _, l := range a.limits {
+		var err error
+		if l.user {
+			err = a.guard.store.Delete(ctx, l.key)
+		} else {
+			err = a.guard.refund(ctx, l, a.at, a.prev[l.key])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clear login attempts: %w", err)
+		}
+	}
+	return nil
+}
+
+// Release takes back an attempt that neither failed nor succeeded, for example when a
+// second factor is still due or the request failed for another reason.
+func (a *Attempt) Release(ctx context.Context) error {
+	if a.settled {
+		return nil
+	}
+	a.settled = true
+	for _, l := range a.limits {
+		if err := a.guard.refund(ctx, l, a.at, a.prev[l.key]); err != nil {
+			return fmt.Errorf("failed to release login attempt: %w", err)
+		}
+	}
+	return nil
+}
+
+func (g *Guard) ttl() time.Duration {
+	return g.cfg.Window + g.cfg.LockoutDuration
+}
+
+// fail adds one failure at now to a, starting over when the previous failures fell out
+// of the window or their lockout has been served.
+func (g *Guard) fail(a Attempts, now time.Time, max int) Attempts {
+	expired := !a.LastFailureAt.IsZero() && now.Sub(a.LastFailureAt) > g.cfg.Window
+	served := !a.LockedUntil.IsZero() && !now.Before(a.LockedUntil)
+	if expired || served {
+		a = Attempts{}
+	}
+	a.Failures++
+	a.LastFailureAt = now
+	if a.Failures >= max {
+		a.LockedUntil = now.Add(g.cfg.LockoutDuration)
+	}
+	return a
+}
+
+// refund takes back one failure counted at at, restoring the previous failure time when
+// no later attempt has been counted since, and lifting a lockout the attempt caused.
+func (g *Guard) refund(ctx context.Context, l limit, at, prev time.Time) error {
+	_, err := g.store.Update(ctx, l.key, g.ttl(), func(cur Attempts) Attempts {
+		if cur.Failures == 0 {
+			return cur
+		}
+		cur.Failures--
+		if cur.Failures == 0 {
+			return Attempts{}
+		}
+		if cur.Failures < l.max {
+			cur.LockedUntil = time.Time{}
+		}
+		if cur.LastFailureAt.Equal(at) {
+			cur.LastFailureAt = prev
+		}
+		return cur
+	})
+	return err
+}
+
+// Unlock clears the username counter and any lockout on it.
+func (g *Guard) Unlock(ctx context.Context, username string) error {
+	if err := g.store.Delete(ctx, userKey(username)); err != nil {
+		return fmt.Errorf("failed to clear login attempts: %w", err)
+	}
+	return nil
+}
+
+// Status returns the current state of a username counter.
+func (g *Guard) Status(ctx context.Context, username string) (Attempts, Decision, error) {
+	a, err := g.store.Get(ctx, userKey(username))
+	if err != nil {
+		return Attempts{}, Decision{}, fmt.Errorf("failed to read login attempts: %w", err)
+	}
+	wait, locked := g.blockedFor(a, g.now())
+	return a, Decision{Allowed: wait == 0, Locked: locked, RetryAfter: wait}, nil
+}
+
+// blockedFor returns how long a must still wait, and whether that wait is a lockout.
+func (g *Guard) blockedFor(a Attempts, now time.Time) (time.Duration, bool) {
+	if a.Failures == 0 {
+		return 0, false
+	}
+	if now.Before(a.LockedUntil) {
+		return a.LockedUntil.Sub(now), true
+	}
+	if !a.LockedUntil.IsZero() {
+		// Lockout served; backoff starts over from the next failure
+		return 0, false
+	}
+	next := a.LastFailureAt.Add(g.backoff(a.Failures))
+	if now.Before(next) {
+		return next.Sub(now), false
+	}
+	return 0, false
+}
+
+func (g *Guard) backoff(failures int) time.Duration {
+	delay := g.cfg.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= g.cfg.MaxDelay {
+			return g.cfg.MaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,332 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/testutil"
+)
+
+// fakeClock is a settable time source shared by the guard and its store.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func testConfig() Config {
+	return Config{
+		MaxUserFailures: 3,
+		MaxIPFailures:   5,
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+}
+
+// stores runs fn against a MemoryStore and a DBStore, both on clock.
+func stores(t *testing.T, fn func(t *testing.T, store Store, clock *fakeClock)) {
+	for _, tt := range []struct {
+		name  string
+		store func(t *testing.T, clock *fakeClock) Store
+	}{
+		{"memory", func(t *testing.T, clock *fakeClock) Store {
+			s := NewMemoryStore()
+			s.now = clock.now
+			return s
+		}},
+		{"database", func(t *testing.T, clock *fakeClock) Store {
+			s := NewDBStore(testutil.NewDB(t))
+			s.now = clock.now
+			return s
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)}
+			fn(t, tt.store(t, clock), clock)
+		})
+	}
+}
+
+func newTestGuard(store Store, clock *fakeClock, cfg Config) *Guard {
+	g := NewGuard(store, cfg)
+	g.SetClock(clock.now)
+	return g
+}
+
+// fail begins an attempt and records it as failed; the attempt must be allowed.
+func fail(t *testing.T, g *Guard, username, ip string) {
+	t.Helper()
+	decision, attempt, err := g.Begin(context.Background(), username, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Allowed {
+		t.Fatalf("attempt refused: %+v", decision)
+	}
+	attempt.Fail()
+}
+
+func TestBackoffGrowth(t *testing.T) {
+	tests := []struct {
+		failures int
+		wait     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 4 * time.Second}, // capped at MaxDelay
+	}
+	stores(t, func(t *testing.T, store Store, clock *fakeClock) {
+		cfg := testConfig()
+		cfg.MaxUserFailures = 10
+		g := newTestGuard(store, clock, cfg)
+		ctx := context.Background()
+
+		for _, tt := range tests {
+			fail(t, g, "operator", "10.0.0.1")
+
+			decision, _, err := g.Begin(ctx, "operator", "10.0.0.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Allowed || decision.Locked || decision.RetryAfter != tt.wait {
+				t.Errorf("after %d failures: %+v, want backoff of %s", tt.failures, decision, tt.wait)
+			}
+
+			clock.advance(tt.wait - time.Millisecond)
+			if decision, _, _ := g.Begin(ctx, "operator", "10.0.0.2"); decision.Allowed {
+				t.Errorf("after %d failures: allowed before the backoff ended", tt.failures)
+			}
+			clock.advance(time.Millisecond)
+		}
+	})
+}
+
+func TestLockout(t *testing.T) {
+	stores(t, func(t *testing.T, store Store, clock *fakeClock) {
+		cfg := testConfig()
+		g := newTestGuard(store, clock, cfg)
+		ctx := context.Background()
+
+		for i := 0; i < cfg.MaxUserFailures; i++ {
+			fail(t, g, "operator", "10.0.0.1")
+			clock.advance(cfg.MaxDelay)
+		}
+
+		decision, attempt, err := g.Begin(ctx, "operator", "10.0.0.2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed || !decision.Locked || attempt != nil {
+			t.Fatalf("decision = %+v, want locked", decision)
+		}
+		// Locked at the last failure, one MaxDelay ago
+		remaining := cfg.LockoutDuration - cfg.MaxDelay
+		if decision.RetryAfter != remaining {
+			t.Errorf("RetryAfter = %s, want %s", decision.RetryAfter, remaining)
+		}
+
+		// The refused attempt is not counted against the other IP
+		if a, _, err := g.Status(ctx, "operator"); err != nil || a.Failures != cfg.MaxUserFailures {
+			t.Errorf("failures = %d (%v), want %d", a.Failures, err, cfg.MaxUserFailures)
+		}
+
+		// Served lockout: the next attempt is allowed and counting starts over
+		clock.advance(remaining)
+		fail(t, g, "operator", "10.0.0.1")
+		a, decision, err := g.Status(ctx, "operator")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Failures != 1 || decision.Locked {
+			t.Errorf("after lockout: %d failures, locked %v; want 1 failure, unlocked", a.Failures, decision.Locked)
+		}
+	})
+}
+
+func TestWindowExpiry(t *testing.T) {
+	stores(t, func(t *testing.T, store Store, clock *fakeClock) {
+		cfg := testConfig()
+		g := newTestGuard(store, clock, cfg)
+
+		for i := 0; i < cfg.MaxUserFailures-1; i++ {
+			fail(t, g, "operator", "10.0.0.1")
+			clock.advance(cfg.MaxDelay)
+		}
+		clock.advance(cfg.Window)
+		fail(t, g, "operator", "10.0.0.1")
+
+		a, decision, err := g.Status(context.Background(), "operator")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Failures != 1 || decision.Locked {
+			t.Errorf("%d failures, locked %v; want old failures forgotten", a.Failures, decision.Locked)
+		}
+	})
+}
+
+func TestUnlock(t *testing.T) {
+	stores(t, func(t *testing.T, store Store, clock *fakeClock) {
+		cfg := testConfig()
+		g := newTestGuard(store, clock, cfg)
+		ctx := context.Background()
+
+		for i := 0; i < cfg.MaxUserFailures; i++ {
+			fail(t, g, "operator", "10.0.0.1")
+			clock.advance(cfg.MaxDelay)
+		}
+		if _, decision, _ := g.Status(ctx, "operator"); !decision.Locked {
+			t.Fatal("not locked")
+		}
+
+		if err := g.Unlock(ctx, "operator"); err != nil {
+			t.Fatal(err)
+		}
+		a, decision, err := g.Status(ctx, "operator")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Failures != 0 || !decision.Allowed {
+			t.Errorf("after unlock: %d failures, %+v", a.Failures, decision)
+		}
+		if decision, _, err := g.Begin(ctx, "operator", "10.0.0.2"); err != nil || !decision.Allowed {
+			t.Errorf("after unlock: %+v (%v), want allowed", decision, err)
+		}
+	})
+}
+
+// TestParallelAttempts checks that an attempt still in progress already counts, so
+// concurrent guesses are throttled before any of them has failed.
+func TestParallelAttempts(t *testing.T) {
+	stores(t, func(t *testing.T, store Store, clock *fakeClock) {
+		g := newTestGuard(store, clock, testConfig())
+		ctx := context.Background()
+
+		decision, first, err := g.Begin(ctx, "operator", "10.0.0.1")
+		if err != nil || !decision.Allowed {
+			t.Fatalf("first attempt: %+v (%v)", decision, err)
+		}
+		if decision, _, err := g.Begin(ctx, "operator", "10.0.0.2"); err != nil || decision.Allowed {
+			t.Errorf("second attempt while the first is in progress: %+v (%v), want refused", decision, err)
+		}
+		first.Fail()
+	})
+}
+
+func TestSettleAttempt(t *testing.T) {
+	tests := []struct {
+		name       string
+		settle     func(ctx context.Context, a *Attempt) error
+		userFails  int
+		ipFails    int
+		nextIPWait bool // a new username from the IP still backs off
+	}{
+		{"fail", func(_ context.Context, a *Attempt) error { a.Fail(); return nil }, 2, 2, true},
+		{"succeed", func(ctx context.Context, a *Attempt) error { return a.Succeed(ctx) }, 0, 1, false},
+		{"release", func(ctx context.Context, a *Attempt) error { return a.Release(ctx) }, 1, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores(t, func(t *testing.T, store Store, clock *fakeClock) {
+				cfg := testConfig()
+				g := newTestGuard(store, clock, cfg)
+				ctx := context.Background()
+
+				// One earlier failure on both counters, its backoff served
+				fail(t, g, "operator", "10.0.0.1")
+				clock.advance(cfg.MaxDelay)
+
+				decision, attempt, err := g.Begin(ctx, "operator", "10.0.0.1")
+				if err != nil || !decision.Allowed {
+					t.Fatalf("attempt: %+v (%v)", decision, err)
+				}
+				if err := tt.settle(ctx, attempt); err != nil {
+					t.Fatal(err)
+				}
+				// Settling twice does nothing
+				if err := attempt.Release(ctx); err != nil {
+					t.Fatal(err)
+				}
+
+				if a, _, _ := g.Status(ctx, "operator"); a.Failures != tt.userFails {
+					t.Errorf("user failures = %d, want %d", a.Failures, tt.userFails)
+				}
+				ip, err := store.Get(ctx, ipKey("10.0.0.1"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ip.Failures != tt.ipFails {
+					t.Errorf("ip failures = %d, want %d", ip.Failures, tt.ipFails)
+				}
+				decision, other, err := g.Begin(ctx, "someone-else", "10.0.0.1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decision.Allowed == tt.nextIPWait {
+					t.Errorf("next attempt from the IP: %+v, want backoff %v", decision, tt.nextIPWait)
+				}
+				if other != nil {
+					other.Fail()
+				}
+			})
+		})
+	}
+}
+
+// TestReleaseLiftsLockout checks that an attempt which reached the limit but did not
+// fail does not leave the counter locked.
+func TestReleaseLiftsLockout(t *testing.T) {
+	stores(t, func(t *testing.T, store Store, clock *fakeClock) {
+		cfg := testConfig()
+		g := newTestGuard(store, clock, cfg)
+		ctx := context.Background()
+
+		for i := 0; i < cfg.MaxUserFailures-1; i++ {
+			fail(t, g, "operator", "10.0.0.1")
+			clock.advance(cfg.MaxDelay)
+		}
+		_, attempt, err := g.Begin(ctx, "operator", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, decision, _ := g.Status(ctx, "operator"); !decision.Locked {
+			t.Fatal("attempt at the limit did not lock")
+		}
+		if err := attempt.Release(ctx); err != nil {
+			t.Fatal(err)
+		}
+		a, decision, err := g.Status(ctx, "operator")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Failures != cfg.MaxUserFailures-1 || decision.Locked {
+			t.Errorf("after release: %d failures, locked %v", a.Failures, decision.Locked)
+		}
+	})
+}
+
+// failingStore fails every call.
+type failingStore struct{}
+
+var errStore = errors.New("store unavailable")
+
+func (failingStore) Get(context.Context, string) (Attempts, error) { return Attempts{}, errStore }
+func (failingStore) Update(context.Context, string, time.Duration, func(Attempts) Attempts) (Attempts, error) {
+	return Attempts{}, errStore
+}
+func (failingStore) Delete(context.Context, string) error { return errStore }
+
+func TestBeginStoreError(t *testing.T) {
+	g := NewGuard(failingStore{}, testConfig())
+	decision, attempt, err := g.Begin(context.Background(), "operator", "10.0.0.1")
+	if !errors.Is(err, errStore) {
+		t.Fatalf("err = %v, want the store error", err)
+	}
+	if decision.Allowed || attempt != nil {
+		t.Errorf("decision = %+v, attempt %v; want refused", decision, attempt)
+	}
+}
@@ -0,0 +1,67 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepThreshold is the map size above which Update drops expired entries.
+const sweepThreshold = 10000
+
+type memoryEntry struct {
+	attempts  Attempts
+	expiresAt time.Time
+}
+
+// MemoryStore keeps attempts in process memory. Suitable for a single instance only.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || s.now().After(e.expiresAt) {
+		return Attempts{}, nil
+	}
+	return e.attempts, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(Attempts) Attempts) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if len(s.entries) > sweepThreshold {
+		for k, e := range s.entries {
+			if now.After(e.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+	}
+
+	var current Attempts
+	if e, ok := s.entries[key]; ok && !now.After(e.expiresAt) {
+		current = e.attempts
+	}
+	next := fn(current)
+	s.entries[key] = memoryEntry{attempts: next, expiresAt: now.Add(ttl)}
+	return next, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
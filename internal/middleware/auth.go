@@ -1,7 +1,12 @@
 package middleware
 
 import (
+	"backend-hotlines3/internal/apikey"
 	"backend-hotlines3/internal/dto"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/session"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
@@ -11,114 +16,270 @@ import (
 )
 
 type AuthMiddleware struct {
-	jwtManager *jwt.JWTManager
+	jwtManager  *jwt.JWTManager
+	sessions    *session.Store
+	apiKeys     *apikey.Store
+	permissions *permission.Resolver
 }
 
-func NewAuthMiddleware(jwtManager *jwt.JWTManager) *AuthMiddleware {
+func NewAuthMiddleware(jwtManager *jwt.JWTManager, sessions *session.Store, apiKeys *apikey.Store, permissions *permission.Resolver) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtManager: jwtManager,
+		jwtManager:  jwtManager,
+		sessions:    sessions,
+		apiKeys:     apiKeys,
+		permissions: permissions,
 	}
 }
 
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, dto.StandardResponse{
-				Success: false,
-				Error: &dto.ErrorInfo{
-					Code:    "MISSING_TOKEN",
-					Message: "Authorization header required",
-				},
-			})
-			c.Abort()
+		if !m.authenticate(c) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, dto.StandardResponse{
-				Success: false,
-				Error: &dto.ErrorInfo{
-					Code:    "INVALID_FORMAT",
-					Message: "Invalid authorization format. Use: Bearer <token>",
-				},
-			})
-			c.Abort()
+func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.authorize(c, roles...) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		claims, err := m.jwtManager.ValidateToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, dto.StandardResponse{
-				Success: false,
-				Error: &dto.ErrorInfo{
-					Code:    "INVALID_TOKEN",
-					Message: "Invalid or expired token",
-				},
-			})
-			c.Abort()
+// RequirePermission allows the request when the caller's role holds at least one of
+// permissions. Use after RequireAuth.
+func (m *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.authorizePermission(c, permissions...) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+// Permissions returns the caller's permissions as resolved by the permission check,
+// or an empty set if the route had none.
+func Permissions(c *gin.Context) permission.Set {
+	if perms, ok := c.Get("permissions"); ok {
+		if set, ok := perms.(permission.Set); ok {
+			return set
+		}
+	}
+	return permission.Set{}
+}
 
-		c.Next()
+// authenticate validates the Bearer token (or API key) and stores its claims in the context.
+// On failure it writes the error response, aborts, and returns false.
+func (m *AuthMiddleware) authenticate(c *gin.Context) bool {
+	if raw := c.GetHeader("X-API-Key"); raw != "" {
+		return m.authenticateAPIKey(c, raw)
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "MISSING_TOKEN",
+				Message: "Authorization header required",
+			},
+		})
+		c.Abort()
+		return false
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_FORMAT",
+				Message: "Invalid authorization format. Use: Bearer <token>",
+			},
+		})
+		c.Abort()
+		return false
 	}
+
+	if apikey.IsAPIKey(tokenString) {
+		return m.authenticateAPIKey(c, tokenString)
+	}
+
+	claims, err := m.jwtManager.ValidateAccessToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_TOKEN",
+				Message: "Invalid or expired token",
+			},
+		})
+		c.Abort()
+		return false
+	}
+
+	// Revoked sessions (logout, admin revoke, deactivation) invalidate their access tokens immediately
+	active, err := m.sessions.Touch(c.Request.Context(), claims.SessionID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		log.Printf("Session check failed: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to verify session",
+			},
+		})
+		c.Abort()
+		return false
+	}
+	if !active {
+		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "SESSION_REVOKED",
+				Message: "Session has been revoked",
+			},
+		})
+		c.Abort()
+		return false
+	}
+
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+	c.Set("team_id", claims.TeamID)
+	c.Set("session_id", claims.SessionID)
+	return true
 }
 
-func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
-		if !exists {
+// authenticateAPIKey validates an API key and its scopes for the current route.
+// The key acts with its own role and team; user_id and session_id are not set.
+func (m *AuthMiddleware) authenticateAPIKey(c *gin.Context, raw string) bool {
+	key, err := m.apiKeys.Authenticate(c.Request.Context(), raw, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalid) || errors.Is(err, apikey.ErrExpired) || errors.Is(err, apikey.ErrRevoked) {
 			c.JSON(http.StatusUnauthorized, dto.StandardResponse{
 				Success: false,
 				Error: &dto.ErrorInfo{
-					Code:    "UNAUTHORIZED",
-					Message: "User not authenticated",
+					Code:    "INVALID_API_KEY",
+					Message: "Invalid, expired or revoked API key",
 				},
 			})
-			c.Abort()
-			return
-		}
-
-		roleStr, ok := userRole.(string)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+		} else {
+			log.Printf("API key check failed: %v", err)
+			c.JSON(http.StatusInternalServerError, dto.StandardResponse{
 				Success: false,
 				Error: &dto.ErrorInfo{
-					Code:    "INVALID_TOKEN",
-					Message: "Invalid role claim in token",
+					Code:    "INTERNAL_ERROR",
+					Message: "Failed to verify API key",
 				},
 			})
-			c.Abort()
-			return
 		}
+		c.Abort()
+		return false
+	}
 
-		hasPermission := false
-		for _, role := range roles {
-			if roleStr == role {
-				hasPermission = true
-				break
-			}
-		}
+	if !apikey.Allows(key.Scopes, c.Request.Method, c.FullPath()) {
+		c.JSON(http.StatusForbidden, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "API_KEY_SCOPE",
+				Message: "API key is not allowed to access this route",
+			},
+		})
+		c.Abort()
+		return false
+	}
 
-		if !hasPermission {
-			c.JSON(http.StatusForbidden, dto.StandardResponse{
-				Success: false,
-				Error: &dto.ErrorInfo{
-					Code:    "FORBIDDEN",
-					Message: "Insufficient permissions",
-				},
-			})
-			c.Abort()
-			return
+	c.Set("api_key_id", key.ID)
+	c.Set("username", "apikey:"+key.Name)
+	c.Set("role", key.Role)
+	c.Set("team_id", key.TeamID)
+	return true
+}
+
+// authorize checks the role set by authenticate against the allowed roles.
+// On failure it writes the error response, aborts, and returns false.
+func (m *AuthMiddleware) authorize(c *gin.Context, roles ...string) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "UNAUTHORIZED",
+				Message: "User not authenticated",
+			},
+		})
+		c.Abort()
+		return false
+	}
+
+	roleStr, ok := userRole.(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INVALID_TOKEN",
+				Message: "Invalid role claim in token",
+			},
+		})
+		c.Abort()
+		return false
+	}
+
+	for _, role := range roles {
+		if roleStr == role {
+			return true
 		}
+	}
 
-		c.Next()
+	c.JSON(http.StatusForbidden, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "FORBIDDEN",
+			Message: "Insufficient permissions",
+		},
+	})
+	c.Abort()
+	return false
+}
+
+// authorizePermission resolves the caller's role to permissions, stores them in the
+// context for handlers, and requires at least one of permissions.
+// On failure it writes the error response, aborts, and returns false.
+func (m *AuthMiddleware) authorizePermission(c *gin.Context, permissions ...string) bool {
+	perms, err := m.permissions.Permissions(c.Request.Context(), c.GetString("role"))
+	if err != nil {
+		log.Printf("Permission check failed: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.StandardResponse{
+			Success: false,
+			Error: &dto.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to verify permissions",
+			},
+		})
+		c.Abort()
+		return false
 	}
+	c.Set("permissions", perms)
+
+	if perms.HasAny(permissions...) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, dto.StandardResponse{
+		Success: false,
+		Error: &dto.ErrorInfo{
+			Code:    "FORBIDDEN",
+			Message: "Insufficient permissions",
+			Details: gin.H{"required": permissions},
+		},
+	})
+	c.Abort()
+	return false
 }
 
 func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
@@ -135,11 +296,17 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := m.jwtManager.ValidateToken(tokenString)
+		claims, err := m.jwtManager.ValidateAccessToken(tokenString)
 		if err == nil {
+			if active, err := m.sessions.Touch(c.Request.Context(), claims.SessionID, c.ClientIP(), c.Request.UserAgent()); err != nil || !active {
+				c.Next()
+				return
+			}
 			c.Set("user_id", claims.UserID)
 			c.Set("username", claims.Username)
 			c.Set("role", claims.Role)
+			c.Set("team_id", claims.TeamID)
+			c.Set("session_id", claims.SessionID)
 		}
 
 		c.Next()
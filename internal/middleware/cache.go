@@ -7,9 +7,15 @@ import (
 )
 
 // CachePublic sets Cache-Control header to allow CDN (e.g. Cloudflare) to cache the response.
-// seconds is the max-age in seconds.
+// seconds is the max-age in seconds. Responses that include soft-deleted rows or past
+// versions are never cached publicly.
 func CachePublic(seconds int) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if IncludeDeleted(c) || c.Query(AsOfParam) != "" {
+			c.Header("Cache-Control", "private, no-store")
+			c.Next()
+			return
+		}
 		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", seconds))
 		c.Next()
 	}
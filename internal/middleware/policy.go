@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"backend-hotlines3/internal/permission"
+
+	"github.com/gin-gonic/gin"
+)
+
+type policyKind int
+
+const (
+	kindPublic policyKind = iota
+	kindAuthenticated
+	kindPermission
+)
+
+// IncludeDeletedParam is the query parameter that asks a read endpoint to return
+// soft-deleted rows as well. Whatever the route's own policy, honouring it requires
+// permission.DeletedRead.
+const IncludeDeletedParam = "includeDeleted"
+
+const includeDeletedKey = "include_deleted"
+
+// AsOfParam is the query parameter that asks a read endpoint for a row as it was at
+// an earlier time. Past versions come from the history, so honouring it requires
+// authentication even on a public route.
+const AsOfParam = "asOf"
+
+// IncludeDeleted reports whether the caller asked for soft-deleted rows and is allowed
+// to see them.
+func IncludeDeleted(c *gin.Context) bool {
+	return c.GetBool(includeDeletedKey)
+}
+
+// Policy describes who may call a route.
+type Policy struct {
+	kind        policyKind
+	permissions []string
+}
+
+var (
+	// PolicyPublic allows anonymous access.
+	PolicyPublic = Policy{kind: kindPublic}
+	// PolicyAuthenticated requires a valid access token or API key.
+	PolicyAuthenticated = Policy{kind: kindAuthenticated}
+)
+
+// PolicyPermission requires a valid access token or API key whose role holds at least
+// one of permissions.
+func PolicyPermission(permissions ...string) Policy {
+	return Policy{kind: kindPermission, permissions: permissions}
+}
+
+func (p Policy) String() string {
+	switch p.kind {
+	case kindPublic:
+		return "public"
+	case kindAuthenticated:
+		return "authenticated"
+	case kindPermission:
+		return "permission:" + strings.Join(p.permissions, "|")
+	default:
+		return "unknown"
+	}
+}
+
+// PolicyTable maps "METHOD /full/route/pattern" (as reported by c.FullPath) to its policy.
+type PolicyTable map[string]Policy
+
+// Lookup returns the policy for a route. Routes missing from the table are
+// public for safe methods and need permission.All otherwise, so a newly added
+// mutating route is locked down to admins until someone decides otherwise.
+func (t PolicyTable) Lookup(method, fullPath string) Policy {
+	if p, ok := t[method+" "+fullPath]; ok {
+		return p
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return PolicyPublic
+	default:
+		return PolicyPermission(permission.All)
+	}
+}
+
+// EnforcePolicy applies the route's policy from the table before the handler runs.
+// It must be registered on a group before the group's routes so c.FullPath is set.
+func (m *AuthMiddleware) EnforcePolicy(table PolicyTable) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := table.Lookup(c.Request.Method, c.FullPath())
+		switch policy.kind {
+		case kindPublic:
+		case kindAuthenticated:
+			if !m.authenticate(c) {
+				return
+			}
+		default:
+			if !m.authenticate(c) || !m.authorizePermission(c, policy.permissions...) {
+				return
+			}
+		}
+
+		authenticated := policy.kind != kindPublic
+		if c.Query(AsOfParam) != "" && !authenticated {
+			if !m.authenticate(c) {
+				return
+			}
+			authenticated = true
+		}
+
+		if c.Query(IncludeDeletedParam) == "true" {
+			if !authenticated && !m.authenticate(c) {
+				return
+			}
+			if !m.authorizePermission(c, permission.DeletedRead) {
+				return
+			}
+			c.Set(includeDeletedKey, true)
+		}
+		c.Next()
+	}
+}
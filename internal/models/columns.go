@@ -4,18 +4,116 @@ package models
 // PostgreSQL requires quoted identifiers for camelCase column names.
 
 var TaskCol = struct {
-	TeamID, JobTypeID, JobDetailID, FeederID, WorkDate, DeletedAt string
+	TeamID, JobTypeID, JobDetailID, FeederID, WorkDate, Latitude, Longitude, Status, DeletedAt string
 }{
 	TeamID:      `"teamId"`,
 	JobTypeID:   `"jobTypeId"`,
 	JobDetailID: `"jobDetailId"`,
 	FeederID:    `"feederId"`,
 	WorkDate:    `"workdate"`,
+	Latitude:    `"latitude"`,
+	Longitude:   `"longitude"`,
+	Status:      `"status"`,
 	DeletedAt:   `"deletedat"`,
 }
 
 var JobDetailCol = struct {
+	JobTypeID, DeletedAt string
+}{
+	JobTypeID: `"jobTypeId"`,
+	DeletedAt: `"deletedAt"`,
+}
+
+var FeederCol = struct {
+	StationID, DeletedAt string
+}{
+	StationID: `"stationId"`,
+	DeletedAt: `"deletedAt"`,
+}
+
+var StationCol = struct {
+	CodeName, OperationID, Latitude, Longitude, DeletedAt string
+}{
+	CodeName:    `"codeName"`,
+	OperationID: `"operationId"`,
+	Latitude:    `"latitude"`,
+	Longitude:   `"longitude"`,
+	DeletedAt:   `"deletedAt"`,
+}
+
+var JobTypeCol = struct {
 	DeletedAt string
 }{
 	DeletedAt: `"deletedAt"`,
 }
+
+var PEACol = struct {
+	OperationID, DeletedAt string
+}{
+	OperationID: `"operationId"`,
+	DeletedAt:   `"deletedAt"`,
+}
+
+var OperationCenterCol = struct {
+	DeletedAt string
+}{
+	DeletedAt: `"deletedAt"`,
+}
+
+var TeamCol = struct {
+	DeletedAt string
+}{
+	DeletedAt: `"deletedAt"`,
+}
+
+var UserCol = struct {
+	TeamID, DeletedAt string
+}{
+	TeamID:    `"teamId"`,
+	DeletedAt: `"deletedAt"`,
+}
+
+var RefreshTokenCol = struct {
+	SessionID, UserID, IssuedAt, RevokedAt, ExpiresAt, LastSeenAt string
+}{
+	SessionID:  `"sessionId"`,
+	UserID:     `"userId"`,
+	IssuedAt:   `"issuedAt"`,
+	RevokedAt:  `"revokedAt"`,
+	ExpiresAt:  `"expiresAt"`,
+	LastSeenAt: `"lastSeenAt"`,
+}
+
+var APIKeyCol = struct {
+	Prefix, TeamID, RevokedAt, LastUsedAt string
+}{
+	Prefix:     `"prefix"`,
+	TeamID:     `"teamId"`,
+	RevokedAt:  `"revokedAt"`,
+	LastUsedAt: `"lastUsedAt"`,
+}
+
+var RolePermissionCol = struct {
+	RoleID string
+}{
+	RoleID: `"roleId"`,
+}
+
+var AuditLogCol = struct {
+	OccurredAt, ActorUserID, ActorAPIKeyID, Action, EntityType, EntityID string
+}{
+	OccurredAt:    `"occurredAt"`,
+	ActorUserID:   `"actorUserId"`,
+	ActorAPIKeyID: `"actorApiKeyId"`,
+	Action:        `"action"`,
+	EntityType:    `"entityType"`,
+	EntityID:      `"entityId"`,
+}
+
+var TaskRevisionCol = struct {
+	TaskID, Version, CreatedAt string
+}{
+	TaskID:    `"taskId"`,
+	Version:   `"version"`,
+	CreatedAt: `"createdAt"`,
+}
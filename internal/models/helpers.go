@@ -3,6 +3,7 @@ package models
 import "gorm.io/gorm"
 
 // CountTasksBy returns a map of id -> task count for the given column.
+// Soft-deleted tasks are not counted.
 // Used by List() handlers for team, job_type, job_detail, feeder.
 func CountTasksBy(db *gorm.DB, colName string, ids []int64) map[int64]int64 {
 	countMap := make(map[int64]int64)
@@ -19,7 +20,6 @@ func CountTasksBy(db *gorm.DB, colName string, ids []int64) map[int64]int64 {
 	db.Model(&TaskDaily{}).
 		Select(colName+" as id, count(*) as count").
 		Where(colName+" IN ?", ids).
-		Scopes(TaskNotDeleted).
 		Group(colName).
 		Find(&rows)
 
@@ -35,7 +35,6 @@ func CountTasksFor(db *gorm.DB, colName string, id int64) int64 {
 	var count int64
 	db.Model(&TaskDaily{}).
 		Where(colName+" = ?", id).
-		Scopes(TaskNotDeleted).
 		Count(&count)
 	return count
 }
@@ -1,5 +1,5 @@
 // Package models defines the database models for the Hotline maintenance system.
-// It includes 9 models for managing electrical network maintenance tasks.
+// It includes the models for managing electrical network maintenance tasks and user sessions.
 package models
 
 import (
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 // StringArray - Custom type for string array in PostgreSQL
@@ -172,6 +173,8 @@ type OperationCenter struct {
 	ID   int64  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
 	Name string `gorm:"not null;column:name" json:"name"`
 
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
+
 	Peas     []PEA     `gorm:"foreignKey:OperationID" json:"peas,omitempty"`
 	Stations []Station `gorm:"foreignKey:OperationID" json:"stations,omitempty"`
 }
@@ -188,6 +191,8 @@ type PEA struct {
 	Fullname    string `gorm:"not null;column:fullname" json:"fullname"`
 	OperationID int64  `gorm:"not null;column:operationId" json:"operationId"`
 
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
+
 	OperationCenter *OperationCenter `gorm:"foreignKey:OperationID;references:ID" json:"operationCenter,omitempty"`
 }
 
@@ -202,6 +207,12 @@ type Station struct {
 	Name        string `gorm:"not null;column:name" json:"name"`
 	CodeName    string `gorm:"not null;unique;column:codeName" json:"codeName"`
 	OperationID int64  `gorm:"not null;column:operationId" json:"operationId"`
+	Version     int    `gorm:"not null;default:1;column:version" json:"version"`
+
+	Latitude  *decimal.Decimal `gorm:"type:decimal(9,6);column:latitude" json:"latitude,omitempty"`
+	Longitude *decimal.Decimal `gorm:"type:decimal(9,6);column:longitude" json:"longitude,omitempty"`
+
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
 
 	OperationCenter *OperationCenter `gorm:"foreignKey:OperationID;references:ID" json:"operationCenter,omitempty"`
 	Feeders         []Feeder         `gorm:"foreignKey:StationID" json:"feeders,omitempty"`
@@ -217,6 +228,12 @@ type Feeder struct {
 	ID        int64  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
 	Code      string `gorm:"not null;unique;column:code" json:"code"`
 	StationID int64  `gorm:"not null;column:stationId;index:Feeder_stationId_idx" json:"stationId"`
+	Version   int    `gorm:"not null;default:1;column:version" json:"version"`
+
+	// Route is the feeder's line as an encoded polyline at six decimals (see geo.EncodePolyline)
+	Route *string `gorm:"type:text;column:route" json:"route,omitempty"`
+
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
 
 	Station *Station    `gorm:"foreignKey:StationID;references:ID" json:"station,omitempty"`
 	Tasks   []TaskDaily `gorm:"foreignKey:FeederID" json:"tasks,omitempty"`
@@ -232,6 +249,8 @@ type JobType struct {
 	ID   int64  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
 	Name string `gorm:"not null;unique;column:name" json:"name"`
 
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
+
 	Tasks      []TaskDaily `gorm:"foreignKey:JobTypeID" json:"tasks,omitempty"`
 	JobDetails []JobDetail `gorm:"foreignKey:JobTypeID" json:"jobDetails,omitempty"`
 }
@@ -243,12 +262,13 @@ func (JobType) TableName() string {
 
 // JobDetail - รายละเอียดงาน
 type JobDetail struct {
-	ID        int64      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
-	Name      string     `gorm:"not null;unique;column:name" json:"name"`
-	CreatedAt time.Time  `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
-	UpdatedAt time.Time  `gorm:"not null;type:timestamptz(6);column:updatedAt" json:"updatedAt"`
-	DeletedAt *time.Time `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt,omitempty"`
-	JobTypeID *int64     `gorm:"column:jobTypeId;index:JobDetail_jobTypeId_idx" json:"jobTypeId"`
+	ID        int64          `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name      string         `gorm:"not null;unique;column:name" json:"name"`
+	CreatedAt time.Time      `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt time.Time      `gorm:"not null;type:timestamptz(6);column:updatedAt" json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
+	JobTypeID *int64         `gorm:"column:jobTypeId;index:JobDetail_jobTypeId_idx" json:"jobTypeId"`
+	Version   int            `gorm:"not null;default:1;column:version" json:"version"`
 
 	JobType *JobType    `gorm:"foreignKey:JobTypeID;references:ID" json:"jobType,omitempty"`
 	Tasks   []TaskDaily `gorm:"foreignKey:JobDetailID" json:"tasks,omitempty"`
@@ -264,6 +284,8 @@ type Team struct {
 	ID   int64  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
 	Name string `gorm:"not null;column:name" json:"name"`
 
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
+
 	Tasks []TaskDaily `gorm:"foreignKey:TeamID" json:"tasks,omitempty"`
 }
 
@@ -285,11 +307,23 @@ type TaskDaily struct {
 	URLsAfter   StringArray      `gorm:"type:text[];column:urlsAfter" json:"urlsAfter"`
 	CreatedAt   time.Time        `gorm:"not null;type:timestamptz(6);column:createdat;default:CURRENT_TIMESTAMP" json:"createdAt"`
 	UpdatedAt   time.Time        `gorm:"not null;type:timestamptz(6);column:updatedat" json:"updatedAt"`
-	DeletedAt   *time.Time       `gorm:"type:timestamptz(6);column:deletedat" json:"deletedAt,omitempty"`
+	DeletedAt   gorm.DeletedAt   `gorm:"type:timestamptz(6);column:deletedat" json:"deletedAt"`
 	Detail      *string          `gorm:"column:detail" json:"detail,omitempty"`
 	TeamID      int64            `gorm:"not null;column:teamId" json:"teamId"`
 	Latitude    *decimal.Decimal `gorm:"type:decimal(9,6);column:latitude;index:TaskDaily_latitude_longitude_idx" json:"latitude,omitempty"`
 	Longitude   *decimal.Decimal `gorm:"type:decimal(9,6);column:longitude;index:TaskDaily_latitude_longitude_idx" json:"longitude,omitempty"`
+	Version     int              `gorm:"not null;default:1;column:version" json:"version"`
+
+	// Workflow: a task is reported as a draft, submitted for review and then approved
+	// or rejected by a supervisor; a rejected task is fixed and submitted again as
+	// corrected. Rows that predate the workflow were migrated as approved.
+	Status          string     `gorm:"not null;type:varchar(20);default:draft;column:status;index:TaskDaily_status_idx" json:"status"`
+	SubmittedAt     *time.Time `gorm:"type:timestamptz(6);column:submittedAt" json:"submittedAt,omitempty"`
+	SubmittedByID   *uint      `gorm:"column:submittedById" json:"submittedById,omitempty"`
+	ReviewedByID    *uint      `gorm:"column:reviewedById" json:"reviewedById,omitempty"`
+	ReviewedByName  *string    `gorm:"column:reviewedByName" json:"reviewedByName,omitempty"`
+	ReviewedAt      *time.Time `gorm:"type:timestamptz(6);column:reviewedAt" json:"reviewedAt,omitempty"`
+	RejectionReason *string    `gorm:"column:rejectionReason" json:"rejectionReason,omitempty"`
 
 	Team      *Team      `gorm:"foreignKey:TeamID;references:ID" json:"team,omitempty"`
 	JobType   *JobType   `gorm:"foreignKey:JobTypeID;references:ID" json:"jobType,omitempty"`
@@ -302,6 +336,18 @@ func (TaskDaily) TableName() string {
 	return "TaskDaily"
 }
 
+// Values of TaskDaily.Status.
+const (
+	TaskStatusDraft     = "draft"
+	TaskStatusSubmitted = "submitted"
+	TaskStatusApproved  = "approved"
+	TaskStatusRejected  = "rejected"
+	TaskStatusCorrected = "corrected"
+)
+
+// TaskStatuses lists every TaskDaily.Status in workflow order.
+var TaskStatuses = []string{TaskStatusDraft, TaskStatusSubmitted, TaskStatusApproved, TaskStatusRejected, TaskStatusCorrected}
+
 // User - ผู้ใช้งานระบบ
 type User struct {
 	ID        uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
@@ -311,9 +357,16 @@ type User struct {
 	TeamID    *int64     `gorm:"column:teamId;index:User_teamId_idx" json:"teamId,omitempty"`
 	IsActive  bool       `gorm:"not null;default:true;column:isActive" json:"isActive"`
 	LastLogin *time.Time `gorm:"column:lastLogin" json:"lastLogin,omitempty"`
-	CreatedAt time.Time  `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
-	UpdatedAt time.Time  `gorm:"not null;type:timestamptz(6);column:updatedAt" json:"updatedAt"`
-	DeletedAt *time.Time `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt,omitempty"`
+
+	// Two-factor authentication (TOTP). TOTPSecret is set on enrolment and
+	// TOTPEnabled once the first code has been confirmed.
+	TOTPSecret   *string `gorm:"column:totpSecret" json:"-"`
+	TOTPEnabled  bool    `gorm:"not null;default:false;column:totpEnabled" json:"totpEnabled"`
+	TOTPLastStep int64   `gorm:"not null;default:0;column:totpLastStep" json:"-"`
+
+	CreatedAt time.Time      `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt time.Time      `gorm:"not null;type:timestamptz(6);column:updatedAt" json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz(6);column:deletedAt" json:"deletedAt"`
 
 	Team *Team `gorm:"foreignKey:TeamID;references:ID" json:"team,omitempty"`
 }
@@ -322,3 +375,171 @@ type User struct {
 func (User) TableName() string {
 	return "User"
 }
+
+// RecoveryCode - รหัสสำรองสำหรับ 2FA (ใช้ได้ครั้งเดียว, เก็บเป็น bcrypt hash)
+type RecoveryCode struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	UserID    uint       `gorm:"not null;column:userId;index:RecoveryCode_userId_idx" json:"userId"`
+	CodeHash  string     `gorm:"not null;column:codeHash" json:"-"`
+	UsedAt    *time.Time `gorm:"type:timestamptz(6);column:usedAt" json:"usedAt,omitempty"`
+	CreatedAt time.Time  `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (RecoveryCode) TableName() string {
+	return "RecoveryCode"
+}
+
+// RefreshToken - refresh token ที่ออกให้ผู้ใช้ (หนึ่งแถวต่อหนึ่ง token)
+// Tokens that share a SessionID form one login session (a rotation family):
+// each /auth/refresh revokes the presented token and issues its successor.
+type RefreshToken struct {
+	ID           string     `gorm:"primaryKey;type:varchar(36);column:id" json:"id"` // jti
+	SessionID    string     `gorm:"not null;type:varchar(36);column:sessionId;index:RefreshToken_sessionId_idx" json:"sessionId"`
+	UserID       uint       `gorm:"not null;column:userId;index:RefreshToken_userId_idx" json:"userId"`
+	UserAgent    string     `gorm:"column:userAgent" json:"userAgent"`
+	IPAddress    string     `gorm:"column:ipAddress" json:"ipAddress"`
+	IssuedAt     time.Time  `gorm:"not null;type:timestamptz(6);column:issuedAt" json:"issuedAt"`
+	ExpiresAt    time.Time  `gorm:"not null;type:timestamptz(6);column:expiresAt" json:"expiresAt"`
+	RevokedAt    *time.Time `gorm:"type:timestamptz(6);column:revokedAt" json:"revokedAt,omitempty"`
+	ReplacedByID *string    `gorm:"type:varchar(36);column:replacedById" json:"replacedById,omitempty"`
+	// Last use of the session while this token is current, by refresh or access token
+	LastSeenAt        *time.Time `gorm:"type:timestamptz(6);column:lastSeenAt" json:"lastSeenAt,omitempty"`
+	LastSeenIP        string     `gorm:"column:lastSeenIp" json:"lastSeenIp,omitempty"`
+	LastSeenUserAgent string     `gorm:"column:lastSeenUserAgent" json:"lastSeenUserAgent,omitempty"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (RefreshToken) TableName() string {
+	return "RefreshToken"
+}
+
+// LoginThrottle - ตัวนับการ login ผิดพลาดที่ใช้ร่วมกันระหว่างหลาย instance
+// Key is "user:<username>" or "ip:<address>"; see internal/lockout.
+type LoginThrottle struct {
+	Key           string    `gorm:"primaryKey;column:key" json:"key"`
+	Failures      int       `gorm:"not null;default:0;column:failures" json:"failures"`
+	LastFailureAt time.Time `gorm:"type:timestamptz(6);column:lastFailureAt" json:"lastFailureAt"`
+	LockedUntil   time.Time `gorm:"type:timestamptz(6);column:lockedUntil" json:"lockedUntil"`
+	ExpiresAt     time.Time `gorm:"not null;type:timestamptz(6);column:expiresAt;index:LoginThrottle_expiresAt_idx" json:"expiresAt"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (LoginThrottle) TableName() string {
+	return "LoginThrottle"
+}
+
+// LoginFailure - ประวัติการ login ไม่สำเร็จ
+type LoginFailure struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Username  string    `gorm:"not null;column:username;index:LoginFailure_username_idx" json:"username"`
+	UserID    *uint     `gorm:"column:userId;index:LoginFailure_userId_idx" json:"userId,omitempty"`
+	IPAddress string    `gorm:"column:ipAddress" json:"ipAddress"`
+	UserAgent string    `gorm:"column:userAgent" json:"userAgent"`
+	Reason    string    `gorm:"not null;column:reason" json:"reason"`
+	CreatedAt time.Time `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (LoginFailure) TableName() string {
+	return "LoginFailure"
+}
+
+// APIKey - API key สำหรับ client ที่เป็นเครื่อง (script, kiosk)
+// The full key is "hlk_<prefix>_<secret>"; only Prefix and a SHA-256 hash of the whole
+// key are stored. Scopes is a comma-separated list (see internal/apikey).
+type APIKey struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name        string     `gorm:"not null;column:name" json:"name"`
+	Prefix      string     `gorm:"not null;unique;type:varchar(16);column:prefix" json:"prefix"`
+	KeyHash     string     `gorm:"not null;type:varchar(64);column:keyHash" json:"-"`
+	Role        string     `gorm:"not null;default:viewer;column:role" json:"role"`
+	Scopes      string     `gorm:"not null;default:read;column:scopes" json:"scopes"`
+	TeamID      *int64     `gorm:"column:teamId" json:"teamId,omitempty"`
+	CreatedByID *uint      `gorm:"column:createdById" json:"createdById,omitempty"`
+	ExpiresAt   *time.Time `gorm:"type:timestamptz(6);column:expiresAt" json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time `gorm:"type:timestamptz(6);column:lastUsedAt" json:"lastUsedAt,omitempty"`
+	LastUsedIP  string     `gorm:"column:lastUsedIp" json:"lastUsedIp,omitempty"`
+	RevokedAt   *time.Time `gorm:"type:timestamptz(6);column:revokedAt" json:"revokedAt,omitempty"`
+	CreatedAt   time.Time  `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (APIKey) TableName() string {
+	return "ApiKey"
+}
+
+// Role - บทบาทผู้ใช้และสิทธิ์ที่ได้รับ
+// User.Role and APIKey.Role refer to Role.Name. System roles are seeded at startup
+// and cannot be deleted; see internal/permission for the permission catalog.
+type Role struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name        string    `gorm:"not null;unique;type:varchar(50);column:name" json:"name"`
+	Description string    `gorm:"column:description" json:"description"`
+	IsSystem    bool      `gorm:"not null;default:false;column:isSystem" json:"isSystem"`
+	CreatedAt   time.Time `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt   time.Time `gorm:"not null;type:timestamptz(6);column:updatedAt" json:"updatedAt"`
+
+	Permissions []RolePermission `gorm:"foreignKey:RoleID;references:ID;constraint:OnDelete:CASCADE" json:"permissions,omitempty"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (Role) TableName() string {
+	return "Role"
+}
+
+// RolePermission - สิทธิ์หนึ่งรายการของบทบาท
+type RolePermission struct {
+	RoleID     uint   `gorm:"primaryKey;column:roleId" json:"roleId"`
+	Permission string `gorm:"primaryKey;type:varchar(100);column:permission" json:"permission"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (RolePermission) TableName() string {
+	return "RolePermission"
+}
+
+// AuditLog - บันทึกการเปลี่ยนแปลงข้อมูล (เพิ่มได้อย่างเดียว ห้ามแก้ไข/ลบ)
+// Before/After are JSON snapshots of the entity; Changes holds only the fields that
+// differ as {"field": {"from": ..., "to": ...}}. See internal/audit.
+type AuditLog struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	OccurredAt    time.Time `gorm:"not null;type:timestamptz(6);column:occurredAt;index:AuditLog_occurredAt_idx" json:"occurredAt"`
+	ActorUserID   *uint     `gorm:"column:actorUserId;index:AuditLog_actorUserId_idx" json:"actorUserId,omitempty"`
+	ActorAPIKeyID *uint     `gorm:"column:actorApiKeyId" json:"actorApiKeyId,omitempty"`
+	ActorName     string    `gorm:"column:actorName" json:"actorName"`
+	Action        string    `gorm:"not null;type:varchar(50);column:action" json:"action"`
+	EntityType    string    `gorm:"not null;type:varchar(50);column:entityType;index:AuditLog_entity_idx,priority:1" json:"entityType"`
+	EntityID      string    `gorm:"not null;type:varchar(100);column:entityId;index:AuditLog_entity_idx,priority:2" json:"entityId"`
+	Before        *string   `gorm:"type:jsonb;column:before" json:"before,omitempty"`
+	After         *string   `gorm:"type:jsonb;column:after" json:"after,omitempty"`
+	Changes       *string   `gorm:"type:jsonb;column:changes" json:"changes,omitempty"`
+	IPAddress     string    `gorm:"column:ipAddress" json:"ipAddress"`
+	RequestID     string    `gorm:"type:varchar(64);column:requestId" json:"requestId"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (AuditLog) TableName() string {
+	return "AuditLog"
+}
+
+// TaskRevision - ประวัติการแก้ไขงาน (หนึ่งแถวต่อหนึ่งเวอร์ชัน ไม่มีการแก้ไขย้อนหลัง)
+// Snapshot is the TaskDaily row as JSON (without relations) after the change.
+// ChangedFields lists the JSON field names that differ from the previous version.
+type TaskRevision struct {
+	ID            int64       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	TaskID        int64       `gorm:"not null;column:taskId;uniqueIndex:TaskRevision_taskId_version_key,priority:1" json:"taskId"`
+	Version       int         `gorm:"not null;column:version;uniqueIndex:TaskRevision_taskId_version_key,priority:2" json:"version"`
+	Action        string      `gorm:"not null;type:varchar(20);column:action" json:"action"`
+	Snapshot      string      `gorm:"not null;type:jsonb;column:snapshot" json:"snapshot"`
+	ChangedFields StringArray `gorm:"type:text[];column:changedFields" json:"changedFields"`
+	RevertedFrom  *int        `gorm:"column:revertedFrom" json:"revertedFrom,omitempty"`
+	AuthorID      *uint       `gorm:"column:authorId" json:"authorId,omitempty"`
+	AuthorName    string      `gorm:"column:authorName" json:"authorName"`
+	CreatedAt     time.Time   `gorm:"not null;type:timestamptz(6);column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
+}
+
+// TableName กำหนดชื่อตารางใน database
+func (TaskRevision) TableName() string {
+	return "TaskRevision"
+}
@@ -1,16 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"backend-hotlines3/internal/geo"
 
-// TaskNotDeleted filters out soft-deleted TaskDaily records.
-func TaskNotDeleted(db *gorm.DB) *gorm.DB {
-	return db.Where(TaskCol.DeletedAt + " IS NULL")
-}
-
-// JobDetailNotDeleted filters out soft-deleted JobDetail records.
-func JobDetailNotDeleted(db *gorm.DB) *gorm.DB {
-	return db.Where(JobDetailCol.DeletedAt + " IS NULL")
-}
+	"gorm.io/gorm"
+)
 
 // TaskByYear filters tasks by year extracted from workdate.
 func TaskByYear(year string) func(*gorm.DB) *gorm.DB {
@@ -62,6 +56,16 @@ func TaskByFeeder(feederID string) func(*gorm.DB) *gorm.DB {
 	}
 }
 
+// TaskByStatus filters tasks by workflow status. Skips if empty or "all".
+func TaskByStatus(status string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if status == "" || status == "all" {
+			return db
+		}
+		return db.Where(TaskCol.Status+" = ?", status)
+	}
+}
+
 // TaskByDateRange filters tasks between startDate and endDate.
 func TaskByDateRange(startDate, endDate string) func(*gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -80,6 +84,52 @@ func TaskFeederNotNull(db *gorm.DB) *gorm.DB {
 	return db.Where(TaskCol.FeederID + " IS NOT NULL")
 }
 
+// TaskWithCoordinates filters tasks that have both latitude and longitude.
+func TaskWithCoordinates(db *gorm.DB) *gorm.DB {
+	return db.
+		Where(TaskCol.Latitude + " IS NOT NULL").
+		Where(TaskCol.Longitude + " IS NOT NULL")
+}
+
+// TaskInBBox filters tasks whose coordinates lie inside b. Tasks without
+// coordinates never match.
+func TaskInBBox(b geo.BBox) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where(TaskCol.Latitude+" BETWEEN ? AND ?", b.MinLat, b.MaxLat).
+			Where(TaskCol.Longitude+" BETWEEN ? AND ?", b.MinLng, b.MaxLng)
+	}
+}
+
+// TaskNear filters tasks within radiusKm of lat,lng. The enclosing bounding box is
+// applied first so the latitude/longitude index narrows the rows before the
+// distance is computed.
+func TaskNear(lat, lng, radiusKm float64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Scopes(TaskInBBox(geo.Around(lat, lng, radiusKm))).
+			Where(TaskDistanceSQL()+" <= ?", append(geo.DistanceArgs(lat, lng), radiusKm)...)
+	}
+}
+
+// StationNear filters stations within radiusKm of lat,lng, narrowed by the enclosing
+// bounding box like TaskNear. Stations without coordinates never match.
+func StationNear(lat, lng, radiusKm float64) func(*gorm.DB) *gorm.DB {
+	b := geo.Around(lat, lng, radiusKm)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where(StationCol.Latitude+" BETWEEN ? AND ?", b.MinLat, b.MaxLat).
+			Where(StationCol.Longitude+" BETWEEN ? AND ?", b.MinLng, b.MaxLng).
+			Where(geo.DistanceSQL(StationCol.Latitude, StationCol.Longitude)+" <= ?", append(geo.DistanceArgs(lat, lng), radiusKm)...)
+	}
+}
+
+// TaskDistanceSQL is the distance in km from a task to the point bound by
+// geo.DistanceArgs.
+func TaskDistanceSQL() string {
+	return geo.DistanceSQL(TaskCol.Latitude, TaskCol.Longitude)
+}
+
 // ApplyDashboardFilters applies year, month, team, and jobType filters together.
 func ApplyDashboardFilters(year, month, teamID, jobTypeID string) func(*gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -0,0 +1,98 @@
+// Package permission defines what each role may do.
+// Roles and their permissions live in the Role/RolePermission tables; User.Role and
+// APIKey.Role name a role. Access tokens carry only the role, and the Resolver maps it
+// to permissions (with a short cache) so edits to a role apply without re-login.
+package permission
+
+import (
+	"sort"
+	"strings"
+)
+
+// Permissions enforced by the API.
+const (
+	// All grants every permission, including ones added later. Held by the admin role.
+	All = "*"
+
+	ReferenceWrite   = "reference:write"     // create/update/delete teams, job types, feeders, stations, ...
+	TaskWriteOwnTeam = "task:write:own-team" // create/update/delete tasks of the caller's team
+	TaskWriteAnyTeam = "task:write:any-team" // create/update/delete tasks of any team
+	TaskApprove      = "task:approve"        // approve or reject submitted tasks and edit them under review
+	UploadWrite      = "upload:write"        // upload and delete task photos
+	UserManage       = "user:manage"         // manage users, their sessions and login lockouts
+	RoleManage       = "role:manage"         // manage roles and their permissions
+	APIKeyManage     = "apikey:manage"       // manage API keys
+	AuditRead        = "audit:read"          // read the audit log
+	DeletedRead      = "deleted:read"        // see soft-deleted rows with ?includeDeleted=true
+)
+
+// Definition documents one permission for GET /v1/permissions.
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Catalog lists every permission that may be granted to a role.
+var Catalog = []Definition{
+	{All, "Every permission, including ones added in the future"},
+	{ReferenceWrite, "Create, update and delete reference data (teams, job types, job details, feeders, stations, PEAs, operation centers)"},
+	{TaskWriteOwnTeam, "Create, update and delete tasks of the caller's own team"},
+	{TaskWriteAnyTeam, "Create, update and delete tasks of any team"},
+	{TaskApprove, "Approve or reject submitted tasks, and edit tasks that are under review or approved"},
+	{UploadWrite, "Upload and delete task photos"},
+	{UserManage, "Manage users, their sessions and login lockouts"},
+	{RoleManage, "Manage roles and their permissions"},
+	{APIKeyManage, "Manage API keys"},
+	{AuditRead, "Read the audit log of all changes"},
+	{DeletedRead, "See soft-deleted records by passing includeDeleted=true to list and detail endpoints"},
+}
+
+// Valid reports whether name is in the Catalog.
+func Valid(name string) bool {
+	for _, d := range Catalog {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Set is the permissions held by one role.
+type Set map[string]bool
+
+func NewSet(names ...string) Set {
+	s := make(Set, len(names))
+	for _, n := range names {
+		s[n] = true
+	}
+	return s
+}
+
+// Has reports whether the set grants p.
+func (s Set) Has(p string) bool {
+	return s[All] || s[p]
+}
+
+// HasAny reports whether the set grants at least one of ps.
+func (s Set) HasAny(ps ...string) bool {
+	for _, p := range ps {
+		if s.Has(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the permissions sorted by name.
+func (s Set) List() []string {
+	names := make([]string, 0, len(s))
+	for n := range s {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s Set) String() string {
+	return strings.Join(s.List(), ",")
+}
@@ -0,0 +1,71 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultCacheTTL bounds how long another replica may serve stale permissions after a role edit.
+const DefaultCacheTTL = 30 * time.Second
+
+type cacheEntry struct {
+	perms     Set
+	expiresAt time.Time
+}
+
+// Resolver maps role names to permission sets, caching each lookup for ttl.
+// A role that does not exist resolves to an empty set.
+type Resolver struct {
+	db    *gorm.DB
+	ttl   time.Duration
+	now   func() time.Time
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func NewResolver(db *gorm.DB, ttl time.Duration) *Resolver {
+	return &Resolver{db: db, ttl: ttl, now: time.Now, cache: make(map[string]cacheEntry)}
+}
+
+// Permissions returns the permissions of role.
+func (r *Resolver) Permissions(ctx context.Context, role string) (Set, error) {
+	now := r.now()
+	r.mu.Lock()
+	if e, ok := r.cache[role]; ok && now.Before(e.expiresAt) {
+		r.mu.Unlock()
+		return e.perms, nil
+	}
+	r.mu.Unlock()
+
+	var row models.Role
+	err := r.db.WithContext(ctx).Preload("Permissions").Where("name = ?", role).First(&row).Error
+	perms := Set{}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to load role %q: %w", role, err)
+	default:
+		for _, p := range row.Permissions {
+			perms[p.Permission] = true
+		}
+	}
+
+	r.mu.Lock()
+	r.cache[role] = cacheEntry{perms: perms, expiresAt: now.Add(r.ttl)}
+	r.mu.Unlock()
+	return perms, nil
+}
+
+// Invalidate drops cached permissions so the next lookup reads the database.
+func (r *Resolver) Invalidate() {
+	r.mu.Lock()
+	r.cache = make(map[string]cacheEntry)
+	r.mu.Unlock()
+}
@@ -0,0 +1,69 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Built-in role names.
+const (
+	RoleAdmin      = "admin"
+	RoleSupervisor = "supervisor"
+	RoleUser       = "user"
+	RoleViewer     = "viewer"
+)
+
+// defaultRoles are created by Seed when missing. Seed also grants a built-in role any
+// of its default permissions it lacks, so a permission added here reaches existing
+// databases; permissions added through the API are kept. Admin always keeps All.
+var defaultRoles = []struct {
+	name        string
+	description string
+	permissions []string
+}{
+	{RoleAdmin, "Full access", []string{All}},
+	{RoleSupervisor, "Manages tasks of every team", []string{TaskWriteAnyTeam, TaskApprove, UploadWrite}},
+	{RoleUser, "Field staff; manages tasks of their own team", []string{TaskWriteOwnTeam, UploadWrite}},
+	{RoleViewer, "Read-only access", nil},
+}
+
+// Seed creates the built-in roles and tops up their default permissions. It is safe
+// to run on every start.
+func Seed(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, def := range defaultRoles {
+			role := models.Role{Name: def.name, Description: def.description, IsSystem: true}
+			result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(&role)
+			if result.Error != nil {
+				return fmt.Errorf("failed to seed role %s: %w", def.name, result.Error)
+			}
+			if role.ID == 0 {
+				if err := tx.Where("name = ?", def.name).First(&role).Error; err != nil {
+					return fmt.Errorf("failed to load role %s: %w", def.name, err)
+				}
+			}
+			for _, p := range def.permissions {
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+					Create(&models.RolePermission{RoleID: role.ID, Permission: p}).Error; err != nil {
+					return fmt.Errorf("failed to seed permissions of %s: %w", def.name, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Defaults returns the default permissions of the built-in role name, or nil.
+func Defaults(name string) []string {
+	for _, def := range defaultRoles {
+		if def.name == name {
+			return def.permissions
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package permission_test
+
+import (
+	"context"
+	"testing"
+
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/testutil"
+)
+
+// TestSeedTopsUpDefaults simulates a supervisor role seeded before task:approve was one
+// of its defaults and later customised with an extra permission.
+func TestSeedTopsUpDefaults(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+
+	var role models.Role
+	if err := db.Where("name = ?", permission.RoleSupervisor).First(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Where(models.RolePermissionCol.RoleID+" = ? AND permission = ?", role.ID, permission.TaskApprove).
+		Delete(&models.RolePermission{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.RolePermission{RoleID: role.ID, Permission: permission.AuditRead}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := permission.Seed(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var granted []string
+	if err := db.Model(&models.RolePermission{}).Where(models.RolePermissionCol.RoleID+" = ?", role.ID).
+		Pluck("permission", &granted).Error; err != nil {
+		t.Fatal(err)
+	}
+	got := permission.NewSet(granted...)
+	for _, p := range append(permission.Defaults(permission.RoleSupervisor), permission.AuditRead) {
+		if !got.Has(p) {
+			t.Errorf("supervisor lacks %s after Seed; has %v", p, granted)
+		}
+	}
+	if len(granted) != len(permission.Defaults(permission.RoleSupervisor))+1 {
+		t.Errorf("supervisor permissions = %v, want the defaults plus %s", granted, permission.AuditRead)
+	}
+}
@@ -0,0 +1,170 @@
+package router
+
+import (
+	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/permission"
+)
+
+var (
+	referenceWrite = middleware.PolicyPermission(permission.ReferenceWrite)
+	taskWrite      = middleware.PolicyPermission(permission.TaskWriteOwnTeam, permission.TaskWriteAnyTeam)
+	taskApprove    = middleware.PolicyPermission(permission.TaskApprove)
+	uploadWrite    = middleware.PolicyPermission(permission.UploadWrite)
+	userManage     = middleware.PolicyPermission(permission.UserManage)
+	roleManage     = middleware.PolicyPermission(permission.RoleManage)
+	apiKeyManage   = middleware.PolicyPermission(permission.APIKeyManage)
+	auditRead      = middleware.PolicyPermission(permission.AuditRead)
+	deletedRead    = middleware.PolicyPermission(permission.DeletedRead)
+	adminOnly      = middleware.PolicyPermission(permission.All)
+)
+
+// routePolicies is the access policy for every /v1 route.
+// Reads of reference data, tasks and dashboards stay public so the CDN can cache them;
+// writes need a permission held by the caller's role (see internal/permission).
+// Routes not listed here fall back to PolicyTable.Lookup (public GET, admin everything else).
+var routePolicies = middleware.PolicyTable{
+	// Auth
+	"POST /v1/auth/login":              middleware.PolicyPublic,
+	"POST /v1/auth/refresh":            middleware.PolicyPublic,
+	"POST /v1/auth/register":           userManage,
+	"POST /v1/auth/logout":             middleware.PolicyAuthenticated,
+	"GET /v1/auth/me":                  middleware.PolicyAuthenticated,
+	"GET /v1/auth/sessions":            middleware.PolicyAuthenticated,
+	"DELETE /v1/auth/sessions/:id":     middleware.PolicyAuthenticated,
+	"POST /v1/auth/2fa/setup":          middleware.PolicyPublic,
+	"POST /v1/auth/2fa/verify":         middleware.PolicyPublic,
+	"POST /v1/auth/2fa/enroll":         middleware.PolicyAuthenticated,
+	"POST /v1/auth/2fa/enable":         middleware.PolicyAuthenticated,
+	"POST /v1/auth/2fa/disable":        middleware.PolicyAuthenticated,
+	"POST /v1/auth/2fa/recovery-codes": middleware.PolicyAuthenticated,
+
+	// Teams
+	"GET /v1/teams":              middleware.PolicyPublic,
+	"GET /v1/teams/:id":          middleware.PolicyPublic,
+	"POST /v1/teams":             referenceWrite,
+	"POST /v1/teams/merge":       referenceWrite,
+	"PUT /v1/teams/:id":          referenceWrite,
+	"DELETE /v1/teams/:id":       referenceWrite,
+	"POST /v1/teams/:id/restore": referenceWrite,
+
+	// Job Types
+	"GET /v1/job-types":              middleware.PolicyPublic,
+	"GET /v1/job-types/:id":          middleware.PolicyPublic,
+	"POST /v1/job-types":             referenceWrite,
+	"PUT /v1/job-types/:id":          referenceWrite,
+	"DELETE /v1/job-types/:id":       referenceWrite,
+	"POST /v1/job-types/:id/restore": referenceWrite,
+
+	// Job Details
+	"GET /v1/job-details":              middleware.PolicyPublic,
+	"GET /v1/job-details/:id":          middleware.PolicyPublic,
+	"POST /v1/job-details":             referenceWrite,
+	"POST /v1/job-details/merge":       referenceWrite,
+	"PUT /v1/job-details/:id":          referenceWrite,
+	"DELETE /v1/job-details/:id":       referenceWrite,
+	"POST /v1/job-details/:id/restore": referenceWrite,
+
+	// Feeders
+	"GET /v1/feeders":              middleware.PolicyPublic,
+	"GET /v1/feeders/suggest":      middleware.PolicyPublic,
+	"GET /v1/feeders/:id":          middleware.PolicyPublic,
+	"POST /v1/feeders":             referenceWrite,
+	"POST /v1/feeders/merge":       referenceWrite,
+	"PUT /v1/feeders/:id":          referenceWrite,
+	"DELETE /v1/feeders/:id":       referenceWrite,
+	"POST /v1/feeders/:id/restore": referenceWrite,
+
+	// Stations
+	"GET /v1/stations":              middleware.PolicyPublic,
+	"GET /v1/stations/:id":          middleware.PolicyPublic,
+	"POST /v1/stations":             referenceWrite,
+	"POST /v1/stations/merge":       referenceWrite,
+	"PUT /v1/stations/:id":          referenceWrite,
+	"DELETE /v1/stations/:id":       referenceWrite,
+	"POST /v1/stations/:id/restore": referenceWrite,
+
+	// Topology
+	"POST /v1/topology/import":   referenceWrite,
+	"POST /v1/topology/geometry": referenceWrite,
+
+	// PEAs
+	"GET /v1/peas":              middleware.PolicyPublic,
+	"GET /v1/peas/:id":          middleware.PolicyPublic,
+	"POST /v1/peas":             referenceWrite,
+	"POST /v1/peas/bulk":        referenceWrite,
+	"PUT /v1/peas/:id":          referenceWrite,
+	"DELETE /v1/peas/:id":       referenceWrite,
+	"POST /v1/peas/:id/restore": referenceWrite,
+
+	// Operation Centers
+	"GET /v1/operation-centers":              middleware.PolicyPublic,
+	"GET /v1/operation-centers/:id":          middleware.PolicyPublic,
+	"POST /v1/operation-centers":             referenceWrite,
+	"PUT /v1/operation-centers/:id":          referenceWrite,
+	"DELETE /v1/operation-centers/:id":       referenceWrite,
+	"POST /v1/operation-centers/:id/restore": referenceWrite,
+
+	// Tasks
+	"GET /v1/tasks":              middleware.PolicyPublic,
+	"GET /v1/tasks/by-team":      middleware.PolicyPublic,
+	"GET /v1/tasks/by-filter":    middleware.PolicyPublic,
+	"GET /v1/tasks/export":       middleware.PolicyAuthenticated,
+	"GET /v1/tasks/geojson":      middleware.PolicyAuthenticated,
+	"GET /v1/tasks/kml":          middleware.PolicyAuthenticated,
+	"GET /v1/tasks/:id":          middleware.PolicyPublic,
+	"POST /v1/tasks":             taskWrite,
+	"POST /v1/tasks/import":      taskWrite,
+	"PUT /v1/tasks/:id":          taskWrite,
+	"DELETE /v1/tasks/:id":       taskWrite,
+	"GET /v1/tasks/:id/history":  middleware.PolicyAuthenticated,
+	"POST /v1/tasks/:id/revert":  taskWrite,
+	"POST /v1/tasks/:id/restore": taskWrite,
+	"POST /v1/tasks/:id/submit":  taskWrite,
+	"POST /v1/tasks/:id/approve": taskApprove,
+	"POST /v1/tasks/:id/reject":  taskApprove,
+
+	// Upload
+	"POST /v1/upload/image":  uploadWrite,
+	"DELETE /v1/upload/*key": uploadWrite,
+
+	// Dashboard
+	"GET /v1/dashboard/summary":       middleware.PolicyPublic,
+	"GET /v1/dashboard/top-jobs":      middleware.PolicyPublic,
+	"GET /v1/dashboard/top-feeders":   middleware.PolicyPublic,
+	"GET /v1/dashboard/feeder-matrix": middleware.PolicyPublic,
+	"GET /v1/dashboard/stats":         middleware.PolicyPublic,
+	"GET /v1/dashboard/heatmap":       middleware.PolicyPublic,
+	"GET /v1/dashboard/clusters":      middleware.PolicyPublic,
+
+	// Users
+	"GET /v1/users":                      userManage,
+	"GET /v1/users/:id":                  userManage,
+	"POST /v1/users":                     userManage,
+	"PUT /v1/users/:id":                  userManage,
+	"DELETE /v1/users/:id":               userManage,
+	"POST /v1/users/:id/restore":         userManage,
+	"POST /v1/users/:id/revoke-sessions": userManage,
+	"POST /v1/users/:id/unlock":          userManage,
+	"GET /v1/users/:id/login-failures":   userManage,
+	"PUT /v1/users/:id/password":         middleware.PolicyAuthenticated,
+
+	// API keys
+	"GET /v1/api-keys":        apiKeyManage,
+	"POST /v1/api-keys":       apiKeyManage,
+	"DELETE /v1/api-keys/:id": apiKeyManage,
+
+	// Roles and permissions
+	"GET /v1/permissions":  roleManage,
+	"GET /v1/roles":        roleManage,
+	"GET /v1/roles/:id":    roleManage,
+	"POST /v1/roles":       roleManage,
+	"PUT /v1/roles/:id":    roleManage,
+	"DELETE /v1/roles/:id": roleManage,
+
+	// Audit log
+	"GET /v1/audit": auditRead,
+
+	// Trash bin
+	"GET /v1/trash":        deletedRead,
+	"POST /v1/trash/purge": adminOnly,
+}
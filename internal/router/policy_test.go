@@ -0,0 +1,345 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/apikey"
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/internal/lockout"
+	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/session"
+	"backend-hotlines3/internal/testutil"
+	"backend-hotlines3/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// unlistedPath is registered by the tests only, to exercise the fallback of
+// PolicyTable.Lookup for routes missing from routePolicies.
+const unlistedPath = "/v1/zz-unlisted"
+
+// caller is one identity the policy tests send requests as.
+type caller struct {
+	name  string
+	auth  func(*http.Request)
+	perms permission.Set
+	// scopes is set for API keys, whose scopes are checked before the role
+	scopes string
+}
+
+func (c caller) anonymous() bool { return c.auth == nil }
+
+type policyFixture struct {
+	engine  *gin.Engine
+	routes  gin.RoutesInfo
+	callers map[string]caller
+}
+
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Cloudflare.R2.AccountID = "test"
+	cfg.Cloudflare.R2.BucketName = "test"
+	return cfg
+}
+
+func newPolicyFixture(t *testing.T) *policyFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	db := testutil.NewDB(t)
+	jwtManager := jwt.NewJWTManager("test-secret", time.Hour, 24*time.Hour)
+	guard := lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig())
+	real := SetupRouter(testConfig(), db, jwtManager, guard)
+
+	// The same policy middleware in front of stub handlers, so every route can be
+	// called without the data its real handler needs.
+	sessions := session.NewStore(db)
+	apiKeys := apikey.NewStore(db)
+	resolver := permission.NewResolver(db, time.Minute)
+	authMw := middleware.NewAuthMiddleware(jwtManager, sessions, apiKeys, resolver)
+	engine := gin.New()
+	v1 := engine.Group("/v1")
+	v1.Use(authMw.EnforcePolicy(routePolicies))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	var routes gin.RoutesInfo
+	for _, r := range real.Routes() {
+		if strings.HasPrefix(r.Path, "/v1/") {
+			v1.Handle(r.Method, strings.TrimPrefix(r.Path, "/v1"), ok)
+			routes = append(routes, r)
+		}
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		v1.Handle(method, strings.TrimPrefix(unlistedPath, "/v1"), ok)
+		routes = append(routes, gin.RouteInfo{Method: method, Path: unlistedPath})
+	}
+
+	teamID := int64(1)
+	user := func(id uint, role string, team *int64) func(*http.Request) {
+		sid := uuid.New().String()
+		pair, err := jwtManager.GenerateTokenPair(id, role+"-user", role, team, sid)
+		if err != nil {
+			t.Fatalf("generate token: %v", err)
+		}
+		if err := sessions.Issue(ctx, &models.RefreshToken{
+			ID:        pair.RefreshTokenID,
+			SessionID: sid,
+			UserID:    id,
+			IssuedAt:  time.Now(),
+			ExpiresAt: pair.RefreshExpiresAt,
+		}); err != nil {
+			t.Fatalf("issue session: %v", err)
+		}
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+pair.AccessToken) }
+	}
+	key := func(role, scopes string) func(*http.Request) {
+		raw, err := apiKeys.Create(ctx, &models.APIKey{Name: role + "-key", Role: role, Scopes: scopes})
+		if err != nil {
+			t.Fatalf("create api key: %v", err)
+		}
+		return func(r *http.Request) { r.Header.Set("X-API-Key", raw) }
+	}
+
+	// What each role may do comes from the roles permission.Seed created, so the
+	// expectations follow the default grants rather than a copy of them
+	perms := func(role string) permission.Set {
+		set, err := resolver.Permissions(ctx, role)
+		if err != nil {
+			t.Fatalf("resolve %s: %v", role, err)
+		}
+		return set
+	}
+	adminPerms := perms(permission.RoleAdmin)
+	supervisorPerms := perms(permission.RoleSupervisor)
+	userPerms := perms(permission.RoleUser)
+	viewerPerms := perms(permission.RoleViewer)
+
+	callers := map[string]caller{}
+	for _, c := range []caller{
+		{name: "anonymous"},
+		{name: "viewer", auth: user(1, permission.RoleViewer, nil), perms: viewerPerms},
+		{name: "user", auth: user(2, permission.RoleUser, &teamID), perms: userPerms},
+		{name: "supervisor", auth: user(3, permission.RoleSupervisor, nil), perms: supervisorPerms},
+		{name: "admin", auth: user(4, permission.RoleAdmin, nil), perms: adminPerms},
+		{name: "admin-key", auth: key(permission.RoleAdmin, "*"), perms: adminPerms, scopes: "*"},
+		{name: "read-key", auth: key(permission.RoleAdmin, "read"), perms: adminPerms, scopes: "read"},
+		{name: "viewer-key", auth: key(permission.RoleViewer, "*"), perms: viewerPerms, scopes: "*"},
+	} {
+		callers[c.name] = c
+	}
+
+	return &policyFixture{engine: engine, routes: routes, callers: callers}
+}
+
+func (f *policyFixture) do(t *testing.T, c caller, method, target string) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	if c.auth != nil {
+		c.auth(req)
+	}
+	w := httptest.NewRecorder()
+	f.engine.ServeHTTP(w, req)
+	return w.Code
+}
+
+// concretePath fills route parameters so the path can be requested.
+func concretePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "1"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// expectedStatus is what the policy of a route promises to c: 401 when credentials
+// are needed and missing, 403 when the role or API key scope falls short, 200 otherwise.
+func expectedStatus(policy middleware.Policy, c caller, method, path string, includeDeleted bool) int {
+	kind := policy.String()
+	if kind != "public" {
+		if c.anonymous() {
+			return http.StatusUnauthorized
+		}
+		if c.scopes != "" && !apikey.Allows(c.scopes, method, path) {
+			return http.StatusForbidden
+		}
+		if required, ok := strings.CutPrefix(kind, "permission:"); ok && !c.perms.HasAny(strings.Split(required, "|")...) {
+			return http.StatusForbidden
+		}
+	}
+	if includeDeleted {
+		if kind == "public" {
+			if c.anonymous() {
+				return http.StatusUnauthorized
+			}
+			if c.scopes != "" && !apikey.Allows(c.scopes, method, path) {
+				return http.StatusForbidden
+			}
+		}
+		if !c.perms.Has(permission.DeletedRead) {
+			return http.StatusForbidden
+		}
+	}
+	return http.StatusOK
+}
+
+func TestEveryRouteHasPolicy(t *testing.T) {
+	f := newPolicyFixture(t)
+
+	registered := map[string]bool{}
+	for _, r := range f.routes {
+		if r.Path == unlistedPath {
+			continue
+		}
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if _, ok := routePolicies[key]; !ok {
+			t.Errorf("route %s has no entry in routePolicies", key)
+		}
+	}
+	for key := range routePolicies {
+		if !registered[key] {
+			t.Errorf("routePolicies lists %s, which is not a registered route", key)
+		}
+	}
+}
+
+func TestRoutePolicies(t *testing.T) {
+	f := newPolicyFixture(t)
+
+	for _, r := range f.routes {
+		policy := routePolicies.Lookup(r.Method, r.Path)
+		target := concretePath(r.Path)
+		for _, c := range f.callers {
+			want := expectedStatus(policy, c, r.Method, r.Path, false)
+			if got := f.do(t, c, r.Method, target); got != want {
+				t.Errorf("%s %s as %s (%s): got %d, want %d", r.Method, r.Path, c.name, policy, got, want)
+			}
+
+			if r.Method != http.MethodGet {
+				continue
+			}
+			want = expectedStatus(policy, c, r.Method, r.Path, true)
+			if got := f.do(t, c, r.Method, target+"?includeDeleted=true"); got != want {
+				t.Errorf("%s %s?includeDeleted=true as %s (%s): got %d, want %d", r.Method, r.Path, c.name, policy, got, want)
+			}
+		}
+	}
+}
+
+func TestRoutePolicyExamples(t *testing.T) {
+	f := newPolicyFixture(t)
+
+	tests := []struct {
+		method, target, caller string
+		want                   int
+	}{
+		// Public reads, permission-gated writes
+		{"GET", "/v1/tasks", "anonymous", http.StatusOK},
+		{"POST", "/v1/tasks", "anonymous", http.StatusUnauthorized},
+		{"POST", "/v1/tasks", "viewer", http.StatusForbidden},
+		{"POST", "/v1/tasks", "user", http.StatusOK},
+		{"POST", "/v1/tasks", "admin", http.StatusOK},
+		{"POST", "/v1/tasks", "admin-key", http.StatusOK},
+		{"POST", "/v1/tasks", "read-key", http.StatusForbidden},
+		{"POST", "/v1/tasks", "viewer-key", http.StatusForbidden},
+		{"POST", "/v1/tasks/1/approve", "user", http.StatusForbidden},
+		{"POST", "/v1/tasks/1/approve", "supervisor", http.StatusOK},
+		{"POST", "/v1/teams", "supervisor", http.StatusForbidden},
+		{"POST", "/v1/teams", "admin", http.StatusOK},
+
+		// Default grants of the seeded roles
+		{"POST", "/v1/upload/image", "viewer", http.StatusForbidden},
+		{"POST", "/v1/upload/image", "user", http.StatusOK},
+		{"POST", "/v1/upload/image", "supervisor", http.StatusOK},
+		{"GET", "/v1/trash", "supervisor", http.StatusForbidden},
+		{"GET", "/v1/tasks?includeDeleted=true", "supervisor", http.StatusForbidden},
+
+		// Authenticated reads
+		{"GET", "/v1/auth/me", "anonymous", http.StatusUnauthorized},
+		{"GET", "/v1/auth/me", "viewer", http.StatusOK},
+		{"GET", "/v1/tasks/export", "anonymous", http.StatusUnauthorized},
+		{"GET", "/v1/tasks/export", "viewer", http.StatusOK},
+		{"GET", "/v1/tasks/export", "read-key", http.StatusOK},
+
+		// Administration
+		{"GET", "/v1/users", "user", http.StatusForbidden},
+		{"GET", "/v1/users", "admin", http.StatusOK},
+		{"POST", "/v1/roles", "supervisor", http.StatusForbidden},
+		{"POST", "/v1/roles", "admin", http.StatusOK},
+		{"GET", "/v1/audit", "supervisor", http.StatusForbidden},
+		{"POST", "/v1/trash/purge", "supervisor", http.StatusForbidden},
+		{"POST", "/v1/trash/purge", "admin", http.StatusOK},
+
+		// API keys never reach the auth and key management groups
+		{"GET", "/v1/auth/me", "admin-key", http.StatusForbidden},
+		{"GET", "/v1/api-keys", "admin-key", http.StatusForbidden},
+		{"GET", "/v1/auth/me", "admin", http.StatusOK},
+
+		// includeDeleted needs deleted:read even on public routes
+		{"GET", "/v1/teams?includeDeleted=true", "anonymous", http.StatusUnauthorized},
+		{"GET", "/v1/teams?includeDeleted=true", "viewer", http.StatusForbidden},
+		{"GET", "/v1/teams?includeDeleted=true", "admin", http.StatusOK},
+		{"GET", "/v1/teams?includeDeleted=true", "admin-key", http.StatusOK},
+		{"GET", "/v1/teams?includeDeleted=false", "anonymous", http.StatusOK},
+
+		// Routes missing from routePolicies: public GET, admin-only otherwise
+		{"GET", unlistedPath, "anonymous", http.StatusOK},
+		{"POST", unlistedPath, "anonymous", http.StatusUnauthorized},
+		{"POST", unlistedPath, "supervisor", http.StatusForbidden},
+		{"POST", unlistedPath, "admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := f.do(t, f.callers[tt.caller], tt.method, tt.target); got != tt.want {
+			t.Errorf("%s %s as %s: got %d, want %d", tt.method, tt.target, tt.caller, got, tt.want)
+		}
+	}
+}
+
+func TestRevokedSessionIsRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	jwtManager := jwt.NewJWTManager("test-secret", time.Hour, 24*time.Hour)
+	sessions := session.NewStore(db)
+	authMw := middleware.NewAuthMiddleware(jwtManager, sessions, apikey.NewStore(db), permission.NewResolver(db, time.Minute))
+
+	engine := gin.New()
+	engine.Group("/v1").Use(authMw.EnforcePolicy(routePolicies)).GET("/auth/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	sid := uuid.New().String()
+	pair, err := jwtManager.GenerateTokenPair(1, "admin", permission.RoleAdmin, nil, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.Issue(context.Background(), &models.RefreshToken{
+		ID: pair.RefreshTokenID, SessionID: sid, UserID: 1, IssuedAt: time.Now(), ExpiresAt: pair.RefreshExpiresAt,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+	if got := get(); got != http.StatusOK {
+		t.Fatalf("active session: got %d, want 200", got)
+	}
+	if err := sessions.RevokeSession(context.Background(), sid); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != http.StatusUnauthorized {
+		t.Fatalf("revoked session: got %d, want 401", got)
+	}
+}
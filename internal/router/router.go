@@ -1,9 +1,13 @@
 package router
 
 import (
+	"backend-hotlines3/internal/apikey"
 	"backend-hotlines3/internal/config"
 	v1 "backend-hotlines3/internal/handlers/v1"
+	"backend-hotlines3/internal/lockout"
 	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/session"
 	"backend-hotlines3/pkg/jwt"
 	"log"
 
@@ -11,12 +15,15 @@ import (
 	"gorm.io/gorm"
 )
 
-func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *gin.Engine {
+func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager, loginGuard *lockout.Guard) *gin.Engine {
 	r := gin.Default()
 
 	// CORS middleware
 	r.Use(CORSMiddleware(cfg))
 
+	// Request ID — echoed in the response and stored with audit log entries
+	r.Use(middleware.RequestID())
+
 	// Health check — cache 1 minute
 	r.GET("/health", middleware.CachePublic(60), func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -25,23 +32,42 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 		})
 	})
 
+	// JWKS — public keys for verifying our tokens elsewhere; cache 5 minutes so rotations propagate
+	r.GET("/.well-known/jwks.json", middleware.CachePublic(300), func(c *gin.Context) {
+		c.JSON(200, jwtManager.JWKS())
+	})
+
 	// ============================================
 	// API v1 Routes (New Standard Format)
 	// ============================================
 	apiV1 := r.Group("/v1")
 	{
-		// Auth middleware
-		authMw := middleware.NewAuthMiddleware(jwtManager)
+		// Auth middleware — every /v1 route is checked against routePolicies (see policy.go)
+		sessions := session.NewStore(db)
+		apiKeys := apikey.NewStore(db)
+		permissions := permission.NewResolver(db, permission.DefaultCacheTTL)
+		authMw := middleware.NewAuthMiddleware(jwtManager, sessions, apiKeys, permissions)
+		apiV1.Use(authMw.EnforcePolicy(routePolicies))
 
 		// Auth Routes — no CDN cache (mutations + user-specific)
-		authHandler := v1.NewAuthHandler(db, jwtManager)
+		authHandler := v1.NewAuthHandler(db, jwtManager, sessions, loginGuard, cfg.Security.TwoFactor)
 		authGroup := apiV1.Group("/auth")
 		{
 			authGroup.POST("/login", authHandler.Login)
 			authGroup.POST("/register", authHandler.Register)
 			authGroup.POST("/refresh", authHandler.RefreshToken)
-			authGroup.POST("/logout", authMw.RequireAuth(), authHandler.Logout)
-			authGroup.GET("/me", authMw.RequireAuth(), middleware.CachePrivate(), authHandler.Me)
+			authGroup.POST("/logout", authHandler.Logout)
+			authGroup.GET("/me", middleware.CachePrivate(), authHandler.Me)
+			authGroup.GET("/sessions", middleware.CachePrivate(), authHandler.ListSessions)
+			authGroup.DELETE("/sessions/:id", authHandler.RevokeSession)
+
+			// Two-factor authentication (setup/verify are authorised by the login challenge token)
+			authGroup.POST("/2fa/setup", authHandler.SetupTwoFactor)
+			authGroup.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+			authGroup.POST("/2fa/enroll", authHandler.EnrollTwoFactor)
+			authGroup.POST("/2fa/enable", authHandler.EnableTwoFactor)
+			authGroup.POST("/2fa/disable", authHandler.DisableTwoFactor)
+			authGroup.POST("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
 		}
 
 		// Teams — cache 2 minutes (has task counts that update with new tasks)
@@ -51,8 +77,10 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			teamsV1.GET("", middleware.CachePublic(120), handler.List)
 			teamsV1.GET("/:id", middleware.CachePublic(120), handler.GetByID)
 			teamsV1.POST("", handler.Create)
+			teamsV1.POST("/merge", handler.Merge)
 			teamsV1.PUT("/:id", handler.Update)
 			teamsV1.DELETE("/:id", handler.Delete)
+			teamsV1.POST("/:id/restore", handler.Restore)
 		}
 
 		// Job Types — cache 5 minutes (admin-only edits, changes infrequently)
@@ -64,6 +92,7 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			jobTypesV1.POST("", handler.Create)
 			jobTypesV1.PUT("/:id", handler.Update)
 			jobTypesV1.DELETE("/:id", handler.Delete)
+			jobTypesV1.POST("/:id/restore", handler.Restore)
 		}
 
 		// Job Details — cache 5 minutes (admin-only edits, changes infrequently)
@@ -73,6 +102,7 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			jobDetailsV1.GET("", middleware.CachePublic(300), handler.List)
 			jobDetailsV1.GET("/:id", middleware.CachePublic(300), handler.GetByID)
 			jobDetailsV1.POST("", handler.Create)
+			jobDetailsV1.POST("/merge", handler.Merge)
 			jobDetailsV1.PUT("/:id", handler.Update)
 			jobDetailsV1.DELETE("/:id", handler.Delete)
 			jobDetailsV1.POST("/:id/restore", handler.Restore)
@@ -83,10 +113,13 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 		{
 			handler := v1.NewFeederHandler(db)
 			feedersV1.GET("", middleware.CachePublic(120), handler.List)
+			feedersV1.GET("/suggest", middleware.CachePublic(60), handler.Suggest)
 			feedersV1.GET("/:id", middleware.CachePublic(120), handler.GetByID)
 			feedersV1.POST("", handler.Create)
+			feedersV1.POST("/merge", handler.Merge)
 			feedersV1.PUT("/:id", handler.Update)
 			feedersV1.DELETE("/:id", handler.Delete)
+			feedersV1.POST("/:id/restore", handler.Restore)
 		}
 
 		// Stations — cache 10 minutes (static reference data)
@@ -96,8 +129,10 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			stationsV1.GET("", middleware.CachePublic(600), handler.List)
 			stationsV1.GET("/:id", middleware.CachePublic(600), handler.GetByID)
 			stationsV1.POST("", handler.Create)
+			stationsV1.POST("/merge", handler.Merge)
 			stationsV1.PUT("/:id", handler.Update)
 			stationsV1.DELETE("/:id", handler.Delete)
+			stationsV1.POST("/:id/restore", handler.Restore)
 		}
 
 		// PEAs — cache 10 minutes (static reference data)
@@ -110,6 +145,7 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			peasV1.POST("/bulk", handler.BulkCreate)
 			peasV1.PUT("/:id", handler.Update)
 			peasV1.DELETE("/:id", handler.Delete)
+			peasV1.POST("/:id/restore", handler.Restore)
 		}
 
 		// Operation Centers — cache 10 minutes (static reference data, rarely changes)
@@ -121,6 +157,15 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			operationCentersV1.POST("", handler.Create)
 			operationCentersV1.PUT("/:id", handler.Update)
 			operationCentersV1.DELETE("/:id", handler.Delete)
+			operationCentersV1.POST("/:id/restore", handler.Restore)
+		}
+
+		// Topology — bulk upsert of operation centers, PEAs, stations and feeders
+		topologyV1 := apiV1.Group("/topology")
+		{
+			handler := v1.NewTopologyHandler(db)
+			topologyV1.POST("/import", handler.Import)
+			topologyV1.POST("/geometry", handler.ImportGeometry)
 		}
 
 		// Tasks
@@ -132,12 +177,24 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			tasksV1.GET("/by-filter", middleware.CachePublic(180), handler.ListByFilter) // cache 3 min (per year/month combo)
 			tasksV1.GET("/:id", middleware.CachePublic(60), handler.GetByID)    // cache 1 min
 			tasksV1.POST("", handler.Create)
+			tasksV1.POST("/import", handler.Import)
+
+			exportHandler := v1.NewTaskExportHandler(cfg, db)
+			tasksV1.GET("/export", exportHandler.Export)
+			tasksV1.GET("/geojson", exportHandler.GeoJSON)
+			tasksV1.GET("/kml", exportHandler.KML)
 			tasksV1.PUT("/:id", handler.Update)
 			tasksV1.DELETE("/:id", handler.Delete)
+			tasksV1.GET("/:id/history", middleware.CachePrivate(), handler.History)
+			tasksV1.POST("/:id/revert", handler.Revert)
+			tasksV1.POST("/:id/restore", handler.Restore)
+			tasksV1.POST("/:id/submit", handler.Submit)
+			tasksV1.POST("/:id/approve", handler.Approve)
+			tasksV1.POST("/:id/reject", handler.Reject)
 		}
 
 		// Upload — no cache (presigned URLs are unique per request)
-		uploadHandler, err := v1.NewUploadHandler(cfg)
+		uploadHandler, err := v1.NewUploadHandler(cfg, db)
 		if err != nil {
 			log.Printf("Warning: Upload handler initialization failed: %v", err)
 		} else {
@@ -157,30 +214,63 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, jwtManager *jwt.JWTManager) *g
 			dashboardV1.GET("/top-feeders", middleware.CachePublic(300), handler.TopFeeders)
 			dashboardV1.GET("/feeder-matrix", middleware.CachePublic(300), handler.FeederMatrix)
 			dashboardV1.GET("/stats", middleware.CachePublic(300), handler.Stats)
+			dashboardV1.GET("/heatmap", middleware.CachePublic(300), handler.Heatmap)
+			dashboardV1.GET("/clusters", middleware.CachePublic(300), handler.Clusters)
 		}
 
 		// Users — no cache (admin-only + user-specific context)
 		usersV1 := apiV1.Group("/users")
 		{
-			handler := v1.NewUserHandler(db)
-
-			// Apply authentication middleware to all user routes
-			usersV1.Use(authMw.RequireAuth())
-
-			// Admin only routes
-			adminUsers := usersV1.Group("")
-			adminUsers.Use(authMw.RequireRole("admin"))
-			{
-				adminUsers.GET("", middleware.CachePrivate(), handler.List)
-				adminUsers.GET("/:id", middleware.CachePrivate(), handler.GetByID)
-				adminUsers.POST("", handler.Create)
-				adminUsers.PUT("/:id", handler.Update)
-				adminUsers.DELETE("/:id", handler.Delete)
-			}
+			handler := v1.NewUserHandler(db, sessions, loginGuard)
+			usersV1.GET("", middleware.CachePrivate(), handler.List)
+			usersV1.GET("/:id", middleware.CachePrivate(), handler.GetByID)
+			usersV1.POST("", handler.Create)
+			usersV1.PUT("/:id", handler.Update)
+			usersV1.DELETE("/:id", handler.Delete)
+			usersV1.POST("/:id/restore", handler.Restore)
+			usersV1.POST("/:id/revoke-sessions", handler.RevokeSessions)
+			usersV1.POST("/:id/unlock", handler.Unlock)
+			usersV1.GET("/:id/login-failures", middleware.CachePrivate(), handler.LoginFailures)
 
 			// User can change their own password (authenticated, but not necessarily admin)
 			usersV1.PUT("/:id/password", handler.ChangePassword)
 		}
+
+		// API keys for machine clients — no cache (admin-only)
+		apiKeysV1 := apiV1.Group("/api-keys")
+		{
+			handler := v1.NewAPIKeyHandler(db, apiKeys)
+			apiKeysV1.GET("", middleware.CachePrivate(), handler.List)
+			apiKeysV1.POST("", handler.Create)
+			apiKeysV1.DELETE("/:id", handler.Revoke)
+		}
+
+		// Roles and permissions — no cache (admin-only)
+		roleHandler := v1.NewRoleHandler(db, permissions)
+		apiV1.GET("/permissions", middleware.CachePrivate(), roleHandler.ListPermissions)
+		rolesV1 := apiV1.Group("/roles")
+		{
+			rolesV1.GET("", middleware.CachePrivate(), roleHandler.List)
+			rolesV1.GET("/:id", middleware.CachePrivate(), roleHandler.GetByID)
+			rolesV1.POST("", roleHandler.Create)
+			rolesV1.PUT("/:id", roleHandler.Update)
+			rolesV1.DELETE("/:id", roleHandler.Delete)
+		}
+
+		// Audit log — no cache (admin-only)
+		auditV1 := apiV1.Group("/audit")
+		{
+			handler := v1.NewAuditHandler(db)
+			auditV1.GET("", middleware.CachePrivate(), handler.List)
+		}
+
+		// Trash bin of soft-deleted records — no cache (admin-only)
+		trashV1 := apiV1.Group("/trash")
+		{
+			handler := v1.NewTrashHandler(cfg, db)
+			trashV1.GET("", middleware.CachePrivate(), handler.List)
+			trashV1.POST("/purge", handler.Purge)
+		}
 	}
 
 	return r
@@ -190,8 +280,9 @@ func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, X-Request-ID, If-Match, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
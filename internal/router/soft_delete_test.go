@@ -0,0 +1,235 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/lockout"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/permission"
+	"backend-hotlines3/internal/session"
+	"backend-hotlines3/internal/testutil"
+	"backend-hotlines3/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// softDeleteFixture is the real router over a database holding one live and one
+// soft-deleted row of each kind.
+type softDeleteFixture struct {
+	engine *gin.Engine
+	live   map[string]int64
+	gone   map[string]int64
+	tokens map[string]string
+}
+
+func newSoftDeleteFixture(t *testing.T) *softDeleteFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	db := testutil.NewDB(t)
+	jwtManager := jwt.NewJWTManager("test-secret", time.Hour, 24*time.Hour)
+	guard := lockout.NewGuard(lockout.NewMemoryStore(), lockout.DefaultConfig())
+	f := &softDeleteFixture{
+		engine: SetupRouter(testConfig(), db, jwtManager, guard),
+		live:   map[string]int64{},
+		gone:   map[string]int64{},
+		tokens: map[string]string{},
+	}
+
+	create := func(kind string, live, gone interface{}, id func(interface{}) int64) {
+		t.Helper()
+		if err := db.Create(live).Error; err != nil {
+			t.Fatalf("create %s: %v", kind, err)
+		}
+		if err := db.Create(gone).Error; err != nil {
+			t.Fatalf("create %s: %v", kind, err)
+		}
+		if err := db.Delete(gone).Error; err != nil {
+			t.Fatalf("delete %s: %v", kind, err)
+		}
+		f.live[kind], f.gone[kind] = id(live), id(gone)
+	}
+
+	oc := models.OperationCenter{Name: "Center"}
+	if err := db.Create(&oc).Error; err != nil {
+		t.Fatal(err)
+	}
+	create("teams", &models.Team{Name: "Live"}, &models.Team{Name: "Gone"},
+		func(v interface{}) int64 { return v.(*models.Team).ID })
+	create("job-types", &models.JobType{Name: "Live"}, &models.JobType{Name: "Gone"},
+		func(v interface{}) int64 { return v.(*models.JobType).ID })
+	create("stations",
+		&models.Station{Name: "Live", CodeName: "LIVE", OperationID: oc.ID},
+		&models.Station{Name: "Gone", CodeName: "GONE", OperationID: oc.ID},
+		func(v interface{}) int64 { return v.(*models.Station).ID })
+	create("feeders",
+		&models.Feeder{Code: "LIVE01", StationID: f.live["stations"]},
+		&models.Feeder{Code: "GONE01", StationID: f.live["stations"]},
+		func(v interface{}) int64 { return v.(*models.Feeder).ID })
+
+	task := func() *models.TaskDaily {
+		return &models.TaskDaily{
+			WorkDate:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			JobTypeID:   f.live["job-types"],
+			JobDetailID: 1,
+			TeamID:      f.live["teams"],
+			Status:      models.TaskStatusApproved,
+		}
+	}
+	create("tasks", task(), task(), func(v interface{}) int64 { return v.(*models.TaskDaily).ID })
+
+	for _, role := range []string{permission.RoleAdmin, permission.RoleViewer} {
+		sid := uuid.New().String()
+		pair, err := jwtManager.GenerateTokenPair(1, role, role, nil, sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := session.NewStore(db).Issue(ctx, &models.RefreshToken{
+			ID:        pair.RefreshTokenID,
+			SessionID: sid,
+			UserID:    1,
+			IssuedAt:  time.Now(),
+			ExpiresAt: pair.RefreshExpiresAt,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		f.tokens[role] = pair.AccessToken
+	}
+	return f
+}
+
+// get calls target as role ("" for anonymous) and returns the recorder.
+func (f *softDeleteFixture) get(t *testing.T, role, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if role != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[role])
+	}
+	w := httptest.NewRecorder()
+	f.engine.ServeHTTP(w, req)
+	return w
+}
+
+// listIDs returns the ids in a list response.
+func listIDs(t *testing.T, w *httptest.ResponseRecorder) map[int64]bool {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data []struct {
+			ID int64 `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	ids := make(map[int64]bool, len(resp.Data))
+	for _, item := range resp.Data {
+		ids[item.ID] = true
+	}
+	return ids
+}
+
+var softDeleteKinds = []string{"teams", "job-types", "stations", "feeders", "tasks"}
+
+func TestSoftDeletedRowsAreHidden(t *testing.T) {
+	f := newSoftDeleteFixture(t)
+
+	for _, kind := range softDeleteKinds {
+		t.Run(kind, func(t *testing.T) {
+			for _, role := range []string{"", permission.RoleViewer, permission.RoleAdmin} {
+				ids := listIDs(t, f.get(t, role, "/v1/"+kind))
+				if !ids[f.live[kind]] {
+					t.Errorf("%q: live row missing from list", role)
+				}
+				if ids[f.gone[kind]] {
+					t.Errorf("%q: deleted row listed", role)
+				}
+
+				if w := f.get(t, role, fmt.Sprintf("/v1/%s/%d", kind, f.live[kind])); w.Code != http.StatusOK {
+					t.Errorf("%q: live lookup status = %d", role, w.Code)
+				}
+				if w := f.get(t, role, fmt.Sprintf("/v1/%s/%d", kind, f.gone[kind])); w.Code != http.StatusNotFound {
+					t.Errorf("%q: deleted lookup status = %d, want 404", role, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestDashboardSummaryExcludesSoftDeleted(t *testing.T) {
+	f := newSoftDeleteFixture(t)
+
+	// includeDeleted is ignored by aggregates
+	for _, target := range []string{"/v1/dashboard/summary", "/v1/dashboard/summary?includeDeleted=true"} {
+		w := f.get(t, permission.RoleAdmin, target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data struct {
+				TotalTasks    int64 `json:"totalTasks"`
+				TotalJobTypes int64 `json:"totalJobTypes"`
+				TotalFeeders  int64 `json:"totalFeeders"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		d := resp.Data
+		if d.TotalTasks != 1 || d.TotalJobTypes != 1 || d.TotalFeeders != 1 {
+			t.Errorf("%s: totals = %d tasks, %d job types, %d feeders; want 1 each", target, d.TotalTasks, d.TotalJobTypes, d.TotalFeeders)
+		}
+	}
+}
+
+func TestIncludeDeleted(t *testing.T) {
+	f := newSoftDeleteFixture(t)
+
+	for _, kind := range softDeleteKinds {
+		t.Run(kind, func(t *testing.T) {
+			list := "/v1/" + kind
+			lookup := fmt.Sprintf("/v1/%s/%d", kind, f.gone[kind])
+
+			// Without deleted:read the flag is refused, not ignored
+			for _, target := range []string{list, lookup} {
+				if w := f.get(t, "", target+"?includeDeleted=true"); w.Code != http.StatusUnauthorized {
+					t.Errorf("anonymous %s: status = %d, want 401", target, w.Code)
+				}
+				if w := f.get(t, permission.RoleViewer, target+"?includeDeleted=true"); w.Code != http.StatusForbidden {
+					t.Errorf("viewer %s: status = %d, want 403", target, w.Code)
+				}
+			}
+
+			// The plain list is cached publicly; with deleted rows it must not be
+			if w := f.get(t, permission.RoleAdmin, list); w.Header().Get("Cache-Control") == "private, no-store" {
+				t.Errorf("plain list Cache-Control = %q, want public", w.Header().Get("Cache-Control"))
+			}
+			w := f.get(t, permission.RoleAdmin, list+"?includeDeleted=true")
+			if got := w.Header().Get("Cache-Control"); got != "private, no-store" {
+				t.Errorf("list Cache-Control = %q, want private, no-store", got)
+			}
+			ids := listIDs(t, w)
+			if !ids[f.live[kind]] || !ids[f.gone[kind]] {
+				t.Errorf("list with includeDeleted = %v, want live %d and deleted %d", ids, f.live[kind], f.gone[kind])
+			}
+
+			w = f.get(t, permission.RoleAdmin, lookup+"?includeDeleted=true")
+			if w.Code != http.StatusOK {
+				t.Errorf("deleted lookup status = %d, want 200", w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "private, no-store" {
+				t.Errorf("lookup Cache-Control = %q, want private, no-store", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/permission"
+)
+
+// TestAsOfRequiresAuthentication checks that past versions of a task are guarded like
+// its history, although the task itself is public.
+func TestAsOfRequiresAuthentication(t *testing.T) {
+	f := newSoftDeleteFixture(t)
+	lookup := fmt.Sprintf("/v1/tasks/%d", f.live["tasks"])
+	asOf := lookup + "?asOf=" + time.Now().Format(time.DateOnly)
+
+	if w := f.get(t, "", lookup); w.Code != http.StatusOK {
+		t.Errorf("anonymous lookup: status = %d, want 200", w.Code)
+	}
+	for _, target := range []string{asOf, lookup + "/history"} {
+		if w := f.get(t, "", target); w.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous %s: status = %d, want 401", target, w.Code)
+		}
+	}
+
+	w := f.get(t, permission.RoleViewer, asOf)
+	if w.Code != http.StatusOK {
+		t.Fatalf("viewer asOf: status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("asOf Cache-Control = %q, want private, no-store", got)
+	}
+}
@@ -0,0 +1,211 @@
+// Package session persists refresh tokens so they can be rotated and revoked.
+// A session is one login on one device; every refresh token issued for it shares
+// the session ID. Presenting an already-rotated token revokes the whole session.
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound is returned when the presented refresh token was never issued.
+	ErrNotFound = errors.New("refresh token not found")
+	// ErrReused is returned when a revoked refresh token is presented again.
+	// The whole session has been revoked by the time it is returned.
+	ErrReused = errors.New("refresh token reuse detected")
+	// ErrExpired is returned when the stored refresh token has expired.
+	ErrExpired = errors.New("refresh token expired")
+)
+
+// touchInterval limits how often the last use of a busy session is written.
+const touchInterval = time.Minute
+
+type Store struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// WithTx returns a store that works in tx, so a revocation commits or rolls back
+// together with the change that caused it.
+func (s *Store) WithTx(tx *gorm.DB) *Store {
+	return &Store{db: tx, now: s.now}
+}
+
+// Issue stores the first refresh token of a new session.
+func (s *Store) Issue(ctx context.Context, token *models.RefreshToken) error {
+	markSeen(token)
+	if err := s.db.WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return nil
+}
+
+// Rotate revokes the refresh token oldID and stores next as its successor in the same session.
+// If oldID was already revoked, every token in its session is revoked and ErrReused is returned.
+func (s *Store) Rotate(ctx context.Context, oldID string, next *models.RefreshToken) error {
+	reused := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, "id = ?", oldID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		now := s.now()
+		if current.RevokedAt != nil {
+			// Reuse: someone replayed a rotated token. Kill the family but commit.
+			reused = true
+			return revokeWhere(tx, now, models.RefreshTokenCol.SessionID+" = ?", current.SessionID)
+		}
+		if now.After(current.ExpiresAt) {
+			return ErrExpired
+		}
+
+		next.SessionID = current.SessionID
+		next.UserID = current.UserID
+		markSeen(next)
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"revokedAt":    now,
+			"replacedById": next.ID,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrExpired) {
+			return err
+		}
+		return fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if reused {
+		return ErrReused
+	}
+	return nil
+}
+
+// RevokeSession revokes every outstanding refresh token of a session.
+func (s *Store) RevokeSession(ctx context.Context, sessionID string) error {
+	if err := revokeWhere(s.db.WithContext(ctx), s.now(), models.RefreshTokenCol.SessionID+" = ?", sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSession revokes one session of a user. It reports false if the user
+// has no active session with that ID.
+func (s *Store) RevokeUserSession(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where(models.RefreshTokenCol.UserID+" = ?", userID).
+		Where(models.RefreshTokenCol.SessionID+" = ?", sessionID).
+		Where(models.RefreshTokenCol.RevokedAt+" IS NULL").
+		Update("revokedAt", s.now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeUser revokes every session of a user, e.g. when the account is deactivated.
+func (s *Store) RevokeUser(ctx context.Context, userID uint) error {
+	if err := revokeWhere(s.db.WithContext(ctx), s.now(), models.RefreshTokenCol.UserID+" = ?", userID); err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
+
+// ListActive returns the current refresh token of each active session of a user,
+// most recently seen first.
+func (s *Store) ListActive(ctx context.Context, userID uint) ([]models.RefreshToken, error) {
+	var tokens []models.RefreshToken
+	if err := s.db.WithContext(ctx).
+		Where(models.RefreshTokenCol.UserID+" = ?", userID).
+		Scopes(activeTokens(s.now())).
+		Order("COALESCE(" + models.RefreshTokenCol.LastSeenAt + ", " + models.RefreshTokenCol.IssuedAt + ") DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return tokens, nil
+}
+
+// IsActive reports whether a session still has an unrevoked, unexpired refresh token.
+func (s *Store) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where(models.RefreshTokenCol.SessionID+" = ?", sessionID).
+		Scopes(activeTokens(s.now())).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Touch reports whether a session is active, as IsActive, and records the time, IP and
+// user agent of this use on it. Access tokens of revoked sessions are rejected by the
+// auth middleware through this check. The use is written at most once per
+// touchInterval, so a busy session is not written on every request.
+func (s *Store) Touch(ctx context.Context, sessionID, ip, userAgent string) (bool, error) {
+	now := s.now()
+	var token models.RefreshToken
+	err := s.db.WithContext(ctx).
+		Where(models.RefreshTokenCol.SessionID+" = ?", sessionID).
+		Scopes(activeTokens(now)).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	if token.LastSeenAt == nil || now.Sub(*token.LastSeenAt) >= touchInterval {
+		if err := s.db.WithContext(ctx).Model(&token).Updates(map[string]interface{}{
+			"lastSeenAt":        now,
+			"lastSeenIp":        ip,
+			"lastSeenUserAgent": userAgent,
+		}).Error; err != nil {
+			return false, fmt.Errorf("failed to record session use: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// markSeen records the issue of token as the last use of its session; a refresh is
+// a use by the client that presented it.
+func markSeen(token *models.RefreshToken) {
+	seen := token.IssuedAt
+	token.LastSeenAt = &seen
+	token.LastSeenIP = token.IPAddress
+	token.LastSeenUserAgent = token.UserAgent
+}
+
+func activeTokens(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where(models.RefreshTokenCol.RevokedAt+" IS NULL").
+			Where(models.RefreshTokenCol.ExpiresAt+" > ?", now)
+	}
+}
+
+func revokeWhere(db *gorm.DB, now time.Time, query string, args ...interface{}) error {
+	return db.Model(&models.RefreshToken{}).
+		Where(query, args...).
+		Where(models.RefreshTokenCol.RevokedAt+" IS NULL").
+		Update("revokedAt", now).Error
+}
@@ -0,0 +1,209 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+)
+
+// newTestStore returns a store whose clock reads *now.
+func newTestStore(t *testing.T, now *time.Time) *Store {
+	t.Helper()
+	s := NewStore(testutil.NewDB(t))
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func token(id, sessionID string, userID uint, issued time.Time) *models.RefreshToken {
+	return &models.RefreshToken{
+		ID:        id,
+		SessionID: sessionID,
+		UserID:    userID,
+		UserAgent: "agent-" + id,
+		IPAddress: "10.0.0.1",
+		IssuedAt:  issued,
+		ExpiresAt: issued.Add(24 * time.Hour),
+	}
+}
+
+func mustActive(t *testing.T, s *Store, sessionID string, want bool) {
+	t.Helper()
+	active, err := s.IsActive(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != want {
+		t.Errorf("session %s active = %v, want %v", sessionID, active, want)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := newTestStore(t, &now)
+
+	if err := s.Issue(ctx, token("t1", "s1", 1, now)); err != nil {
+		t.Fatal(err)
+	}
+	next := token("t2", "", 0, now)
+	if err := s.Rotate(ctx, "t1", next); err != nil {
+		t.Fatal(err)
+	}
+	if next.SessionID != "s1" || next.UserID != 1 {
+		t.Errorf("successor in session %q of user %d, want s1 of user 1", next.SessionID, next.UserID)
+	}
+
+	var old models.RefreshToken
+	if err := s.db.First(&old, "id = ?", "t1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if old.RevokedAt == nil || old.ReplacedByID == nil || *old.ReplacedByID != "t2" {
+		t.Errorf("rotated token = %+v, want revoked and replaced by t2", old)
+	}
+	mustActive(t, s, "s1", true)
+
+	if err := s.Rotate(ctx, "missing", token("t3", "", 0, now)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotate unknown token: err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestRotateReuse checks that presenting a rotated token again revokes its whole
+// session, including the successor the legitimate client holds.
+func TestRotateReuse(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := newTestStore(t, &now)
+
+	for _, tk := range []*models.RefreshToken{token("t1", "s1", 1, now), token("o1", "s2", 1, now)} {
+		if err := s.Issue(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Rotate(ctx, "t1", token("t2", "", 0, now)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Rotate(ctx, "t1", token("t3", "", 0, now)); !errors.Is(err, ErrReused) {
+		t.Fatalf("replay: err = %v, want ErrReused", err)
+	}
+	mustActive(t, s, "s1", false)
+	mustActive(t, s, "s2", true)
+
+	// The replayed successor was never stored and the live one is now rejected too
+	var count int64
+	s.db.Model(&models.RefreshToken{}).Where("id = ?", "t3").Count(&count)
+	if count != 0 {
+		t.Error("token issued on replay was stored")
+	}
+	if err := s.Rotate(ctx, "t2", token("t4", "", 0, now)); !errors.Is(err, ErrReused) {
+		t.Errorf("rotate revoked successor: err = %v, want ErrReused", err)
+	}
+}
+
+func TestRotateExpired(t *testing.T) {
+	ctx := context.Background()
+	issued := time.Now().Add(-48 * time.Hour)
+	s := newTestStore(t, &issued)
+
+	if err := s.Issue(ctx, token("t1", "s1", 1, issued)); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	if err := s.Rotate(ctx, "t1", token("t2", "", 0, now)); !errors.Is(err, ErrExpired) {
+		t.Errorf("err = %v, want ErrExpired", err)
+	}
+	mustActive(t, s, "s1", false)
+}
+
+func TestRevokeUserSession(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := newTestStore(t, &now)
+
+	for _, tk := range []*models.RefreshToken{token("a1", "a", 1, now), token("b1", "b", 1, now), token("c1", "c", 2, now)} {
+		if err := s.Issue(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another user's session is not found
+	if found, err := s.RevokeUserSession(ctx, 1, "c"); err != nil || found {
+		t.Errorf("revoke another user's session: found %v (%v), want not found", found, err)
+	}
+	if found, err := s.RevokeUserSession(ctx, 1, "a"); err != nil || !found {
+		t.Fatalf("revoke own session: found %v (%v)", found, err)
+	}
+	if found, err := s.RevokeUserSession(ctx, 1, "a"); err != nil || found {
+		t.Errorf("revoke twice: found %v (%v), want not found", found, err)
+	}
+	mustActive(t, s, "a", false)
+	mustActive(t, s, "b", true)
+	mustActive(t, s, "c", true)
+
+	if err := s.RevokeUser(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	mustActive(t, s, "b", false)
+	mustActive(t, s, "c", true)
+}
+
+func TestTouch(t *testing.T) {
+	ctx := context.Background()
+	issued := time.Now().Truncate(time.Second)
+	now := issued
+	s := newTestStore(t, &now)
+
+	if err := s.Issue(ctx, token("t1", "s1", 1, issued)); err != nil {
+		t.Fatal(err)
+	}
+	seen := func() models.RefreshToken {
+		t.Helper()
+		tokens, err := s.ListActive(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != 1 {
+			t.Fatalf("%d active sessions, want 1", len(tokens))
+		}
+		return tokens[0]
+	}
+
+	// A new session was last seen where it logged in
+	if tk := seen(); !tk.LastSeenAt.Equal(issued) || tk.LastSeenIP != "10.0.0.1" || tk.LastSeenUserAgent != "agent-t1" {
+		t.Errorf("after issue: seen %v from %s (%s)", tk.LastSeenAt, tk.LastSeenIP, tk.LastSeenUserAgent)
+	}
+
+	tests := []struct {
+		advance time.Duration
+		ip      string
+		want    time.Duration // since issue
+		wantIP  string
+	}{
+		{touchInterval - time.Second, "10.0.0.2", 0, "10.0.0.1"},               // throttled
+		{time.Second, "10.0.0.2", touchInterval, "10.0.0.2"},                   // recorded
+		{time.Second, "10.0.0.3", touchInterval, "10.0.0.2"},                   // throttled again
+		{touchInterval, "10.0.0.3", 2*touchInterval + time.Second, "10.0.0.3"}, // recorded
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.advance)
+		active, err := s.Touch(ctx, "s1", tt.ip, "browser")
+		if err != nil || !active {
+			t.Fatalf("touch %d: active %v (%v)", i+1, active, err)
+		}
+		if tk := seen(); !tk.LastSeenAt.Equal(issued.Add(tt.want)) || tk.LastSeenIP != tt.wantIP {
+			t.Errorf("touch %d: seen %v from %s, want %v from %s", i+1, tk.LastSeenAt, tk.LastSeenIP, issued.Add(tt.want), tt.wantIP)
+		}
+	}
+
+	if err := s.RevokeSession(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if active, err := s.Touch(ctx, "s1", "10.0.0.1", "browser"); err != nil || active {
+		t.Errorf("touch revoked session: active %v (%v), want inactive", active, err)
+	}
+}
@@ -0,0 +1,61 @@
+// Package testutil provides the database used by tests: an in-memory SQLite database
+// with the production schema, so handlers and stores run without a PostgreSQL server.
+// Queries that rely on PostgreSQL-only functions still need a real database.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"backend-hotlines3/internal/database"
+	"backend-hotlines3/internal/permission"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var dbCount atomic.Int64
+
+// NewDB opens an empty in-memory database with every model migrated and the built-in
+// roles seeded. Each call gets its own database, closed when the test ends.
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared&_pragma=foreign_keys(0)", dbCount.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), database.GormConfig(logger.Silent))
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	// One connection keeps the in-memory database alive and serialises writes
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// The SQLite driver only reads TIMESTAMP columns back as time.Time
+	for _, model := range database.Models() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("parse %T: %v", model, err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if strings.HasPrefix(string(field.DataType), "timestamptz") {
+				field.DataType = "timestamp"
+			}
+		}
+	}
+
+	if err := database.MigrateModels(db); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	if err := permission.Seed(context.Background(), db); err != nil {
+		t.Fatalf("seed roles: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,225 @@
+package topology
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Document is an OperationCenter → PEA / Station → Feeder hierarchy to import.
+// Names that are left empty keep the stored value of an existing row.
+type Document struct {
+	OperationCenters []OperationCenter `json:"operationCenters"`
+}
+
+type OperationCenter struct {
+	Name     string    `json:"name"`
+	PEAs     []PEA     `json:"peas,omitempty"`
+	Stations []Station `json:"stations,omitempty"`
+}
+
+type PEA struct {
+	Shortname string `json:"shortname"`
+	Fullname  string `json:"fullname,omitempty"`
+}
+
+type Station struct {
+	CodeName string   `json:"codeName"`
+	Name     string   `json:"name,omitempty"`
+	Feeders  []Feeder `json:"feeders,omitempty"`
+}
+
+type Feeder struct {
+	Code string `json:"code"`
+}
+
+// Problem is an error in the input, located by a JSON path or a CSV line.
+type Problem struct {
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// ProblemsError is returned when the input is malformed; nothing was written.
+type ProblemsError struct {
+	Problems []Problem
+}
+
+func (e *ProblemsError) Error() string {
+	return fmt.Sprintf("topology input has %d problems", len(e.Problems))
+}
+
+// ParseJSON reads a Document in its JSON form.
+func ParseJSON(r io.Reader) (*Document, error) {
+	var doc Document
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, Validate(&doc)
+}
+
+// CSV columns, one entity per row. Every row names its operation center; a PEA row
+// adds peaShortname/peaFullname, a station row stationCodeName/stationName, and a
+// feeder row feederCode under stationCodeName.
+var csvColumns = []string{"operationCenter", "peaShortname", "peaFullname", "stationCodeName", "stationName", "feederCode"}
+
+// ParseCSV reads a Document from CSV with a header row naming csvColumns.
+func ParseCSV(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, &ProblemsError{Problems: []Problem{{Location: "line 1", Message: "missing header row"}}}
+	}
+
+	index := make(map[string]int)
+	for i, name := range rows[0] {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["operationcenter"]; !ok {
+		return nil, &ProblemsError{Problems: []Problem{{Location: "line 1", Message: "missing column operationCenter"}}}
+	}
+
+	var problems []Problem
+	doc := &Document{}
+	type stationRef struct {
+		center string
+		index  int
+	}
+	centers := make(map[string]*OperationCenter)
+	stations := make(map[string]stationRef)
+	var order []string
+
+	for n, row := range rows[1:] {
+		line := fmt.Sprintf("line %d", n+2)
+		cell := func(col string) string {
+			i, ok := index[strings.ToLower(col)]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		ocName := cell("operationCenter")
+		if ocName == "" {
+			problems = append(problems, Problem{Location: line, Message: "operationCenter is required"})
+			continue
+		}
+		oc, ok := centers[ocName]
+		if !ok {
+			oc = &OperationCenter{Name: ocName}
+			centers[ocName] = oc
+			order = append(order, ocName)
+		}
+
+		if shortname := cell("peaShortname"); shortname != "" {
+			oc.PEAs = append(oc.PEAs, PEA{Shortname: shortname, Fullname: cell("peaFullname")})
+		}
+
+		codeName := cell("stationCodeName")
+		if codeName == "" {
+			if cell("stationName") != "" || cell("feederCode") != "" {
+				problems = append(problems, Problem{Location: line, Message: "stationCodeName is required for station and feeder rows"})
+			}
+			continue
+		}
+		ref, ok := stations[codeName]
+		if !ok {
+			ref = stationRef{center: ocName, index: len(oc.Stations)}
+			oc.Stations = append(oc.Stations, Station{CodeName: codeName})
+			stations[codeName] = ref
+		} else if ref.center != ocName {
+			problems = append(problems, Problem{Location: line, Message: fmt.Sprintf("station %q is listed under operation center %q", codeName, ref.center)})
+			continue
+		}
+		station := &oc.Stations[ref.index]
+		if name := cell("stationName"); name != "" {
+			station.Name = name
+		}
+		if code := cell("feederCode"); code != "" {
+			station.Feeders = append(station.Feeders, Feeder{Code: code})
+		}
+	}
+
+	for _, name := range order {
+		doc.OperationCenters = append(doc.OperationCenters, *centers[name])
+	}
+	if len(problems) > 0 {
+		return nil, &ProblemsError{Problems: problems}
+	}
+	return doc, Validate(doc)
+}
+
+// Validate checks that keys are present and that no PEA, station or feeder appears
+// twice in the document.
+func Validate(doc *Document) error {
+	var problems []Problem
+	add := func(location, message string) {
+		problems = append(problems, Problem{Location: location, Message: message})
+	}
+
+	centers := make(map[string]bool)
+	peas := make(map[string]bool)
+	stations := make(map[string]bool)
+	feeders := make(map[string]bool)
+
+	for i, oc := range doc.OperationCenters {
+		ocPath := fmt.Sprintf("operationCenters[%d]", i)
+		if strings.TrimSpace(oc.Name) == "" {
+			add(ocPath+".name", "required")
+		} else if centers[oc.Name] {
+			add(ocPath+".name", fmt.Sprintf("operation center %q listed twice", oc.Name))
+		}
+		centers[oc.Name] = true
+
+		for j, pea := range oc.PEAs {
+			path := fmt.Sprintf("%s.peas[%d].shortname", ocPath, j)
+			if strings.TrimSpace(pea.Shortname) == "" {
+				add(path, "required")
+			} else if peas[pea.Shortname] {
+				add(path, fmt.Sprintf("PEA %q listed twice", pea.Shortname))
+			}
+			peas[pea.Shortname] = true
+		}
+
+		for j, station := range oc.Stations {
+			stPath := fmt.Sprintf("%s.stations[%d]", ocPath, j)
+			if strings.TrimSpace(station.CodeName) == "" {
+				add(stPath+".codeName", "required")
+			} else if stations[station.CodeName] {
+				add(stPath+".codeName", fmt.Sprintf("station %q listed twice", station.CodeName))
+			}
+			stations[station.CodeName] = true
+
+			for k, feeder := range station.Feeders {
+				path := fmt.Sprintf("%s.feeders[%d].code", stPath, k)
+				if strings.TrimSpace(feeder.Code) == "" {
+					add(path, "required")
+				} else if feeders[feeder.Code] {
+					add(path, fmt.Sprintf("feeder %q listed twice", feeder.Code))
+				}
+				feeders[feeder.Code] = true
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ProblemsError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"backend-hotlines3/internal/geo"
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Geometry is the station locations and feeder routes read from a GeoJSON
+// FeatureCollection.
+type Geometry struct {
+	Stations []StationLocation
+	Feeders  []FeederRoute
+}
+
+// StationLocation places the station with CodeName.
+type StationLocation struct {
+	CodeName string
+	Lat, Lng float64
+}
+
+// FeederRoute is the line of the feeder with Code.
+type FeederRoute struct {
+	Code   string
+	Points []geo.Point
+}
+
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// ParseGeoJSON reads a FeatureCollection in which Point features with a "codeName"
+// property locate stations and LineString features with a "code" property are feeder
+// routes. Coordinates are [lng, lat] and must lie inside Thailand.
+func ParseGeoJSON(r io.Reader) (*Geometry, error) {
+	var fc struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, err
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, &ProblemsError{Problems: []Problem{{Location: "type", Message: "must be FeatureCollection"}}}
+	}
+
+	var problems []Problem
+	add := func(location, message string) {
+		problems = append(problems, Problem{Location: location, Message: message})
+	}
+	g := &Geometry{}
+	stations := make(map[string]bool)
+	feeders := make(map[string]bool)
+
+	for i, f := range fc.Features {
+		path := fmt.Sprintf("features[%d]", i)
+		if f.Geometry == nil {
+			add(path+".geometry", "required")
+			continue
+		}
+
+		switch f.Geometry.Type {
+		case "Point":
+			codeName := stringProperty(f.Properties, "codeName")
+			if codeName == "" {
+				add(path+".properties.codeName", "required for a station Point")
+				continue
+			}
+			if stations[codeName] {
+				add(path+".properties.codeName", fmt.Sprintf("station %q listed twice", codeName))
+				continue
+			}
+			stations[codeName] = true
+
+			var c []float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &c); err != nil || len(c) < 2 {
+				add(path+".geometry.coordinates", "must be [lng, lat]")
+				continue
+			}
+			if !geo.Thailand.Contains(c[1], c[0]) {
+				add(path+".geometry.coordinates", "must be inside Thailand")
+				continue
+			}
+			g.Stations = append(g.Stations, StationLocation{CodeName: codeName, Lat: c[1], Lng: c[0]})
+
+		case "LineString":
+			code := stringProperty(f.Properties, "code")
+			if code == "" {
+				add(path+".properties.code", "required for a feeder LineString")
+				continue
+			}
+			if feeders[code] {
+				add(path+".properties.code", fmt.Sprintf("feeder %q listed twice", code))
+				continue
+			}
+			feeders[code] = true
+
+			var cs [][]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &cs); err != nil || len(cs) < 2 {
+				add(path+".geometry.coordinates", "must be at least two [lng, lat] positions")
+				continue
+			}
+			route := FeederRoute{Code: code, Points: make([]geo.Point, 0, len(cs))}
+			for j, c := range cs {
+				if len(c) < 2 || !geo.Thailand.Contains(c[1], c[0]) {
+					add(fmt.Sprintf("%s.geometry.coordinates[%d]", path, j), "must be [lng, lat] inside Thailand")
+					break
+				}
+				route.Points = append(route.Points, geo.Point{Lat: c[1], Lng: c[0]})
+			}
+			if len(route.Points) == len(cs) {
+				g.Feeders = append(g.Feeders, route)
+			}
+
+		default:
+			add(path+".geometry.type", fmt.Sprintf("%s is not supported, use Point for stations and LineString for feeders", f.Geometry.Type))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ProblemsError{Problems: problems}
+	}
+	return g, nil
+}
+
+// stringProperty returns a string or number property as text.
+func stringProperty(properties map[string]interface{}, key string) string {
+	switch v := properties[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// ApplyGeometry sets the location of each station and the route of each feeder in g.
+// Stations and feeders are matched by codeName and code and must already exist; an
+// unknown key is reported as a *ProblemsError and nothing is written.
+func ApplyGeometry(ctx context.Context, db *gorm.DB, g *Geometry, dryRun bool) (*Result, error) {
+	return run(ctx, db, dryRun, func(a *applier) error {
+		for i := range g.Stations {
+			if err := a.stationLocation(fmt.Sprintf("stations[%d]", i), &g.Stations[i]); err != nil {
+				return err
+			}
+		}
+		for i := range g.Feeders {
+			if err := a.feederRoute(fmt.Sprintf("feeders[%d]", i), &g.Feeders[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// roundCoordinate rounds to the six decimals of the coordinate columns, so an
+// unchanged location is not reported as an update.
+func roundCoordinate(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+func (a *applier) stationLocation(path string, in *StationLocation) error {
+	var station models.Station
+	err := a.tx.Where(models.StationCol.CodeName+" = ?", in.CodeName).First(&station).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		a.problems = append(a.problems, Problem{Location: path, Message: fmt.Sprintf("station %q not found", in.CodeName)})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var from [2]interface{}
+	if station.Latitude != nil && station.Longitude != nil {
+		lat, _ := station.Latitude.Float64()
+		lng, _ := station.Longitude.Float64()
+		from = [2]interface{}{lat, lng}
+	}
+	var f fieldSet
+	f.set("latitude", from[0], roundCoordinate(in.Lat))
+	f.set("longitude", from[1], roundCoordinate(in.Lng))
+	return a.save(&station, in.CodeName, false, true, f)
+}
+
+func (a *applier) feederRoute(path string, in *FeederRoute) error {
+	var feeder models.Feeder
+	err := a.tx.Where("code = ?", in.Code).First(&feeder).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		a.problems = append(a.problems, Problem{Location: path, Message: fmt.Sprintf("feeder %q not found", in.Code)})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var from interface{}
+	if feeder.Route != nil {
+		from = *feeder.Route
+	}
+	var f fieldSet
+	f.set("route", from, geo.EncodePolyline(in.Points))
+	return a.save(&feeder, in.Code, false, true, f)
+}
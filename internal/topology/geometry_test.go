@@ -0,0 +1,140 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"backend-hotlines3/internal/geo"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+)
+
+const geometryDocument = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "properties": {"codeName": "CMA"}, "geometry": {"type": "Point", "coordinates": [98.9853, 18.788344]}},
+	{"type": "Feature", "properties": {"code": "CMA01"}, "geometry": {"type": "LineString", "coordinates": [[98.9853, 18.788344], [98.99, 18.8], [98.98, 18.81]]}}
+]}`
+
+func TestParseGeoJSON(t *testing.T) {
+	g, err := ParseGeoJSON(strings.NewReader(geometryDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Stations) != 1 || g.Stations[0] != (StationLocation{CodeName: "CMA", Lat: 18.788344, Lng: 98.9853}) {
+		t.Errorf("stations = %+v", g.Stations)
+	}
+	if len(g.Feeders) != 1 || g.Feeders[0].Code != "CMA01" || len(g.Feeders[0].Points) != 3 || g.Feeders[0].Points[1] != (geo.Point{Lat: 18.8, Lng: 98.99}) {
+		t.Errorf("feeders = %+v", g.Feeders)
+	}
+}
+
+func TestParseGeoJSONProblems(t *testing.T) {
+	_, err := ParseGeoJSON(strings.NewReader(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"codeName": "CMA"}, "geometry": {"type": "Point", "coordinates": [139.69, 35.68]}},
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [98.98, 18.78]}},
+		{"type": "Feature", "properties": {"code": "CMA01"}, "geometry": {"type": "LineString", "coordinates": [[98.98, 18.78]]}},
+		{"type": "Feature", "properties": {"code": "CMA02"}, "geometry": {"type": "LineString", "coordinates": [[98.98, 18.78], [139.69, 35.68]]}},
+		{"type": "Feature", "properties": {"code": "CMA03"}, "geometry": {"type": "Polygon", "coordinates": []}},
+		{"type": "Feature", "properties": {"codeName": "CMA"}}
+	]}`))
+
+	var problems *ProblemsError
+	if !errors.As(err, &problems) {
+		t.Fatalf("err = %v, want *ProblemsError", err)
+	}
+	want := []string{
+		"features[0].geometry.coordinates",
+		"features[1].properties.codeName",
+		"features[2].geometry.coordinates",
+		"features[3].geometry.coordinates[1]",
+		"features[4].geometry.type",
+		"features[5].geometry",
+	}
+	if len(problems.Problems) != len(want) {
+		t.Fatalf("problems = %+v, want %d", problems.Problems, len(want))
+	}
+	for i, p := range problems.Problems {
+		if p.Location != want[i] {
+			t.Errorf("problem %d at %s (%s), want %s", i, p.Location, p.Message, want[i])
+		}
+	}
+
+	if _, err := ParseGeoJSON(strings.NewReader(`{"type": "Feature"}`)); !errors.As(err, &problems) {
+		t.Errorf("not a FeatureCollection: err = %v, want *ProblemsError", err)
+	}
+}
+
+func TestApplyGeometry(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	mustApply(t, db, baseDocument, false)
+
+	g, err := ParseGeoJSON(strings.NewReader(geometryDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ApplyGeometry(ctx, db, g, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := changes(result)
+	if got["Station CMA"] != ActionUpdate || got["Feeder CMA01"] != ActionUpdate || len(got) != 2 {
+		t.Errorf("changes = %v, want station CMA and feeder CMA01 updated", got)
+	}
+
+	var station models.Station
+	if err := db.Where(models.StationCol.CodeName+" = ?", "CMA").First(&station).Error; err != nil {
+		t.Fatal(err)
+	}
+	if station.Latitude == nil || station.Latitude.String() != "18.788344" || station.Longitude.String() != "98.9853" || station.Version != 2 {
+		t.Errorf("station at %v,%v version %d, want 18.788344,98.9853 at version 2", station.Latitude, station.Longitude, station.Version)
+	}
+	var feeder models.Feeder
+	if err := db.Where("code = ?", "CMA01").First(&feeder).Error; err != nil {
+		t.Fatal(err)
+	}
+	if feeder.Route == nil {
+		t.Fatal("feeder route not set")
+	}
+	route, err := geo.DecodePolyline(*feeder.Route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(route) != 3 || route[2] != (geo.Point{Lat: 18.81, Lng: 98.98}) {
+		t.Errorf("route = %v, want the imported line", route)
+	}
+
+	// The same file again is unchanged
+	result, err = ApplyGeometry(ctx, db, g, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 0 {
+		t.Errorf("second import changes = %+v, want none", result.Changes)
+	}
+}
+
+func TestApplyGeometryUnknownKey(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	mustApply(t, db, baseDocument, false)
+
+	g := &Geometry{
+		Stations: []StationLocation{{CodeName: "CMA", Lat: 18.79, Lng: 98.98}},
+		Feeders:  []FeederRoute{{Code: "XXX01", Points: []geo.Point{{Lat: 18.79, Lng: 98.98}, {Lat: 18.8, Lng: 98.99}}}},
+	}
+	_, err := ApplyGeometry(ctx, db, g, false)
+	var problems *ProblemsError
+	if !errors.As(err, &problems) || len(problems.Problems) != 1 || problems.Problems[0].Location != "feeders[0]" {
+		t.Fatalf("err = %v, want the unknown feeder reported", err)
+	}
+
+	var station models.Station
+	if err := db.Where(models.StationCol.CodeName+" = ?", "CMA").First(&station).Error; err != nil {
+		t.Fatal(err)
+	}
+	if station.Latitude != nil {
+		t.Errorf("station located at %v, want the import rolled back", station.Latitude)
+	}
+}
@@ -0,0 +1,310 @@
+// Package topology imports the OperationCenter → PEA / Station → Feeder reference data
+// in bulk. Rows are matched by their natural keys (operation center name, PEA shortname,
+// station codeName and feeder code) and created, updated or restored in one transaction.
+// Station locations and feeder routes are imported separately from GeoJSON.
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Actions reported in Change.Action and Result.Summary.
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionRestore   = "restore"
+	ActionUnchanged = "unchanged"
+)
+
+// Change is one row that the import creates, updates or restores.
+type Change struct {
+	Entity string                  `json:"entity"`
+	Key    string                  `json:"key"`
+	Action string                  `json:"action"`
+	ID     int64                   `json:"id,omitempty"`
+	Fields map[string]audit.Change `json:"fields,omitempty"`
+}
+
+// Result is the diff between the document and the database. On a dry run nothing was
+// written and the IDs of rows that would be created are left out.
+type Result struct {
+	DryRun  bool                      `json:"dryRun"`
+	Changes []Change                  `json:"changes"`
+	Summary map[string]map[string]int `json:"summary"`
+
+	// Audit holds one entry per change. Callers that must not commit the import without
+	// its audit trail pass Apply a transaction and record the entries in it.
+	Audit []audit.Entry `json:"-"`
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("topology dry run")
+
+// Apply upserts doc. Soft-deleted rows that match a key are restored rather than
+// duplicated. A row that cannot be created because a required name is missing is
+// reported as a *ProblemsError and nothing is written.
+func Apply(ctx context.Context, db *gorm.DB, doc *Document, dryRun bool) (*Result, error) {
+	return run(ctx, db, dryRun, func(a *applier) error {
+		for i := range doc.OperationCenters {
+			if err := a.operationCenter(fmt.Sprintf("operationCenters[%d]", i), &doc.OperationCenters[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// run calls fn with an applier in one transaction, which is rolled back on a dry run
+// or when fn recorded problems.
+func run(ctx context.Context, db *gorm.DB, dryRun bool, fn func(a *applier) error) (*Result, error) {
+	a := &applier{result: &Result{
+		DryRun:  dryRun,
+		Changes: []Change{},
+		Summary: make(map[string]map[string]int),
+	}}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		a.tx = tx
+		if err := fn(a); err != nil {
+			return err
+		}
+		if len(a.problems) > 0 {
+			return &ProblemsError{Problems: a.problems}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	if dryRun {
+		a.result.Audit = nil
+		for i := range a.result.Changes {
+			if a.result.Changes[i].Action == ActionCreate {
+				a.result.Changes[i].ID = 0
+			}
+		}
+	}
+	return a.result, nil
+}
+
+type applier struct {
+	tx       *gorm.DB
+	result   *Result
+	problems []Problem
+}
+
+// row is a reference model; every one of them has an int64 ID field.
+type row interface {
+	TableName() string
+}
+
+// fieldSet collects the columns an import changes on an existing row.
+type fieldSet struct {
+	updates map[string]interface{}
+	fields  map[string]audit.Change
+}
+
+// set records column as changed when to differs from from. An empty string leaves the
+// stored value alone.
+func (f *fieldSet) set(column string, from, to interface{}) {
+	if to == "" || from == to {
+		return
+	}
+	if f.updates == nil {
+		f.updates = make(map[string]interface{})
+		f.fields = make(map[string]audit.Change)
+	}
+	f.updates[column] = to
+	f.fields[column] = audit.Change{From: from, To: to}
+}
+
+func (a *applier) count(entity, action string) {
+	if a.result.Summary[entity] == nil {
+		a.result.Summary[entity] = make(map[string]int)
+	}
+	a.result.Summary[entity][action]++
+}
+
+// create inserts r and records the change.
+func (a *applier) create(r row, key string) error {
+	if err := a.tx.Create(r).Error; err != nil {
+		return err
+	}
+	id := reflect.ValueOf(r).Elem().FieldByName("ID").Int()
+	a.result.Changes = append(a.result.Changes, Change{Entity: r.TableName(), Key: key, Action: ActionCreate, ID: id})
+	a.result.Audit = append(a.result.Audit, audit.Entry{
+		Action:     audit.ActionCreate,
+		EntityType: r.TableName(),
+		EntityID:   id,
+		After:      r,
+	})
+	a.count(r.TableName(), ActionCreate)
+	return nil
+}
+
+// save writes f to the stored row before, clearing deletedAt when it is soft-deleted,
+// and records the change. before itself is left untouched for the audit snapshot.
+func (a *applier) save(before row, key string, deleted, versioned bool, f fieldSet) error {
+	entity := before.TableName()
+	id := reflect.ValueOf(before).Elem().FieldByName("ID").Int()
+	if !deleted && len(f.updates) == 0 {
+		a.count(entity, ActionUnchanged)
+		return nil
+	}
+
+	action := ActionUpdate
+	updates := make(map[string]interface{}, len(f.updates)+2)
+	fields := make(map[string]audit.Change, len(f.fields)+1)
+	for column, value := range f.updates {
+		updates[column] = value
+		fields[column] = f.fields[column]
+	}
+	if deleted {
+		action = ActionRestore
+		updates["deletedAt"] = nil
+		fields["deletedAt"] = audit.Change{From: reflect.ValueOf(before).Elem().FieldByName("DeletedAt").Interface(), To: nil}
+	}
+	if versioned {
+		updates["version"] = gorm.Expr("version + 1")
+	}
+
+	after := reflect.New(reflect.TypeOf(before).Elem()).Interface()
+	if err := a.tx.Unscoped().Model(after).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return err
+	}
+	if err := a.tx.Unscoped().First(after, id).Error; err != nil {
+		return err
+	}
+
+	a.result.Changes = append(a.result.Changes, Change{Entity: entity, Key: key, Action: action, ID: id, Fields: fields})
+	auditAction := audit.ActionUpdate
+	if deleted {
+		auditAction = audit.ActionRestore
+	}
+	a.result.Audit = append(a.result.Audit, audit.Entry{
+		Action:     auditAction,
+		EntityType: entity,
+		EntityID:   id,
+		Before:     before,
+		After:      after,
+	})
+	a.count(entity, action)
+	return nil
+}
+
+// find loads the row matching query into dest, preferring a live row over a
+// soft-deleted one. It reports false when no row matches.
+func (a *applier) find(dest row, query string, args ...interface{}) (bool, error) {
+	err := a.tx.Unscoped().Where(query, args...).Order(`"deletedAt" IS NOT NULL, id`).First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (a *applier) operationCenter(path string, in *OperationCenter) error {
+	var oc models.OperationCenter
+	found, err := a.find(&oc, "name = ?", in.Name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		oc = models.OperationCenter{Name: in.Name}
+		err = a.create(&oc, in.Name)
+	} else {
+		err = a.save(&oc, in.Name, oc.DeletedAt.Valid, false, fieldSet{})
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range in.PEAs {
+		if err := a.pea(fmt.Sprintf("%s.peas[%d]", path, i), &in.PEAs[i], oc.ID); err != nil {
+			return err
+		}
+	}
+	for i := range in.Stations {
+		if err := a.station(fmt.Sprintf("%s.stations[%d]", path, i), &in.Stations[i], oc.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *applier) pea(path string, in *PEA, operationID int64) error {
+	var pea models.PEA
+	found, err := a.find(&pea, "shortname = ?", in.Shortname)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if in.Fullname == "" {
+			a.problems = append(a.problems, Problem{Location: path + ".fullname", Message: fmt.Sprintf("required to create PEA %q", in.Shortname)})
+			return nil
+		}
+		return a.create(&models.PEA{Shortname: in.Shortname, Fullname: in.Fullname, OperationID: operationID}, in.Shortname)
+	}
+
+	var f fieldSet
+	f.set("fullname", pea.Fullname, in.Fullname)
+	f.set("operationId", pea.OperationID, operationID)
+	return a.save(&pea, in.Shortname, pea.DeletedAt.Valid, false, f)
+}
+
+func (a *applier) station(path string, in *Station, operationID int64) error {
+	var station models.Station
+	found, err := a.find(&station, models.StationCol.CodeName+" = ?", in.CodeName)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if in.Name == "" {
+			a.problems = append(a.problems, Problem{Location: path + ".name", Message: fmt.Sprintf("required to create station %q", in.CodeName)})
+			return nil
+		}
+		station = models.Station{CodeName: in.CodeName, Name: in.Name, OperationID: operationID, Version: 1}
+		err = a.create(&station, in.CodeName)
+	} else {
+		var f fieldSet
+		f.set("name", station.Name, in.Name)
+		f.set("operationId", station.OperationID, operationID)
+		err = a.save(&station, in.CodeName, station.DeletedAt.Valid, true, f)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range in.Feeders {
+		if err := a.feeder(&in.Feeders[i], station.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *applier) feeder(in *Feeder, stationID int64) error {
+	var feeder models.Feeder
+	found, err := a.find(&feeder, "code = ?", in.Code)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return a.create(&models.Feeder{Code: in.Code, StationID: stationID, Version: 1}, in.Code)
+	}
+
+	var f fieldSet
+	f.set("stationId", feeder.StationID, stationID)
+	return a.save(&feeder, in.Code, feeder.DeletedAt.Valid, true, f)
+}
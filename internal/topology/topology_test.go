@@ -0,0 +1,278 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"backend-hotlines3/internal/audit"
+	"backend-hotlines3/internal/models"
+	"backend-hotlines3/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// baseDocument is one operation center with a PEA and a station with two feeders.
+const baseDocument = `{"operationCenters": [{
+	"name": "North",
+	"peas": [{"shortname": "CM1", "fullname": "Chiang Mai 1"}],
+	"stations": [{"codeName": "CMA", "name": "Chiang Mai A", "feeders": [{"code": "CMA01"}, {"code": "CMA02"}]}]
+}]}`
+
+func parseDocument(t *testing.T, doc string) *Document {
+	t.Helper()
+	d, err := ParseJSON(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("parse document: %v", err)
+	}
+	return d
+}
+
+func mustApply(t *testing.T, db *gorm.DB, doc string, dryRun bool) *Result {
+	t.Helper()
+	result, err := Apply(context.Background(), db, parseDocument(t, doc), dryRun)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	return result
+}
+
+// changes maps "entity key" to the action taken on it.
+func changes(result *Result) map[string]string {
+	m := make(map[string]string, len(result.Changes))
+	for _, c := range result.Changes {
+		m[c.Entity+" "+c.Key] = c.Action
+	}
+	return m
+}
+
+// rowCounts counts the rows of each reference table, soft-deleted ones included.
+func rowCounts(t *testing.T, db *gorm.DB) map[string]int64 {
+	t.Helper()
+	counts := make(map[string]int64)
+	for _, r := range []row{&models.OperationCenter{}, &models.PEA{}, &models.Station{}, &models.Feeder{}} {
+		var n int64
+		if err := db.Unscoped().Model(r).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		counts[r.TableName()] = n
+	}
+	return counts
+}
+
+func TestApplyCreatesThenLeavesUnchanged(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	result := mustApply(t, db, baseDocument, false)
+	want := map[string]int{"OperationCenter": 1, "Pea": 1, "Station": 1, "Feeder": 2}
+	for entity, n := range want {
+		if got := result.Summary[entity][ActionCreate]; got != n {
+			t.Errorf("%s created = %d, want %d", entity, got, n)
+		}
+	}
+	if len(result.Audit) != 5 {
+		t.Errorf("%d audit entries, want one per created row", len(result.Audit))
+	}
+	for _, c := range result.Changes {
+		if c.ID == 0 {
+			t.Errorf("created %s %s has no ID", c.Entity, c.Key)
+		}
+	}
+
+	var feeder models.Feeder
+	if err := db.Preload("Station.OperationCenter").Where("code = ?", "CMA02").First(&feeder).Error; err != nil {
+		t.Fatal(err)
+	}
+	if feeder.Station == nil || feeder.Station.CodeName != "CMA" || feeder.Station.OperationCenter == nil || feeder.Station.OperationCenter.Name != "North" {
+		t.Errorf("feeder CMA02 = %+v, want under station CMA of North", feeder)
+	}
+
+	// Importing the same document again changes nothing
+	result = mustApply(t, db, baseDocument, false)
+	if len(result.Changes) != 0 || len(result.Audit) != 0 {
+		t.Errorf("second import changes = %+v, want none", result.Changes)
+	}
+	for entity, n := range want {
+		if got := result.Summary[entity][ActionUnchanged]; got != n {
+			t.Errorf("%s unchanged = %d, want %d", entity, got, n)
+		}
+	}
+}
+
+func TestApplyUpdatesByKey(t *testing.T) {
+	db := testutil.NewDB(t)
+	mustApply(t, db, baseDocument, false)
+	var before models.Station
+	if err := db.Where(models.StationCol.CodeName+" = ?", "CMA").First(&before).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Renames the PEA and station, and moves feeder CMA02 to a new station. The
+	// station's empty name would keep its stored value.
+	result := mustApply(t, db, `{"operationCenters": [{
+		"name": "North",
+		"peas": [{"shortname": "CM1", "fullname": "Chiang Mai One"}],
+		"stations": [
+			{"codeName": "CMA", "name": "Chiang Mai Alpha", "feeders": [{"code": "CMA01"}]},
+			{"codeName": "CMB", "name": "Chiang Mai B", "feeders": [{"code": "CMA02"}]}
+		]
+	}]}`, false)
+
+	got := changes(result)
+	want := map[string]string{
+		"Pea CM1":      ActionUpdate,
+		"Station CMA":  ActionUpdate,
+		"Station CMB":  ActionCreate,
+		"Feeder CMA02": ActionUpdate,
+	}
+	if len(got) != len(want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	for key, action := range want {
+		if got[key] != action {
+			t.Errorf("%s: action %q, want %q", key, got[key], action)
+		}
+	}
+	for _, c := range result.Changes {
+		if c.Key == "CMA" && (len(c.Fields) != 1 || c.Fields["name"].To != "Chiang Mai Alpha") {
+			t.Errorf("station CMA fields = %v, want only the new name", c.Fields)
+		}
+	}
+
+	var station models.Station
+	if err := db.Where(models.StationCol.CodeName+" = ?", "CMA").First(&station).Error; err != nil {
+		t.Fatal(err)
+	}
+	if station.ID != before.ID || station.Name != "Chiang Mai Alpha" || station.Version != before.Version+1 {
+		t.Errorf("station = %+v, want row %d renamed at version %d", station, before.ID, before.Version+1)
+	}
+	var feeder models.Feeder
+	if err := db.Preload("Station").Where("code = ?", "CMA02").First(&feeder).Error; err != nil {
+		t.Fatal(err)
+	}
+	if feeder.Station == nil || feeder.Station.CodeName != "CMB" || feeder.Version != 2 {
+		t.Errorf("feeder CMA02 under %+v at version %d, want under CMB at version 2", feeder.Station, feeder.Version)
+	}
+	if counts := rowCounts(t, db); counts["Station"] != 2 || counts["Feeder"] != 2 || counts["Pea"] != 1 {
+		t.Errorf("row counts = %v, want rows updated in place", counts)
+	}
+}
+
+func TestApplyRestoresSoftDeleted(t *testing.T) {
+	db := testutil.NewDB(t)
+	mustApply(t, db, baseDocument, false)
+	if err := db.Where("shortname = ?", "CM1").Delete(&models.PEA{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Where("code = ?", "CMA01").Delete(&models.Feeder{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// PEA and feeder keys are enough to restore them; no name is needed
+	result := mustApply(t, db, `{"operationCenters": [{
+		"name": "North",
+		"peas": [{"shortname": "CM1"}],
+		"stations": [{"codeName": "CMA", "feeders": [{"code": "CMA01"}]}]
+	}]}`, false)
+
+	got := changes(result)
+	if got["Pea CM1"] != ActionRestore || got["Feeder CMA01"] != ActionRestore || len(got) != 2 {
+		t.Errorf("changes = %v, want PEA CM1 and feeder CMA01 restored", got)
+	}
+	restores := 0
+	for _, e := range result.Audit {
+		if e.Action == audit.ActionRestore {
+			restores++
+		}
+	}
+	if restores != 2 {
+		t.Errorf("%d restore audit entries, want 2", restores)
+	}
+
+	var pea models.PEA
+	if err := db.Where("shortname = ?", "CM1").First(&pea).Error; err != nil {
+		t.Fatalf("PEA CM1 not restored: %v", err)
+	}
+	if pea.Fullname != "Chiang Mai 1" {
+		t.Errorf("restored fullname = %q, want the stored one kept", pea.Fullname)
+	}
+	if counts := rowCounts(t, db); counts["Pea"] != 1 || counts["Feeder"] != 2 {
+		t.Errorf("row counts = %v, want no duplicates", counts)
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	db := testutil.NewDB(t)
+	mustApply(t, db, baseDocument, false)
+	before := rowCounts(t, db)
+
+	result := mustApply(t, db, `{"operationCenters": [{
+		"name": "North",
+		"stations": [{"codeName": "CMA", "name": "Chiang Mai Alpha", "feeders": [{"code": "CMA03"}]}]
+	}]}`, true)
+
+	if !result.DryRun || result.Audit != nil {
+		t.Errorf("dry run = %v with %d audit entries, want a dry run without audit", result.DryRun, len(result.Audit))
+	}
+	got := changes(result)
+	if got["Station CMA"] != ActionUpdate || got["Feeder CMA03"] != ActionCreate || len(got) != 2 {
+		t.Errorf("changes = %v, want station CMA updated and feeder CMA03 created", got)
+	}
+	for _, c := range result.Changes {
+		if c.Action == ActionCreate && c.ID != 0 {
+			t.Errorf("dry run reports ID %d for %s %s, which was rolled back", c.ID, c.Entity, c.Key)
+		}
+		if c.Action == ActionUpdate && c.ID == 0 {
+			t.Errorf("dry run hides the ID of %s %s", c.Entity, c.Key)
+		}
+	}
+
+	// Nothing was written
+	if after := rowCounts(t, db); after["Feeder"] != before["Feeder"] {
+		t.Errorf("row counts = %v after dry run, want %v", after, before)
+	}
+	var station models.Station
+	if err := db.Where(models.StationCol.CodeName+" = ?", "CMA").First(&station).Error; err != nil {
+		t.Fatal(err)
+	}
+	if station.Name != "Chiang Mai A" || station.Version != 1 {
+		t.Errorf("station = %q at version %d after dry run, want unchanged", station.Name, station.Version)
+	}
+}
+
+// TestApplyProblemsRollBack checks that a row which cannot be created rolls back every
+// other change of the import.
+func TestApplyProblemsRollBack(t *testing.T) {
+	db := testutil.NewDB(t)
+	mustApply(t, db, baseDocument, false)
+	before := rowCounts(t, db)
+
+	_, err := Apply(context.Background(), db, parseDocument(t, `{"operationCenters": [
+		{"name": "North", "stations": [{"codeName": "CMA", "name": "Chiang Mai Alpha", "feeders": [{"code": "CMA03"}]}]},
+		{"name": "South", "peas": [{"shortname": "HY1"}], "stations": [{"codeName": "HYA"}]}
+	]}`), false)
+
+	var problems *ProblemsError
+	if !errors.As(err, &problems) {
+		t.Fatalf("err = %v, want *ProblemsError", err)
+	}
+	locations := map[string]bool{}
+	for _, p := range problems.Problems {
+		locations[p.Location] = true
+	}
+	if len(locations) != 2 || !locations["operationCenters[1].peas[0].fullname"] || !locations["operationCenters[1].stations[0].name"] {
+		t.Errorf("problems = %+v, want the missing PEA fullname and station name", problems.Problems)
+	}
+
+	if after := rowCounts(t, db); after["OperationCenter"] != before["OperationCenter"] || after["Feeder"] != before["Feeder"] {
+		t.Errorf("row counts = %v, want %v", after, before)
+	}
+	var station models.Station
+	if err := db.Where(models.StationCol.CodeName+" = ?", "CMA").First(&station).Error; err != nil {
+		t.Fatal(err)
+	}
+	if station.Name != "Chiang Mai A" {
+		t.Errorf("station renamed to %q, want the update rolled back", station.Name)
+	}
+}
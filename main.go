@@ -12,11 +12,14 @@ import (
 
 	"backend-hotlines3/internal/config"
 	"backend-hotlines3/internal/database"
+	"backend-hotlines3/internal/lockout"
 	"backend-hotlines3/internal/middleware"
+	"backend-hotlines3/internal/permission"
 	"backend-hotlines3/internal/router"
 	"backend-hotlines3/pkg/jwt"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -48,6 +51,11 @@ func main() {
 		log.Println("AutoMigrate completed")
 	}
 
+	// Built-in roles (admin, supervisor, user, viewer) must exist for permission checks
+	if err := permission.Seed(ctx, db); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+
 	// Initialize JWT Manager
 	accessTokenExpiry, err := time.ParseDuration(cfg.JWT.AccessTokenExpiry)
 	if err != nil {
@@ -58,10 +66,19 @@ func main() {
 		log.Fatalf("Failed to parse refresh token expiry: %v", err)
 	}
 
-	jwtManager := jwt.NewJWTManager(cfg.JWT.Secret, accessTokenExpiry, refreshTokenExpiry)
+	jwtManager, err := newJWTManager(cfg.JWT, accessTokenExpiry, refreshTokenExpiry)
+	if err != nil {
+		log.Fatalf("Failed to configure JWT signing keys: %v", err)
+	}
+
+	// Login brute-force protection
+	loginGuard, err := newLoginGuard(ctx, cfg.Security.Login, db)
+	if err != nil {
+		log.Fatalf("Failed to configure login protection: %v", err)
+	}
 
 	// สร้าง router
-	r := router.SetupRouter(cfg, db, jwtManager)
+	r := router.SetupRouter(cfg, db, jwtManager, loginGuard)
 
 	// Global Recovery Middleware (Handle Panics)
 	r.Use(middleware.RecoveryMiddleware())
@@ -101,3 +118,115 @@ func main() {
 
 	log.Println("Server exited")
 }
+
+// newJWTManager signs with cfg.Secret (HS256) unless asymmetric keys are configured.
+// With keys, the secret is kept as a verify-only legacy key so existing tokens stay valid
+// until they expire.
+func newJWTManager(cfg config.JWTConfig, accessTokenExpiry, refreshTokenExpiry time.Duration) (*jwt.JWTManager, error) {
+	if len(cfg.Keys) == 0 {
+		return jwt.NewJWTManager(cfg.Secret, accessTokenExpiry, refreshTokenExpiry), nil
+	}
+
+	// The legacy secret predates every configured key, so it sorts first and keeps
+	// verifying until the first key has been active for the token lifetime.
+	var keys []*jwt.Key
+	if cfg.Secret != "" {
+		legacy, err := jwt.NewHMACKey("", []byte(cfg.Secret), time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, legacy)
+	}
+
+	// Every key needs a fixed active_from: a start time taken from the clock would move
+	// with each restart and differ between replicas, and with it the retirement of the
+	// keys before it
+	activeFrom := make([]time.Time, len(cfg.Keys))
+	for i, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, fmt.Errorf("jwt key without kid")
+		}
+		if kc.ActiveFrom == "" {
+			return nil, fmt.Errorf("jwt key %q: active_from is required", kc.ID)
+		}
+		t, err := time.Parse(time.RFC3339, kc.ActiveFrom)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: invalid active_from: %w", kc.ID, err)
+		}
+		activeFrom[i] = t
+	}
+
+	for i, kc := range cfg.Keys {
+		pemData := []byte(kc.PrivateKey)
+		if kc.PrivateKeyFile != "" {
+			data, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %q: %w", kc.ID, err)
+			}
+			pemData = data
+		}
+
+		key, err := jwt.ParsePrivateKeyPEM(kc.ID, pemData, activeFrom[i])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return jwt.NewJWTManagerWithKeys(keys, accessTokenExpiry, refreshTokenExpiry)
+}
+
+// newLoginGuard builds the login throttle from config, falling back to lockout.DefaultConfig
+// for unset values. The database store is shared by replicas and purged hourly.
+func newLoginGuard(ctx context.Context, cfg config.LoginConfig, db *gorm.DB) (*lockout.Guard, error) {
+	guardCfg := lockout.DefaultConfig()
+	if cfg.MaxUserFailures > 0 {
+		guardCfg.MaxUserFailures = cfg.MaxUserFailures
+	}
+	if cfg.MaxIPFailures > 0 {
+		guardCfg.MaxIPFailures = cfg.MaxIPFailures
+	}
+	durations := []struct {
+		value  string
+		target *time.Duration
+	}{
+		{cfg.BaseDelay, &guardCfg.BaseDelay},
+		{cfg.MaxDelay, &guardCfg.MaxDelay},
+		{cfg.LockoutDuration, &guardCfg.LockoutDuration},
+		{cfg.Window, &guardCfg.Window},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", d.value, err)
+		}
+		*d.target = parsed
+	}
+
+	switch cfg.Store {
+	case "", "memory":
+		return lockout.NewGuard(lockout.NewMemoryStore(), guardCfg), nil
+	case "database":
+		store := lockout.NewDBStore(db)
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := store.Purge(ctx); err != nil {
+						log.Printf("Failed to purge login throttle: %v", err)
+					}
+				}
+			}
+		}()
+		return lockout.NewGuard(store, guardCfg), nil
+	default:
+		return nil, fmt.Errorf("unknown login store %q (use memory or database)", cfg.Store)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"backend-hotlines3/internal/config"
+	"backend-hotlines3/pkg/jwt"
+)
+
+func testKeyPEM(t *testing.T) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// TestNewJWTManagerKeepsLegacySecret switches a deployment from the HS256 secret to a
+// key: tokens issued by the previous process keep verifying until the retention window
+// after the key's active_from, and are rejected after it.
+func TestNewJWTManagerKeepsLegacySecret(t *testing.T) {
+	const secret = "legacy-secret"
+	accessExpiry, refreshExpiry := time.Hour, 24*time.Hour
+
+	previous := jwt.NewJWTManager(secret, accessExpiry, refreshExpiry)
+	pair, err := previous.GenerateTokenPair(1, "alice", "user", nil, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotation := time.Now().Add(-time.Minute).Truncate(time.Second)
+	j, err := newJWTManager(config.JWTConfig{
+		Secret: secret,
+		Keys:   []config.JWTKeyConfig{{ID: "2026-01", PrivateKey: testKeyPEM(t), ActiveFrom: rotation.Format(time.RFC3339)}},
+	}, accessExpiry, refreshExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.ValidateAccessToken(pair.AccessToken); err != nil {
+		t.Errorf("legacy access token rejected after switching to keys: %v", err)
+	}
+	if _, err := j.ValidateRefreshToken(pair.RefreshToken); err != nil {
+		t.Errorf("legacy refresh token rejected after switching to keys: %v", err)
+	}
+
+	// The new key signs
+	if got := len(j.JWKS().Keys); got != 1 {
+		t.Fatalf("JWKS has %d keys, want 1", got)
+	}
+	fresh, err := j.GenerateTokenPair(1, "alice", "user", nil, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := previous.ValidateAccessToken(fresh.AccessToken); err == nil {
+		t.Error("new token was signed with the legacy secret")
+	}
+
+	// The refresh token itself is still unexpired here; only the secret has retired
+	j.SetClock(func() time.Time { return rotation.Add(refreshExpiry + time.Second) })
+	if _, err := j.ValidateRefreshToken(pair.RefreshToken); err == nil {
+		t.Error("legacy refresh token still accepted after the retention window")
+	}
+	if _, err := j.ValidateRefreshToken(fresh.RefreshToken); err != nil {
+		t.Errorf("refresh token of the new key rejected: %v", err)
+	}
+}
+
+func TestNewJWTManagerRequiresActiveFrom(t *testing.T) {
+	_, err := newJWTManager(config.JWTConfig{
+		Secret: "legacy-secret",
+		Keys: []config.JWTKeyConfig{
+			{ID: "2026-01", PrivateKey: testKeyPEM(t), ActiveFrom: "2026-01-01T00:00:00Z"},
+			{ID: "2026-07", PrivateKey: testKeyPEM(t)},
+		},
+	}, time.Hour, 24*time.Hour)
+	if err == nil {
+		t.Fatal("key without active_from accepted")
+	}
+}
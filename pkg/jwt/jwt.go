@@ -1,5 +1,8 @@
 // Package jwt provides JWT token generation and validation for authentication.
-// It supports access tokens (1h) and refresh tokens (7d) with role-based claims.
+// It supports access tokens (1h) and refresh tokens (7d) with role and team claims.
+// Every token carries a unique ID (jti) and a typed claim so one kind cannot be used as the other.
+// Tokens are signed with HS256 (a shared secret) or with RS256/EdDSA keys identified by kid;
+// the public keys of the latter are exposed as a JWKS so other services can verify tokens.
 package jwt
 
 import (
@@ -7,71 +10,175 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// TokenType distinguishes access tokens from refresh tokens.
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+	// ChallengeToken is issued by login when a second factor is still required.
+	// It grants nothing except completing the two-factor step.
+	ChallengeToken TokenType = "2fa_challenge"
+)
+
+var ErrWrongTokenType = errors.New("wrong token type")
+
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	TeamID    *int64    `json:"team_id,omitempty"`
+	SessionID string    `json:"sid"`
+	TokenType TokenType `json:"token_type"`
 	jwt.RegisteredClaims
 }
 
+// TokenPair is the result of GenerateTokenPair. RefreshTokenID and RefreshExpiresAt
+// are returned so the caller can persist the refresh token server-side.
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	RefreshTokenID   string
+	RefreshExpiresAt time.Time
+}
+
 type JWTManager struct {
-	secret             []byte
+	keys               *keySet
 	accessTokenExpiry  time.Duration
 	refreshTokenExpiry time.Duration
+	now                func() time.Time
 }
 
+// NewJWTManager returns a manager that signs with a single HS256 secret.
 func NewJWTManager(secret string, accessTokenExpiry, refreshTokenExpiry time.Duration) *JWTManager {
+	key := &Key{Algorithm: AlgHS256, signingKey: []byte(secret), verifyKey: []byte(secret)}
 	return &JWTManager{
-		secret:             []byte(secret),
+		keys:               &keySet{keys: []*Key{key}},
 		accessTokenExpiry:  accessTokenExpiry,
 		refreshTokenExpiry: refreshTokenExpiry,
+		now:                time.Now,
+	}
+}
+
+// NewJWTManagerWithKeys returns a manager that signs with the newest active key.
+// A superseded key keeps verifying for the longest token lifetime, so rotating keys
+// never invalidates tokens that were already issued.
+func NewJWTManagerWithKeys(keys []*Key, accessTokenExpiry, refreshTokenExpiry time.Duration) (*JWTManager, error) {
+	retention := accessTokenExpiry
+	if refreshTokenExpiry > retention {
+		retention = refreshTokenExpiry
+	}
+	set, err := newKeySet(keys, retention)
+	if err != nil {
+		return nil, err
 	}
+	return &JWTManager{
+		keys:               set,
+		accessTokenExpiry:  accessTokenExpiry,
+		refreshTokenExpiry: refreshTokenExpiry,
+		now:                time.Now,
+	}, nil
+}
+
+// SetClock replaces the time source used to issue and validate tokens, so tests can
+// move a fake clock past expiry or key rotation times.
+func (j *JWTManager) SetClock(now func() time.Time) {
+	j.now = now
 }
 
-func (j *JWTManager) GenerateTokenPair(userID uint, username, role string) (string, string, error) {
-	accessToken, err := j.generateToken(userID, username, role, j.accessTokenExpiry)
+// JWKS returns the public keys that currently verify tokens, plus keys scheduled to sign next.
+func (j *JWTManager) JWKS() JWKS {
+	return j.keys.published(j.now())
+}
+
+// GenerateTokenPair issues an access and a refresh token for the given session.
+func (j *JWTManager) GenerateTokenPair(userID uint, username, role string, teamID *int64, sessionID string) (*TokenPair, error) {
+	base := Claims{
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		TeamID:    teamID,
+		SessionID: sessionID,
+	}
+
+	accessToken, _, _, err := j.generateToken(base, AccessToken, j.accessTokenExpiry)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	refreshToken, err := j.generateToken(userID, username, role, j.refreshTokenExpiry)
+	refreshToken, refreshID, refreshExpiresAt, err := j.generateToken(base, RefreshToken, j.refreshTokenExpiry)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return accessToken, refreshToken, nil
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		RefreshTokenID:   refreshID,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
 }
 
-func (j *JWTManager) generateToken(userID uint, username, role string, expiry time.Duration) (string, error) {
-	claims := Claims{
+// GenerateChallengeToken issues a short-lived token proving the password step of login succeeded.
+func (j *JWTManager) GenerateChallengeToken(userID uint, username, role string, teamID *int64, expiry time.Duration) (string, time.Time, error) {
+	token, _, expiresAt, err := j.generateToken(Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+		TeamID:   teamID,
+	}, ChallengeToken, expiry)
+	return token, expiresAt, err
+}
+
+func (j *JWTManager) generateToken(claims Claims, tokenType TokenType, expiry time.Duration) (string, string, time.Time, error) {
+	now := j.now()
+	expiresAt := now.Add(expiry)
+	tokenID := uuid.New().String()
+
+	key, err := j.keys.signing(now)
+	if err != nil {
+		return "", "", time.Time{}, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(j.secret)
+	claims.TokenType = tokenType
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        tokenID,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	tokenString, err := token.SignedString(key.signingKey)
 	if err != nil {
-		return "", err
+		return "", "", time.Time{}, err
 	}
 
-	return tokenString, nil
+	return tokenString, tokenID, expiresAt, nil
 }
 
+// ValidateToken verifies the signature and expiry of any token issued by this manager.
+// Prefer ValidateAccessToken / ValidateRefreshToken, which also check the token type.
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
+	now := j.now()
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// Tokens without kid were signed by the legacy HS256 secret
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.keys.verifying(kid, now)
+		if !ok {
+			return nil, errors.New("unknown or retired signing key")
+		}
+		if token.Method.Alg() != key.Algorithm {
 			return nil, errors.New("invalid signing method")
 		}
-		return j.secret, nil
-	})
+		return key.verifyKey, nil
+	}, jwt.WithTimeFunc(j.now))
 
 	if err != nil {
 		return nil, err
@@ -84,3 +191,29 @@ func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ValidateAccessToken validates a token and requires it to be an access token.
+func (j *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
+	return j.validateTyped(tokenString, AccessToken)
+}
+
+// ValidateRefreshToken validates a token and requires it to be a refresh token.
+func (j *JWTManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return j.validateTyped(tokenString, RefreshToken)
+}
+
+// ValidateChallengeToken validates a token and requires it to be a two-factor challenge token.
+func (j *JWTManager) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	return j.validateTyped(tokenString, ChallengeToken)
+}
+
+func (j *JWTManager) validateTyped(tokenString string, tokenType TokenType) (*Claims, error) {
+	claims, err := j.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
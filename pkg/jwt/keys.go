@@ -0,0 +1,208 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is one signing key. Keys are ordered by ActiveFrom: the newest active key signs,
+// older keys keep verifying until every token they could have signed has expired.
+type Key struct {
+	ID         string    // kid header; empty for the legacy HS256 secret
+	Algorithm  string    // HS256, RS256 or EdDSA
+	ActiveFrom time.Time // zero means active immediately
+
+	signingKey interface{}
+	verifyKey  interface{}
+}
+
+// NewHMACKey returns an HS256 key. HMAC keys are never published in the JWKS.
+func NewHMACKey(id string, secret []byte, activeFrom time.Time) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("hmac secret is empty")
+	}
+	return &Key{ID: id, Algorithm: AlgHS256, ActiveFrom: activeFrom, signingKey: secret, verifyKey: secret}, nil
+}
+
+// ParsePrivateKeyPEM parses a PKCS#1 RSA or PKCS#8 RSA/Ed25519 private key.
+// The algorithm follows from the key type.
+func ParsePrivateKeyPEM(id string, data []byte, activeFrom time.Time) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM block found", id)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("key %q: RSA keys must be at least 2048 bits", id)
+		}
+		return &Key{ID: id, Algorithm: AlgRS256, ActiveFrom: activeFrom, signingKey: k, verifyKey: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Algorithm: AlgEdDSA, ActiveFrom: activeFrom, signingKey: k, verifyKey: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", id, parsed)
+	}
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *Key) jwk() (JWK, bool) {
+	b64 := base64.RawURLEncoding
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: AlgRS256,
+			N:   b64.EncodeToString(pub.N.Bytes()),
+			E:   b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: AlgEdDSA,
+			Crv: "Ed25519",
+			X:   b64.EncodeToString(pub),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
+
+// keySet holds keys sorted by ActiveFrom and answers which key signs and which verify at a time.
+type keySet struct {
+	keys      []*Key
+	retention time.Duration // how long a superseded key keeps verifying
+}
+
+func newKeySet(keys []*Key, retention time.Duration) (*keySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		seen[k.ID] = true
+	}
+
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom) })
+	return &keySet{keys: sorted, retention: retention}, nil
+}
+
+// signing returns the newest key that is active at now.
+func (s *keySet) signing(now time.Time) (*Key, error) {
+	var current *Key
+	for _, k := range s.keys {
+		if !k.ActiveFrom.After(now) {
+			current = k
+		}
+	}
+	if current == nil {
+		return nil, errors.New("no signing key is active yet")
+	}
+	return current, nil
+}
+
+// retiresAt returns when key i stops verifying; zero means never (it is the latest key).
+func (s *keySet) retiresAt(i int) time.Time {
+	if i+1 >= len(s.keys) {
+		return time.Time{}
+	}
+	return s.keys[i+1].ActiveFrom.Add(s.retention)
+}
+
+// verifying returns the key with id if it may still verify tokens at now.
+func (s *keySet) verifying(id string, now time.Time) (*Key, bool) {
+	for i, k := range s.keys {
+		if k.ID != id {
+			continue
+		}
+		if k.ActiveFrom.After(now) {
+			return nil, false
+		}
+		if retire := s.retiresAt(i); !retire.IsZero() && now.After(retire) {
+			return nil, false
+		}
+		return k, true
+	}
+	return nil, false
+}
+
+// published returns the public keys verifiers should know at now: keys still verifying,
+// plus scheduled keys so caches pick them up before they start signing.
+func (s *keySet) published(now time.Time) JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for i, k := range s.keys {
+		if retire := s.retiresAt(i); !retire.IsZero() && now.After(retire) {
+			continue
+		}
+		if jwk, ok := k.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+func newTestEd25519Key(t *testing.T, id string, activeFrom time.Time) *Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Algorithm: AlgEdDSA, ActiveFrom: activeFrom, signingKey: priv, verifyKey: priv.Public()}
+}
+
+// TestLegacyKeyVerifiesDuringRetention rotates from the HS256 secret to an EdDSA key and
+// checks that tokens signed with the secret before the rotation stay valid until they
+// expire, and that the secret stops verifying after the retention window.
+func TestLegacyKeyVerifiesDuringRetention(t *testing.T) {
+	rotation := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	accessExpiry, refreshExpiry := time.Hour, 24*time.Hour
+
+	legacy, err := NewHMACKey("", []byte("legacy-secret"), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := NewJWTManagerWithKeys([]*Key{legacy, newTestEd25519Key(t, "2026-07", rotation)}, accessExpiry, refreshExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := rotation.Add(-time.Minute)
+	j.SetClock(func() time.Time { return now })
+	before, err := j.GenerateTokenPair(1, "alice", "user", nil, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = rotation.Add(time.Minute)
+	after, err := j.GenerateTokenPair(1, "alice", "user", nil, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.ValidateAccessToken(after.AccessToken); err != nil {
+		t.Fatalf("token from the new key: %v", err)
+	}
+
+	if _, err := j.ValidateAccessToken(before.AccessToken); err != nil {
+		t.Errorf("legacy access token rejected right after rotation: %v", err)
+	}
+
+	now = rotation.Add(refreshExpiry - 2*time.Minute)
+	if _, err := j.ValidateRefreshToken(before.RefreshToken); err != nil {
+		t.Errorf("legacy refresh token rejected within the retention window: %v", err)
+	}
+
+	// The key is retired once every token it signed has expired
+	if _, ok := j.keys.verifying("", rotation.Add(refreshExpiry+time.Second)); ok {
+		t.Error("legacy key still verifies after the retention window")
+	}
+	if _, ok := j.keys.verifying("", rotation.Add(refreshExpiry-time.Second)); !ok {
+		t.Error("legacy key retired before the retention window ended")
+	}
+}
+
+func TestSigningKeyFollowsActiveFrom(t *testing.T) {
+	rotation := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	current := newTestEd25519Key(t, "2026-01", rotation.Add(-180*24*time.Hour))
+	next := newTestEd25519Key(t, "2026-07", rotation)
+	set, err := newKeySet([]*Key{next, current}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		at   time.Time
+		want string
+	}{
+		{rotation.Add(-time.Second), "2026-01"},
+		{rotation, "2026-07"},
+	} {
+		key, err := set.signing(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.ID != tt.want {
+			t.Errorf("signing key at %s = %q, want %q", tt.at, key.ID, tt.want)
+		}
+	}
+
+	// The scheduled key is published before it signs
+	if got := len(set.published(rotation.Add(-time.Hour)).Keys); got != 2 {
+		t.Errorf("published %d keys before rotation, want 2", got)
+	}
+}
@@ -5,6 +5,7 @@ package s3
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -107,3 +108,13 @@ func (r *R2Client) DeleteObject(ctx context.Context, fileKey string) error {
 func (r *R2Client) GetPublicURL(fileKey string) string {
 	return fmt.Sprintf("%s/%s", r.publicURL, fileKey)
 }
+
+// KeyFromURL returns the file key of a URL produced by GetPublicURL, and false for URLs
+// that do not point into this bucket.
+func (r *R2Client) KeyFromURL(fileURL string) (string, bool) {
+	key := strings.TrimPrefix(fileURL, r.publicURL+"/")
+	if key == fileURL || key == "" {
+		return "", false
+	}
+	return key, true
+}